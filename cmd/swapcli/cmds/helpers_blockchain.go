@@ -1,287 +1,179 @@
 package cmds
 
 import (
-	"bytes"
 	"encoding/hex"
 	"errors"
-	"sync"
-	"time"
+	"fmt"
+	"io"
 
+	"github.com/spf13/pflag"
 	"github.com/transmutate-io/atomicswap/cryptos"
-	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
 	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
-	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
+	"github.com/transmutate-io/atomicswap/internal/uiutil"
+	"github.com/transmutate-io/atomicswap/params"
 	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/atomicswap/tx"
 	"github.com/transmutate-io/cryptocore"
-	"github.com/transmutate-io/cryptocore/block"
-	"github.com/transmutate-io/cryptocore/tx"
 	"github.com/transmutate-io/cryptocore/types"
 )
 
-type newClientFunc func(addr, user, pass string, tlsConf *cryptocore.TLSConfig) (cryptocore.Client, error)
+func newClient(c *cryptos.Crypto, cfg *chainutil.ClientConfig) (cryptocore.Client, error) {
+	cl, err := cfg.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+	if err = chainutil.CheckNetwork(cl, c, _network.MustNetwork(c.Name)); err != nil {
+		chainutil.CloseClient(cl)
+		return nil, err
+	}
+	return cl, nil
+}
 
-var newClientFuncs = map[string]newClientFunc{
-	cryptos.Bitcoin.Name:     cryptocore.NewClientBTC,
-	cryptos.Litecoin.Name:    cryptocore.NewClientLTC,
-	cryptos.Dogecoin.Name:    cryptocore.NewClientDOGE,
-	cryptos.Decred.Name:      cryptocore.NewClientDCR,
-	cryptos.BitcoinCash.Name: cryptocore.NewClientBCH,
+func mustNewClient(c *cryptos.Crypto, cfg *chainutil.ClientConfig) cryptocore.Client {
+	r, err := newClient(c, cfg)
+	if err == chainutil.ErrClientUnavailable {
+		cmdutil.ErrorExit(exitcodes.UnknownCrypto, c.Name)
+	} else if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	return r
 }
 
-func newClient(
-	c *cryptos.Crypto,
-	address string,
-	username string,
-	password string,
-	tlsConf *cryptocore.TLSConfig,
-) (cryptocore.Client, error) {
-	nc, ok := newClientFuncs[c.Name]
-	if !ok {
-		return nil, errors.New("client unavailable")
+// mustClientConfig returns the client configuration from the flags
+func mustClientConfig(fs *pflag.FlagSet) *chainutil.ClientConfig {
+	return &chainutil.ClientConfig{
+		Address:  flagutil.MustRPCAddress(fs),
+		Username: flagutil.MustRPCUsername(fs),
+		Password: flagutil.MustRPCPassword(fs),
+		TLS:      flagutil.MustRPCTLSConfig(fs),
+		Backend:  flagutil.MustBackend(fs),
 	}
-	return nc(address, username, password, tlsConf)
 }
 
-func mustNewClient(
-	c *cryptos.Crypto,
-	address string,
-	username string,
-	password string,
-	tlsConf *cryptocore.TLSConfig,
-) cryptocore.Client {
-	r, err := newClient(c, address, username, password, tlsConf)
+// mustNewScanner returns a new chain scanner with the client and the chain
+// query strategy from the flags
+func mustNewScanner(fs *pflag.FlagSet, c *cryptos.Crypto) *chainutil.Scanner {
+	cfg := mustClientConfig(fs)
+	cfg.ChainQuery = flagutil.MustChainQuery(fs)
+	r, err := cfg.NewScanner(c, mustNewClient(c, cfg))
 	if err != nil {
-		cmdutil.ErrorExit(exitcodes.UnknownCrypto, c.Name)
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
 	return r
 }
 
-type blockWatchData struct {
-	Bottom uint64 `yaml:"bottom"`
-	Top    uint64 `yaml:"top"`
-}
+type (
+	blockWatchData = chainutil.BlockWatchData
+	watchData      = chainutil.WatchData
+)
 
-type watchData struct {
-	Own    *blockWatchData `yaml:"own"`
-	Trader *blockWatchData `yaml:"trader"`
+var errFeeNotAccepted = errors.New("fee not accepted")
+
+// spendOptions holds the options to redeem or recover funds
+type spendOptions struct {
+	client      *chainutil.ClientConfig
+	fee         *chainutil.Fee
+	maxFeeShare float64
+	acceptFee   bool
+	verboseRaw  bool
+	// the broadcasts are notified with the trade name
+	notifier  *notifyutil.Notifier
+	tradeName string
 }
 
-func getBlockAtHeight(cl cryptocore.Client, height uint64) (block.Block, error) {
-	bh, err := cl.BlockHash(height)
-	if err != nil {
-		return nil, err
+// mustSpendOptions returns the spend options from the flags. If the fee is not
+// set it's estimated for the confirmation target
+func mustSpendOptions(fs *pflag.FlagSet) *spendOptions {
+	r := &spendOptions{
+		client:      mustClientConfig(fs),
+		maxFeeShare: flagutil.MustMaxFeeShare(fs),
+		acceptFee:   flagutil.MustAcceptFee(fs),
+		verboseRaw:  flagutil.MustVerboseLevel(fs, 1) > 0,
+		notifier:    flagutil.MustNotifier(fs),
 	}
-	return cl.Block(bh)
+	if fs.Changed("fee") {
+		r.fee = &chainutil.Fee{Value: _fee.Value, Fixed: _fee.Fixed}
+	} else {
+		r.fee = &chainutil.Fee{Target: flagutil.MustConfTarget(fs)}
+	}
+	return r
 }
 
-var errClosed = errors.New("closed")
+type spendTxFunc = func(tr trade.Trade, chain params.Chain, destAddr string, fee uint64, fixedFee bool) (tx.Tx, error)
 
-func blockTransactions(cl cryptocore.Client, txs []types.Bytes, closec chan struct{}) ([]tx.Tx, error) {
-	r := make([]tx.Tx, 0, len(txs))
-	for _, i := range txs {
-		select {
-		case <-closec:
-			return nil, errClosed
-		default:
-		}
-		tx, err := cl.Transaction(i)
-		if err != nil {
-			return nil, err
-		}
-		r = append(r, tx)
+// newSpendTx returns the transaction spending the funds, estimating the fee if needed
+func newSpendTx(tr trade.Trade, c *cryptos.Crypto, destAddr string, opts *spendOptions, f spendTxFunc) (tx.Tx, error) {
+	fee, fixedFee, err := opts.fee.Resolve(c, opts.client)
+	if err != nil {
+		return nil, err
 	}
-	return r, nil
+	return f(tr, _network.MustNetwork(c.Name), destAddr, fee, fixedFee)
 }
 
-type blockData struct {
-	height uint64
-	txs    []tx.Tx
-}
-
-const (
-	initTimeout = time.Second
-	maxTimeout  = time.Minute
-)
-
-func iterateBlocks(cl cryptocore.Client, wd *blockWatchData, stopBottom uint64) (chan *blockData, chan error, func()) {
-	closec := make(chan struct{}, 0)
-	bdc := make(chan *blockData, 0)
-	errc := make(chan error, 1)
-	wg := &sync.WaitGroup{}
-	wg.Add(2)
-	closeIter := func() {
-		close(closec)
-		wg.Wait()
-		close(errc)
-		close(bdc)
+// checkFee shows the fee of the transaction and asks for confirmation when it
+// exceeds the maximum share of the amount
+func checkFee(out io.Writer, c *cryptos.Crypto, t tx.Tx, amounts []uint64, opts *spendOptions) error {
+	txUTXO, ok := t.TxUTXO()
+	if !ok {
+		return tx.ErrNotUTXO
 	}
-	blockCount, err := cl.BlockCount()
+	fee, err := tx.Fee(txUTXO, amounts)
 	if err != nil {
-		errc <- err
-		return bdc, errc, closeIter
+		return err
+	}
+	var total uint64
+	for _, i := range amounts {
+		total += i
+	}
+	size := t.SerializedSize()
+	fmt.Fprintf(out, "fee: %s %s (%d per byte, %d bytes)\n",
+		types.NewAmount(fee, uint64(c.Decimals)),
+		c.Short,
+		fee/size,
+		size,
+	)
+	if opts.acceptFee || float64(fee) <= opts.maxFeeShare*float64(total) {
+		return nil
+	}
+	pr := fmt.Sprintf("the fee is %.2f%% of the amount, send anyway? ", float64(fee)*100/float64(total))
+	if ok, _ := uiutil.InputYesNo(pr, false); !ok {
+		return errFeeNotAccepted
 	}
-	go func() {
-		defer wg.Done()
-		var (
-			height        uint64
-			nextBlockHash []byte
-		)
-		if wd.Top == 0 {
-			height = blockCount
-		} else {
-			height = wd.Top + 1
-		}
-		timeout := initTimeout
-		for {
-			var (
-				block block.Block
-				err   error
-			)
-			if nextBlockHash != nil {
-				block, err = cl.Block(nextBlockHash)
-			} else {
-				block, err = getBlockAtHeight(cl, height)
-			}
-			if err != nil {
-				if err == cryptocore.ErrNoBlock {
-					timeout *= 2
-					if timeout > maxTimeout {
-						timeout = maxTimeout
-					}
-					timer := time.NewTimer(timeout)
-					select {
-					case <-closec:
-						timer.Stop()
-						return
-					case <-timer.C:
-						timer.Stop()
-						continue
-					}
-				}
-				errc <- err
-				return
-			}
-			timeout = initTimeout
-			blockTxs, err := blockTransactions(cl, block.Transactions(), closec)
-			if err != nil {
-				if err == errClosed {
-					return
-				}
-				errc <- err
-				return
-			}
-			select {
-			case <-closec:
-				return
-			case bdc <- &blockData{
-				height: uint64(block.Height()),
-				txs:    blockTxs,
-			}:
-				height++
-				nextBlockHash = block.NextBlockHash()
-			}
-		}
-	}()
-	go func() {
-		defer wg.Done()
-		var (
-			height        uint64
-			prevBlockHash []byte
-		)
-		if wd.Bottom == 0 {
-			height = blockCount - 1
-		} else {
-			height = wd.Bottom - 1
-		}
-		for {
-			if height < stopBottom {
-				return
-			}
-			var (
-				block block.Block
-				err   error
-			)
-			if prevBlockHash == nil {
-				block, err = getBlockAtHeight(cl, height)
-			} else {
-				block, err = cl.Block(prevBlockHash)
-			}
-			if err != nil {
-				errc <- err
-				return
-			}
-			blockTxs, err := blockTransactions(cl, block.Transactions(), closec)
-			if err != nil {
-				if err == errClosed {
-					return
-				}
-				errc <- err
-				return
-			}
-			select {
-			case <-closec:
-				return
-			case bdc <- &blockData{
-				height: uint64(block.Height()),
-				txs:    blockTxs,
-			}:
-				prevBlockHash = block.PreviousBlockHash()
-				height = uint64(block.Height()) - 1
-			}
-			if height+1 == stopBottom {
-				return
-			}
-		}
-	}()
-	return bdc, errc, closeIter
+	return nil
 }
 
-func extractToken(c *cryptos.Crypto, t tx.Tx, lock trade.Lock) (types.Bytes, error) {
-	txUtxo, ok := t.UTXO()
-	if !ok {
-		panic("not implemented")
+// spendFunds sends a transaction spending the funds and returns the transaction id
+func spendFunds(
+	tr trade.Trade,
+	out io.Writer,
+	c *cryptos.Crypto,
+	fd trade.FundsData,
+	destAddr string,
+	opts *spendOptions,
+	f spendTxFunc,
+) (types.Bytes, error) {
+	cl, err := newClient(c, opts.client)
+	if err != nil {
+		return nil, err
+	}
+	defer chainutil.CloseClient(cl)
+	t, err := newSpendTx(tr, c, destAddr, opts, f)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkFee(out, c, t, chainutil.FundsAmounts(fd), opts); err != nil {
+		return nil, err
 	}
-	ld, err := lock.LockData()
+	b, err := t.Serialize()
 	if err != nil {
 		return nil, err
 	}
-	for _, j := range txUtxo.Inputs() {
-		if j.Coinbase() != nil {
-			continue
-		}
-		dis, err := script.DisassembleStrings(c, j.UnlockScript().Bytes())
-		if err != nil {
-			continue
-		}
-		if len(dis) != 5 {
-			continue
-		}
-		if dis[3] != "0" {
-			continue
-		}
-		h, err := hash.New(c)
-		if err != nil {
-			return nil, err
-		}
-		b, err := hex.DecodeString(dis[1])
-		if err != nil {
-			continue
-		}
-		hb := h.Hash160(b)
-		if !bytes.Equal(ld.RedeemKeyData, hb) {
-			continue
-		}
-		if b, err = hex.DecodeString(dis[4]); err != nil {
-			continue
-		}
-		if !bytes.Equal(lock.Bytes(), b) {
-			continue
-		}
-		if b, err = hex.DecodeString(dis[2]); err != nil {
-			continue
-		}
-		return b, nil
+	if opts.verboseRaw {
+		fmt.Fprintf(out, "raw transaction: %s\n", hex.EncodeToString(b))
 	}
-	return nil, nil
+	return cl.SendRawTransaction(b)
 }
@@ -0,0 +1,107 @@
+package cmds
+
+import (
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/transmutate-io/atomicswap/envelope"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
+	"github.com/transmutate-io/atomicswap/internal/uiutil"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/trade"
+	"gopkg.in/yaml.v2"
+)
+
+func openIdentityKey(cmd *cobra.Command) (key.Private, error) {
+	return storeutil.New(dataDir(cmd)).OpenIdentityKey()
+}
+
+func mustOpenIdentityKey(cmd *cobra.Command) key.Private {
+	r, err := openIdentityKey(cmd)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	return r
+}
+
+func sealMessage(out io.Writer, k key.Private, typ string, sessionID []byte, expiry time.Duration, payload interface{}) error {
+	env, err := envelope.New(typ, sessionID, time.Now().Add(expiry), payload)
+	if err != nil {
+		return err
+	}
+	if err = env.Sign(k); err != nil {
+		return err
+	}
+	return yaml.NewEncoder(out).Encode(env)
+}
+
+func envelopeOptions(fs *pflag.FlagSet, sessionID []byte) *envelope.Options {
+	return &envelope.Options{
+		AllowUnsigned: flagutil.MustAllowUnsigned(fs),
+		AllowExpired:  flagutil.MustAllowExpired(fs),
+		Signer:        flagutil.MustSigner(fs),
+		SessionID:     sessionID,
+	}
+}
+
+// tradeEnvelopeOptions returns the options to open a message of the trader.
+// The message must be signed by the trader identity key when it's known
+func tradeEnvelopeOptions(fs *pflag.FlagSet, tr trade.Trade) (*envelope.Options, error) {
+	r := envelopeOptions(fs, tr.SessionID())
+	signer, err := envelope.PeerSigner(r.Signer, tr.PeerSigner())
+	if err != nil {
+		return nil, err
+	}
+	r.Signer = signer
+	return r, nil
+}
+
+// bindPeerSigner binds the trade to the signer of the first message of the
+// trader
+func bindPeerSigner(tr trade.Trade, env *envelope.Envelope) {
+	if len(tr.PeerSigner()) == 0 && env.IsSigned() {
+		tr.SetPeerSigner(env.Signer)
+	}
+}
+
+func openMessage(in io.Reader, typ string, opts *envelope.Options) (*envelope.Envelope, error) {
+	b, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Open(b, typ, opts)
+}
+
+const defaultMessageExpiry = time.Hour
+
+// openMessageInteractive opens a message asking to override the unsigned and
+// expired checks
+func openMessageInteractive(b []byte, typ string, sessionID []byte, signer []byte) (*envelope.Envelope, error) {
+	opts := &envelope.Options{SessionID: sessionID, Signer: signer}
+	for {
+		r, err := envelope.Open(b, typ, opts)
+		if err == nil {
+			return r, nil
+		}
+		var override *bool
+		switch err {
+		case envelope.ErrUnsigned:
+			override = &opts.AllowUnsigned
+		case envelope.ErrExpired:
+			override = &opts.AllowExpired
+		default:
+			return nil, err
+		}
+		accept, ok := uiutil.InputYesNo(err.Error()+", accept anyway", false)
+		if !ok || !accept {
+			return nil, err
+		}
+		*override = true
+	}
+}
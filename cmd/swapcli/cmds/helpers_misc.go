@@ -1,20 +1,20 @@
 package cmds
 
 import (
-	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/envelope"
 	"github.com/transmutate-io/atomicswap/internal/cmdutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
+	"github.com/transmutate-io/atomicswap/signer"
 	"github.com/transmutate-io/atomicswap/trade"
-	"gopkg.in/yaml.v2"
 )
 
 func dataDir(cmd *cobra.Command) string {
@@ -29,12 +29,7 @@ func tradePath(cmd *cobra.Command, name string) string {
 	return filepath.Join(tradesDir(cmd), name)
 }
 
-func createFile(p string) (*os.File, error) {
-	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
-		return nil, err
-	}
-	return os.Create(p)
-}
+func createFile(p string) (*os.File, error) { return storeutil.CreateFile(p) }
 
 func mustCreateFile(p string) *os.File {
 	r, err := createFile(p)
@@ -68,21 +63,7 @@ func mustParseDuration(d string) time.Duration {
 }
 
 func eachTrade(td string, f func(string, trade.Trade) error) error {
-	return filepath.Walk(td, func(path string, info os.FileInfo, err error) error {
-		if info == nil || info.IsDir() {
-			return nil
-		}
-		tf, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer tf.Close()
-		tr := &trade.OnChainTrade{}
-		if err = yaml.NewDecoder(tf).Decode(tr); err != nil {
-			return err
-		}
-		return f(filepath.ToSlash(cmdutil.TrimPath(path, td)), tr)
-	})
+	return storeutil.EachTrade(td, f)
 }
 
 func eachProposal(td string, f func(string, trade.Trade) error) error {
@@ -91,18 +72,7 @@ func eachProposal(td string, f func(string, trade.Trade) error) error {
 	})
 }
 
-func openTradeFile(tp string) (trade.Trade, error) {
-	f, err := os.Open(tp)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	r := &trade.OnChainTrade{}
-	if err = yaml.NewDecoder(f).Decode(r); err != nil {
-		return nil, err
-	}
-	return r, nil
-}
+func openTradeFile(tp string) (trade.Trade, error) { return storeutil.OpenTradeFile(tp) }
 
 func mustOpenTrade(cmd *cobra.Command, name string) trade.Trade {
 	r, err := openTradeFile(tradePath(cmd, name))
@@ -112,30 +82,29 @@ func mustOpenTrade(cmd *cobra.Command, name string) trade.Trade {
 	return r
 }
 
-func saveTrade(tp string, tr trade.Trade) error {
-	f, err := createFile(tp)
-	if err != nil {
-		return err
+// mustSetKeySigner sets the signer for the trade keys if one is provided
+func mustSetKeySigner(fs *pflag.FlagSet, tr trade.Trade) {
+	spec := flagutil.MustKeySigner(fs)
+	if spec == "" {
+		return
 	}
-	defer f.Close()
-	if err = yaml.NewEncoder(f).Encode(tr); err != nil {
-		return err
+	s, err := signer.Open(spec)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
-	return nil
+	tr.SetSigner(s)
 }
 
+func saveTrade(tp string, tr trade.Trade) error { return storeutil.SaveTradeFile(tp, tr) }
+
 func mustSaveTrade(cmd *cobra.Command, name string, tr trade.Trade) {
 	if err := saveTrade(tradePath(cmd, name), tr); err != nil {
 		cmdutil.ErrorExit(exitcodes.CantSaveTrade, err)
 	}
 }
 
-func openLockSet(r io.Reader, ownCrypto, traderCrypto *cryptos.Crypto) *trade.Locks {
-	b, err := ioutil.ReadAll(r)
-	if err != nil {
-		cmdutil.ErrorExit(exitcodes.CantOpenLockSet, err)
-	}
-	ls, err := trade.UnamrshalLocks(ownCrypto, traderCrypto, b)
+func openLockSet(env *envelope.Envelope, ownCrypto, traderCrypto *cryptos.Crypto) *trade.Locks {
+	ls, err := trade.UnamrshalLocks(ownCrypto, traderCrypto, []byte(env.Payload))
 	if err != nil {
 		cmdutil.ErrorExit(exitcodes.CantOpenLockSet, err)
 	}
@@ -150,24 +119,7 @@ func watchDataPath(cmd *cobra.Command, name string) string {
 	return filepath.Join(watchDataDir(cmd), name)
 }
 
-func openWatchData(wdPath string) (*watchData, error) {
-	r := &watchData{
-		Own:    &blockWatchData{Top: 0, Bottom: 0},
-		Trader: &blockWatchData{Top: 0, Bottom: 0},
-	}
-	f, err := os.Open(wdPath)
-	if err != nil {
-		if e, ok := err.(*os.PathError); ok && e.Err == syscall.ENOENT {
-			return r, nil
-		}
-		return nil, err
-	}
-	defer f.Close()
-	if err = yaml.NewDecoder(f).Decode(r); err != nil {
-		return nil, err
-	}
-	return r, nil
-}
+func openWatchData(wdPath string) (*watchData, error) { return storeutil.OpenWatchDataFile(wdPath) }
 
 func mustOpenWatchData(cmd *cobra.Command, name string) *watchData {
 	r, err := openWatchData(watchDataPath(cmd, name))
@@ -178,12 +130,7 @@ func mustOpenWatchData(cmd *cobra.Command, name string) *watchData {
 }
 
 func saveWatchData(wdPath string, wd *watchData) error {
-	f, err := createFile(wdPath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	return yaml.NewEncoder(f).Encode(wd)
+	return storeutil.SaveWatchDataFile(wdPath, wd)
 }
 
 func mustSaveWatchData(cmd *cobra.Command, name string, wd *watchData) {
@@ -194,7 +141,7 @@ func mustSaveWatchData(cmd *cobra.Command, name string, wd *watchData) {
 
 func consoleConfigDir(cmd *cobra.Command) string { return filepath.Join(dataDir(cmd), "config") }
 
-const DEFAULT_CONSOLE_CONFIG_NAME = "console_defaults.yaml"
+const DEFAULT_CONSOLE_CONFIG_NAME = storeutil.DEFAULT_CONFIG_NAME
 
 func consoleConfigPath(cmd *cobra.Command, name string) string {
 	if name == "" {
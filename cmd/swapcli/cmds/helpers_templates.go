@@ -19,7 +19,7 @@ var (
 	}
 
 	watchableTradesTemplates = []string{
-		`{{ .name }}: {{ $s := .trade.Stager.Stage.String }}{{ if eq $s "lock-funds" -}}
+		`{{ .name }}: {{ $s := .stage.String }}{{ if eq $s "lock-funds" -}}
 	wait for own funds deposit
 	{{- else if or (eq $s "send-proposal-response") (eq $s "wait-locked-funds") -}}
 	wait for trader funds deposit
@@ -47,31 +47,77 @@ var (
 `,
 	}
 
+	txDecodeTemplates = []string{
+		`txid: {{ .tx.TxID.Hex }}
+{{ range $i, $in := .tx.Inputs -}}
+input {{ $i }}: {{ $in.TxID.Hex }}:{{ $in.N }}
+{{ end -}}
+{{ range $i, $out := .tx.Outputs -}}
+output {{ $i }}: {{ amount $out.Value }} {{ $.crypto.Short }}
+{{ end -}}
+{{ if .fee }}fee: {{ amount .fee.Value }} {{ .crypto.Short }}
+{{ end -}}
+`,
+		`crypto: {{ .crypto.Name }}
+txid: {{ .tx.TxID.Hex }}
+wtxid: {{ .tx.WTxID.Hex }}
+size: {{ .size }} bytes
+locktime: {{ .tx.LockTimeUInt32 }}
+inputs:
+{{- range $i, $in := .tx.Inputs }}
+  {{ $i }}: {{ $in.TxID.Hex }}:{{ $in.N }}
+    sequence: {{ printf "%#08x" $in.Sequence }}
+    signature script: {{ disasm $in.SignatureScript }}
+    {{- range $j, $w := $in.Witness }}
+    witness {{ $j }}: {{ $w.Hex }}
+    {{- end }}
+{{- end }}
+outputs:
+{{- range $i, $out := .tx.Outputs }}
+  {{ $i }}: {{ amount $out.Value }} {{ $.crypto.Short }}
+    script: {{ disasm $out.Script }}
+{{- end }}
+{{ if .fee }}fee: {{ amount .fee.Value }} {{ .crypto.Short }} ({{ .fee.Rate }} per byte)
+{{ end -}}
+`,
+	}
+
 	tradeListTemplates = []string{
 		"{{ .name }}\n",
 		"{{ .name }} - {{ .trade.OwnInfo.Amount }} {{ .trade.OwnInfo.Crypto.Short }} for {{ .trade.TraderInfo.Amount }} {{ .trade.TraderInfo.Crypto.Short }}\n",
 		"{{ .name }} - {{ .trade.OwnInfo.Amount }} {{ .trade.OwnInfo.Crypto.Short }} (locked for {{ .trade.Duration.String }}) for {{ .trade.TraderInfo.Amount }} {{ .trade.TraderInfo.Crypto.Short }}\n",
 	}
 
+	offerListTemplates = []string{
+		"{{ .name }}\n",
+		"{{ .name }} - {{ .offer.Own.Short }} for {{ .offer.Trader.Short }} at {{ .offer.Price }}\n",
+		"{{ .name }} - {{ .offer.Own.Short }} for {{ .offer.Trader.Short }} at {{ .offer.Price }} ({{ .offer.MinAmount }} to {{ .offer.MaxAmount }} {{ .offer.Own.Short }}, locked for {{ .offer.LockDuration.String }})\n",
+	}
+
+	inventoryListTemplates = []string{
+		"{{ .crypto.Short }}: {{ .available }}\n",
+		"{{ .crypto.Short }}: {{ .available }} available of {{ .inventory }}\n",
+	}
+
 	lockSetInfoTemplates = []string{
 		`hash: {{ if ne .buyer.lockData.TokenHash.Hex .seller.lockData.TokenHash.Hex }}mis{{ end }}match
 buyer:
-  recovery key data: {{ if ne .buyer.lockData.RecoveryKeyData.Hex .trade.RecoveryKey.Public.KeyData.Hex }}mis{{ end }}match
+  recovery key data: {{ if ne .buyer.lockData.RecoveryKeyData.Hex .trade.RecoveryPublicKey.KeyData.Hex }}mis{{ end }}match
   time lock expiry: {{ .buyer.lockData.LockTime.UTC }} (in {{ .buyer.lockData.LockTime.UTC.Sub now.UTC  }})
   time lock expiry: {{ .buyer.lockData.LockTime.UTC }} (in {{ .buyer.lockData.LockTime.UTC.Sub now.UTC  }})
 seller:
-  redeem key data: {{ if ne .seller.lockData.RedeemKeyData.Hex .trade.RedeemKey.Public.KeyData.Hex }}mis{{ end }}match
+  redeem key data: {{ if ne .seller.lockData.RedeemKeyData.Hex .trade.RedeemPublicKey.KeyData.Hex }}mis{{ end }}match
   time lock expiry: {{ .seller.lockData.LockTime.UTC }} (in {{ .seller.lockData.LockTime.UTC.Sub now.UTC  }}, {{ .buyer.lockData.LockTime.UTC.Sub .seller.lockData.LockTime.UTC }} before buyer)
 `,
 		`hash: {{ if ne .buyer.lockData.TokenHash.Hex .seller.lockData.TokenHash.Hex }}mis{{ end }}match
 buyer:
   deposit address: {{ .buyer.depositAddr }}
   redeem key data: {{ .buyer.lockData.RedeemKeyData.Hex }}
-  recovery key data: {{ if ne .buyer.lockData.RecoveryKeyData.Hex .trade.RecoveryKey.Public.KeyData.Hex }}mis{{ end }}match
+  recovery key data: {{ if ne .buyer.lockData.RecoveryKeyData.Hex .trade.RecoveryPublicKey.KeyData.Hex }}mis{{ end }}match
   time lock expiry: {{ .buyer.lockData.LockTime.UTC }} (in {{ .buyer.lockData.LockTime.UTC.Sub now.UTC  }})
 seller:
   deposit address: {{ .seller.depositAddr }}
-  redeem key data: {{ if ne .seller.lockData.RedeemKeyData.Hex .trade.RedeemKey.Public.KeyData.Hex }}mis{{ end }}match
+  redeem key data: {{ if ne .seller.lockData.RedeemKeyData.Hex .trade.RedeemPublicKey.KeyData.Hex }}mis{{ end }}match
   recovery key data: {{ .seller.lockData.RecoveryKeyData.Hex }}
   time lock expiry: {{ .seller.lockData.LockTime.UTC }} (in {{ .seller.lockData.LockTime.UTC.Sub now.UTC  }}, {{ .buyer.lockData.LockTime.UTC.Sub .seller.lockData.LockTime.UTC }} before buyer)
 `,
@@ -79,11 +125,11 @@ seller:
 buyer:
   deposit address: {{ .buyer.depositAddr}} ({{ .buyer.chain }})
   redeem key data: {{ .buyer.lockData.RedeemKeyData.Hex }}
-  recovery key data: {{ if ne .buyer.lockData.RecoveryKeyData.Hex .trade.RecoveryKey.Public.KeyData.Hex }}mis{{ end }}match ({{ .buyer.lockData.RecoveryKeyData.Hex }}, {{ .trade.RecoveryKey.Public.KeyData.Hex }})
+  recovery key data: {{ if ne .buyer.lockData.RecoveryKeyData.Hex .trade.RecoveryPublicKey.KeyData.Hex }}mis{{ end }}match ({{ .buyer.lockData.RecoveryKeyData.Hex }}, {{ .trade.RecoveryPublicKey.KeyData.Hex }})
   time lock expiry: {{ .buyer.lockData.LockTime.UTC }} (in {{ .buyer.lockData.LockTime.UTC.Sub now.UTC  }})
 seller:
   deposit address: {{ .seller.depositAddr }} ({{ .seller.chain }})
-  redeem key data: {{ if ne .seller.lockData.RedeemKeyData.Hex .trade.RedeemKey.Public.KeyData.Hex }}mis{{ end }}match ({{ .seller.lockData.RedeemKeyData.Hex }}, {{ .trade.RedeemKey.Public.KeyData.Hex }})
+  redeem key data: {{ if ne .seller.lockData.RedeemKeyData.Hex .trade.RedeemPublicKey.KeyData.Hex }}mis{{ end }}match ({{ .seller.lockData.RedeemKeyData.Hex }}, {{ .trade.RedeemPublicKey.KeyData.Hex }})
   recovery key data: {{ .seller.lockData.RecoveryKeyData.Hex }}
   time lock expiry: {{ .seller.lockData.LockTime.UTC }} (in {{ .seller.lockData.LockTime.UTC.Sub now.UTC  }}, {{ .buyer.lockData.LockTime.UTC.Sub .seller.lockData.LockTime.UTC }} before buyer)
 `,
@@ -105,8 +151,8 @@ func newBlockInfo(height uint64, txCount int) tplutil.TemplateData {
 	return tplutil.TemplateData{"height": height, "txCount": txCount}
 }
 
-func newTradeInfo(name string, trade trade.Trade) tplutil.TemplateData {
-	return tplutil.TemplateData{"name": name, "trade": trade}
+func newTradeInfo(name string, tr trade.Trade) tplutil.TemplateData {
+	return tplutil.TemplateData{"name": name, "trade": tr, "stage": trade.CurrentStage(tr)}
 }
 
 func newLockInfo(l trade.Lock, c *cryptos.Crypto) (tplutil.TemplateData, error) {
@@ -0,0 +1,32 @@
+package cmds
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+var IdentityCmd = &cobra.Command{
+	Use:     "identity",
+	Short:   "show the identity public key used to sign messages",
+	Aliases: []string{"id"},
+	Args:    cobra.NoArgs,
+	Run:     cmdShowIdentity,
+}
+
+func init() {
+	flagutil.AddFlags(flagutil.FlagFuncMap{
+		IdentityCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddOutput,
+		},
+	})
+}
+
+func cmdShowIdentity(cmd *cobra.Command, args []string) {
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	k := mustOpenIdentityKey(cmd)
+	fmt.Fprintf(out, "%s\n", types.Bytes(k.Public().SerializeCompressed()).Hex())
+}
@@ -15,6 +15,8 @@ import (
 	"github.com/c-bata/go-prompt"
 	"github.com/spf13/cobra"
 	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/envelope"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
 	"github.com/transmutate-io/atomicswap/internal/cmdutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
@@ -43,6 +45,7 @@ func init() {
 		interactiveActionsHandlers["config/clients/"+c+"/address"] = newActionConfigClientAddress(c)
 		interactiveActionsHandlers["config/clients/"+c+"/username"] = newActionConfigClientUsername(c)
 		interactiveActionsHandlers["config/clients/"+c+"/password"] = newActionConfigClientPassword(c)
+		interactiveActionsHandlers["config/clients/"+c+"/backend"] = newActionConfigClientBackend(c)
 		interactiveActionsHandlers["config/clients/"+c+"/show"] = newActionConfigClientShow(c)
 		interactiveActionsHandlers["config/clients/"+c+"/tls/ca"] = newActionConfigClientTLSCaCert(c)
 		interactiveActionsHandlers["config/clients/"+c+"/tls/cert"] = newActionConfigClientTLSCert(c)
@@ -407,12 +410,7 @@ func actionImportTrades(cmd *cobra.Command) {
 	}
 }
 
-type clientConfig struct {
-	Address  string
-	Username string
-	Password string
-	TLS      *cryptocore.TLSConfig
-}
+type clientConfig = chainutil.ClientConfig
 
 type consoleConfig map[string]*clientConfig
 
@@ -452,6 +450,14 @@ func newActionConfigClientPassword(name string) consoleCommand {
 	}
 }
 
+func newActionConfigClientBackend(name string) consoleCommand {
+	return func(cmd *cobra.Command) {
+		cfg := mainConfig.client(name)
+		cfg.Backend = uiutil.InputTextWithDefault("new backend url (empty for the node)", cfg.Backend)
+		mainConfig[name] = cfg
+	}
+}
+
 func newActionConfigClientShow(name string) consoleCommand {
 	return func(cmd *cobra.Command) {
 		b := bytes.NewBuffer(make([]byte, 0, 1024))
@@ -623,8 +629,7 @@ func actionExportProposal(cmd *cobra.Command) {
 	if tn == "" && tr == nil {
 		return
 	}
-	buyerTrade, err := tr.Buyer()
-	if err != nil {
+	if _, err = tr.Buyer(); err != nil {
 		fmt.Printf("can't open trade: %s\n", err)
 		return
 	}
@@ -645,13 +650,17 @@ func actionExportProposal(cmd *cobra.Command) {
 		defer f.Close()
 		fout = f
 	}
-	prop, err := buyerTrade.GenerateBuyProposal()
+	k, err := openIdentityKey(cmd)
 	if err != nil {
-		fmt.Printf("can't generate buy proposal: %s\n", err)
+		fmt.Printf("can't open identity key: %s\n", err)
+		return
+	}
+	if err = exportProposal(tr, fout, k, defaultMessageExpiry); err != nil {
+		fmt.Printf("can't export proposal: %s\n", err)
 		return
 	}
-	if err = yaml.NewEncoder(fout).Encode(prop); err != nil {
-		fmt.Printf("can't encode proposal: %s\n", err)
+	if err = saveTrade(tn, tr); err != nil {
+		fmt.Printf("can't save trade: %s\n", err)
 	}
 }
 
@@ -677,12 +686,12 @@ func actionAcceptProposal(cmd *cobra.Command) {
 		fmt.Printf("can't read proposal: %s\n", err)
 		return
 	}
-	prop, err := trade.UnamrshalBuyProposal(b)
+	env, err := openMessageInteractive(b, envelope.TypeProposal, nil, nil)
 	if err != nil {
-		fmt.Printf("can't decode proposal: %s\n", err)
+		fmt.Printf("can't open proposal: %s\n", err)
 		return
 	}
-	if err = acceptProposal("", tn, prop); err != nil {
+	if err = acceptProposal("", tn, env, false); err != nil {
 		fmt.Printf("can't accept proposal: %s\n", err)
 	}
 }
@@ -721,7 +730,12 @@ func actionExportLockSet(cmd *cobra.Command) {
 		return
 	}
 	defer f.Close()
-	if err = exportLockSet(tn, f); err != nil {
+	k, err := openIdentityKey(cmd)
+	if err != nil {
+		fmt.Printf("can't open identity key: %s\n", err)
+		return
+	}
+	if err = exportLockSet(tn, f, k, defaultMessageExpiry); err != nil {
 		fmt.Printf("can't export lockset: %s\n", err)
 		return
 	}
@@ -749,6 +763,11 @@ func actionAcceptLockSet(cmd *cobra.Command) {
 		fmt.Printf("can't read lockset file: %s\n", err)
 		return
 	}
+	env, err := openMessageInteractive(lsBytes, envelope.TypeLockSet, tr.SessionID(), tr.PeerSigner())
+	if err != nil {
+		fmt.Printf("can't open lockset: %s\n", err)
+		return
+	}
 	tpl, err := template.New("main").
 		Funcs(template.FuncMap{"now": time.Now}).
 		Parse(lockSetInfoTemplates[len(lockSetInfoTemplates)-1])
@@ -757,7 +776,7 @@ func actionAcceptLockSet(cmd *cobra.Command) {
 		return
 	}
 	fmt.Printf("\nlockset info:\n\n")
-	if err = showLockSetInfo(tp, bytes.NewReader(lsBytes), os.Stdout, tpl); err != nil {
+	if err = showLockSetInfo(tp, env, os.Stdout, tpl); err != nil {
 		fmt.Printf("can't show lockset info: %s\n", err)
 		return
 	}
@@ -770,7 +789,8 @@ func actionAcceptLockSet(cmd *cobra.Command) {
 		fmt.Printf("not accepted\n")
 		return
 	}
-	if err := acceptLockSet(tr, bytes.NewReader(lsBytes)); err != nil {
+	bindPeerSigner(tr, env)
+	if err := acceptLockSet(tr, env); err != nil {
 		fmt.Printf("can't accept trade: %s\n", err)
 		return
 	}
@@ -796,19 +816,28 @@ func actionLockSetInfo(cmd *cobra.Command) {
 	if inFn == "" {
 		return
 	}
-	fin, err := os.Open(inFn)
+	tr, err := openTradeFile(tp)
+	if err != nil {
+		fmt.Printf("can't open trade: %s\n", err)
+		return
+	}
+	lsBytes, err := ioutil.ReadFile(inFn)
 	if err != nil {
-		fmt.Printf("can't open lockset file: %s\n", err)
+		fmt.Printf("can't read lockset file: %s\n", err)
+		return
+	}
+	env, err := openMessageInteractive(lsBytes, envelope.TypeLockSet, tr.SessionID(), tr.PeerSigner())
+	if err != nil {
+		fmt.Printf("can't open lockset: %s\n", err)
 		return
 	}
-	defer fin.Close()
 	tpl, err := newLockSetTemplate().
 		Parse(lockSetInfoTemplates[len(lockSetInfoTemplates)-1])
 	if err != nil {
 		fmt.Printf("can't parse template: %s\n", err)
 		return
 	}
-	if err = showLockSetInfo(tp, fin, os.Stdout, tpl); err != nil {
+	if err = showLockSetInfo(tp, env, os.Stdout, tpl); err != nil {
 		fmt.Printf("can't show lockset info: %s\n", err)
 	}
 }
@@ -857,13 +886,7 @@ func actionWatch(
 	}
 	cryptoInfo := selectCryptoInfo(tr)
 	clientCfg := mainConfig.client(cryptoInfo.Crypto.Name)
-	cl, err := newClient(
-		cryptoInfo.Crypto,
-		clientCfg.Address,
-		clientCfg.Username,
-		clientCfg.Password,
-		clientCfg.TLS,
-	)
+	cl, err := newClient(cryptoInfo.Crypto, clientCfg)
 	if err != nil {
 		return err
 	}
@@ -875,13 +898,23 @@ func actionWatch(
 	if !ok {
 		return nil
 	}
+	sc, err := clientCfg.NewScanner(cryptoInfo.Crypto, cl)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	closec, stop := newSignalChan()
+	defer stop()
 	return watchDeposit(
+		tn,
+		nil,
 		tr,
 		wd,
 		os.Stdout,
 		depositTpl,
 		blockTpl,
-		cl,
+		sc,
+		closec,
 		uint64(firstBlock),
 		false,
 		uint64(confirmations),
@@ -940,13 +973,7 @@ func actionWatchSecret(cmd *cobra.Command) {
 		return
 	}
 	clientCfg := mainConfig.client(tr.OwnInfo().Crypto.Name)
-	cl, err := newClient(
-		tr.OwnInfo().Crypto,
-		clientCfg.Address,
-		clientCfg.Username,
-		clientCfg.Password,
-		clientCfg.TLS,
-	)
+	cl, err := newClient(tr.OwnInfo().Crypto, clientCfg)
 	if err != nil {
 		fmt.Printf("can't create client: %s\n", err)
 		return
@@ -966,7 +993,15 @@ func actionWatchSecret(cmd *cobra.Command) {
 		fmt.Printf("can't parse template: %s\n", err)
 		return
 	}
-	err = watchSecretToken(tr, wd, cl, uint64(firstBlock), os.Stdout, blockTpl, foundTpl)
+	sc, err := clientCfg.NewScanner(tr.OwnInfo().Crypto, cl)
+	if err != nil {
+		fmt.Printf("can't create scanner: %s\n", err)
+		return
+	}
+	defer sc.Close()
+	closec, stop := newSignalChan()
+	defer stop()
+	err = watchSecretToken(tn, nil, tr, wd, sc, closec, uint64(firstBlock), os.Stdout, blockTpl, foundTpl)
 	if err != nil {
 		fmt.Printf("error watching for the secret token: %s\n", err)
 		return
@@ -990,21 +1025,22 @@ func actionListRedeemable(cmd *cobra.Command) {
 	fmt.Println()
 }
 
-func inputRedeemRecoverData(cmd *cobra.Command, pr string) (trade.Trade, string, uint64, bool, bool) {
+func inputRedeemRecoverData(cmd *cobra.Command, pr string) (string, trade.Trade, string, *chainutil.Fee, bool) {
 	tn, tr, err := openTradeFromInput(cmd, pr)
 	if err != nil {
 		fmt.Printf("can't open trade: %s\n", err)
-		return nil, "", 0, false, false
+		return "", nil, "", nil, false
 	}
 	if tn == "" && tr == nil {
-		return nil, "", 0, false, false
+		return "", nil, "", nil, false
 	}
 	destAddr := uiutil.InputText(fmt.Sprintf("destination address (%s)", tr.TraderInfo().Crypto.Name))
 	if destAddr == "" {
 		fmt.Println("aborted")
-		return nil, "", 0, false, false
+		return "", nil, "", nil, false
 	}
 	choices := []prompt.Suggest{
+		{Text: "estimate", Description: "fee per byte estimated by the node"},
 		{Text: "byte", Description: "per byte fee"},
 		{Text: "fixed", Description: "fixed fee"},
 	}
@@ -1016,7 +1052,14 @@ func inputRedeemRecoverData(cmd *cobra.Command, pr string) (trade.Trade, string,
 		fmt.Printf("\n  .. abort\n\n")
 	})
 	if !ok {
-		return nil, "", 0, false, false
+		return "", nil, "", nil, false
+	}
+	if ft == "estimate" {
+		target, ok := uiutil.InputIntWithDefault("confirmation target (blocks)", 6)
+		if !ok {
+			return "", nil, "", nil, false
+		}
+		return tn, tr, destAddr, &chainutil.Fee{Target: uint64(target)}, true
 	}
 	var intPr string
 	if ft == "fixed" {
@@ -1026,35 +1069,33 @@ func inputRedeemRecoverData(cmd *cobra.Command, pr string) (trade.Trade, string,
 	}
 	fee, ok := uiutil.InputIntWithDefault(intPr, 1)
 	if !ok {
-		return nil, "", 0, false, false
+		return "", nil, "", nil, false
 	}
-	var fixedFee bool
-	if ft == "fixed" {
-		fixedFee = true
+	return tn, tr, destAddr, &chainutil.Fee{Value: uint64(fee), Fixed: ft == "fixed"}, true
+}
+
+// consoleSpendOptions returns the spend options for the console
+func consoleSpendOptions(cryptoName string, fee *chainutil.Fee) *spendOptions {
+	return &spendOptions{
+		client:      mainConfig.client(cryptoName),
+		fee:         fee,
+		maxFeeShare: 0.05,
+		verboseRaw:  true,
 	}
-	return tr, destAddr, uint64(fee), fixedFee, true
 }
 
 func actionRedeem(cmd *cobra.Command) {
-	tr, destAddr, fee, fixedFee, ok := inputRedeemRecoverData(cmd, "trade to redeem: ")
+	tn, tr, destAddr, fee, ok := inputRedeemRecoverData(cmd, "trade to redeem: ")
 	if !ok {
 		return
 	}
-	cfg := mainConfig.client(tr.TraderInfo().Crypto.Name)
-	err := redeemToAddress(
-		tr,
-		os.Stdout,
-		destAddr,
-		cfg.Address,
-		cfg.Username,
-		cfg.Password,
-		cfg.TLS,
-		uint64(fee),
-		fixedFee,
-		true,
-	)
+	err := redeemToAddress(tr, os.Stdout, destAddr, consoleSpendOptions(tr.TraderInfo().Crypto.Name, fee))
 	if err != nil {
 		fmt.Printf("can't redeem funds: %s\n", err)
+		return
+	}
+	if err = saveTrade(tn, tr); err != nil {
+		fmt.Printf("can't save trade: %s\n", err)
 	}
 }
 
@@ -1073,33 +1114,16 @@ func actionListRecoverable(cmd *cobra.Command) {
 }
 
 func actionRecover(cmd *cobra.Command) {
-	tr, destAddr, fee, fixedFee, ok := inputRedeemRecoverData(cmd, "trade to recover: ")
+	tn, tr, destAddr, fee, ok := inputRedeemRecoverData(cmd, "trade to recover: ")
 	if !ok {
 		return
 	}
-	cfg := mainConfig.client(tr.TraderInfo().Crypto.Name)
-	cl, err := newClient(
-		tr.OwnInfo().Crypto,
-		cfg.Address,
-		cfg.Username,
-		cfg.Password,
-		cfg.TLS,
-	)
+	err := recoverFunds(tr, os.Stdout, destAddr, consoleSpendOptions(tr.OwnInfo().Crypto.Name, fee))
 	if err != nil {
-		fmt.Printf("can't create client: %s\n", err)
+		fmt.Printf("can't recover funds: %s\n", err)
 		return
 	}
-	err = recoverFunds(
-		tr,
-		cl,
-		tr.OwnInfo(),
-		destAddr,
-		fixedFee,
-		fee,
-		os.Stdout,
-		true,
-	)
-	if err != nil {
-		fmt.Printf("can't recover funds: %s\n", err)
+	if err = saveTrade(tn, tr); err != nil {
+		fmt.Printf("can't save trade: %s\n", err)
 	}
 }
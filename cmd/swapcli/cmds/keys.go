@@ -0,0 +1,221 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/networks"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/atomicswap/signer"
+	"github.com/transmutate-io/atomicswap/trade"
+)
+
+var (
+	KeysCmd = &cobra.Command{
+		Use:     "keys <command>",
+		Short:   "trade keys commands",
+		Aliases: []string{"key", "k"},
+	}
+	exportKeyCmd = &cobra.Command{
+		Use:     "export <trade_name> redeem|recovery",
+		Short:   "export a trade key to output",
+		Long:    "export a trade key as WIF or extended private key, to sweep the funds from another wallet",
+		Aliases: []string{"exp", "e"},
+		Args:    cobra.ExactArgs(2),
+		Run:     cmdExportKey,
+	}
+	importKeyCmd = &cobra.Command{
+		Use:     "import <trade_name> redeem|recovery",
+		Short:   "import a trade key from input",
+		Long:    "import a WIF or extended private key into a trade, before the key is shared with the trader",
+		Aliases: []string{"imp", "i"},
+		Args:    cobra.ExactArgs(2),
+		Run:     cmdImportKey,
+	}
+	useKeyCmd = &cobra.Command{
+		Use:     "use <trade_name> redeem|recovery <path>",
+		Short:   "use a key held by a signer",
+		Long:    "replace a trade key with the key derived by the signer at the path (eg. m/0'/1), before the key is shared with the trader",
+		Aliases: []string{"u"},
+		Args:    cobra.ExactArgs(3),
+		Run:     cmdUseKey,
+	}
+)
+
+func init() {
+	network := &_network
+	flagutil.AddFlags(flagutil.FlagFuncMap{
+		exportKeyCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddOutput,
+			flagutil.AddKeyEncoding,
+			network.AddFlag,
+		},
+		importKeyCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddInput,
+			flagutil.AddKeyEncoding,
+			network.AddFlag,
+		},
+		useKeyCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddKeySigner,
+		},
+	})
+	cmdutil.AddCommands(KeysCmd, []*cobra.Command{
+		exportKeyCmd,
+		importKeyCmd,
+		useKeyCmd,
+	})
+}
+
+var (
+	errInvalidKeyName     = errors.New("invalid key name, expecting redeem or recovery")
+	errInvalidKeyEncoding = errors.New("invalid key encoding, expecting wif or xprv")
+	errKeyInSigner        = errors.New("the key is held by a signer")
+	errMissingKeySigner   = errors.New("missing key signer")
+)
+
+// tradeKey returns the key and crypto for the given key name
+func tradeKey(tr trade.Trade, name string) (key.Private, *cryptos.Crypto, error) {
+	switch name {
+	case "redeem":
+		return tr.RedeemKey(), tr.TraderInfo().Crypto, nil
+	case "recovery":
+		return tr.RecoveryKey(), tr.OwnInfo().Crypto, nil
+	default:
+		return nil, nil, errInvalidKeyName
+	}
+}
+
+func keyNetwork(c *cryptos.Crypto) (*params.Network, error) {
+	chain, err := _network.Network(c.Name)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := networks.AllByName[c.Name][chain]
+	if !ok {
+		return nil, params.InvalidChainError(chain.String())
+	}
+	return p.Network(), nil
+}
+
+func exportKey(tr trade.Trade, name string, encoding string, out io.Writer) error {
+	k, c, err := tradeKey(tr, name)
+	if err != nil {
+		return err
+	}
+	if k == nil {
+		return errKeyInSigner
+	}
+	n, err := keyNetwork(c)
+	if err != nil {
+		return err
+	}
+	var r string
+	switch encoding {
+	case "wif":
+		r = key.EncodeWIF(c, k, n)
+	case "xprv":
+		r = key.EncodeExtended(c, k, n)
+	default:
+		return errInvalidKeyEncoding
+	}
+	_, err = fmt.Fprintf(out, "%s\n", r)
+	return err
+}
+
+func cmdExportKey(cmd *cobra.Command, args []string) {
+	fs := cmd.Flags()
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	tr := mustOpenTrade(cmd, args[0])
+	if err := exportKey(tr, args[1], flagutil.MustKeyEncoding(fs), out); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func importKey(tr trade.Trade, name string, encoding string, in io.Reader) error {
+	_, c, err := tradeKey(tr, name)
+	if err != nil {
+		return err
+	}
+	n, err := keyNetwork(c)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	s := strings.TrimSpace(string(b))
+	var k key.Private
+	switch encoding {
+	case "wif":
+		k, err = key.DecodeWIF(c, s, n)
+	case "xprv":
+		k, err = key.DecodeExtended(c, s, n)
+	default:
+		return errInvalidKeyEncoding
+	}
+	if err != nil {
+		return err
+	}
+	if name == "redeem" {
+		return tr.SetRedeemKey(k)
+	}
+	return tr.SetRecoveryKey(k)
+}
+
+func cmdImportKey(cmd *cobra.Command, args []string) {
+	fs := cmd.Flags()
+	in, closeIn := flagutil.MustOpenInput(fs)
+	defer closeIn()
+	tr := mustOpenTrade(cmd, args[0])
+	if err := importKey(tr, args[1], flagutil.MustKeyEncoding(fs), in); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	mustSaveTrade(cmd, args[0], tr)
+}
+
+func useKey(tr trade.Trade, name string, spec string, path string) error {
+	if spec == "" {
+		return errMissingKeySigner
+	}
+	_, c, err := tradeKey(tr, name)
+	if err != nil {
+		return err
+	}
+	s, err := signer.Open(spec)
+	if err != nil {
+		return err
+	}
+	pub, err := s.PublicKey(c, &signer.KeyRef{Path: path})
+	if err != nil {
+		return err
+	}
+	ref := signer.NewKeyRef(pub, path)
+	if path != "" {
+		if ref.Fingerprint, err = signer.MasterFingerprint(s, c); err != nil {
+			return err
+		}
+	}
+	if name == "redeem" {
+		return tr.SetRedeemKeyRef(ref)
+	}
+	return tr.SetRecoveryKeyRef(ref)
+}
+
+func cmdUseKey(cmd *cobra.Command, args []string) {
+	tr := mustOpenTrade(cmd, args[0])
+	if err := useKey(tr, args[1], flagutil.MustKeySigner(cmd.Flags()), args[2]); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	mustSaveTrade(cmd, args[0], tr)
+}
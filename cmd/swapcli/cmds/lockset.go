@@ -6,12 +6,13 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/envelope"
 	"github.com/transmutate-io/atomicswap/internal/cmdutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
 	"github.com/transmutate-io/atomicswap/internal/tplutil"
+	"github.com/transmutate-io/atomicswap/key"
 	"github.com/transmutate-io/atomicswap/trade"
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -66,12 +67,19 @@ func init() {
 			flagutil.AddOutput,
 			network.AddFlag,
 			flagutil.AddInput,
+			flagutil.AddAllowUnsigned,
+			flagutil.AddAllowExpired,
+			flagutil.AddSigner,
 		},
 		acceptLockSetCmd.Flags(): []flagutil.FlagFunc{
 			flagutil.AddInput,
+			flagutil.AddAllowUnsigned,
+			flagutil.AddAllowExpired,
+			flagutil.AddSigner,
 		},
 		exportLockSetCmd.Flags(): []flagutil.FlagFunc{
 			flagutil.AddOutput,
+			flagutil.AddExpiry,
 		},
 	})
 	cmdutil.AddCommands(LockSetCmd, []*cobra.Command{
@@ -97,7 +105,7 @@ func cmdListLockSets(cmd *cobra.Command, args []string) {
 	}
 }
 
-func exportLockSet(tp string, out io.Writer) error {
+func exportLockSet(tp string, out io.Writer, k key.Private, expiry time.Duration) error {
 	tr, err := openTradeFile(tp)
 	if err != nil {
 		return err
@@ -107,36 +115,56 @@ func exportLockSet(tp string, out io.Writer) error {
 		return err
 	}
 	ls := str.Locks()
-	return yaml.NewEncoder(out).Encode(ls)
+	return sealMessage(out, k, envelope.TypeLockSet, tr.SessionID(), expiry, ls)
 }
 
 func cmdExportLockSet(cmd *cobra.Command, args []string) {
 	out, outClose := flagutil.MustOpenOutput(cmd.Flags())
 	defer outClose()
-	if err := exportLockSet(tradePath(cmd, args[0]), out); err != nil {
+	err := exportLockSet(
+		tradePath(cmd, args[0]),
+		out,
+		mustOpenIdentityKey(cmd),
+		flagutil.MustExpiry(cmd.Flags()),
+	)
+	if err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
 }
 
-func acceptLockSet(tr trade.Trade, lsIn io.Reader) error {
+func acceptLockSet(tr trade.Trade, env *envelope.Envelope) error {
 	btr, err := tr.Buyer()
 	if err != nil {
 		return err
 	}
-	return btr.SetLocks(openLockSet(lsIn, tr.OwnInfo().Crypto, tr.TraderInfo().Crypto))
+	return btr.SetLocks(openLockSet(env, tr.OwnInfo().Crypto, tr.TraderInfo().Crypto))
 }
 
-func cmdAcceptLockSet(cmd *cobra.Command, args []string) {
-	tr := mustOpenTrade(cmd, args[0])
+func mustOpenLockSetMessage(cmd *cobra.Command, tr trade.Trade) *envelope.Envelope {
 	in, inClose := flagutil.MustOpenInput(cmd.Flags())
 	defer inClose()
-	if err := acceptLockSet(tr, in); err != nil {
+	opts, err := tradeEnvelopeOptions(cmd.Flags(), tr)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantOpenLockSet, err)
+	}
+	r, err := openMessage(in, envelope.TypeLockSet, opts)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantOpenLockSet, err)
+	}
+	return r
+}
+
+func cmdAcceptLockSet(cmd *cobra.Command, args []string) {
+	tr := mustOpenTrade(cmd, args[0])
+	env := mustOpenLockSetMessage(cmd, tr)
+	bindPeerSigner(tr, env)
+	if err := acceptLockSet(tr, env); err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
 	mustSaveTrade(cmd, args[0], tr)
 }
 
-func showLockSetInfo(tp string, lsIn io.Reader, out io.Writer, tpl *template.Template) error {
+func showLockSetInfo(tp string, env *envelope.Envelope, out io.Writer, tpl *template.Template) error {
 	tr, err := openTradeFile(tp)
 	if err != nil {
 		return err
@@ -144,7 +172,7 @@ func showLockSetInfo(tp string, lsIn io.Reader, out io.Writer, tpl *template.Tem
 	if _, err := tr.Buyer(); err != nil {
 		return err
 	}
-	ls := openLockSet(lsIn, tr.OwnInfo().Crypto, tr.TraderInfo().Crypto)
+	ls := openLockSet(env, tr.OwnInfo().Crypto, tr.TraderInfo().Crypto)
 	ownLockInfo, err := newLockInfo(ls.Buyer, tr.OwnInfo().Crypto)
 	if err != nil {
 		return err
@@ -161,12 +189,11 @@ func newLockSetTemplate() *template.Template {
 }
 
 func cmdShowLockSetInfo(cmd *cobra.Command, args []string) {
-	in, inClose := flagutil.MustOpenInput(cmd.Flags())
-	defer inClose()
+	env := mustOpenLockSetMessage(cmd, mustOpenTrade(cmd, args[0]))
 	out, outClose := flagutil.MustOpenOutput(cmd.Flags())
 	defer outClose()
 	tpl := tplutil.MustOpenTemplate(cmd.Flags(), lockSetInfoTemplates, template.FuncMap{"now": time.Now})
-	if err := showLockSetInfo(tradePath(cmd, args[0]), in, out, tpl); err != nil {
+	if err := showLockSetInfo(tradePath(cmd, args[0]), env, out, tpl); err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
 }
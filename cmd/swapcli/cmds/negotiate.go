@@ -0,0 +1,226 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/uiutil"
+	"github.com/transmutate-io/atomicswap/negotiation"
+	"github.com/transmutate-io/atomicswap/roles"
+	"github.com/transmutate-io/atomicswap/stages"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+var (
+	NegotiateCmd = &cobra.Command{
+		Use:     "negotiate <command>",
+		Short:   "peer to peer negotiation commands",
+		Aliases: []string{"n", "neg"},
+	}
+	negotiateListenCmd = &cobra.Command{
+		Use:     "listen <address> <trade_name>",
+		Short:   "wait for a peer and negotiate a trade",
+		Aliases: []string{"l"},
+		Args:    cobra.ExactArgs(2),
+		Run:     cmdNegotiateListen,
+	}
+	negotiateConnectCmd = &cobra.Command{
+		Use:     "connect <address> <trade_name>",
+		Short:   "connect to a peer and negotiate a trade",
+		Aliases: []string{"c"},
+		Args:    cobra.ExactArgs(2),
+		Run:     cmdNegotiateConnect,
+	}
+)
+
+func init() {
+	network := &_network
+	flagutil.AddFlags(flagutil.FlagFuncMap{
+		negotiateListenCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddPeerKey,
+			flagutil.AddPeerTimeout,
+			flagutil.AddAutoAccept,
+			flagutil.AddOutput,
+			flagutil.AddRPC,
+			network.AddFlag,
+		},
+		negotiateConnectCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddPeerKey,
+			flagutil.AddPeerTimeout,
+			flagutil.AddAutoAccept,
+			flagutil.AddOutput,
+			flagutil.AddRPC,
+			network.AddFlag,
+		},
+	})
+	cmdutil.AddCommands(NegotiateCmd, []*cobra.Command{
+		negotiateListenCmd,
+		negotiateConnectCmd,
+	})
+}
+
+// peerConfig returns the configuration of a connection to a peer
+func peerConfig(cmd *cobra.Command) *negotiation.Config {
+	fs := cmd.Flags()
+	r := &negotiation.Config{
+		Identity: mustOpenIdentityKey(cmd),
+		Timeout:  flagutil.MustPeerTimeout(fs),
+	}
+	if fs.Lookup("peerkey") != nil {
+		r.PeerIdentity = flagutil.MustPeerKey(fs)
+	}
+	return r
+}
+
+var errNothingToNegotiate = errors.New("nothing to negotiate")
+
+func proposalDecider(out io.Writer, autoAccept bool) func(*trade.BuyProposal) error {
+	return func(prop *trade.BuyProposal) error {
+		fmt.Fprintf(out,
+			"proposal: %s %s (locked for %s) for %s %s (locked for %s)\n",
+			prop.Buyer.Amount, prop.Buyer.Crypto.Short, prop.Buyer.LockDuration,
+			prop.Seller.Amount, prop.Seller.Crypto.Short, prop.Seller.LockDuration,
+		)
+		if autoAccept {
+			return nil
+		}
+		if ok, _ := uiutil.InputYesNo("accept proposal? ", false); !ok {
+			return errors.New("proposal not accepted")
+		}
+		return nil
+	}
+}
+
+var errRedeemNotBroadcast = errors.New("the token is revealed after the redeem is broadcast")
+
+// redeemCheck returns true if the redeem of the trader funds was broadcast
+type redeemCheck = func(tr trade.Trade) (bool, error)
+
+// newRedeemCheck returns a redeem check looking up the spender of the trader
+// funds with the client
+func newRedeemCheck(cfg *chainutil.ClientConfig) redeemCheck {
+	return func(tr trade.Trade) (bool, error) {
+		c := tr.TraderInfo().Crypto
+		cl, err := newClient(c, cfg)
+		if err != nil {
+			return false, err
+		}
+		defer chainutil.CloseClient(cl)
+		sf := chainutil.NewSpendFinder(c, cl, cfg)
+		if sf == nil {
+			return false, errors.New("can't look up the redeem with the client")
+		}
+		return chainutil.RedeemBroadcast(sf, cl, tr)
+	}
+}
+
+// negotiate runs the negotiation step for the current trade stage. If the
+// trade doesn't exist a new one is created from the received proposal. The
+// buyer reveals the token only after its redeem was broadcast
+func negotiate(c *negotiation.Conn, tp string, out io.Writer, autoAccept bool, redeemed redeemCheck) error {
+	tr, err := openTradeFile(tp)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if tr, err = negotiation.AnswerProposal(c, proposalDecider(out, autoAccept)); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "proposal accepted, locks sent\n")
+		return saveTrade(tp, tr)
+	}
+	stage := trade.CurrentStage(tr)
+	switch {
+	case tr.Role() == roles.Buyer && stage == stages.ShareProposal:
+		if err = negotiation.ProposeTrade(c, tr); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "proposal accepted, locks received\n")
+		return saveTrade(tp, tr)
+	case tr.Role() == roles.Buyer && stage == stages.RedeemFunds:
+		ok, err := redeemed(tr)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errRedeemNotBroadcast
+		}
+		if err = negotiation.RevealToken(c, tr); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "token revealed\n")
+		return nil
+	case tr.Role() == roles.Seller && stage == stages.WaitSecretToken:
+		if err = negotiation.ReceiveToken(c, tr); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "token received: %s\n", tr.Token().Hex())
+		return saveTrade(tp, tr)
+	default:
+		return errNothingToNegotiate
+	}
+}
+
+func cmdNegotiate(cmd *cobra.Command, tradeName string, dial func(*negotiation.Config) (*negotiation.Conn, error)) {
+	fs := cmd.Flags()
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	c, err := dial(peerConfig(cmd))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	defer c.Close()
+	fmt.Fprintf(out, "connected to peer %s\n", types.Bytes(c.RemoteIdentity()).Hex())
+	err = negotiate(c, tradePath(cmd, tradeName), out, flagutil.MustAutoAccept(fs), newRedeemCheck(mustClientConfig(fs)))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func cmdNegotiateListen(cmd *cobra.Command, args []string) {
+	cmdNegotiate(cmd, args[1], func(cfg *negotiation.Config) (*negotiation.Conn, error) {
+		l, err := net.Listen("tcp", args[0])
+		if err != nil {
+			return nil, err
+		}
+		defer l.Close()
+		c, err := l.Accept()
+		if err != nil {
+			return nil, err
+		}
+		r, err := negotiation.Server(c, cfg)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		return r, nil
+	})
+}
+
+func dialPeer(addr string, cfg *negotiation.Config) (*negotiation.Conn, error) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r, err := negotiation.Client(c, cfg)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func cmdNegotiateConnect(cmd *cobra.Command, args []string) {
+	cmdNegotiate(cmd, args[1], func(cfg *negotiation.Config) (*negotiation.Conn, error) {
+		return dialPeer(args[0], cfg)
+	})
+}
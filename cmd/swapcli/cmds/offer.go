@@ -0,0 +1,419 @@
+package cmds
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/duration"
+	"github.com/transmutate-io/atomicswap/envelope"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
+	"github.com/transmutate-io/atomicswap/internal/tplutil"
+	"github.com/transmutate-io/atomicswap/negotiation"
+	"github.com/transmutate-io/atomicswap/orderbook"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	OfferCmd = &cobra.Command{
+		Use:     "offer <command>",
+		Short:   "standing offers commands",
+		Aliases: []string{"o"},
+	}
+	addOfferCmd = &cobra.Command{
+		Use:     "add <name> <own_crypto> <trader_crypto> <price> <min_amount> <max_amount> <duration>",
+		Short:   "add a standing offer",
+		Aliases: []string{"a"},
+		Args:    cobra.ExactArgs(7),
+		Run:     cmdAddOffer,
+	}
+	listOffersCmd = &cobra.Command{
+		Use:     "list",
+		Short:   "list offers to output",
+		Aliases: []string{"ls", "l"},
+		Args:    cobra.NoArgs,
+		Run:     cmdListOffers,
+	}
+	removeOfferCmd = &cobra.Command{
+		Use:     "remove <name>",
+		Short:   "remove an offer",
+		Aliases: []string{"rm", "del", "d"},
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdRemoveOffer,
+	}
+	inventoryCmd = &cobra.Command{
+		Use:     "inventory [<crypto> <amount>]",
+		Short:   "set the inventory of a crypto or list the inventory to output",
+		Aliases: []string{"inv", "i"},
+		Args:    cobra.RangeArgs(0, 2),
+		Run:     cmdInventory,
+	}
+	publishOffersCmd = &cobra.Command{
+		Use:     "publish",
+		Short:   "publish the offers to output",
+		Aliases: []string{"pub", "p"},
+		Args:    cobra.NoArgs,
+		Run:     cmdPublishOffers,
+	}
+	quoteOfferCmd = &cobra.Command{
+		Use:     "quote <offer_name> <amount> <trade_name>",
+		Short:   "create a trade from an offer and export it's proposal to output",
+		Aliases: []string{"q"},
+		Args:    cobra.ExactArgs(3),
+		Run:     cmdQuoteOffer,
+	}
+	serveOffersCmd = &cobra.Command{
+		Use:     "serve <address>",
+		Short:   "publish the offers and answer quote requests from peers",
+		Aliases: []string{"s"},
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdServeOffers,
+	}
+	fetchOffersCmd = &cobra.Command{
+		Use:     "fetch <address>",
+		Short:   "fetch the offers of a peer to output",
+		Aliases: []string{"f"},
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdFetchOffers,
+	}
+	requestQuoteCmd = &cobra.Command{
+		Use:     "request <address> <offer_name> <amount> <trade_name>",
+		Short:   "request a quote from a peer and accept the resulting proposal",
+		Aliases: []string{"r", "req"},
+		Args:    cobra.ExactArgs(4),
+		Run:     cmdRequestQuote,
+	}
+)
+
+func init() {
+	flagutil.AddFlags(flagutil.FlagFuncMap{
+		addOfferCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddTraderDuration,
+		},
+		listOffersCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddVerbose,
+			flagutil.AddFormat,
+			flagutil.AddOutput,
+		},
+		inventoryCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddVerbose,
+			flagutil.AddFormat,
+			flagutil.AddOutput,
+		},
+		publishOffersCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddOutput,
+			flagutil.AddExpiry,
+		},
+		quoteOfferCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddOutput,
+			flagutil.AddExpiry,
+		},
+		serveOffersCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddPeerTimeout,
+			flagutil.AddOutput,
+		},
+		fetchOffersCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddPeerKey,
+			flagutil.AddPeerTimeout,
+			flagutil.AddOutput,
+		},
+		requestQuoteCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddPeerKey,
+			flagutil.AddPeerTimeout,
+			flagutil.AddAutoAccept,
+			flagutil.AddOutput,
+		},
+	})
+	cmdutil.AddCommands(OfferCmd, []*cobra.Command{
+		addOfferCmd,
+		listOffersCmd,
+		removeOfferCmd,
+		inventoryCmd,
+		publishOffersCmd,
+		quoteOfferCmd,
+		serveOffersCmd,
+		fetchOffersCmd,
+		requestQuoteCmd,
+	})
+}
+
+func mustOpenOrderBook(cmd *cobra.Command) *orderbook.Book {
+	r, err := storeutil.New(dataDir(cmd)).OpenOrderBook()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	return r
+}
+
+func mustSaveOrderBook(cmd *cobra.Command, b *orderbook.Book) {
+	if err := storeutil.New(dataDir(cmd)).SaveOrderBook(b); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func mustOpenAllTrades(cmd *cobra.Command) []trade.Trade {
+	r, err := storeutil.New(dataDir(cmd)).Trades()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	return r
+}
+
+func cmdAddOffer(cmd *cobra.Command, args []string) {
+	b := mustOpenOrderBook(cmd)
+	err := b.Add(args[0], &orderbook.Offer{
+		Own:                mustParseCrypto(args[1]),
+		Trader:             mustParseCrypto(args[2]),
+		Price:              types.Amount(args[3]),
+		MinAmount:          types.Amount(args[4]),
+		MaxAmount:          types.Amount(args[5]),
+		LockDuration:       duration.Duration(mustParseDuration(args[6])),
+		TraderLockDuration: duration.Duration(flagutil.MustTraderDuration(cmd.Flags())),
+	})
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	mustSaveOrderBook(cmd, b)
+}
+
+func sortedOfferNames(offers map[string]*orderbook.Offer) []string {
+	r := make([]string, 0, len(offers))
+	for name := range offers {
+		r = append(r, name)
+	}
+	sort.Strings(r)
+	return r
+}
+
+func listOffers(b *orderbook.Book, out io.Writer, tpl *template.Template) error {
+	for _, name := range sortedOfferNames(b.Offers) {
+		if err := tpl.Execute(out, tplutil.TemplateData{"name": name, "offer": b.Offers[name]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cmdListOffers(cmd *cobra.Command, args []string) {
+	tpl := tplutil.MustOpenTemplate(cmd.Flags(), offerListTemplates, nil)
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	if err := listOffers(mustOpenOrderBook(cmd), out, tpl); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func cmdRemoveOffer(cmd *cobra.Command, args []string) {
+	b := mustOpenOrderBook(cmd)
+	if err := b.Remove(args[0]); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	mustSaveOrderBook(cmd, b)
+}
+
+func listInventory(b *orderbook.Book, trades []trade.Trade, out io.Writer, tpl *template.Template) error {
+	names := make([]string, 0, len(b.Inventory))
+	for name := range b.Inventory {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c, err := parseCrypto(name)
+		if err != nil {
+			return err
+		}
+		err = tpl.Execute(out, tplutil.TemplateData{
+			"crypto":    c,
+			"inventory": b.Inventory[name],
+			"available": types.NewAmount(b.Available(c, trades), uint64(c.Decimals)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cmdInventory(cmd *cobra.Command, args []string) {
+	b := mustOpenOrderBook(cmd)
+	switch len(args) {
+	case 0:
+		tpl := tplutil.MustOpenTemplate(cmd.Flags(), inventoryListTemplates, nil)
+		out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+		defer closeOut()
+		if err := listInventory(b, mustOpenAllTrades(cmd), out, tpl); err != nil {
+			cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+		}
+	case 2:
+		if err := b.SetInventory(mustParseCrypto(args[0]), types.Amount(args[1])); err != nil {
+			cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+		}
+		mustSaveOrderBook(cmd, b)
+	default:
+		cmdutil.ErrorExit(exitcodes.ExecutionError, "expecting a crypto and an amount")
+	}
+}
+
+func cmdPublishOffers(cmd *cobra.Command, args []string) {
+	offers := mustOpenOrderBook(cmd).Published(mustOpenAllTrades(cmd))
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	err := sealMessage(
+		out,
+		mustOpenIdentityKey(cmd),
+		envelope.TypeOffers,
+		nil,
+		flagutil.MustExpiry(cmd.Flags()),
+		offers,
+	)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func cmdQuoteOffer(cmd *cobra.Command, args []string) {
+	if _, err := openTradeFile(tradePath(cmd, args[2])); err == nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, "trade exists: "+args[2])
+	}
+	b := mustOpenOrderBook(cmd)
+	tr, prop, err := b.Quote(
+		&orderbook.QuoteRequest{Offer: args[0], Amount: types.Amount(args[1])},
+		mustOpenAllTrades(cmd),
+	)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	sid, err := envelope.NewSessionID()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	tr.SetSessionID(sid)
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	err = sealMessage(out, mustOpenIdentityKey(cmd), envelope.TypeProposal, sid, flagutil.MustExpiry(cmd.Flags()), prop)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	mustSaveTrade(cmd, args[2], tr)
+}
+
+// serveOffers publishes the offers to a peer and answers it's quote request
+func serveOffers(cmd *cobra.Command, c *negotiation.Conn, out io.Writer) error {
+	b, err := storeutil.New(dataDir(cmd)).OpenOrderBook()
+	if err != nil {
+		return err
+	}
+	trades, err := storeutil.New(dataDir(cmd)).Trades()
+	if err != nil {
+		return err
+	}
+	if err = negotiation.PublishOffers(c, b.Published(trades)); err != nil {
+		return err
+	}
+	var name string
+	tr, err := negotiation.AnswerQuote(c, func(req *orderbook.QuoteRequest) (trade.Trade, *trade.BuyProposal, error) {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, nil, err
+		}
+		name = fmt.Sprintf("%s-%x", req.Offer, suffix)
+		return b.Quote(req, trades)
+	})
+	if err != nil {
+		return err
+	}
+	if err = storeutil.CreateTradeFile(tradePath(cmd, name), tr); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "trade %s created\n", name)
+	return nil
+}
+
+func cmdServeOffers(cmd *cobra.Command, args []string) {
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	cfg := peerConfig(cmd)
+	l, err := net.Listen("tcp", args[0])
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	defer l.Close()
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+		}
+		c, err := negotiation.Server(nc, cfg)
+		if err != nil {
+			nc.Close()
+			fmt.Fprintf(out, "can't handshake: %s\n", err)
+			continue
+		}
+		fmt.Fprintf(out, "connected to peer %s\n", types.Bytes(c.RemoteIdentity()).Hex())
+		if err = serveOffers(cmd, c, out); err != nil {
+			fmt.Fprintf(out, "can't answer peer: %s\n", err)
+		}
+		c.Close()
+	}
+}
+
+func mustDialPeer(cmd *cobra.Command, addr string) *negotiation.Conn {
+	r, err := dialPeer(addr, peerConfig(cmd))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	return r
+}
+
+func cmdFetchOffers(cmd *cobra.Command, args []string) {
+	c := mustDialPeer(cmd, args[0])
+	defer c.Close()
+	offers, err := negotiation.ReceiveOffers(c)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	if err = yaml.NewEncoder(out).Encode(offers); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func cmdRequestQuote(cmd *cobra.Command, args []string) {
+	if _, err := openTradeFile(tradePath(cmd, args[3])); err == nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, "trade exists: "+args[3])
+	}
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	c := mustDialPeer(cmd, args[0])
+	defer c.Close()
+	offers, err := negotiation.ReceiveOffers(c)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	offer, ok := offers[args[1]]
+	if !ok {
+		c.Cancel(orderbook.OfferNotFoundError(args[1]).Error())
+		cmdutil.ErrorExit(exitcodes.ExecutionError, orderbook.OfferNotFoundError(args[1]))
+	}
+	tr, err := negotiation.RequestQuote(
+		c,
+		offer,
+		&orderbook.QuoteRequest{Offer: args[1], Amount: types.Amount(args[2])},
+		proposalDecider(out, flagutil.MustAutoAccept(cmd.Flags())),
+	)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	fmt.Fprintf(out, "proposal accepted, locks sent\n")
+	mustSaveTrade(cmd, args[3], tr)
+}
@@ -2,17 +2,21 @@ package cmds
 
 import (
 	"io"
-	"io/ioutil"
 	"path/filepath"
 	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/duration"
+	"github.com/transmutate-io/atomicswap/envelope"
 	"github.com/transmutate-io/atomicswap/internal/cmdutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
 	"github.com/transmutate-io/atomicswap/internal/tplutil"
+	"github.com/transmutate-io/atomicswap/key"
 	"github.com/transmutate-io/atomicswap/roles"
 	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
 	"gopkg.in/yaml.v2"
 )
 
@@ -43,6 +47,27 @@ var (
 		Args:    cobra.ExactArgs(1),
 		Run:     cmdAcceptProposal,
 	}
+	counterProposalCmd = &cobra.Command{
+		Use:     "counter",
+		Short:   "answer a proposal from input with a counter-proposal to output",
+		Aliases: []string{"c"},
+		Args:    cobra.NoArgs,
+		Run:     cmdCounterProposal,
+	}
+	rejectProposalCmd = &cobra.Command{
+		Use:     "reject",
+		Short:   "reject a proposal from input and output the rejection",
+		Aliases: []string{"rej"},
+		Args:    cobra.NoArgs,
+		Run:     cmdRejectProposal,
+	}
+	replyProposalCmd = &cobra.Command{
+		Use:     "reply <trade_name>",
+		Short:   "process a counter-proposal or a rejection from input",
+		Aliases: []string{"r"},
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdReplyProposal,
+	}
 )
 
 func init() {
@@ -54,15 +79,46 @@ func init() {
 		},
 		acceptProposalCmd.Flags(): []flagutil.FlagFunc{
 			flagutil.AddInput,
+			flagutil.AddAllowUnsigned,
+			flagutil.AddAllowExpired,
+			flagutil.AddSigner,
 		},
 		exportProposalCmd.Flags(): []flagutil.FlagFunc{
 			flagutil.AddOutput,
+			flagutil.AddExpiry,
+		},
+		counterProposalCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddInput,
+			flagutil.AddOutput,
+			flagutil.AddExpiry,
+			flagutil.AddCounterTerms,
+			flagutil.AddAllowUnsigned,
+			flagutil.AddAllowExpired,
+			flagutil.AddSigner,
+		},
+		rejectProposalCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddInput,
+			flagutil.AddOutput,
+			flagutil.AddExpiry,
+			flagutil.AddReason,
+			flagutil.AddAllowUnsigned,
+			flagutil.AddAllowExpired,
+			flagutil.AddSigner,
+		},
+		replyProposalCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddInput,
+			flagutil.AddAllowUnsigned,
+			flagutil.AddAllowExpired,
+			flagutil.AddSigner,
 		},
 	})
 	cmdutil.AddCommands(ProposalCmd, []*cobra.Command{
 		listProposalsCmd,
 		exportProposalCmd,
 		acceptProposalCmd,
+		counterProposalCmd,
+		rejectProposalCmd,
+		replyProposalCmd,
 	})
 }
 
@@ -81,7 +137,7 @@ func cmdListProposals(cmd *cobra.Command, args []string) {
 	}
 }
 
-func exportProposal(tr trade.Trade, out io.Writer) error {
+func exportProposal(tr trade.Trade, out io.Writer, k key.Private, expiry time.Duration) error {
 	if tr.Role() != roles.Buyer {
 		cmdutil.ErrorExit(exitcodes.NotABuyer)
 	}
@@ -93,37 +149,160 @@ func exportProposal(tr trade.Trade, out io.Writer) error {
 	if err != nil {
 		return err
 	}
-	return yaml.NewEncoder(out).Encode(prop)
+	if len(tr.SessionID()) == 0 {
+		sid, err := envelope.NewSessionID()
+		if err != nil {
+			return err
+		}
+		tr.SetSessionID(sid)
+	}
+	return sealMessage(out, k, envelope.TypeProposal, tr.SessionID(), expiry, prop)
 }
 
 func cmdExportProposal(cmd *cobra.Command, args []string) {
 	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
 	defer closeOut()
-	if err := exportProposal(mustOpenTrade(cmd, args[0]), out); err != nil {
+	tr := mustOpenTrade(cmd, args[0])
+	err := exportProposal(tr, out, mustOpenIdentityKey(cmd), flagutil.MustExpiry(cmd.Flags()))
+	if err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
+	mustSaveTrade(cmd, args[0], tr)
 }
 
-func acceptProposal(tp string, name string, prop *trade.BuyProposal) error {
+func acceptProposal(tp string, name string, env *envelope.Envelope, allowExpired bool) error {
+	prop, err := trade.UnamrshalBuyProposal([]byte(env.Payload))
+	if err != nil {
+		return err
+	}
+	if !allowExpired && prop.Expired(time.Now()) {
+		return trade.ErrProposalExpired
+	}
 	newTrade, err := trade.AcceptProposal(prop)
 	if err != nil {
 		return err
 	}
+	newTrade.SetSessionID(env.SessionID)
+	newTrade.SetPeerSigner(env.Signer)
 	return saveTrade(filepath.Join(tp, filepath.FromSlash(name)), newTrade)
 }
 
 func cmdAcceptProposal(cmd *cobra.Command, args []string) {
 	in, inClose := flagutil.MustOpenInput(cmd.Flags())
 	defer inClose()
-	b, err := ioutil.ReadAll(in)
+	env, err := openMessage(in, envelope.TypeProposal, envelopeOptions(cmd.Flags(), nil))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	err = acceptProposal(tradesDir(cmd), args[0], env, flagutil.MustAllowExpired(cmd.Flags()))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func mustOpenProposalMessage(cmd *cobra.Command) (*envelope.Envelope, *trade.BuyProposal) {
+	in, inClose := flagutil.MustOpenInput(cmd.Flags())
+	defer inClose()
+	env, err := openMessage(in, envelope.TypeProposal, envelopeOptions(cmd.Flags(), nil))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	prop, err := trade.UnamrshalBuyProposal([]byte(env.Payload))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	if !flagutil.MustAllowExpired(cmd.Flags()) && prop.Expired(time.Now()) {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, trade.ErrProposalExpired)
+	}
+	return env, prop
+}
+
+func cmdCounterProposal(cmd *cobra.Command, args []string) {
+	env, prop := mustOpenProposalMessage(cmd)
+	fs := cmd.Flags()
+	cp, err := trade.NewCounterProposal(
+		prop,
+		types.Amount(flagutil.MustBuyerAmount(fs)),
+		types.Amount(flagutil.MustSellerAmount(fs)),
+		duration.Duration(flagutil.MustBuyerDuration(fs)),
+		duration.Duration(flagutil.MustSellerDuration(fs)),
+	)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	err = sealMessage(
+		out,
+		mustOpenIdentityKey(cmd),
+		envelope.TypeCounterProposal,
+		env.SessionID,
+		flagutil.MustExpiry(fs),
+		cp,
+	)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func cmdRejectProposal(cmd *cobra.Command, args []string) {
+	env, prop := mustOpenProposalMessage(cmd)
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	err := sealMessage(
+		out,
+		mustOpenIdentityKey(cmd),
+		envelope.TypeRejection,
+		env.SessionID,
+		flagutil.MustExpiry(cmd.Flags()),
+		&trade.ProposalRejection{
+			InReplyTo: prop.Nonce,
+			Reason:    flagutil.MustReason(cmd.Flags()),
+		},
+	)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func replyProposal(tr trade.Trade, env *envelope.Envelope) error {
+	btr, err := tr.Buyer()
+	if err != nil {
+		return err
+	}
+	switch env.Type {
+	case envelope.TypeCounterProposal:
+		cp := &trade.CounterProposal{}
+		if err = yaml.Unmarshal([]byte(env.Payload), cp); err != nil {
+			return err
+		}
+		return btr.AcceptCounterProposal(cp)
+	case envelope.TypeRejection:
+		rej := &trade.ProposalRejection{}
+		if err = yaml.Unmarshal([]byte(env.Payload), rej); err != nil {
+			return err
+		}
+		return btr.RejectProposal(rej)
+	default:
+		return envelope.TypeMismatchError(env.Type)
+	}
+}
+
+func cmdReplyProposal(cmd *cobra.Command, args []string) {
+	tr := mustOpenTrade(cmd, args[0])
+	in, inClose := flagutil.MustOpenInput(cmd.Flags())
+	defer inClose()
+	opts, err := tradeEnvelopeOptions(cmd.Flags(), tr)
 	if err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
-	prop, err := trade.UnamrshalBuyProposal(b)
+	env, err := openMessage(in, "", opts)
 	if err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
-	if err = acceptProposal(tradesDir(cmd), args[0], prop); err != nil {
+	bindPeerSigner(tr, env)
+	if err = replyProposal(tr, env); err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
+	mustSaveTrade(cmd, args[0], tr)
 }
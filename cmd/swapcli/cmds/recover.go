@@ -1,20 +1,18 @@
 package cmds
 
 import (
-	"encoding/hex"
 	"fmt"
 	"io"
 	"text/template"
 
 	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
 	"github.com/transmutate-io/atomicswap/internal/cmdutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
 	"github.com/transmutate-io/atomicswap/internal/tplutil"
-	"github.com/transmutate-io/atomicswap/networks"
 	"github.com/transmutate-io/atomicswap/trade"
-	"github.com/transmutate-io/atomicswap/tx"
-	"github.com/transmutate-io/cryptocore"
 )
 
 var (
@@ -30,6 +28,13 @@ var (
 		Args:    cobra.NoArgs,
 		Run:     cmdListRecoverable,
 	}
+	recoverPSBTCmd = &cobra.Command{
+		Use:     "psbt <name> <address>",
+		Short:   "psbt outputs an unsigned recovery transaction (BIP174) to the provided address",
+		Aliases: []string{"p"},
+		Args:    cobra.ExactArgs(2),
+		Run:     cmdRecoverPSBT,
+	}
 	recoverToAddressCmd = &cobra.Command{
 		Use:     "toaddress <name> <address>",
 		Short:   "toaddress recovers the funds to the provided address",
@@ -55,11 +60,24 @@ func init() {
 			flagutil.AddRPC,
 			flagutil.AddOutput,
 			flagutil.AddVerbose,
+			flagutil.AddDryRun,
+			flagutil.AddKeySigner,
+			flagutil.AddConfTarget,
+			flagutil.AddMaxFeeShare,
+			flagutil.AddAcceptFee,
+			flagutil.AddNotify,
+		},
+		recoverPSBTCmd.Flags(): []flagutil.FlagFunc{
+			network.AddFlag,
+			_fee.AddFlag,
+			flagutil.AddRPC,
+			flagutil.AddOutput,
 		},
 	})
 	cmdutil.AddCommands(RecoverCmd, []*cobra.Command{
 		listRecoverableCmd,
 		recoverToAddressCmd,
+		recoverPSBTCmd,
 	})
 }
 
@@ -82,64 +100,72 @@ func cmdListRecoverable(cmd *cobra.Command, args []string) {
 	}
 }
 
-func recoverFunds(tr trade.Trade, cl cryptocore.Client, cryptoInfo *trade.TraderInfo, addr string, feeFixed bool, fee uint64, out io.Writer, verboseRaw bool) error {
-	addrScript, err := networks.
-		AllByName[cryptoInfo.Crypto.Name][_network.MustNetwork(cryptoInfo.Crypto.Name)].
-		AddressToScript(addr)
-	if err != nil {
-		return err
-	}
-	var recoveryFunc func([]byte, uint64) (tx.Tx, error)
-	if feeFixed {
-		recoveryFunc = tr.RecoveryTxFixedFee
-	} else {
-		recoveryFunc = tr.RecoveryTx
-	}
-	tx, err := recoveryFunc(addrScript, fee)
-	if err != nil {
-		return err
-	}
-	b, err := tx.Serialize()
-	if err != nil {
-		return err
-	}
-	if verboseRaw {
-		fmt.Fprintf(out, "raw transaction: %s\n", hex.EncodeToString(b))
-	}
-	txID, err := cl.SendRawTransaction(b)
+func recoverFunds(tr trade.Trade, out io.Writer, destAddr string, opts *spendOptions) error {
+	txID, err := spendFunds(
+		tr,
+		out,
+		tr.OwnInfo().Crypto,
+		tr.RecoverableFunds(),
+		destAddr,
+		opts,
+		chainutil.RecoveryTx,
+	)
 	if err != nil {
 		return err
 	}
+	tr.Finish()
 	fmt.Fprintf(out, "funds recovered (tx id): %s\n", txID.Hex())
+	notifyEvents(out, opts.notifier, &notifyutil.Event{
+		Type:   notifyutil.RecoveryBroadcast,
+		Trade:  opts.tradeName,
+		Crypto: tr.OwnInfo().Crypto.Name,
+		Data:   map[string]interface{}{"txid": txID.Hex()},
+	})
 	return nil
 }
 
 func cmdRecoverToAddress(cmd *cobra.Command, args []string) {
 	tr := mustOpenTrade(cmd, args[0])
+	mustSetKeySigner(cmd.Flags(), tr)
 	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
 	defer closeOut()
 	fs := cmd.Flags()
-	var verboseRaw bool
-	if flagutil.MustVerboseLevel(fs, 1) > 0 {
-		verboseRaw = true
+	opts := mustSpendOptions(fs)
+	defer opts.notifier.Close()
+	opts.tradeName = args[0]
+	if flagutil.MustDryRun(fs) {
+		c := tr.OwnInfo().Crypto
+		t, err := newSpendTx(tr, c, args[1], opts, chainutil.RecoveryTx)
+		if err != nil {
+			cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+		}
+		err = decodeTx(out, mustOpenDryRunTemplate(fs, c), t, chainutil.FundsAmounts(tr.RecoverableFunds()))
+		if err != nil {
+			cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+		}
+		return
 	}
-	err := recoverFunds(
-		tr,
-		mustNewClient(
-			tr.OwnInfo().Crypto,
-			flagutil.MustRPCAddress(fs),
-			flagutil.MustRPCUsername(fs),
-			flagutil.MustRPCPassword(fs),
-			flagutil.MustRPCTLSConfig(fs),
-		),
-		tr.OwnInfo(),
-		args[1],
-		_fee.Fixed,
-		_fee.Value,
-		out,
-		verboseRaw,
-	)
+	if err := recoverFunds(tr, out, args[1], opts); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	mustSaveTrade(cmd, args[0], tr)
+}
+
+func cmdRecoverPSBT(cmd *cobra.Command, args []string) {
+	tr := mustOpenTrade(cmd, args[0])
+	fs := cmd.Flags()
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	c := tr.OwnInfo().Crypto
+	cl := mustNewClient(c, mustClientConfig(fs))
+	defer chainutil.CloseClient(cl)
+	p, err := chainutil.RecoveryPSBT(cl, tr, _network.MustNetwork(c.Name), args[1], _fee.Value, _fee.Fixed)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	s, err := p.Base64()
 	if err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
+	fmt.Fprintf(out, "%s\n", s)
 }
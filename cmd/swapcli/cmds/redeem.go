@@ -1,20 +1,18 @@
 package cmds
 
 import (
-	"encoding/hex"
 	"fmt"
 	"io"
 	"text/template"
 
 	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
 	"github.com/transmutate-io/atomicswap/internal/cmdutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
 	"github.com/transmutate-io/atomicswap/internal/tplutil"
-	"github.com/transmutate-io/atomicswap/networks"
 	"github.com/transmutate-io/atomicswap/trade"
-	"github.com/transmutate-io/atomicswap/tx"
-	"github.com/transmutate-io/cryptocore"
 )
 
 var (
@@ -30,6 +28,13 @@ var (
 		Args:    cobra.NoArgs,
 		Run:     cmdListRedeemable,
 	}
+	redeemPSBTCmd = &cobra.Command{
+		Use:     "psbt <name> <address>",
+		Short:   "psbt outputs an unsigned redeem transaction (BIP174) to the provided address",
+		Aliases: []string{"p"},
+		Args:    cobra.ExactArgs(2),
+		Run:     cmdRedeemPSBT,
+	}
 	redeemToAddressCmd = &cobra.Command{
 		Use:     "toaddress <name> <address>",
 		Short:   "toaddress redeems the funds to the provided address",
@@ -53,11 +58,24 @@ func init() {
 			flagutil.AddRPC,
 			flagutil.AddOutput,
 			flagutil.AddVerbose,
+			flagutil.AddDryRun,
+			flagutil.AddKeySigner,
+			flagutil.AddConfTarget,
+			flagutil.AddMaxFeeShare,
+			flagutil.AddAcceptFee,
+			flagutil.AddNotify,
+		},
+		redeemPSBTCmd.Flags(): []flagutil.FlagFunc{
+			network.AddFlag,
+			_fee.AddFlag,
+			flagutil.AddRPC,
+			flagutil.AddOutput,
 		},
 	})
 	cmdutil.AddCommands(RedeemCmd, []*cobra.Command{
 		listRedeemableCmd,
 		redeemToAddressCmd,
+		redeemPSBTCmd,
 	})
 }
 
@@ -77,73 +95,73 @@ func cmdListRedeemable(cmd *cobra.Command, args []string) {
 	}
 }
 
-func redeemToAddress(
-	tr trade.Trade,
-	out io.Writer,
-	destAddr string,
-	addr string,
-	username string,
-	password string,
-	tlsConf *cryptocore.TLSConfig,
-	fee uint64,
-	fixedFee bool,
-	verboseRaw bool,
-) error {
-	cl, err := newClient(tr.TraderInfo().Crypto, addr, username, password, tlsConf)
-	if err != nil {
-		return err
-	}
-	addrScript, err := networks.
-		AllByName[tr.TraderInfo().Crypto.Name][_network.MustNetwork(tr.TraderInfo().Crypto.Name)].
-		AddressToScript(destAddr)
-	if err != nil {
-		return err
-	}
-	var redeemFunc func([]byte, uint64) (tx.Tx, error)
-	if fixedFee {
-		redeemFunc = tr.RedeemTxFixedFee
-	} else {
-		redeemFunc = tr.RedeemTx
-	}
-	tx, err := redeemFunc(addrScript, fee)
-	if err != nil {
-		return err
-	}
-	b, err := tx.Serialize()
-	if err != nil {
-		return err
-	}
-	if verboseRaw {
-		fmt.Fprintf(out, "raw transaction: %s\n", hex.EncodeToString(b))
-	}
-
-	txID, err := cl.SendRawTransaction(b)
+func redeemToAddress(tr trade.Trade, out io.Writer, destAddr string, opts *spendOptions) error {
+	txID, err := spendFunds(
+		tr,
+		out,
+		tr.TraderInfo().Crypto,
+		tr.RedeemableFunds(),
+		destAddr,
+		opts,
+		chainutil.RedeemTx,
+	)
 	if err != nil {
 		return err
 	}
+	tr.Finish()
 	fmt.Fprintf(out, "funds redeemed (tx id): %s\n", txID.Hex())
+	notifyEvents(out, opts.notifier, &notifyutil.Event{
+		Type:   notifyutil.RedeemBroadcast,
+		Trade:  opts.tradeName,
+		Crypto: tr.TraderInfo().Crypto.Name,
+		Data:   map[string]interface{}{"txid": txID.Hex()},
+	})
 	return nil
 }
 
 func cmdRedeemToAddress(cmd *cobra.Command, args []string) {
 	tr := mustOpenTrade(cmd, args[0])
+	mustSetKeySigner(cmd.Flags(), tr)
 	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
 	defer closeOut()
 	fs := cmd.Flags()
-	err := redeemToAddress(
-		tr,
-		out,
-		args[1],
-		flagutil.MustRPCAddress(fs),
-		flagutil.MustRPCUsername(fs),
-		flagutil.MustRPCPassword(fs),
-		flagutil.MustRPCTLSConfig(fs),
-		_fee.Value,
-		_fee.Fixed,
-		true,
-	)
-	if err != nil {
+	opts := mustSpendOptions(fs)
+	defer opts.notifier.Close()
+	opts.tradeName = args[0]
+	if flagutil.MustDryRun(fs) {
+		c := tr.TraderInfo().Crypto
+		t, err := newSpendTx(tr, c, args[1], opts, chainutil.RedeemTx)
+		if err != nil {
+			cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+		}
+		err = decodeTx(out, mustOpenDryRunTemplate(fs, c), t, chainutil.FundsAmounts(tr.RedeemableFunds()))
+		if err != nil {
+			cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+		}
+		return
+	}
+	opts.verboseRaw = true
+	if err := redeemToAddress(tr, out, args[1], opts); err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
 	mustSaveTrade(cmd, args[0], tr)
 }
+
+func cmdRedeemPSBT(cmd *cobra.Command, args []string) {
+	tr := mustOpenTrade(cmd, args[0])
+	fs := cmd.Flags()
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	c := tr.TraderInfo().Crypto
+	cl := mustNewClient(c, mustClientConfig(fs))
+	defer chainutil.CloseClient(cl)
+	p, err := chainutil.RedeemPSBT(cl, tr, _network.MustNetwork(c.Name), args[1], _fee.Value, _fee.Fixed)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	s, err := p.Base64()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	fmt.Fprintf(out, "%s\n", s)
+}
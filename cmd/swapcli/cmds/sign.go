@@ -0,0 +1,118 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/trade"
+)
+
+var (
+	SignCmd = &cobra.Command{
+		Use:   "sign <trade_name> redeem|recovery <message>",
+		Short: "sign a message with a trade key",
+		Args:  cobra.ExactArgs(3),
+		Run:   cmdSignMessage,
+	}
+	VerifyCmd = &cobra.Command{
+		Use:   "verify <trade_name> redeem|recovery <message> <signature>",
+		Short: "verify a message signed with a trader key",
+		Args:  cobra.ExactArgs(4),
+		Run:   cmdVerifyMessage,
+	}
+)
+
+func init() {
+	flagutil.AddFlags(flagutil.FlagFuncMap{
+		SignCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddOutput,
+		},
+		VerifyCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddOutput,
+		},
+	})
+}
+
+var errTraderKeysUnavailable = errors.New("trader keys unavailable, the locks are not set")
+
+// traderKeyData returns the key data and crypto of a trader key
+func traderKeyData(tr trade.Trade, name string) (key.KeyData, *cryptos.Crypto, error) {
+	var (
+		fd trade.FundsData
+		c  *cryptos.Crypto
+	)
+	switch name {
+	case "redeem":
+		// the trader redeems the funds we lock
+		fd, c = tr.RecoverableFunds(), tr.OwnInfo().Crypto
+	case "recovery":
+		// the trader recovers the funds we redeem
+		fd, c = tr.RedeemableFunds(), tr.TraderInfo().Crypto
+	default:
+		return nil, nil, errInvalidKeyName
+	}
+	if fd == nil || len(fd.Lock().Bytes()) == 0 {
+		return nil, nil, errTraderKeysUnavailable
+	}
+	ld, err := fd.Lock().LockData()
+	if err != nil {
+		return nil, nil, err
+	}
+	if name == "redeem" {
+		return ld.RedeemKeyData, c, nil
+	}
+	return ld.RecoveryKeyData, c, nil
+}
+
+func signMessage(tr trade.Trade, name string, msg string, out io.Writer) error {
+	k, c, err := tradeKey(tr, name)
+	if err != nil {
+		return err
+	}
+	if k == nil {
+		return errKeyInSigner
+	}
+	sig, err := key.SignMessage(c, k, msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", sig)
+	return err
+}
+
+func cmdSignMessage(cmd *cobra.Command, args []string) {
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	tr := mustOpenTrade(cmd, args[0])
+	if err := signMessage(tr, args[1], args[2], out); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func verifyMessage(tr trade.Trade, name string, msg string, sig string, out io.Writer) error {
+	kd, c, err := traderKeyData(tr, name)
+	if err != nil {
+		return err
+	}
+	if err = key.VerifyMessage(c, kd, sig, msg); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "signature verified for %s key %s\n", name, kd.Hex())
+	return err
+}
+
+func cmdVerifyMessage(cmd *cobra.Command, args []string) {
+	out, closeOut := flagutil.MustOpenOutput(cmd.Flags())
+	defer closeOut()
+	tr := mustOpenTrade(cmd, args[0])
+	if err := verifyMessage(tr, args[1], args[2], args[3], out); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
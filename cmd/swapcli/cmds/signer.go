@@ -0,0 +1,122 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/signer"
+)
+
+var (
+	SignerCmd = &cobra.Command{
+		Use:   "signer <command>",
+		Short: "external signer commands",
+	}
+	initSignerCmd = &cobra.Command{
+		Use:     "init <keyfile>",
+		Short:   "create a new signer keystore with a random seed",
+		Aliases: []string{"i"},
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdInitSigner,
+	}
+	pubKeySignerCmd = &cobra.Command{
+		Use:     "pubkey <crypto> <path>",
+		Short:   "show the public key derived by a signer at the path",
+		Aliases: []string{"pub", "p"},
+		Args:    cobra.ExactArgs(2),
+		Run:     cmdPubKeySigner,
+	}
+	serveSignerCmd = &cobra.Command{
+		Use:     "serve <socket>",
+		Short:   "serve a signer keystore on a unix socket",
+		Aliases: []string{"s"},
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdServeSigner,
+	}
+)
+
+func init() {
+	flagutil.AddFlags(flagutil.FlagFuncMap{
+		pubKeySignerCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddKeySigner,
+			flagutil.AddOutput,
+		},
+		serveSignerCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddKeyFile,
+		},
+	})
+	cmdutil.AddCommands(SignerCmd, []*cobra.Command{
+		initSignerCmd,
+		pubKeySignerCmd,
+		serveSignerCmd,
+	})
+}
+
+var errMissingKeyFile = errors.New("missing key file")
+
+func cmdInitSigner(cmd *cobra.Command, args []string) {
+	ks, err := signer.NewKeystore()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	if err = ks.Save(args[0]); err != nil {
+		cmdutil.ErrorExit(exitcodes.CantCreateFile, err)
+	}
+}
+
+func cmdPubKeySigner(cmd *cobra.Command, args []string) {
+	fs := cmd.Flags()
+	c := mustParseCrypto(args[0])
+	spec := flagutil.MustKeySigner(fs)
+	if spec == "" {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, errMissingKeySigner)
+	}
+	s, err := signer.Open(spec)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	pub, err := s.PublicKey(c, &signer.KeyRef{Path: args[1]})
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	fmt.Fprintf(out, "%x\n", pub.SerializeCompressed())
+}
+
+func cmdServeSigner(cmd *cobra.Command, args []string) {
+	kf := flagutil.MustKeyFile(cmd.Flags())
+	if kf == "" {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, errMissingKeyFile)
+	}
+	if _, err := signer.OpenKeystore(kf); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	l, err := net.Listen("unix", args[0])
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		<-sig
+		close(done)
+		// closing the listener removes the socket file
+		l.Close()
+	}()
+	if err = signer.Serve(l, signer.NewFile(kf)); err != nil {
+		select {
+		case <-done:
+		default:
+			cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package cmds
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/tplutil"
+	"github.com/transmutate-io/atomicswap/psbt"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+var (
+	TxCmd = &cobra.Command{
+		Use:     "tx <command>",
+		Short:   "transaction commands",
+		Aliases: []string{"transaction"},
+	}
+	decodeTxCmd = &cobra.Command{
+		Use:     "decode <crypto>",
+		Short:   "decode a raw transaction (hex) from input",
+		Aliases: []string{"dec", "d"},
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdDecodeTx,
+	}
+	finalizeTxCmd = &cobra.Command{
+		Use:     "finalize <crypto>",
+		Short:   "finalize a signed psbt (base64) from input and output the raw transaction",
+		Aliases: []string{"fin", "f"},
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdFinalizeTx,
+	}
+)
+
+func init() {
+	flagutil.AddFlags(flagutil.FlagFuncMap{
+		decodeTxCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddInput,
+			flagutil.AddOutput,
+			flagutil.AddVerbose,
+			flagutil.AddFormat,
+		},
+		finalizeTxCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddInput,
+			flagutil.AddOutput,
+		},
+	})
+	cmdutil.AddCommands(TxCmd, []*cobra.Command{
+		decodeTxCmd,
+		finalizeTxCmd,
+	})
+}
+
+type txFee struct {
+	Value uint64
+	Rate  uint64
+}
+
+func txTemplateFuncs(c *cryptos.Crypto) template.FuncMap {
+	return template.FuncMap{
+		"amount": func(v uint64) types.Amount { return types.NewAmount(v, uint64(c.Decimals)) },
+		"disasm": func(s types.Bytes) string {
+			r, err := script.DisassembleString(c, s)
+			if err != nil {
+				return s.Hex()
+			}
+			return r
+		},
+	}
+}
+
+func mustOpenTxTemplate(fs *pflag.FlagSet, c *cryptos.Crypto) *template.Template {
+	return tplutil.MustOpenTemplate(fs, txDecodeTemplates, txTemplateFuncs(c))
+}
+
+// mustOpenDryRunTemplate opens the decode template for commands where the
+// format flag is not available
+func mustOpenDryRunTemplate(fs *pflag.FlagSet, c *cryptos.Crypto) *template.Template {
+	r, err := tplutil.OpenTemplate(
+		"",
+		flagutil.MustVerboseLevel(fs, len(txDecodeTemplates)-1),
+		txDecodeTemplates,
+		txTemplateFuncs(c),
+	)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.BadTemplate, err)
+	}
+	return r
+}
+
+// decodeTx writes the decoded transaction. If prevAmounts is not nil the fee
+// is shown
+func decodeTx(out io.Writer, tpl *template.Template, t tx.Tx, prevAmounts []uint64) error {
+	txUTXO, ok := t.TxUTXO()
+	if !ok {
+		return tx.ErrNotUTXO
+	}
+	size := t.SerializedSize()
+	data := tplutil.TemplateData{
+		"crypto": t.Crypto(),
+		"tx":     txUTXO,
+		"size":   size,
+		"fee":    nil,
+	}
+	if prevAmounts != nil {
+		fee, err := tx.Fee(txUTXO, prevAmounts)
+		if err != nil {
+			return err
+		}
+		data["fee"] = &txFee{Value: fee, Rate: fee / size}
+	}
+	return tpl.Execute(out, data)
+}
+
+func mustReadInput(fs *pflag.FlagSet) string {
+	in, closeIn := flagutil.MustOpenInput(fs)
+	defer closeIn()
+	b, err := ioutil.ReadAll(in)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.BadInput, err)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func cmdDecodeTx(cmd *cobra.Command, args []string) {
+	fs := cmd.Flags()
+	c := mustParseCrypto(args[0])
+	b, err := hex.DecodeString(mustReadInput(fs))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.BadInput, err)
+	}
+	t, err := tx.Parse(c, b)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.BadInput, err)
+	}
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	if err = decodeTx(out, mustOpenTxTemplate(fs, c), t, nil); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func cmdFinalizeTx(cmd *cobra.Command, args []string) {
+	fs := cmd.Flags()
+	p, err := psbt.ParseBase64(mustParseCrypto(args[0]), mustReadInput(fs))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.BadInput, err)
+	}
+	t, err := p.Finalize()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	b, err := t.Serialize()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	fmt.Fprintf(out, "%s\n", hex.EncodeToString(b))
+}
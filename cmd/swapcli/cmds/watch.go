@@ -1,22 +1,31 @@
 package cmds
 
 import (
-	"encoding/hex"
-	"errors"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
 	"github.com/transmutate-io/atomicswap/internal/cmdutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/metricsutil"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
+	"github.com/transmutate-io/atomicswap/internal/schedutil"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
 	"github.com/transmutate-io/atomicswap/internal/tplutil"
+	"github.com/transmutate-io/atomicswap/stages"
 	"github.com/transmutate-io/atomicswap/trade"
-	"github.com/transmutate-io/cryptocore"
-	"github.com/transmutate-io/cryptocore/types"
 )
 
 var (
@@ -45,6 +54,13 @@ var (
 		Args:    cobra.ExactArgs(1),
 		Run:     cmdWatchTraderDeposit,
 	}
+	watchAllCmd = &cobra.Command{
+		Use:     "all",
+		Short:   "watch every trade in a watchable state, inspecting each block once per crypto",
+		Aliases: []string{"a"},
+		Args:    cobra.NoArgs,
+		Run:     cmdWatchAll,
+	}
 	watchSecretTokenCmd = &cobra.Command{
 		Use:     "secret <trade_name>",
 		Short:   "watch for a deposit redeem and collect the secret token",
@@ -52,6 +68,20 @@ var (
 		Args:    cobra.ExactArgs(1),
 		Run:     cmdWatchSecretToken,
 	}
+	watchRedeemCmd = &cobra.Command{
+		Use:     "redeem <trade_name>",
+		Short:   "watch for the redeem of the trader deposit until it's confirmed",
+		Aliases: []string{"r"},
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdWatchRedeem,
+	}
+	watchLocksCmd = &cobra.Command{
+		Use:     "locks",
+		Short:   "warn before the lock times and recover the own funds once they are reached",
+		Aliases: []string{"lk"},
+		Args:    cobra.NoArgs,
+		Run:     cmdWatchLocks,
+	}
 )
 
 func init() {
@@ -65,9 +95,12 @@ func init() {
 		watchOwnDepositCmd.Flags(): []flagutil.FlagFunc{
 			flagutil.AddRPC,
 			flagutil.AddFirstBlock,
+			flagutil.AddChainQuery,
 			flagutil.AddFormat,
 			flagutil.AddVerbose,
 			flagutil.AddOutput,
+			flagutil.AddNotify,
+			flagutil.AddMetricsAddr,
 			network.AddFlag,
 			flagutil.AddIgnoreTarget,
 			flagutil.AddConfirmations,
@@ -75,9 +108,12 @@ func init() {
 		watchTraderDepositCmd.Flags(): []flagutil.FlagFunc{
 			flagutil.AddRPC,
 			flagutil.AddFirstBlock,
+			flagutil.AddChainQuery,
 			flagutil.AddFormat,
 			flagutil.AddVerbose,
 			flagutil.AddOutput,
+			flagutil.AddNotify,
+			flagutil.AddMetricsAddr,
 			network.AddFlag,
 			flagutil.AddIgnoreTarget,
 			flagutil.AddConfirmations,
@@ -85,18 +121,61 @@ func init() {
 		watchSecretTokenCmd.Flags(): []flagutil.FlagFunc{
 			flagutil.AddRPC,
 			flagutil.AddFirstBlock,
+			flagutil.AddChainQuery,
 			flagutil.AddFormat,
 			flagutil.AddVerbose,
 			flagutil.AddOutput,
+			flagutil.AddNotify,
+			flagutil.AddMetricsAddr,
 			network.AddFlag,
 			flagutil.AddIgnoreTarget,
 		},
+		watchRedeemCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddRPC,
+			flagutil.AddFirstBlock,
+			flagutil.AddChainQuery,
+			flagutil.AddFormat,
+			flagutil.AddVerbose,
+			flagutil.AddOutput,
+			flagutil.AddNotify,
+			flagutil.AddMetricsAddr,
+			network.AddFlag,
+			flagutil.AddConfirmations,
+		},
+		watchAllCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddClientsConfig,
+			flagutil.AddFirstBlock,
+			flagutil.AddChainQuery,
+			flagutil.AddFormat,
+			flagutil.AddVerbose,
+			flagutil.AddOutput,
+			flagutil.AddNotify,
+			flagutil.AddMetricsAddr,
+			network.AddFlag,
+			flagutil.AddConfirmations,
+		},
+		watchLocksCmd.Flags(): []flagutil.FlagFunc{
+			flagutil.AddClientsConfig,
+			flagutil.AddLockSchedule,
+			_fee.AddFlag,
+			flagutil.AddConfTarget,
+			flagutil.AddMaxFeeShare,
+			flagutil.AddAcceptFee,
+			flagutil.AddKeySigner,
+			flagutil.AddOutput,
+			flagutil.AddNotify,
+			flagutil.AddMetricsAddr,
+			network.AddFlag,
+		},
 	})
 	cmdutil.AddCommands(WatchCmd, []*cobra.Command{
 		listWatchableCmd,
 		watchOwnDepositCmd,
 		watchTraderDepositCmd,
 		watchSecretTokenCmd,
+		watchRedeemCmd,
+		watchAllCmd,
+		watchLocksCmd,
 	})
 }
 
@@ -115,24 +194,59 @@ func cmdListWatchable(cmd *cobra.Command, args []string) {
 	}
 }
 
-func containsString(s []string, v string) bool {
-	for _, i := range s {
-		if i == v {
-			return true
+func newSignalChan() (chan struct{}, func()) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, os.Kill)
+	closec := make(chan struct{})
+	donec := make(chan struct{})
+	go func() {
+		select {
+		case <-sig:
+			close(closec)
+		case <-donec:
 		}
+	}()
+	return closec, func() {
+		signal.Stop(sig)
+		close(donec)
 	}
-	return false
 }
 
-func outputID(tx []byte, n uint64) string { return fmt.Sprintf("%s:%d", hex.EncodeToString(tx), n) }
+// notifyEvents sends the events, showing the errors on the output
+func notifyEvents(out io.Writer, n *notifyutil.Notifier, evs ...*notifyutil.Event) {
+	for _, i := range evs {
+		if err := n.Notify(i); err != nil {
+			fmt.Fprintf(out, "can't notify %s: %s\n", i.Type, err)
+		}
+	}
+}
+
+// mustServeMetrics exposes the metrics if the address is set and returns a
+// function to stop the server
+func mustServeMetrics(cmd *cobra.Command) func() {
+	addr := flagutil.MustMetricsAddr(cmd.Flags())
+	if addr == "" {
+		return func() {}
+	}
+	td := tradesDir(cmd)
+	metricsutil.CollectTrades(func(f func(string, trade.Trade) error) error { return eachTrade(td, f) })
+	stop, err := metricsutil.Default.Serve(addr)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	return func() { stop() }
+}
 
 func watchDeposit(
+	name string,
+	n *notifyutil.Notifier,
 	tr trade.Trade,
 	wd *watchData,
 	out io.Writer,
 	depositTpl *template.Template,
 	blockTpl *template.Template,
-	cl cryptocore.Client,
+	sc *chainutil.Scanner,
+	closec <-chan struct{},
 	firstBlock uint64,
 	ignoreTarget bool,
 	confirmations uint64,
@@ -142,106 +256,54 @@ func watchDeposit(
 	tradeSave func(trade.Trade),
 	wdSave func(*watchData),
 ) error {
-	sig := make(chan os.Signal, 0)
-	signal.Notify(sig, os.Interrupt, os.Kill)
-	targetAmount := cryptoInfo.Amount.UInt64(cryptoInfo.Crypto.Decimals)
-	depositAddr, err := funds.Lock().Address(_network.MustNetwork(cryptoInfo.Crypto.Name))
-	if err != nil {
-		return err
+	dt := notifyutil.NewDepositTracker(name, cryptoInfo, confirmations)
+	target := "trader"
+	if bwd == wd.Own {
+		target = "own"
 	}
-	fmt.Printf("watching deposit address: %s\n", depositAddr)
-	outputs, ok := funds.Funds().([]*trade.Output)
-	if !ok {
-		return errors.New("not implemented")
-	}
-	outMap := make(map[string]uint64, len(outputs))
-	totalAmount := uint64(0)
-	for _, i := range outputs {
-		txID := outputID(i.TxID, uint64(i.N))
-		outMap[txID] = i.Amount
-		totalAmount += i.Amount
-		err := depositTpl.Execute(out, newOutputInfo(
-			"known output",
-			txID,
-			cryptoInfo.Crypto,
-			types.NewAmount(i.Amount, uint64(cryptoInfo.Crypto.Decimals)),
-			types.NewAmount(totalAmount, uint64(cryptoInfo.Crypto.Decimals)),
-			cryptoInfo.Amount,
-		))
-		if err != nil {
-			return err
-		}
-	}
-	if !ignoreTarget && totalAmount >= targetAmount {
-		return nil
-	}
-	bdc, errc, closeIter := iterateBlocks(cl, bwd, firstBlock)
-	defer closeIter()
-	var tradeChanged bool
-	for {
-		select {
-		case err := <-errc:
-			return err
-		case <-sig:
-			return nil
-		case bd := <-bdc:
-			if err := blockTpl.Execute(out, newBlockInfo(bd.height, len(bd.txs))); err != nil {
+	defer metricsutil.ForgetWatchData(name, target)
+	return chainutil.WatchDeposit(
+		sc,
+		_network.MustNetwork(cryptoInfo.Crypto.Name),
+		cryptoInfo,
+		funds,
+		bwd,
+		firstBlock,
+		ignoreTarget,
+		closec,
+		&chainutil.DepositHandlers{
+			Address: func(addr string) error {
+				_, err := fmt.Fprintf(out, "watching deposit address: %s\n", addr)
 				return err
-			}
-			for _, i := range bd.txs {
-				txUtxo, ok := i.UTXO()
-				if !ok {
-					return errors.New("not implemented")
-				}
-				for _, j := range txUtxo.Outputs() {
-					if !containsString(j.LockScript().Addresses(), depositAddr) {
-						continue
-					}
-					outID := outputID(i.ID(), uint64(j.N()))
-					if _, ok := outMap[outID]; ok {
-						continue
-					}
-					amount := j.Value().UInt64(cryptoInfo.Crypto.Decimals)
-					funds.AddFunds(&trade.Output{
-						TxID:   i.ID(),
-						N:      uint32(j.N()),
-						Amount: amount,
-					})
-					outMap[outID] = amount
-					totalAmount += amount
-					err = depositTpl.Execute(out, newOutputInfo(
-						"new output found",
-						outID,
-						cryptoInfo.Crypto,
-						j.Value(),
-						types.NewAmount(totalAmount, uint64(cryptoInfo.Crypto.Decimals)),
-						cryptoInfo.Amount,
-					))
-					if err != nil {
-						return err
-					}
-					tradeChanged = true
-					if !ignoreTarget && totalAmount >= targetAmount {
-						break
-					}
+			},
+			Block: func(bd *chainutil.BlockData) error {
+				notifyEvents(out, n, dt.Block(bd.Height)...)
+				return blockTpl.Execute(out, newBlockInfo(bd.Height, bd.TxCount))
+			},
+			Output: func(ev *chainutil.DepositEvent) error {
+				prefix := "known output"
+				if ev.New {
+					prefix = "new output found"
 				}
-			}
-			if bd.height > bwd.Top {
-				bwd.Top = bd.height
-			}
-			if bwd.Bottom == 0 || bd.height < bwd.Bottom {
-				bwd.Bottom = bd.height
-			}
-			wdSave(wd)
-			if tradeChanged {
-				tradeSave(tr)
-				tradeChanged = false
-			}
-			if !ignoreTarget && totalAmount >= targetAmount {
+				err := depositTpl.Execute(out, newOutputInfo(
+					prefix,
+					ev.ID,
+					cryptoInfo.Crypto,
+					ev.Amount,
+					ev.Total,
+					ev.Target,
+				))
+				notifyEvents(out, n, dt.Output(ev)...)
+				return err
+			},
+			SaveTrade: func() error { tradeSave(tr); return nil },
+			SaveWatchData: func() error {
+				metricsutil.ObserveWatchData(name, target, bwd.Top, bwd.Bottom)
+				wdSave(wd)
 				return nil
-			}
-		}
-	}
+			},
+		},
+	)
 }
 
 func cmdWatchDeposit(
@@ -257,19 +319,22 @@ func cmdWatchDeposit(
 	out, closeOut := flagutil.MustOpenOutput(fs)
 	defer closeOut()
 	cryptoInfo := selectCryptoInfo(tr)
+	defer mustServeMetrics(cmd)()
+	sc := mustNewScanner(fs, cryptoInfo.Crypto)
+	defer sc.Close()
+	closec, stop := newSignalChan()
+	defer stop()
+	n := flagutil.MustNotifier(fs)
 	err := watchDeposit(
+		tradeName,
+		n,
 		tr,
 		wd,
 		out,
 		tplutil.MustOpenTemplate(fs, depositChunkLogTemplates, nil),
 		tplutil.MustOpenTemplate(fs, blockInspectionTemplates, nil),
-		mustNewClient(
-			cryptoInfo.Crypto,
-			flagutil.MustRPCAddress(fs),
-			flagutil.MustRPCUsername(fs),
-			flagutil.MustRPCPassword(fs),
-			flagutil.MustRPCTLSConfig(fs),
-		),
+		sc,
+		closec,
 		flagutil.MustFirstBlock(fs),
 		flagutil.MustIgnoreTarget(fs),
 		flagutil.MustConfirmations(fs),
@@ -279,6 +344,8 @@ func cmdWatchDeposit(
 		func(t trade.Trade) { mustSaveTrade(cmd, tradeName, t) },
 		func(nwd *watchData) { saveWatchData(watchDataPath(cmd, tradeName), nwd) },
 	)
+	// the queued events are delivered before exiting
+	n.Close()
 	if err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
@@ -305,96 +372,375 @@ func cmdWatchTraderDeposit(cmd *cobra.Command, args []string) {
 	)
 }
 
-func newSecretTokenWatcher(cl cryptocore.Client, firstBlock uint64, wd *watchData, out io.Writer, blockTpl *template.Template, foundTpl *template.Template) func(trade.Trade) error {
-	return func(tr trade.Trade) error {
-		sig := make(chan os.Signal, 0)
-		signal.Notify(sig, os.Interrupt, os.Kill)
-		bdc, errc, closeIter := iterateBlocks(cl, wd.Own, firstBlock)
-		defer closeIter()
-		for {
-			select {
-			case <-sig:
-				return nil
-			case err := <-errc:
-				return err
-			case db := <-bdc:
-				if err := blockTpl.Execute(out, newBlockInfo(db.height, len(db.txs))); err != nil {
-					return err
-				}
-				for _, i := range db.txs {
-					token, err := extractToken(
-						tr.OwnInfo().Crypto,
-						i,
-						tr.RecoverableFunds().Lock(),
-					)
-					if err != nil {
-						return err
-					}
-					if token == nil {
-						continue
-					}
-					tr.SetToken(token)
-					return foundTpl.Execute(out, token)
-				}
-			}
+func watchSecretToken(
+	name string,
+	n *notifyutil.Notifier,
+	tr trade.Trade,
+	wd *watchData,
+	sc *chainutil.Scanner,
+	closec <-chan struct{},
+	firstBlock uint64,
+	out io.Writer,
+	blockTpl *template.Template,
+	foundTpl *template.Template,
+) error {
+	token, err := chainutil.WatchSecretToken(sc, tr, wd.Own, firstBlock, closec, func(bd *chainutil.BlockData) error {
+		return blockTpl.Execute(out, newBlockInfo(bd.Height, bd.TxCount))
+	})
+	if err != nil || token == nil {
+		return err
+	}
+	notifyEvents(out, n, &notifyutil.Event{
+		Type:   notifyutil.TokenRevealed,
+		Trade:  name,
+		Crypto: tr.OwnInfo().Crypto.Name,
+		Data:   map[string]interface{}{"token": token.Hex()},
+	})
+	return foundTpl.Execute(out, token)
+}
+
+func cmdWatchSecretToken(cmd *cobra.Command, args []string) {
+	tr := mustOpenTrade(cmd, args[0])
+	fs := cmd.Flags()
+	defer mustServeMetrics(cmd)()
+	sc := mustNewScanner(fs, tr.OwnInfo().Crypto)
+	defer sc.Close()
+	out, outClose := flagutil.MustOpenOutput(fs)
+	defer outClose()
+	blockTpl := tplutil.MustOpenTemplate(fs, blockInspectionTemplates, nil)
+	foundTpl, err := template.New("main").Parse("found token: {{ .Hex }}\n")
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	wd := mustOpenWatchData(cmd, args[0])
+	closec, stop := newSignalChan()
+	defer stop()
+	n := flagutil.MustNotifier(fs)
+	err = watchSecretToken(args[0], n, tr, wd, sc, closec, flagutil.MustFirstBlock(fs), out, blockTpl, foundTpl)
+	n.Close()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	mustSaveTrade(cmd, args[0], tr)
+}
+
+func watchRedeem(
+	name string,
+	n *notifyutil.Notifier,
+	tr trade.Trade,
+	sc *chainutil.Scanner,
+	closec <-chan struct{},
+	firstBlock uint64,
+	confirmations uint64,
+	out io.Writer,
+	blockTpl *template.Template,
+) error {
+	rc, err := chainutil.WatchRedeem(sc, tr, firstBlock, confirmations, closec, func(bd *chainutil.BlockData) error {
+		return blockTpl.Execute(out, newBlockInfo(bd.Height, bd.TxCount))
+	})
+	if err != nil || rc == nil {
+		return err
+	}
+	notifyEvents(out, n, &notifyutil.Event{
+		Type:   notifyutil.RedeemConfirmed,
+		Trade:  name,
+		Crypto: tr.TraderInfo().Crypto.Name,
+		Data:   map[string]interface{}{"txid": rc.TxID.Hex(), "height": rc.Height},
+	})
+	_, err = fmt.Fprintf(out, "redeem confirmed (tx id): %s\n", rc.TxID.Hex())
+	return err
+}
+
+func cmdWatchRedeem(cmd *cobra.Command, args []string) {
+	tr := mustOpenTrade(cmd, args[0])
+	fs := cmd.Flags()
+	defer mustServeMetrics(cmd)()
+	sc := mustNewScanner(fs, tr.TraderInfo().Crypto)
+	defer sc.Close()
+	out, outClose := flagutil.MustOpenOutput(fs)
+	defer outClose()
+	blockTpl := tplutil.MustOpenTemplate(fs, blockInspectionTemplates, nil)
+	closec, stop := newSignalChan()
+	defer stop()
+	n := flagutil.MustNotifier(fs)
+	err := watchRedeem(
+		args[0],
+		n,
+		tr,
+		sc,
+		closec,
+		flagutil.MustFirstBlock(fs),
+		flagutil.MustConfirmations(fs),
+		out,
+		blockTpl,
+	)
+	n.Close()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+// lineWriter writes whole lines prefixed with the trade name, sharing the
+// output between the watches of many trades
+type lineWriter struct {
+	mtx    *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(b), nil
 		}
+		w.mtx.Lock()
+		_, err := fmt.Fprintf(w.out, "%s: %s", w.prefix, w.buf[:i+1])
+		w.mtx.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
 	}
 }
 
-func watchSecretToken(tr trade.Trade, wd *watchData, cl cryptocore.Client, firstBlock uint64, out io.Writer, blockTpl *template.Template, foundTpl *template.Template) error {
-	sig := make(chan os.Signal, 0)
-	signal.Notify(sig, os.Interrupt, os.Kill)
-	bdc, errc, closeIter := iterateBlocks(cl, wd.Own, firstBlock)
-	defer closeIter()
+// watchAllTemplates holds the templates used to watch all the trades
+type watchAllTemplates struct {
+	deposit *template.Template
+	block   *template.Template
+	found   *template.Template
+}
+
+// watchTradeStages watches a trade while it's in a watchable stage
+func watchTradeStages(
+	cmd *cobra.Command,
+	name string,
+	tr trade.Trade,
+	n *notifyutil.Notifier,
+	scs *chainutil.Scanners,
+	closec <-chan struct{},
+	firstBlock uint64,
+	out io.Writer,
+	tpls *watchAllTemplates,
+) error {
+	wdPath := watchDataPath(cmd, name)
+	wd, err := openWatchData(wdPath)
+	if err != nil {
+		return err
+	}
+	var saveErr error
+	saveTradeFunc := func(t trade.Trade) {
+		if err := saveTrade(tradePath(cmd, name), t); err != nil {
+			saveErr = err
+		}
+	}
+	saveWDFunc := func(nwd *watchData) {
+		if err := saveWatchData(wdPath, nwd); err != nil {
+			saveErr = err
+		}
+	}
 	for {
-		select {
-		case <-sig:
+		var (
+			info  *trade.TraderInfo
+			bwd   *blockWatchData
+			funds trade.FundsData
+		)
+		stage := trade.CurrentStage(tr)
+		switch stage {
+		case stages.LockFunds:
+			info, bwd, funds = tr.OwnInfo(), wd.Own, tr.RecoverableFunds()
+		case stages.SendProposalResponse, stages.WaitLockedFunds:
+			info, bwd, funds = tr.TraderInfo(), wd.Trader, tr.RedeemableFunds()
+		case stages.WaitSecretToken:
+			info = tr.OwnInfo()
+		default:
 			return nil
-		case err := <-errc:
+		}
+		sc, err := scs.Scanner(info.Crypto)
+		if err != nil {
 			return err
-		case db := <-bdc:
-			if err := blockTpl.Execute(out, newBlockInfo(db.height, len(db.txs))); err != nil {
-				return err
-			}
-			for _, i := range db.txs {
-				token, err := extractToken(
-					tr.OwnInfo().Crypto,
-					i,
-					tr.RecoverableFunds().Lock(),
-				)
-				if err != nil {
-					return err
-				}
-				if token == nil {
-					continue
-				}
-				tr.SetToken(token)
-				return foundTpl.Execute(out, token)
+		}
+		if funds == nil {
+			err = watchSecretToken(name, n, tr, wd, sc, closec, firstBlock, out, tpls.block, tpls.found)
+			if err == nil {
+				err = saveTrade(tradePath(cmd, name), tr)
 			}
+		} else {
+			err = watchDeposit(name, n, tr, wd, out, tpls.deposit, tpls.block, sc, closec, firstBlock,
+				false, flagutil.MustConfirmations(cmd.Flags()), info, bwd, funds, saveTradeFunc, saveWDFunc)
+		}
+		if err != nil {
+			return err
+		}
+		if saveErr != nil {
+			return saveErr
+		}
+		if trade.CurrentStage(tr) == stage {
+			return nil
 		}
 	}
 }
 
-func cmdWatchSecretToken(cmd *cobra.Command, args []string) {
-	tr := mustOpenTrade(cmd, args[0])
-	fs := cmd.Flags()
-	cl := mustNewClient(
-		tr.OwnInfo().Crypto,
-		flagutil.MustRPCAddress(fs),
-		flagutil.MustRPCUsername(fs),
-		flagutil.MustRPCPassword(fs),
-		flagutil.MustRPCTLSConfig(fs),
+// watchAll watches all the trades in a watchable stage until they leave the
+// watchable stages or closec is closed
+func watchAll(
+	cmd *cobra.Command,
+	n *notifyutil.Notifier,
+	scs *chainutil.Scanners,
+	closec <-chan struct{},
+	firstBlock uint64,
+	out io.Writer,
+	tpls *watchAllTemplates,
+) error {
+	var (
+		wg     sync.WaitGroup
+		outMtx sync.Mutex
+		failed int
 	)
-	out, outClose := flagutil.MustOpenOutput(fs)
-	defer outClose()
-	blockTpl := tplutil.MustOpenTemplate(fs, blockInspectionTemplates, nil)
-	foundTpl, err := template.New("main").Parse("found token: {{ .Hex }}\n")
+	err := eachTrade(tradesDir(cmd), func(name string, tr trade.Trade) error {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := &lineWriter{mtx: &outMtx, out: out, prefix: name}
+			if err := watchTradeStages(cmd, name, tr, n, scs, closec, firstBlock, w, tpls); err != nil {
+				fmt.Fprintf(w, "can't watch trade: %s\n", err)
+				outMtx.Lock()
+				failed++
+				outMtx.Unlock()
+			}
+		}()
+		return nil
+	})
+	wg.Wait()
 	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d trades failed", failed)
+	}
+	return nil
+}
+
+// mustOpenClientsConfig opens the clients configuration from the flags
+func mustOpenClientsConfig(cmd *cobra.Command) map[string]*chainutil.ClientConfig {
+	cfgName := flagutil.MustClientsConfig(cmd.Flags())
+	if cfgName == "" {
+		cfgName = DEFAULT_CONSOLE_CONFIG_NAME
+	}
+	r, err := storeutil.New(dataDir(cmd)).OpenClientsConfig(cfgName)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantLoadConfig, err)
+	}
+	return r
+}
+
+func cmdWatchAll(cmd *cobra.Command, args []string) {
+	fs := cmd.Flags()
+	clients := mustOpenClientsConfig(cmd)
+	var err error
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	defer mustServeMetrics(cmd)()
+	tpls := &watchAllTemplates{
+		deposit: tplutil.MustOpenTemplate(fs, depositChunkLogTemplates, nil),
+		block:   tplutil.MustOpenTemplate(fs, blockInspectionTemplates, nil),
+	}
+	if tpls.found, err = template.New("main").Parse("found token: {{ .Hex }}\n"); err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
-	wd := mustOpenWatchData(cmd, args[0])
-	if err := watchSecretToken(tr, wd, cl, flagutil.MustFirstBlock(fs), out, blockTpl, foundTpl); err != nil {
+	scs := chainutil.NewScanners(func(c *cryptos.Crypto) (*chainutil.Scanner, error) {
+		cfg, ok := clients[c.Name]
+		if !ok {
+			return nil, fmt.Errorf("no client configured for %s", c.Name)
+		}
+		cl, err := newClient(c, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if fs.Changed("chainquery") {
+			cfgCopy := *cfg
+			cfgCopy.ChainQuery = flagutil.MustChainQuery(fs)
+			cfg = &cfgCopy
+		}
+		return cfg.NewScanner(c, cl)
+	})
+	defer scs.Close()
+	closec, stop := newSignalChan()
+	defer stop()
+	n := flagutil.MustNotifier(fs)
+	err = watchAll(cmd, n, scs, closec, flagutil.MustFirstBlock(fs), out, tpls)
+	n.Close()
+	if err != nil {
 		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
 	}
-	mustSaveTrade(cmd, args[0], tr)
+}
+
+// mustSchedulerConfig returns the lock scheduler configuration from the flags
+func mustSchedulerConfig(fs *pflag.FlagSet) *schedutil.Config {
+	r := &schedutil.Config{
+		Margins:           flagutil.MustLockMargins(fs),
+		RecoveryAddresses: flagutil.MustRecoverTo(fs),
+	}
+	if fs.Changed("fee") {
+		r.Fee = chainutil.Fee{Value: _fee.Value, Fixed: _fee.Fixed}
+	} else {
+		r.Fee = chainutil.Fee{Target: flagutil.MustConfTarget(fs)}
+	}
+	if !flagutil.MustAcceptFee(fs) {
+		r.MaxFeeShare = flagutil.MustMaxFeeShare(fs)
+	}
+	return r
+}
+
+func cmdWatchLocks(cmd *cobra.Command, args []string) {
+	fs := cmd.Flags()
+	sched := schedutil.New(mustSchedulerConfig(fs), mustOpenClientsConfig(cmd), _network)
+	n := flagutil.MustNotifier(fs)
+	defer n.Close()
+	out, closeOut := flagutil.MustOpenOutput(fs)
+	defer closeOut()
+	defer mustServeMetrics(cmd)()
+	td := tradesDir(cmd)
+	// the recoveries are signed with the key signer and the recovered trades
+	// are saved
+	signedTrades := func(f func(string, trade.Trade) error) error {
+		return eachTrade(td, func(name string, tr trade.Trade) error {
+			mustSetKeySigner(fs, tr)
+			finished := tr.Finished()
+			if err := f(name, tr); err != nil {
+				return err
+			}
+			if !finished && tr.Finished() {
+				return saveTrade(filepath.Join(td, name), tr)
+			}
+			return nil
+		})
+	}
+	closec, stop := newSignalChan()
+	defer stop()
+	sched.Run(signedTrades, flagutil.MustCheckInterval(fs), closec, func(evs []*notifyutil.Event, err error) {
+		for _, i := range evs {
+			fmt.Fprintf(out, "%s: %s %s\n", i.Trade, i.Type, formatEventData(i.Data))
+		}
+		notifyEvents(out, n, evs...)
+		if err != nil {
+			fmt.Fprintf(out, "can't check locks: %s\n", err)
+		}
+	})
+}
+
+// formatEventData formats the event data as sorted key=value pairs
+func formatEventData(data map[string]interface{}) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	r := make([]string, 0, len(keys))
+	for _, k := range keys {
+		r = append(r, fmt.Sprintf("%s=%v", k, data[k]))
+	}
+	return strings.Join(r, " ")
 }
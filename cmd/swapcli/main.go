@@ -8,6 +8,10 @@ import (
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/transmutate-io/atomicswap/cmd/swapcli/cmds"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
 )
 
 var (
@@ -16,6 +20,7 @@ var (
 		Short: "atomic swaps cli tool",
 		Long:  "swapcli is a command line tool to perform atomic swaps",
 	}
+	defaultDataDir string
 )
 
 func init() {
@@ -24,6 +29,7 @@ func init() {
 		fmt.Fprintf(os.Stderr, "can't get homedir: %v\n", err)
 		os.Exit(-1)
 	}
+	defaultDataDir = filepath.Join(hd, ".swapcli")
 	for _, i := range []*cobra.Command{
 		cmds.ListCryptosCmd,
 		cmds.AutoCompleteCmd,
@@ -33,15 +39,31 @@ func init() {
 		cmds.WatchCmd,
 		cmds.RedeemCmd,
 		cmds.RecoverCmd,
+		cmds.NegotiateCmd,
+		cmds.OfferCmd,
+		cmds.IdentityCmd,
+		cmds.KeysCmd,
+		cmds.SignerCmd,
+		cmds.SignCmd,
+		cmds.VerifyCmd,
+		cmds.TxCmd,
 		cmds.InteractiveConsoleCmd,
 	} {
 		rootCmd.AddCommand(i)
 	}
 	rootCmd.
 		PersistentFlags().
-		StringP("data", "D", filepath.Join(hd, ".swapcli"), "set datadir")
+		StringP("data", "D", defaultDataDir, "set datadir")
 }
 
 func main() {
+	dd := flagutil.Lookup(os.Args[1:], "data", "D", defaultDataDir)
+	cfg, err := storeutil.New(dd).OpenNetworksConfig()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantLoadConfig, err)
+	}
+	if err = flagutil.RegisterNetworks(cfg); err != nil {
+		cmdutil.ErrorExit(exitcodes.CantLoadConfig, err)
+	}
 	rootCmd.Execute()
 }
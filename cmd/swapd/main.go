@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/transmutate-io/atomicswap/cmd/swapd/server"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/metricsutil"
+	"github.com/transmutate-io/atomicswap/internal/schedutil"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
+)
+
+var (
+	rootCmd = &cobra.Command{
+		Use:   "swapd",
+		Short: "atomic swaps daemon",
+		Long:  "swapd is a daemon exposing atomic swaps through a local JSON-RPC api",
+		Args:  cobra.NoArgs,
+		Run:   cmdServe,
+	}
+	_network       = flagutil.NetworkFlag("mainnet")
+	_fee           = &flagutil.FeeFlag{}
+	defaultDataDir string
+)
+
+func init() {
+	hd, err := homedir.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't get homedir: %v\n", err)
+		os.Exit(-1)
+	}
+	defaultDataDir = filepath.Join(hd, ".swapcli")
+	fs := rootCmd.Flags()
+	fs.StringP("data", "D", defaultDataDir, "set datadir")
+	fs.StringP("listen", "l", "127.0.0.1:9753", "set the listen address")
+	fs.StringP("config", "c", storeutil.DEFAULT_CONFIG_NAME, "set the clients configuration file")
+	fs.StringP("auth-token-file", "a", "", "set the auth token file (default <datadir>/swapd/auth_token)")
+	_network.AddFlag(fs)
+	flagutil.AddNotify(fs)
+	flagutil.AddMetricsAddr(fs)
+	fs.Bool("schedule", false, "warn before the lock times and recover the funds with --recover-to")
+	flagutil.AddLockSchedule(fs)
+	_fee.AddFlag(fs)
+	flagutil.AddConfTarget(fs)
+	flagutil.AddMaxFeeShare(fs)
+}
+
+// mustSchedulerConfig returns the lock scheduler configuration or nil if the
+// scheduler isn't enabled
+func mustSchedulerConfig(fs *pflag.FlagSet) *schedutil.Config {
+	if !flagutil.MustBool(fs, "schedule") {
+		return nil
+	}
+	r := &schedutil.Config{
+		Margins:           flagutil.MustLockMargins(fs),
+		RecoveryAddresses: flagutil.MustRecoverTo(fs),
+		MaxFeeShare:       flagutil.MustMaxFeeShare(fs),
+	}
+	if fs.Changed("fee") {
+		r.Fee = chainutil.Fee{Value: _fee.Value, Fixed: _fee.Fixed}
+	} else {
+		r.Fee = chainutil.Fee{Target: flagutil.MustConfTarget(fs)}
+	}
+	return r
+}
+
+func cmdServe(cmd *cobra.Command, args []string) {
+	fs := cmd.Flags()
+	st := storeutil.New(flagutil.MustString(fs, "data"))
+	clients, err := st.OpenClientsConfig(flagutil.MustString(fs, "config"))
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantLoadConfig, err)
+	}
+	tokenPath := flagutil.MustString(fs, "auth-token-file")
+	if tokenPath == "" {
+		tokenPath = filepath.Join(st.Root, "swapd", "auth_token")
+	}
+	token, err := server.LoadAuthToken(tokenPath)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	identity, err := st.OpenIdentityKey()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	notifier := flagutil.MustNotifier(fs)
+	srv, err := server.New(&server.Config{
+		Store:            st,
+		Network:          _network,
+		AuthToken:        token,
+		Identity:         identity,
+		Clients:          clients,
+		Notifier:         notifier,
+		Schedule:         mustSchedulerConfig(fs),
+		ScheduleInterval: flagutil.MustCheckInterval(fs),
+	})
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+	if addr := flagutil.MustMetricsAddr(fs); addr != "" {
+		metricsutil.CollectTrades(st.EachTrade)
+		stopMetrics, err := metricsutil.Default.Serve(addr)
+		if err != nil {
+			cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+		}
+		defer stopMetrics()
+		fmt.Printf("metrics at http://%s/metrics\n", addr)
+	}
+	hs := &http.Server{Addr: flagutil.MustString(fs, "listen"), Handler: srv}
+	errc := make(chan error, 1)
+	go func() { errc <- hs.ListenAndServe() }()
+	fmt.Printf("listening on %s (auth token at %s)\n", hs.Addr, tokenPath)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	select {
+	case err = <-errc:
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	case <-sig:
+	}
+	srv.Close()
+	// the events of the stopped watchers are delivered
+	notifier.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = hs.Shutdown(ctx); err != nil {
+		cmdutil.ErrorExit(exitcodes.ExecutionError, err)
+	}
+}
+
+func main() {
+	dd := flagutil.Lookup(os.Args[1:], "data", "D", defaultDataDir)
+	cfg, err := storeutil.New(dd).OpenNetworksConfig()
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantLoadConfig, err)
+	}
+	if err = flagutil.RegisterNetworks(cfg); err != nil {
+		cmdutil.ErrorExit(exitcodes.CantLoadConfig, err)
+	}
+	rootCmd.Execute()
+}
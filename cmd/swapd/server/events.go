@@ -0,0 +1,73 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType represents the type of an event
+type EventType string
+
+// event types
+const (
+	EventStageChanged    EventType = "stage-changed"
+	EventDeposit         EventType = "deposit"
+	EventTokenFound      EventType = "token-found"
+	EventRedeemed        EventType = "redeemed"
+	EventRedeemConfirmed EventType = "redeem-confirmed"
+	EventRecovered       EventType = "recovered"
+	EventWatchStopped    EventType = "watch-stopped"
+	EventNotifyFailed    EventType = "notify-failed"
+	// the lock events of the scheduler
+	EventLockExpiring      EventType = "lock-expiring"
+	EventRecoveryAvailable EventType = "recovery-available"
+	EventLockCheckFailed   EventType = "lock-check-failed"
+)
+
+// Event represents a trade event
+type Event struct {
+	Time  time.Time              `json:"time"`
+	Type  EventType              `json:"type"`
+	Trade string                 `json:"trade"`
+	Stage string                 `json:"stage"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+type eventBus struct {
+	mtx  sync.Mutex
+	subs map[chan *Event]string
+}
+
+func newEventBus() *eventBus { return &eventBus{subs: make(map[chan *Event]string, 4)} }
+
+func (eb *eventBus) subscribe(tradeName string) (chan *Event, func()) {
+	c := make(chan *Event, 64)
+	eb.mtx.Lock()
+	eb.subs[c] = tradeName
+	eb.mtx.Unlock()
+	return c, func() {
+		eb.mtx.Lock()
+		defer eb.mtx.Unlock()
+		if _, ok := eb.subs[c]; ok {
+			delete(eb.subs, c)
+			close(c)
+		}
+	}
+}
+
+func (eb *eventBus) publish(ev *Event) {
+	eb.mtx.Lock()
+	defer eb.mtx.Unlock()
+	for c, name := range eb.subs {
+		if name != "" && name != ev.Trade {
+			continue
+		}
+		select {
+		case c <- ev:
+		default:
+			// slow subscriber, drop it
+			delete(eb.subs, c)
+			close(c)
+		}
+	}
+}
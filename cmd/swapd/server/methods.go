@@ -0,0 +1,481 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/envelope"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
+	"github.com/transmutate-io/atomicswap/roles"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+type methodFunc = func(*Server, json.RawMessage) (interface{}, error)
+
+var methods = map[string]methodFunc{
+	"trade.new":       (*Server).newTrade,
+	"trade.list":      (*Server).listTrades,
+	"trade.status":    (*Server).tradeStatus,
+	"proposal.export": (*Server).exportProposal,
+	"proposal.accept": (*Server).acceptProposal,
+	"lockset.export":  (*Server).exportLockSet,
+	"lockset.accept":  (*Server).acceptLockSet,
+	"watch.start":     (*Server).startWatch,
+	"watch.stop":      (*Server).stopWatch,
+	"watch.list":      (*Server).listWatches,
+	"redeem":          (*Server).redeem,
+	"recover":         (*Server).recover,
+}
+
+func parseCrypto(c string) (*cryptos.Crypto, error) {
+	if r, err := cryptos.ParseShort(c); err == nil {
+		return r, nil
+	}
+	return cryptos.Parse(c)
+}
+
+type nameParams struct {
+	Name string `json:"name"`
+}
+
+func (p *nameParams) check() error {
+	if p.Name == "" {
+		return invalidParams(errors.New("missing trade name"))
+	}
+	if err := storeutil.CheckTradeName(p.Name); err != nil {
+		return invalidParams(err)
+	}
+	return nil
+}
+
+func decodeNameParams(raw json.RawMessage, v interface {
+	check() error
+}) error {
+	if err := decodeParams(raw, v); err != nil {
+		return err
+	}
+	return v.check()
+}
+
+// updateTrade opens a trade, calls f and saves the trade if f succeeds
+func (s *Server) updateTrade(name string, f func(trade.Trade) error) (trade.Trade, error) {
+	if s.isWatchingDeposits(name) {
+		return nil, errWatching
+	}
+	lock := s.tradeLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+	tr, err := s.cfg.Store.OpenTrade(name)
+	if err != nil {
+		return nil, err
+	}
+	before := trade.CurrentStage(tr)
+	if err = f(tr); err != nil {
+		return nil, err
+	}
+	if err = s.cfg.Store.SaveTrade(name, tr); err != nil {
+		return nil, err
+	}
+	if after := trade.CurrentStage(tr); after != before {
+		s.publish(EventStageChanged, name, tr, map[string]interface{}{"previous": before.String()})
+	}
+	return tr, nil
+}
+
+func encodeYAML(v interface{}) (string, error) {
+	b := bytes.NewBuffer(make([]byte, 0, 1024))
+	if err := yaml.NewEncoder(b).Encode(v); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+type newTradeParams struct {
+	nameParams
+	OwnAmount    types.Amount `json:"own_amount"`
+	OwnCrypto    string       `json:"own_crypto"`
+	TraderAmount types.Amount `json:"trader_amount"`
+	TraderCrypto string       `json:"trader_crypto"`
+	Duration     string       `json:"duration"`
+}
+
+func (s *Server) newTrade(raw json.RawMessage) (interface{}, error) {
+	p := &newTradeParams{}
+	if err := decodeNameParams(raw, p); err != nil {
+		return nil, err
+	}
+	ownCrypto, err := parseCrypto(p.OwnCrypto)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	traderCrypto, err := parseCrypto(p.TraderCrypto)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	dur, err := time.ParseDuration(p.Duration)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	tr, err := trade.NewOnChainTrade(p.OwnAmount, ownCrypto, p.TraderAmount, traderCrypto, dur)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.cfg.Store.CreateTrade(p.Name, tr); err != nil {
+		return nil, err
+	}
+	s.publish(EventStageChanged, p.Name, tr, nil)
+	return newTradeStatus(p.Name, tr, s.cfg.Network), nil
+}
+
+func (s *Server) listTrades(raw json.RawMessage) (interface{}, error) {
+	r := make([]*TradeStatus, 0, 16)
+	err := s.cfg.Store.EachTrade(func(name string, tr trade.Trade) error {
+		r = append(r, newTradeStatus(name, tr, s.cfg.Network))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *Server) tradeStatus(raw json.RawMessage) (interface{}, error) {
+	p := &nameParams{}
+	if err := decodeNameParams(raw, p); err != nil {
+		return nil, err
+	}
+	tr, err := s.cfg.Store.OpenTrade(p.Name)
+	if err != nil {
+		return nil, err
+	}
+	return newTradeStatus(p.Name, tr, s.cfg.Network), nil
+}
+
+type exportParams struct {
+	nameParams
+	Expiry string `json:"expiry"`
+}
+
+func (p *exportParams) expiry() (time.Time, error) {
+	if p.Expiry == "" {
+		return time.Now().Add(defaultMessageExpiry), nil
+	}
+	d, err := time.ParseDuration(p.Expiry)
+	if err != nil {
+		return time.Time{}, invalidParams(err)
+	}
+	return time.Now().Add(d), nil
+}
+
+const defaultMessageExpiry = time.Hour
+
+func (s *Server) seal(typ string, sessionID []byte, expiry time.Time, payload interface{}) (string, error) {
+	env, err := envelope.New(typ, sessionID, expiry, payload)
+	if err != nil {
+		return "", err
+	}
+	if err = env.Sign(s.cfg.Identity); err != nil {
+		return "", err
+	}
+	return encodeYAML(env)
+}
+
+func (s *Server) exportProposal(raw json.RawMessage) (interface{}, error) {
+	p := &exportParams{}
+	if err := decodeNameParams(raw, p); err != nil {
+		return nil, err
+	}
+	expiry, err := p.expiry()
+	if err != nil {
+		return nil, err
+	}
+	var prop *trade.BuyProposal
+	tr, err := s.updateTrade(p.Name, func(tr trade.Trade) error {
+		if tr.Role() != roles.Buyer {
+			return errors.New("not a buyer")
+		}
+		btr, err := tr.Buyer()
+		if err != nil {
+			return err
+		}
+		if prop, err = btr.GenerateBuyProposal(); err != nil {
+			return err
+		}
+		if len(tr.SessionID()) == 0 {
+			sid, err := envelope.NewSessionID()
+			if err != nil {
+				return err
+			}
+			tr.SetSessionID(sid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.seal(envelope.TypeProposal, tr.SessionID(), expiry, prop)
+}
+
+type acceptParams struct {
+	nameParams
+	Data          string      `json:"data"`
+	AllowUnsigned bool        `json:"allow_unsigned"`
+	AllowExpired  bool        `json:"allow_expired"`
+	Signer        types.Bytes `json:"signer"`
+}
+
+// open opens the message. The signer must match the known peer signer
+func (p *acceptParams) open(typ string, sessionID []byte, peerSigner []byte) (*envelope.Envelope, error) {
+	signer, err := envelope.PeerSigner(p.Signer, peerSigner)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	r, err := envelope.Open([]byte(p.Data), typ, &envelope.Options{
+		AllowUnsigned: p.AllowUnsigned,
+		AllowExpired:  p.AllowExpired,
+		SessionID:     sessionID,
+		Signer:        signer,
+	})
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	return r, nil
+}
+
+func (s *Server) acceptProposal(raw json.RawMessage) (interface{}, error) {
+	p := &acceptParams{}
+	if err := decodeNameParams(raw, p); err != nil {
+		return nil, err
+	}
+	env, err := p.open(envelope.TypeProposal, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := trade.UnamrshalBuyProposal([]byte(env.Payload))
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	if !p.AllowExpired && prop.Expired(time.Now()) {
+		return nil, invalidParams(trade.ErrProposalExpired)
+	}
+	tr, err := trade.AcceptProposal(prop)
+	if err != nil {
+		return nil, err
+	}
+	tr.SetSessionID(env.SessionID)
+	tr.SetPeerSigner(env.Signer)
+	if err = s.cfg.Store.CreateTrade(p.Name, tr); err != nil {
+		return nil, err
+	}
+	s.publish(EventStageChanged, p.Name, tr, nil)
+	return newTradeStatus(p.Name, tr, s.cfg.Network), nil
+}
+
+func (s *Server) exportLockSet(raw json.RawMessage) (interface{}, error) {
+	p := &exportParams{}
+	if err := decodeNameParams(raw, p); err != nil {
+		return nil, err
+	}
+	expiry, err := p.expiry()
+	if err != nil {
+		return nil, err
+	}
+	tr, err := s.cfg.Store.OpenTrade(p.Name)
+	if err != nil {
+		return nil, err
+	}
+	str, err := tr.Seller()
+	if err != nil {
+		return nil, err
+	}
+	return s.seal(envelope.TypeLockSet, tr.SessionID(), expiry, str.Locks())
+}
+
+func (s *Server) acceptLockSet(raw json.RawMessage) (interface{}, error) {
+	p := &acceptParams{}
+	if err := decodeNameParams(raw, p); err != nil {
+		return nil, err
+	}
+	tr, err := s.updateTrade(p.Name, func(tr trade.Trade) error {
+		btr, err := tr.Buyer()
+		if err != nil {
+			return err
+		}
+		env, err := p.open(envelope.TypeLockSet, tr.SessionID(), tr.PeerSigner())
+		if err != nil {
+			return err
+		}
+		if len(tr.PeerSigner()) == 0 && env.IsSigned() {
+			tr.SetPeerSigner(env.Signer)
+		}
+		ls, err := trade.UnamrshalLocks(tr.OwnInfo().Crypto, tr.TraderInfo().Crypto, []byte(env.Payload))
+		if err != nil {
+			return invalidParams(err)
+		}
+		return btr.SetLocks(ls)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newTradeStatus(p.Name, tr, s.cfg.Network), nil
+}
+
+type watchParams struct {
+	nameParams
+	Target       string `json:"target"`
+	FirstBlock   uint64 `json:"first_block"`
+	IgnoreTarget bool   `json:"ignore_target"`
+	// Confirmations are required by the deposit-confirmed and the
+	// redeem-confirmed events
+	Confirmations uint64 `json:"confirmations"`
+}
+
+func (s *Server) startWatch(raw json.RawMessage) (interface{}, error) {
+	p := &watchParams{}
+	if err := decodeNameParams(raw, p); err != nil {
+		return nil, err
+	}
+	if err := s.startWatcher(p.Name, p.Target, p.FirstBlock, p.Confirmations, p.IgnoreTarget); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+func (s *Server) stopWatch(raw json.RawMessage) (interface{}, error) {
+	p := &watchParams{}
+	if err := decodeNameParams(raw, p); err != nil {
+		return nil, err
+	}
+	s.mtx.Lock()
+	w, ok := s.watchers[watcherKey{Trade: p.Name, Target: p.Target}]
+	s.mtx.Unlock()
+	if !ok {
+		return false, nil
+	}
+	w.stop()
+	return true, nil
+}
+
+// WatchInfo represents an active watcher
+type WatchInfo struct {
+	Trade  string `json:"trade"`
+	Target string `json:"target"`
+}
+
+func (s *Server) listWatches(raw json.RawMessage) (interface{}, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r := make([]*WatchInfo, 0, len(s.watchers))
+	for k := range s.watchers {
+		r = append(r, &WatchInfo{Trade: k.Trade, Target: k.Target})
+	}
+	return r, nil
+}
+
+type spendParams struct {
+	nameParams
+	Address    string `json:"address"`
+	Fee        uint64 `json:"fee"`
+	FixedFee   bool   `json:"fixed_fee"`
+	ConfTarget uint64 `json:"conf_target"`
+}
+
+// SpendResult is the result of a redeem or recover
+type SpendResult struct {
+	TxID    string `json:"txid"`
+	Fee     uint64 `json:"fee"`
+	FeeRate uint64 `json:"fee_rate"`
+}
+
+func (s *Server) spend(raw json.RawMessage, redeem bool) (interface{}, error) {
+	p := &spendParams{}
+	if err := decodeNameParams(raw, p); err != nil {
+		return nil, err
+	}
+	if p.Address == "" {
+		return nil, invalidParams(errors.New("missing address"))
+	}
+	lock := s.tradeLock(p.Name)
+	lock.Lock()
+	defer lock.Unlock()
+	tr, err := s.cfg.Store.OpenTrade(p.Name)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		info       *trade.TraderInfo
+		fd         trade.FundsData
+		spendFunc  = chainutil.RecoveryTx
+		evType     = EventRecovered
+		notifyType = notifyutil.RecoveryBroadcast
+	)
+	if redeem {
+		info, fd, spendFunc, evType = tr.TraderInfo(), tr.RedeemableFunds(), chainutil.RedeemTx, EventRedeemed
+		notifyType = notifyutil.RedeemBroadcast
+	} else {
+		info, fd = tr.OwnInfo(), tr.RecoverableFunds()
+	}
+	chain, err := s.cfg.Network.Network(info.Crypto.Name)
+	if err != nil {
+		return nil, err
+	}
+	cl, err := s.newClient(info.Crypto)
+	if err != nil {
+		return nil, err
+	}
+	defer chainutil.CloseClient(cl)
+	// without a fee, the fee is estimated for the confirmation target
+	fee := &chainutil.Fee{Value: p.Fee, Fixed: p.FixedFee, Target: p.ConfTarget}
+	feeValue, fixedFee, err := fee.Resolve(info.Crypto, s.cfg.Clients[info.Crypto.Name])
+	if err != nil {
+		return nil, err
+	}
+	t, err := spendFunc(tr, chain, p.Address, feeValue, fixedFee)
+	if err != nil {
+		return nil, err
+	}
+	b, err := t.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	txUTXO, ok := t.TxUTXO()
+	if !ok {
+		return nil, tx.ErrNotUTXO
+	}
+	paid, err := tx.Fee(txUTXO, chainutil.FundsAmounts(fd))
+	if err != nil {
+		return nil, err
+	}
+	txID, err := cl.SendRawTransaction(b)
+	if err != nil {
+		return nil, err
+	}
+	tr.Finish()
+	if err = s.cfg.Store.SaveTrade(p.Name, tr); err != nil {
+		return nil, err
+	}
+	s.publish(evType, p.Name, tr, map[string]interface{}{"txid": txID.Hex()})
+	s.notify(tr, &notifyutil.Event{
+		Type:   notifyType,
+		Trade:  p.Name,
+		Crypto: info.Crypto.Name,
+		Data:   map[string]interface{}{"txid": txID.Hex()},
+	})
+	return &SpendResult{
+		TxID:    txID.Hex(),
+		Fee:     paid,
+		FeeRate: paid / t.SerializedSize(),
+	}, nil
+}
+
+func (s *Server) redeem(raw json.RawMessage) (interface{}, error) { return s.spend(raw, true) }
+
+func (s *Server) recover(raw json.RawMessage) (interface{}, error) { return s.spend(raw, false) }
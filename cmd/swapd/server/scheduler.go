@@ -0,0 +1,71 @@
+package server
+
+import (
+	"time"
+
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
+	"github.com/transmutate-io/atomicswap/internal/schedutil"
+	"github.com/transmutate-io/atomicswap/trade"
+)
+
+// the scheduler events are published as
+var scheduleEvents = map[notifyutil.EventType]EventType{
+	notifyutil.LockExpiring:      EventLockExpiring,
+	notifyutil.RecoveryAvailable: EventRecoveryAvailable,
+	notifyutil.RecoveryBroadcast: EventRecovered,
+}
+
+// startScheduler checks the trades locks at every interval until the server
+// is closed
+func (s *Server) startScheduler() {
+	sched := schedutil.New(s.cfg.Schedule, s.cfg.Clients, s.cfg.Network)
+	interval := s.cfg.ScheduleInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		defer close(s.scheduler.donec)
+		sched.Run(s.eachLockedTrade, interval, s.scheduler.closec, s.scheduled)
+	}()
+}
+
+// eachLockedTrade calls f for each trade while holding the trade lock. The
+// trades are reopened under the lock, the listed ones can be outdated. The
+// trades finished by f are saved
+func (s *Server) eachLockedTrade(f func(string, trade.Trade) error) error {
+	return s.cfg.Store.EachTrade(func(name string, _ trade.Trade) error {
+		lock := s.tradeLock(name)
+		lock.Lock()
+		defer lock.Unlock()
+		tr, err := s.cfg.Store.OpenTrade(name)
+		if err != nil {
+			return err
+		}
+		finished := tr.Finished()
+		if err := f(name, tr); err != nil {
+			return err
+		}
+		if !finished && tr.Finished() {
+			return s.cfg.Store.SaveTrade(name, tr)
+		}
+		return nil
+	})
+}
+
+func (s *Server) scheduled(evs []*notifyutil.Event, err error) {
+	for _, i := range evs {
+		tr, terr := s.cfg.Store.OpenTrade(i.Trade)
+		if terr != nil {
+			continue
+		}
+		s.publish(scheduleEvents[i.Type], i.Trade, tr, i.Data)
+		s.notify(tr, i)
+	}
+	if err != nil {
+		s.events.publish(&Event{
+			Time: time.Now().UTC(),
+			Type: EventLockCheckFailed,
+			Data: map[string]interface{}{"error": err.Error()},
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
+	"github.com/transmutate-io/atomicswap/internal/schedutil"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore"
+)
+
+// Config holds the server configuration
+type Config struct {
+	Store     *storeutil.Store
+	Network   flagutil.NetworkFlag
+	AuthToken string
+	Identity  key.Private
+	Clients   map[string]*chainutil.ClientConfig
+	// Notifier receives the trade events, optional
+	Notifier *notifyutil.Notifier
+	// Schedule enables the lock checks and the automatic recoveries, optional
+	Schedule *schedutil.Config
+	// ScheduleInterval is the interval between the lock checks, one minute
+	// if not set
+	ScheduleInterval time.Duration
+}
+
+// Server is the swapd api server
+type Server struct {
+	cfg       *Config
+	events    *eventBus
+	mtx       sync.Mutex
+	locks     map[string]*sync.Mutex
+	watchers  map[watcherKey]*watcher
+	scanners  *chainutil.Scanners
+	scheduler *watcher
+}
+
+// New returns a new server
+func New(cfg *Config) (*Server, error) {
+	if cfg.AuthToken == "" {
+		return nil, errors.New("missing auth token")
+	}
+	if cfg.Identity == nil {
+		return nil, errors.New("missing identity key")
+	}
+	if cfg.Clients == nil {
+		cfg.Clients = map[string]*chainutil.ClientConfig{}
+	}
+	r := &Server{
+		cfg:      cfg,
+		events:   newEventBus(),
+		locks:    make(map[string]*sync.Mutex, 16),
+		watchers: make(map[watcherKey]*watcher, 16),
+	}
+	r.scanners = chainutil.NewScanners(r.newScanner)
+	cfg.Notifier.OnError(r.notifyFailed)
+	if cfg.Schedule != nil {
+		r.scheduler = newWatcher()
+		r.startScheduler()
+	}
+	return r, nil
+}
+
+// Close stops all the watchers and the chain scanners
+func (s *Server) Close() {
+	s.mtx.Lock()
+	ws := make([]*watcher, 0, len(s.watchers))
+	for _, w := range s.watchers {
+		ws = append(ws, w)
+	}
+	s.mtx.Unlock()
+	for _, w := range ws {
+		w.stop()
+	}
+	if s.scheduler != nil {
+		s.scheduler.stop()
+	}
+	s.scanners.Close()
+}
+
+func (s *Server) tradeLock(name string) *sync.Mutex {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r, ok := s.locks[name]
+	if !ok {
+		r = &sync.Mutex{}
+		s.locks[name] = r
+	}
+	return r
+}
+
+func (s *Server) newClient(c *cryptos.Crypto) (cryptocore.Client, error) {
+	cfg, ok := s.cfg.Clients[c.Name]
+	if !ok {
+		return nil, errors.New("no client configured for " + c.Name)
+	}
+	cl, err := cfg.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := s.cfg.Network.Network(c.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err = chainutil.CheckNetwork(cl, c, chain); err != nil {
+		chainutil.CloseClient(cl)
+		return nil, err
+	}
+	return cl, nil
+}
+
+func (s *Server) newScanner(c *cryptos.Crypto) (*chainutil.Scanner, error) {
+	cl, err := s.newClient(c)
+	if err != nil {
+		return nil, err
+	}
+	return s.cfg.Clients[c.Name].NewScanner(c, cl)
+}
+
+func (s *Server) publish(evType EventType, name string, tr trade.Trade, data map[string]interface{}) {
+	s.events.publish(&Event{
+		Time:  time.Now().UTC(),
+		Type:  evType,
+		Trade: name,
+		Stage: trade.CurrentStage(tr).String(),
+		Data:  data,
+	})
+}
+
+// notify queues the events in the notifier. The failures are published
+func (s *Server) notify(tr trade.Trade, evs ...*notifyutil.Event) {
+	for _, i := range evs {
+		if err := s.cfg.Notifier.Notify(i); err != nil {
+			s.publish(EventNotifyFailed, i.Trade, tr, map[string]interface{}{
+				"event": string(i.Type),
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// notifyFailed publishes the events the notifier failed to deliver
+func (s *Server) notifyFailed(ev *notifyutil.Event, err error) {
+	r := &Event{
+		Time:  time.Now().UTC(),
+		Type:  EventNotifyFailed,
+		Trade: ev.Trade,
+		Data: map[string]interface{}{
+			"event": string(ev.Type),
+			"error": err.Error(),
+		},
+	}
+	if tr, err := s.cfg.Store.OpenTrade(ev.Trade); err == nil {
+		r.Stage = trade.CurrentStage(tr).String()
+	}
+	s.events.publish(r)
+}
+
+// ServeHTTP implement http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/rpc":
+		s.serveRPC(w, r)
+	case "/events":
+		s.serveEvents(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	h := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(h, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(h[len(prefix):]), []byte(s.cfg.AuthToken)) == 1
+}
+
+func (s *Server) serveRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	resp := &Response{Version: jsonRPCVersion}
+	req := &Request{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		resp.Error = newError(CodeParseError, err.Error())
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	resp.ID = req.ID
+	if req.Version != jsonRPCVersion || req.Method == "" {
+		resp.Error = newError(CodeInvalidRequest, "invalid request")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	h, ok := methods[req.Method]
+	if !ok {
+		resp.Error = newError(CodeMethodNotFound, "method not found: "+req.Method)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	res, err := h(s, req.Params)
+	if err != nil {
+		resp.Error = executionError(err)
+	} else {
+		resp.Result = res
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	evc, unsubscribe := s.events.subscribe(r.URL.Query().Get("trade"))
+	defer unsubscribe()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	fl.Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-evc:
+			if !ok {
+				return
+			}
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+			fl.Flush()
+		}
+	}
+}
+
+const authTokenSize = 32
+
+// LoadAuthToken loads the auth token from a file, creating a new random one if
+// it doesn't exist
+func LoadAuthToken(p string) (string, error) {
+	b, err := ioutil.ReadFile(p)
+	if err == nil {
+		return strings.TrimSpace(string(b)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	tb := make([]byte, authTokenSize)
+	if _, err = rand.Read(tb); err != nil {
+		return "", err
+	}
+	r := hex.EncodeToString(tb)
+	if err = os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return "", err
+	}
+	if err = ioutil.WriteFile(p, []byte(r+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return r, nil
+}
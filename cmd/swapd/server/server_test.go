@@ -0,0 +1,306 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/envelope"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+const testToken = "token"
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server, func()) {
+	dir, err := ioutil.TempDir("", "swapd")
+	require.NoError(t, err, "can't create temp dir")
+	id, err := envelope.NewIdentityKey()
+	require.NoError(t, err, "can't create identity key")
+	s, err := New(&Config{
+		Store:     storeutil.New(dir),
+		Network:   "mainnet",
+		AuthToken: testToken,
+		Identity:  id,
+	})
+	require.NoError(t, err, "can't create server")
+	hs := httptest.NewServer(s)
+	return s, hs, func() {
+		hs.Close()
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func newTestRequest(t *testing.T, method, url string, body []byte, token string) *http.Request {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	require.NoError(t, err, "can't create request")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func call(t *testing.T, hs *httptest.Server, body string) *Response {
+	resp, err := hs.Client().Do(newTestRequest(t, http.MethodPost, hs.URL+"/rpc", []byte(body), testToken))
+	require.NoError(t, err, "can't post request")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "status mismatch")
+	r := &Response{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(r), "can't decode response")
+	return r
+}
+
+func callMethod(t *testing.T, hs *httptest.Server, method string, params interface{}) *Response {
+	b, err := json.Marshal(params)
+	require.NoError(t, err, "can't marshal params")
+	req, err := json.Marshal(&Request{Version: jsonRPCVersion, ID: json.RawMessage("1"), Method: method, Params: b})
+	require.NoError(t, err, "can't marshal request")
+	return call(t, hs, string(req))
+}
+
+func testTradeParams(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          name,
+		"own_amount":    1.5,
+		"own_crypto":    "btc",
+		"trader_amount": 100.5,
+		"trader_crypto": "ltc",
+		"duration":      "48h",
+	}
+}
+
+func TestAuth(t *testing.T) {
+	_, hs, cleanup := newTestServer(t)
+	defer cleanup()
+	for _, i := range []struct {
+		token  string
+		path   string
+		status int
+	}{
+		{"", "/rpc", http.StatusUnauthorized},
+		{"other", "/rpc", http.StatusUnauthorized},
+		{testToken, "/rpc", http.StatusMethodNotAllowed},
+		{testToken, "/other", http.StatusNotFound},
+	} {
+		resp, err := hs.Client().Do(newTestRequest(t, http.MethodGet, hs.URL+i.path, nil, i.token))
+		require.NoError(t, err, "can't get")
+		resp.Body.Close()
+		require.Equal(t, i.status, resp.StatusCode, "status mismatch")
+	}
+}
+
+func TestRPC(t *testing.T) {
+	_, hs, cleanup := newTestServer(t)
+	defer cleanup()
+	for _, i := range []struct {
+		body string
+		code int
+	}{
+		{`{`, CodeParseError},
+		{`{"jsonrpc":"1.0","id":1,"method":"trade.list"}`, CodeInvalidRequest},
+		{`{"jsonrpc":"2.0","id":1}`, CodeInvalidRequest},
+		{`{"jsonrpc":"2.0","id":1,"method":"other"}`, CodeMethodNotFound},
+		{`{"jsonrpc":"2.0","id":1,"method":"trade.status","params":{}}`, CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":1,"method":"trade.status","params":[]}`, CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":1,"method":"trade.status","params":{"name":"none"}}`, CodeExecutionError},
+	} {
+		r := call(t, hs, i.body)
+		require.NotNil(t, r.Error, "expecting an error")
+		require.Equal(t, i.code, r.Error.Code, "error code mismatch: %s", i.body)
+	}
+	r := callMethod(t, hs, "trade.new", testTradeParams("trade1"))
+	require.Nil(t, r.Error, "can't create trade")
+	require.Equal(t, json.RawMessage("1"), r.ID, "id mismatch")
+	r = callMethod(t, hs, "trade.new", testTradeParams("trade1"))
+	require.NotNil(t, r.Error, "expecting an existing trade")
+	for _, i := range []string{"../trade2", "/tmp/trade2", "a/../trade2"} {
+		r = callMethod(t, hs, "trade.new", testTradeParams(i))
+		require.NotNil(t, r.Error, "expecting an invalid name: %s", i)
+		require.Equal(t, CodeInvalidParams, r.Error.Code, "error code mismatch: %s", i)
+	}
+	r = callMethod(t, hs, "trade.list", nil)
+	require.Nil(t, r.Error, "can't list trades")
+	trades := r.Result.([]interface{})
+	require.Len(t, trades, 1, "trades mismatch")
+	require.Equal(t, "trade1", trades[0].(map[string]interface{})["name"], "name mismatch")
+	r = callMethod(t, hs, "trade.status", map[string]interface{}{"name": "trade1"})
+	require.Nil(t, r.Error, "can't get status")
+	st := r.Result.(map[string]interface{})
+	require.Equal(t, "bitcoin", st["own"].(map[string]interface{})["crypto"], "crypto mismatch")
+	require.Equal(t, "litecoin", st["trader"].(map[string]interface{})["crypto"], "crypto mismatch")
+	r = callMethod(t, hs, "redeem", map[string]interface{}{"name": "trade1"})
+	require.NotNil(t, r.Error, "expecting an error")
+	require.Equal(t, CodeInvalidParams, r.Error.Code, "expecting a missing address")
+}
+
+func TestEvents(t *testing.T) {
+	_, hs, cleanup := newTestServer(t)
+	defer cleanup()
+	resp, err := hs.Client().Do(newTestRequest(t, http.MethodGet, hs.URL+"/events?trade=trade2", nil, testToken))
+	require.NoError(t, err, "can't get events")
+	defer resp.Body.Close()
+	require.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"), "content type mismatch")
+	evc := make(chan *Event, 4)
+	go func() {
+		s := bufio.NewScanner(resp.Body)
+		for s.Scan() {
+			ev := &Event{}
+			if err := json.Unmarshal(s.Bytes(), ev); err == nil {
+				evc <- ev
+			}
+		}
+		close(evc)
+	}()
+	// filtered by trade
+	for _, i := range []string{"trade1", "trade2"} {
+		r := callMethod(t, hs, "trade.new", testTradeParams(i))
+		require.Nil(t, r.Error, "can't create trade")
+	}
+	select {
+	case ev := <-evc:
+		require.Equal(t, "trade2", ev.Trade, "trade mismatch")
+		require.Equal(t, EventStageChanged, ev.Type, "event mismatch")
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no events received")
+	}
+}
+
+func TestTradeLock(t *testing.T) {
+	s, hs, cleanup := newTestServer(t)
+	defer cleanup()
+	require.True(t, s.tradeLock("trade1") == s.tradeLock("trade1"), "expecting the same lock")
+	require.False(t, s.tradeLock("trade1") == s.tradeLock("trade2"), "expecting different locks")
+	r := callMethod(t, hs, "trade.new", testTradeParams("trade1"))
+	require.Nil(t, r.Error, "can't create trade")
+	// the trades can't be updated while the deposits are watched
+	s.mtx.Lock()
+	s.watchers[watcherKey{Trade: "trade1", Target: WatchOwn}] = newWatcher()
+	s.mtx.Unlock()
+	_, err := s.updateTrade("trade1", func(trade.Trade) error { return nil })
+	require.Equal(t, errWatching, err, "expecting a watched trade")
+	s.mtx.Lock()
+	delete(s.watchers, watcherKey{Trade: "trade1", Target: WatchOwn})
+	s.mtx.Unlock()
+	_, err = s.updateTrade("trade1", func(trade.Trade) error { return nil })
+	require.NoError(t, err, "can't update trade")
+	// the trade is locked while updated
+	lock := s.tradeLock("trade1")
+	lock.Lock()
+	donec := make(chan struct{})
+	go func() {
+		defer close(donec)
+		s.updateTrade("trade1", func(trade.Trade) error { return nil })
+	}()
+	select {
+	case <-donec:
+		require.Fail(t, "expecting a locked trade")
+	case <-time.After(50 * time.Millisecond):
+	}
+	lock.Unlock()
+	<-donec
+}
+
+func resign(t *testing.T, data string) string {
+	env := &envelope.Envelope{}
+	require.NoError(t, yaml.Unmarshal([]byte(data), env), "can't unmarshal envelope")
+	k, err := envelope.NewIdentityKey()
+	require.NoError(t, err, "can't create identity key")
+	require.NoError(t, env.Sign(k), "can't sign")
+	b, err := yaml.Marshal(env)
+	require.NoError(t, err, "can't marshal envelope")
+	return string(b)
+}
+
+func TestPeerSigner(t *testing.T) {
+	buyer, buyerHS, buyerCleanup := newTestServer(t)
+	defer buyerCleanup()
+	seller, sellerHS, sellerCleanup := newTestServer(t)
+	defer sellerCleanup()
+	r := callMethod(t, buyerHS, "trade.new", testTradeParams("trade1"))
+	require.Nil(t, r.Error, "can't create trade")
+	r = callMethod(t, buyerHS, "proposal.export", map[string]interface{}{"name": "trade1"})
+	require.Nil(t, r.Error, "can't export proposal")
+	r = callMethod(t, sellerHS, "proposal.accept", map[string]interface{}{"name": "trade1", "data": r.Result})
+	require.Nil(t, r.Error, "can't accept proposal")
+	// the seller trade is bound to the buyer identity
+	tr, err := seller.cfg.Store.OpenTrade("trade1")
+	require.NoError(t, err, "can't open trade")
+	require.Equal(t, buyer.cfg.Identity.Public().SerializeCompressed(), []byte(tr.PeerSigner()), "signer mismatch")
+	r = callMethod(t, sellerHS, "lockset.export", map[string]interface{}{"name": "trade1"})
+	require.Nil(t, r.Error, "can't export lockset")
+	lockSet := r.Result.(string)
+	// a pinned signer must match the known signer
+	_, err = buyer.updateTrade("trade1", func(tr trade.Trade) error {
+		tr.SetPeerSigner(seller.cfg.Identity.Public().SerializeCompressed())
+		return nil
+	})
+	require.NoError(t, err, "can't update trade")
+	r = callMethod(t, buyerHS, "lockset.accept", map[string]interface{}{
+		"name":   "trade1",
+		"data":   lockSet,
+		"signer": types.Bytes(buyer.cfg.Identity.Public().SerializeCompressed()),
+	})
+	require.NotNil(t, r.Error, "expecting a signer mismatch")
+	require.Equal(t, envelope.ErrSignerMismatch.Error(), r.Error.Message, "expecting a signer mismatch")
+	// a message signed by another key is rejected
+	r = callMethod(t, buyerHS, "lockset.accept", map[string]interface{}{"name": "trade1", "data": resign(t, lockSet)})
+	require.NotNil(t, r.Error, "expecting a signer mismatch")
+	require.Equal(t, CodeInvalidParams, r.Error.Code, "error code mismatch")
+	require.Equal(t, envelope.ErrSignerMismatch.Error(), r.Error.Message, "expecting a signer mismatch")
+	r = callMethod(t, buyerHS, "lockset.accept", map[string]interface{}{"name": "trade1", "data": lockSet})
+	require.Nil(t, r.Error, "can't accept lockset")
+}
+
+func TestEachLockedTrade(t *testing.T) {
+	s, hs, cleanup := newTestServer(t)
+	defer cleanup()
+	r := callMethod(t, hs, "trade.new", testTradeParams("trade1"))
+	require.Nil(t, r.Error, "can't create trade")
+	// the trade is finished while the check waits for the lock
+	lock := s.tradeLock("trade1")
+	lock.Lock()
+	finished := make(chan bool, 1)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.eachLockedTrade(func(name string, tr trade.Trade) error {
+			finished <- tr.Finished()
+			return nil
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	tr, err := s.cfg.Store.OpenTrade("trade1")
+	require.NoError(t, err, "can't open trade")
+	tr.Finish()
+	require.NoError(t, s.cfg.Store.SaveTrade("trade1", tr), "can't save trade")
+	lock.Unlock()
+	require.NoError(t, <-errc, "can't check trades")
+	require.True(t, <-finished, "expecting the saved trade")
+}
+
+func TestSaveWatched(t *testing.T) {
+	s, hs, cleanup := newTestServer(t)
+	defer cleanup()
+	r := callMethod(t, hs, "trade.new", testTradeParams("trade1"))
+	require.Nil(t, r.Error, "can't create trade")
+	// the trade is finished after the watch started
+	tr, err := s.cfg.Store.OpenTrade("trade1")
+	require.NoError(t, err, "can't open trade")
+	tr.Finish()
+	require.NoError(t, s.cfg.Store.SaveTrade("trade1", tr), "can't save trade")
+	token := types.Bytes{1, 2, 3}
+	require.NoError(t, s.saveWatched("trade1", func(tr trade.Trade) { tr.SetToken(token) }), "can't save trade")
+	tr, err = s.cfg.Store.OpenTrade("trade1")
+	require.NoError(t, err, "can't open trade")
+	require.True(t, tr.Finished(), "expecting a finished trade")
+	require.Equal(t, token, tr.Token(), "token mismatch")
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// FundsStatus represents the status of one side of the trade
+type FundsStatus struct {
+	Crypto         string       `json:"crypto"`
+	Amount         types.Amount `json:"amount"`
+	Deposited      types.Amount `json:"deposited"`
+	DepositAddress string       `json:"deposit_address,omitempty"`
+}
+
+// TradeStatus represents the status of a trade
+type TradeStatus struct {
+	Name     string       `json:"name"`
+	Role     string       `json:"role"`
+	Stage    string       `json:"stage"`
+	Duration string       `json:"duration"`
+	HasToken bool         `json:"has_token"`
+	Own      *FundsStatus `json:"own"`
+	Trader   *FundsStatus `json:"trader"`
+}
+
+func newFundsStatus(info *trade.TraderInfo, fd trade.FundsData, network flagutil.NetworkFlag) *FundsStatus {
+	r := &FundsStatus{
+		Crypto:    info.Crypto.Name,
+		Amount:    info.Amount,
+		Deposited: types.NewAmount(trade.FundsAmount(fd), uint64(info.Crypto.Decimals)),
+	}
+	if fd == nil || len(fd.Lock().Bytes()) == 0 {
+		return r
+	}
+	chain, err := network.Network(info.Crypto.Name)
+	if err != nil {
+		return r
+	}
+	if addr, err := fd.Lock().Address(chain); err == nil {
+		r.DepositAddress = addr
+	}
+	return r
+}
+
+func newTradeStatus(name string, tr trade.Trade, network flagutil.NetworkFlag) *TradeStatus {
+	return &TradeStatus{
+		Name:     name,
+		Role:     tr.Role().String(),
+		Stage:    trade.CurrentStage(tr).String(),
+		Duration: tr.Duration().String(),
+		HasToken: len(tr.Token()) > 0,
+		Own:      newFundsStatus(tr.OwnInfo(), tr.RecoverableFunds(), network),
+		Trader:   newFundsStatus(tr.TraderInfo(), tr.RedeemableFunds(), network),
+	}
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/internal/metricsutil"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/atomicswap/trade"
+)
+
+// watch targets
+const (
+	WatchOwn    = "own"
+	WatchTrader = "trader"
+	WatchSecret = "secret"
+	WatchRedeem = "redeem"
+)
+
+type watcherKey struct {
+	Trade  string
+	Target string
+}
+
+type watcher struct {
+	closec   chan struct{}
+	donec    chan struct{}
+	stopOnce sync.Once
+}
+
+func newWatcher() *watcher {
+	return &watcher{closec: make(chan struct{}), donec: make(chan struct{})}
+}
+
+func (w *watcher) stop() {
+	w.stopOnce.Do(func() { close(w.closec) })
+	<-w.donec
+}
+
+var errWatching = errors.New("trade is being watched")
+
+func (s *Server) isWatchingDeposits(name string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, i := range []string{WatchOwn, WatchTrader} {
+		if _, ok := s.watchers[watcherKey{Trade: name, Target: i}]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) startWatcher(name string, target string, firstBlock uint64, confirmations uint64, ignoreTarget bool) error {
+	key := watcherKey{Trade: name, Target: target}
+	lock := s.tradeLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+	tr, err := s.cfg.Store.OpenTrade(name)
+	if err != nil {
+		return err
+	}
+	wd, err := s.cfg.Store.OpenWatchData(name)
+	if err != nil {
+		return err
+	}
+	var (
+		info  *trade.TraderInfo
+		funds trade.FundsData
+		bwd   *chainutil.BlockWatchData
+	)
+	switch target {
+	case WatchOwn:
+		info, funds, bwd = tr.OwnInfo(), tr.RecoverableFunds(), wd.Own
+	case WatchTrader:
+		info, funds, bwd = tr.TraderInfo(), tr.RedeemableFunds(), wd.Trader
+	case WatchSecret:
+		info, bwd = tr.OwnInfo(), wd.Own
+	case WatchRedeem:
+		info, funds = tr.TraderInfo(), tr.RedeemableFunds()
+	default:
+		return errors.New("invalid watch target: " + target)
+	}
+	if target != WatchSecret && (funds == nil || len(funds.Lock().Bytes()) == 0) {
+		return errors.New("missing lock")
+	}
+	sc, err := s.scanners.Scanner(info.Crypto)
+	if err != nil {
+		return err
+	}
+	chain, err := s.cfg.Network.Network(info.Crypto.Name)
+	if err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	if _, ok := s.watchers[key]; ok {
+		s.mtx.Unlock()
+		return errors.New("already watching")
+	}
+	w := newWatcher()
+	s.watchers[key] = w
+	s.mtx.Unlock()
+	go func() {
+		defer close(w.donec)
+		defer func() {
+			s.mtx.Lock()
+			delete(s.watchers, key)
+			s.mtx.Unlock()
+			metricsutil.ForgetWatchData(name, target)
+		}()
+		var err error
+		switch target {
+		case WatchSecret:
+			err = s.watchSecret(name, tr, sc, bwd, firstBlock, w.closec)
+		case WatchRedeem:
+			err = s.watchRedeem(name, tr, sc, firstBlock, confirmations, w.closec)
+		default:
+			err = s.watchDeposit(name, tr, wd, sc, chain, info, funds, bwd, firstBlock, confirmations, ignoreTarget, w.closec)
+		}
+		data := map[string]interface{}{"target": target}
+		if err != nil {
+			data["error"] = err.Error()
+		}
+		s.publish(EventWatchStopped, name, tr, data)
+	}()
+	return nil
+}
+
+// saveWatched reopens the trade under the lock, applies the changes found by a
+// watcher and saves it. The watchers hold a copy of the trade, saving it would
+// undo the updates made since the watch started
+func (s *Server) saveWatched(name string, f func(trade.Trade)) error {
+	lock := s.tradeLock(name)
+	lock.Lock()
+	defer lock.Unlock()
+	tr, err := s.cfg.Store.OpenTrade(name)
+	if err != nil {
+		return err
+	}
+	before := trade.CurrentStage(tr)
+	f(tr)
+	if err = s.cfg.Store.SaveTrade(name, tr); err != nil {
+		return err
+	}
+	if after := trade.CurrentStage(tr); after != before {
+		s.publish(EventStageChanged, name, tr, map[string]interface{}{"previous": before.String()})
+	}
+	return nil
+}
+
+// addOutputs adds the watched outputs missing from the funds
+func addOutputs(fd trade.FundsData, watched trade.FundsData) {
+	known := make(map[string]struct{}, 4)
+	outputs, _ := fd.Funds().([]*trade.Output)
+	for _, i := range outputs {
+		known[chainutil.OutputID(i.TxID, uint64(i.N))] = struct{}{}
+	}
+	outputs, _ = watched.Funds().([]*trade.Output)
+	for _, i := range outputs {
+		if _, ok := known[chainutil.OutputID(i.TxID, uint64(i.N))]; !ok {
+			fd.AddFunds(i)
+		}
+	}
+}
+
+func (s *Server) watchDeposit(
+	name string,
+	tr trade.Trade,
+	wd *chainutil.WatchData,
+	sc *chainutil.Scanner,
+	chain params.Chain,
+	info *trade.TraderInfo,
+	funds trade.FundsData,
+	bwd *chainutil.BlockWatchData,
+	firstBlock uint64,
+	confirmations uint64,
+	ignoreTarget bool,
+	closec <-chan struct{},
+) error {
+	dt := notifyutil.NewDepositTracker(name, info, confirmations)
+	target, savedFunds := WatchTrader, trade.Trade.RedeemableFunds
+	if bwd == wd.Own {
+		target, savedFunds = WatchOwn, trade.Trade.RecoverableFunds
+	}
+	return chainutil.WatchDeposit(sc, chain, info, funds, bwd, firstBlock, ignoreTarget, closec, &chainutil.DepositHandlers{
+		Block: func(bd *chainutil.BlockData) error {
+			s.notify(tr, dt.Block(bd.Height)...)
+			return nil
+		},
+		Output: func(ev *chainutil.DepositEvent) error {
+			if !ev.New {
+				return nil
+			}
+			s.notify(tr, dt.Output(ev)...)
+			s.publish(EventDeposit, name, tr, map[string]interface{}{
+				"output": ev.ID,
+				"amount": ev.Amount,
+				"total":  ev.Total,
+				"target": ev.Target,
+			})
+			return nil
+		},
+		SaveTrade: func() error {
+			return s.saveWatched(name, func(saved trade.Trade) { addOutputs(savedFunds(saved), funds) })
+		},
+		SaveWatchData: func() error {
+			metricsutil.ObserveWatchData(name, target, bwd.Top, bwd.Bottom)
+			return s.cfg.Store.SaveWatchData(name, wd)
+		},
+	})
+}
+
+func (s *Server) watchSecret(
+	name string,
+	tr trade.Trade,
+	sc *chainutil.Scanner,
+	bwd *chainutil.BlockWatchData,
+	firstBlock uint64,
+	closec <-chan struct{},
+) error {
+	token, err := chainutil.WatchSecretToken(sc, tr, bwd, firstBlock, closec, nil)
+	if err != nil || token == nil {
+		return err
+	}
+	s.publish(EventTokenFound, name, tr, map[string]interface{}{"token": token.Hex()})
+	s.notify(tr, &notifyutil.Event{
+		Type:   notifyutil.TokenRevealed,
+		Trade:  name,
+		Crypto: tr.OwnInfo().Crypto.Name,
+		Data:   map[string]interface{}{"token": token.Hex()},
+	})
+	return s.saveWatched(name, func(saved trade.Trade) { saved.SetToken(token) })
+}
+
+func (s *Server) watchRedeem(
+	name string,
+	tr trade.Trade,
+	sc *chainutil.Scanner,
+	firstBlock uint64,
+	confirmations uint64,
+	closec <-chan struct{},
+) error {
+	rc, err := chainutil.WatchRedeem(sc, tr, firstBlock, confirmations, closec, nil)
+	if err != nil || rc == nil {
+		return err
+	}
+	data := map[string]interface{}{"txid": rc.TxID.Hex(), "height": rc.Height}
+	s.publish(EventRedeemConfirmed, name, tr, data)
+	s.notify(tr, &notifyutil.Event{
+		Type:   notifyutil.RedeemConfirmed,
+		Trade:  name,
+		Crypto: tr.TraderInfo().Crypto.Name,
+		Data:   data,
+	})
+	return nil
+}
@@ -0,0 +1,29 @@
+package cryptos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CryptoExistsError is returned when registering a crypto that already exists
+type CryptoExistsError string
+
+// Error implement error
+func (e CryptoExistsError) Error() string {
+	return fmt.Sprintf("crypto already exists: \"%s\"", string(e))
+}
+
+// Register adds a crypto to the available cryptocurrencies
+func Register(c *Crypto) error {
+	c.Name = strings.ToLower(c.Name)
+	c.Short = strings.ToUpper(c.Short)
+	if _, ok := Cryptos[c.Name]; ok {
+		return CryptoExistsError(c.Name)
+	}
+	if _, ok := CryptosShort[c.Short]; ok {
+		return CryptoExistsError(c.Short)
+	}
+	Cryptos[c.Name] = c
+	CryptosShort[c.Short] = c
+	return nil
+}
@@ -0,0 +1,230 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+// Version is the current envelope version
+const Version = 1
+
+// envelope payload types
+const (
+	TypeProposal        = "proposal"
+	TypeLockSet         = "lockset"
+	TypeCounterProposal = "counter-proposal"
+	TypeRejection       = "rejection"
+	TypeOffers          = "offers"
+	TypeIdentity        = "identity"
+)
+
+// sessionIDSize is the size of a new session id
+const sessionIDSize = 16
+
+// identityCrypto is the crypto used for the identity keys
+var identityCrypto = cryptos.Bitcoin
+
+var (
+	// ErrUnsigned is returned when an envelope isn't signed
+	ErrUnsigned = errors.New("unsigned message")
+	// ErrExpired is returned when an envelope is expired
+	ErrExpired = errors.New("expired message")
+	// ErrSessionMismatch is returned when the session id doesn't match
+	ErrSessionMismatch = errors.New("session mismatch")
+	// ErrSignerMismatch is returned when the signer isn't the expected one
+	ErrSignerMismatch = errors.New("signer mismatch")
+)
+
+// UnsupportedVersionError is returned for an unknown envelope version
+type UnsupportedVersionError uint32
+
+func (e UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported envelope version: %d", uint32(e))
+}
+
+// TypeMismatchError is returned when the envelope has an unexpected type
+type TypeMismatchError string
+
+func (e TypeMismatchError) Error() string {
+	return fmt.Sprintf("unexpected message type: %s", string(e))
+}
+
+// Envelope represents a signed message
+type Envelope struct {
+	Version   uint32      `yaml:"version"`
+	Type      string      `yaml:"type"`
+	SessionID types.Bytes `yaml:"session_id"`
+	Expiry    time.Time   `yaml:"expiry"`
+	Payload   string      `yaml:"payload"`
+	Signer    types.Bytes `yaml:"signer,omitempty"`
+	Signature types.Bytes `yaml:"signature,omitempty"`
+}
+
+// NewSessionID returns a new random session id
+func NewSessionID() (types.Bytes, error) {
+	r := make([]byte, sessionIDSize)
+	if _, err := rand.Read(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// New returns a new unsigned envelope for the payload
+func New(typ string, sessionID []byte, expiry time.Time, payload interface{}) (*Envelope, error) {
+	b, err := yaml.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		Version:   Version,
+		Type:      typ,
+		SessionID: sessionID,
+		Expiry:    expiry.UTC(),
+		Payload:   string(b),
+	}, nil
+}
+
+// NewIdentityKey returns a new identity key
+func NewIdentityKey() (key.Private, error) { return key.NewPrivate(identityCrypto) }
+
+// ParseIdentityKey parses a serialized identity private key
+func ParseIdentityKey(b []byte) (key.Private, error) { return key.ParsePrivate(identityCrypto, b) }
+
+func writeBytes(b *bytes.Buffer, v []byte) {
+	binary.Write(b, binary.BigEndian, uint32(len(v)))
+	b.Write(v)
+}
+
+// Digest returns the digest signed by the envelope signature
+func (e *Envelope) Digest() []byte {
+	b := bytes.NewBuffer(make([]byte, 0, 1024))
+	binary.Write(b, binary.BigEndian, e.Version)
+	writeBytes(b, []byte(e.Type))
+	writeBytes(b, e.SessionID)
+	binary.Write(b, binary.BigEndian, e.Expiry.Unix())
+	writeBytes(b, []byte(e.Payload))
+	writeBytes(b, e.Signer)
+	r := sha256.Sum256(b.Bytes())
+	return r[:]
+}
+
+// Sign signs the envelope with the identity key
+func (e *Envelope) Sign(k key.Private) error {
+	e.Signer = k.Public().SerializeCompressed()
+	sig, err := k.Sign(e.Digest())
+	if err != nil {
+		return err
+	}
+	e.Signature = sig
+	return nil
+}
+
+// IsSigned returns true if the envelope is signed
+func (e *Envelope) IsSigned() bool { return len(e.Signer) > 0 && len(e.Signature) > 0 }
+
+// VerifySignature verifies the envelope signature
+func (e *Envelope) VerifySignature() error {
+	if !e.IsSigned() {
+		return ErrUnsigned
+	}
+	pub, err := key.ParsePublic(identityCrypto, e.Signer)
+	if err != nil {
+		return err
+	}
+	return pub.Verify(e.Signature, e.Digest())
+}
+
+// Expired returns true if the envelope is expired
+func (e *Envelope) Expired(now time.Time) bool { return !e.Expiry.IsZero() && now.After(e.Expiry) }
+
+// Options are the envelope verification options
+type Options struct {
+	// AllowUnsigned accepts unsigned messages and plain payloads
+	AllowUnsigned bool
+	// AllowExpired accepts expired messages
+	AllowExpired bool
+	// SessionID is the expected session id (if set)
+	SessionID []byte
+	// Signer is the expected signer public key (if set)
+	Signer []byte
+}
+
+// PeerSigner returns the signer expected from a peer. The key pinned by the
+// user and the key known from the previous messages must match
+func PeerSigner(pinned, known []byte) ([]byte, error) {
+	if len(pinned) > 0 && len(known) > 0 && !bytes.Equal(pinned, known) {
+		return nil, ErrSignerMismatch
+	}
+	if len(known) > 0 {
+		return known, nil
+	}
+	return pinned, nil
+}
+
+func isEnvelope(b []byte) bool {
+	v := &struct {
+		Version uint32 `yaml:"version"`
+		Payload string `yaml:"payload"`
+	}{}
+	if err := yaml.Unmarshal(b, v); err != nil {
+		return false
+	}
+	return v.Version != 0 && v.Payload != ""
+}
+
+// Open parses and verifies an envelope. If AllowUnsigned is set plain payloads
+// are returned inside an unsigned envelope. An empty type accepts any type. The
+// expected session id and signer are checked on unsigned messages too, the
+// signer of an unsigned message is never trusted
+func Open(b []byte, typ string, opts *Options) (*Envelope, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if !isEnvelope(b) {
+		if !opts.AllowUnsigned {
+			return nil, ErrUnsigned
+		}
+		return checkPins(&Envelope{Type: typ, Payload: string(b)}, opts)
+	}
+	r := &Envelope{}
+	if err := yaml.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	if r.Version != Version {
+		return nil, UnsupportedVersionError(r.Version)
+	}
+	if typ != "" && r.Type != typ {
+		return nil, TypeMismatchError(r.Type)
+	}
+	if err := r.VerifySignature(); err != nil {
+		if err != ErrUnsigned || !opts.AllowUnsigned {
+			return nil, err
+		}
+		r.Signer = nil
+	}
+	if !opts.AllowExpired && r.Expired(time.Now()) {
+		return nil, ErrExpired
+	}
+	return checkPins(r, opts)
+}
+
+// checkPins checks the envelope session id and signer against the expected ones
+func checkPins(e *Envelope, opts *Options) (*Envelope, error) {
+	if len(opts.SessionID) > 0 && !bytes.Equal(opts.SessionID, e.SessionID) {
+		return nil, ErrSessionMismatch
+	}
+	if len(opts.Signer) > 0 && !bytes.Equal(opts.Signer, e.Signer) {
+		return nil, ErrSignerMismatch
+	}
+	return e, nil
+}
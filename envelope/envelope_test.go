@@ -0,0 +1,108 @@
+package envelope
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type testPayload struct {
+	Value string `yaml:"value"`
+}
+
+func newTestEnvelope(t *testing.T, expiry time.Time) ([]byte, *Envelope) {
+	k, err := NewIdentityKey()
+	require.NoError(t, err, "can't create identity key")
+	sid, err := NewSessionID()
+	require.NoError(t, err, "can't create session id")
+	env, err := New(TypeProposal, sid, expiry, &testPayload{Value: "hello"})
+	require.NoError(t, err, "can't create envelope")
+	require.NoError(t, env.Sign(k), "can't sign")
+	b, err := yaml.Marshal(env)
+	require.NoError(t, err, "can't marshal")
+	return b, env
+}
+
+func TestEnvelope(t *testing.T) {
+	b, env := newTestEnvelope(t, time.Now().Add(time.Hour))
+	// valid
+	env2, err := Open(b, TypeProposal, &Options{SessionID: env.SessionID, Signer: env.Signer})
+	require.NoError(t, err, "can't open")
+	p := &testPayload{}
+	require.NoError(t, yaml.Unmarshal([]byte(env2.Payload), p), "can't unmarshal payload")
+	require.Equal(t, "hello", p.Value, "payload mismatch")
+	// wrong type
+	_, err = Open(b, TypeLockSet, nil)
+	require.Equal(t, TypeMismatchError(TypeProposal), err, "expecting type mismatch")
+	// wrong session
+	_, err = Open(b, TypeProposal, &Options{SessionID: []byte{1}})
+	require.Equal(t, ErrSessionMismatch, err, "expecting session mismatch")
+	// tampered payload
+	env.Payload = "value: bye\n"
+	tb, err := yaml.Marshal(env)
+	require.NoError(t, err, "can't marshal")
+	_, err = Open(tb, TypeProposal, nil)
+	require.Error(t, err, "expecting bad signature")
+	// unsigned
+	env.Signature = nil
+	env.Signer = nil
+	ub, err := yaml.Marshal(env)
+	require.NoError(t, err, "can't marshal")
+	_, err = Open(ub, TypeProposal, nil)
+	require.Equal(t, ErrUnsigned, err, "expecting unsigned")
+	_, err = Open(ub, TypeProposal, &Options{AllowUnsigned: true})
+	require.NoError(t, err, "can't open unsigned")
+	// plain payload
+	_, err = Open([]byte("value: hello\n"), TypeProposal, nil)
+	require.Equal(t, ErrUnsigned, err, "expecting unsigned")
+	_, err = Open([]byte("value: hello\n"), TypeProposal, &Options{AllowUnsigned: true})
+	require.NoError(t, err, "can't open plain payload")
+}
+
+func TestUnsignedPins(t *testing.T) {
+	_, env := newTestEnvelope(t, time.Now().Add(time.Hour))
+	plain := []byte("value: hello\n")
+	_, err := Open(plain, TypeProposal, &Options{AllowUnsigned: true, Signer: env.Signer})
+	require.Equal(t, ErrSignerMismatch, err, "expecting signer mismatch")
+	_, err = Open(plain, TypeProposal, &Options{AllowUnsigned: true, SessionID: env.SessionID})
+	require.Equal(t, ErrSessionMismatch, err, "expecting session mismatch")
+	// an unsigned envelope can't claim a signer
+	env.Signature = nil
+	ub, err := yaml.Marshal(env)
+	require.NoError(t, err, "can't marshal")
+	_, err = Open(ub, TypeProposal, &Options{AllowUnsigned: true, Signer: env.Signer})
+	require.Equal(t, ErrSignerMismatch, err, "expecting signer mismatch")
+	env2, err := Open(ub, TypeProposal, &Options{AllowUnsigned: true, SessionID: env.SessionID})
+	require.NoError(t, err, "can't open unsigned")
+	require.Empty(t, env2.Signer, "expecting no signer")
+}
+
+func TestEnvelopeExpired(t *testing.T) {
+	b, _ := newTestEnvelope(t, time.Now().Add(-time.Minute))
+	_, err := Open(b, TypeProposal, nil)
+	require.Equal(t, ErrExpired, err, "expecting expired")
+	_, err = Open(b, TypeProposal, &Options{AllowExpired: true})
+	require.NoError(t, err, "can't open expired")
+}
+
+func TestPeerSigner(t *testing.T) {
+	b, env := newTestEnvelope(t, time.Now().Add(time.Hour))
+	other, _ := newTestEnvelope(t, time.Now().Add(time.Hour))
+	otherEnv := &Envelope{}
+	require.NoError(t, yaml.Unmarshal(other, otherEnv), "can't unmarshal")
+	// the known signer is expected
+	signer, err := PeerSigner(nil, env.Signer)
+	require.NoError(t, err, "can't get signer")
+	_, err = Open(b, TypeProposal, &Options{Signer: signer})
+	require.NoError(t, err, "can't open")
+	_, err = Open(other, TypeProposal, &Options{Signer: signer})
+	require.Equal(t, ErrSignerMismatch, err, "expecting signer mismatch")
+	// the pinned signer must match the known signer
+	_, err = PeerSigner(otherEnv.Signer, env.Signer)
+	require.Equal(t, ErrSignerMismatch, err, "expecting signer mismatch")
+	signer, err = PeerSigner(env.Signer, nil)
+	require.NoError(t, err, "can't get signer")
+	require.Equal(t, []byte(env.Signer), signer, "signer mismatch")
+}
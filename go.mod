@@ -15,6 +15,7 @@ require (
 	github.com/decred/dcrd/dcrutil v1.3.0
 	github.com/decred/dcrd/txscript/v3 v3.0.0-20200604211334-3f43437d1338
 	github.com/decred/dcrd/wire v1.3.0
+	github.com/flynn/noise v1.0.0
 	github.com/gcash/bchd v0.15.2
 	github.com/gcash/bchutil v0.0.0-20191012211144-98e73ec336ba
 	github.com/mattn/go-colorable v0.1.7 // indirect
@@ -27,7 +28,7 @@ require (
 	github.com/stretchr/testify v1.5.1
 	github.com/transmutate-io/cryptocore v0.0.2-0.20200901232536-56e77d8e1bc6
 	github.com/transmutate-io/reflection v0.0.1
-	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
 	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
 	gopkg.in/yaml.v2 v2.2.4
 )
@@ -0,0 +1,13 @@
+package hash
+
+import "github.com/transmutate-io/atomicswap/cryptos"
+
+// RegisterLike registers the hasher of base for the crypto c
+func RegisterLike(c *cryptos.Crypto, base *cryptos.Crypto) error {
+	nf, ok := newHasherFuncs[base.Name]
+	if !ok {
+		return cryptos.InvalidCryptoError(base.Name)
+	}
+	newHasherFuncs[c.Name] = nf
+	return nil
+}
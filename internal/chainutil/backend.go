@@ -0,0 +1,236 @@
+package chainutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/url"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	atx "github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+var (
+	// ErrUnsupported is returned by the methods not available in a backend
+	ErrUnsupported = errors.New("not supported by the backend")
+	// ErrUnknownBlock is returned for a block not requested by height first
+	ErrUnknownBlock = errors.New("unknown block")
+	// ErrBackendTimeout is returned when a backend doesn't answer in time
+	ErrBackendTimeout = errors.New("backend timeout")
+	// ErrTxMismatch is returned when a backend answers with another transaction
+	ErrTxMismatch = errors.New("transaction mismatch")
+)
+
+// InvalidBackendError is returned for an unknown backend url
+type InvalidBackendError string
+
+func (e InvalidBackendError) Error() string { return "invalid backend: " + string(e) }
+
+type (
+	// HistoryItem is a transaction in the history of a script
+	HistoryItem struct {
+		TxID types.Bytes
+		// Height is the block height, zero if unconfirmed
+		Height uint64
+	}
+
+	// HistoryClient is implemented by the backends indexing the transactions
+	// by script
+	HistoryClient interface {
+		// ScriptHistory returns the transactions with outputs paying to the
+		// script and with inputs spending them
+		ScriptHistory(script []byte) ([]*HistoryItem, error)
+	}
+
+	// ScriptSubscriber is implemented by the backends notifying the changes in
+	// the history of a script
+	ScriptSubscriber interface {
+		// SubscribeScript returns a channel receiving the history changes
+		SubscribeScript(script []byte) (<-chan struct{}, error)
+	}
+
+	// FeeEstimator is implemented by the backends estimating the fee
+	FeeEstimator interface {
+		// EstimateFee returns the fee rate in coins per kilobyte
+		EstimateFee(target uint64) (float64, error)
+	}
+
+	// Outspend is the spender of an output
+	Outspend struct {
+		Spent bool
+		TxID  types.Bytes
+		// Input is the index of the spending input
+		Input int
+		// Height is the block height of the spender, zero if unconfirmed
+		Height uint64
+	}
+
+	// OutspendClient is implemented by the backends indexing the spenders of
+	// the outputs
+	OutspendClient interface {
+		// Outspends returns the spenders of the outputs of a transaction
+		Outspends(txID types.Bytes) ([]*Outspend, error)
+	}
+)
+
+type newBackendFunc func(c *cryptos.Crypto, cfg *ClientConfig, u *url.URL) (cryptocore.Client, error)
+
+var (
+	// backends by url scheme
+	newBackendFuncs = map[string]newBackendFunc{
+		"electrum":  newElectrumBackend,
+		"electrums": newElectrumBackend,
+		"esplora":   newEsploraBackend,
+		"esploras":  newEsploraBackend,
+	}
+
+	// transactions decoded from the node json by the backends
+	newBackendTxFuncs = map[string]func() tx.Tx{
+		cryptos.Bitcoin.Name:     func() tx.Tx { return &tx.TxBTC{} },
+		cryptos.Litecoin.Name:    func() tx.Tx { return &tx.TxLTC{} },
+		cryptos.Dogecoin.Name:    func() tx.Tx { return &tx.TxDOGE{} },
+		cryptos.BitcoinCash.Name: func() tx.Tx { return &tx.TxBCH{} },
+	}
+)
+
+// backendTLSConfig returns the tls configuration of a backend
+func backendTLSConfig(cfg *ClientConfig) (*tls.Config, error) {
+	if cfg.TLS == nil {
+		return &tls.Config{}, nil
+	}
+	return cfg.tlsConfig()
+}
+
+func newElectrumBackend(c *cryptos.Crypto, cfg *ClientConfig, u *url.URL) (cryptocore.Client, error) {
+	if u.Scheme != "electrums" {
+		return DialElectrum(c, u.Host, nil)
+	}
+	tlsConf, err := backendTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return DialElectrum(c, u.Host, tlsConf)
+}
+
+func newEsploraBackend(c *cryptos.Crypto, cfg *ClientConfig, u *url.URL) (cryptocore.Client, error) {
+	base := &url.URL{Scheme: "http", Host: u.Host, Path: u.Path}
+	if u.Scheme != "esploras" {
+		return NewEsploraClient(c, base.String(), nil)
+	}
+	base.Scheme = "https"
+	tlsConf, err := backendTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewEsploraClient(c, base.String(), tlsConf)
+}
+
+// CloseClient closes the connection of a backend client, if any
+func CloseClient(cl cryptocore.Client) error {
+	if c, ok := cl.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// noWallet implements the wallet and block generation methods of the
+// backends, they're not available. Only the exported methods of
+// cryptocore.Client are implemented
+type noWallet struct{ cryptocore.Client }
+
+// NewAddress implement cryptocore.Client
+func (noWallet) NewAddress() (string, error) { return "", ErrUnsupported }
+
+// SendToAddress implement cryptocore.Client
+func (noWallet) SendToAddress(string, types.Amount) (types.Bytes, error) {
+	return nil, ErrUnsupported
+}
+
+// Balance implement cryptocore.Client
+func (noWallet) Balance(int64) (types.Amount, error) { return "", ErrUnsupported }
+
+// RawBlock implement cryptocore.Client
+func (noWallet) RawBlock(types.Bytes) (types.Bytes, error) { return nil, ErrUnsupported }
+
+// ReceivedByAddress implement cryptocore.Client
+func (noWallet) ReceivedByAddress(interface{}, interface{}, interface{}) ([]*types.AddressFunds, error) {
+	return nil, ErrUnsupported
+}
+
+// CanGenerateBlocksToAddress implement cryptocore.Client
+func (noWallet) CanGenerateBlocksToAddress() bool { return false }
+
+// GenerateBlocksToAddress implement cryptocore.Client
+func (noWallet) GenerateBlocksToAddress(int, string) ([]types.Bytes, error) {
+	return nil, ErrUnsupported
+}
+
+// CanGenerateBlocks implement cryptocore.Client
+func (noWallet) CanGenerateBlocks() bool { return false }
+
+// GenerateBlocks implement cryptocore.Client
+func (noWallet) GenerateBlocks(int) ([]types.Bytes, error) { return nil, ErrUnsupported }
+
+// decodeRawTx decodes a raw transaction into the node format. The id of the
+// transaction must be hash
+func decodeRawTx(c *cryptos.Crypto, newTx func() tx.Tx, raw []byte, hash types.Bytes) (tx.Tx, error) {
+	t, err := atx.Parse(c, raw)
+	if err != nil {
+		return nil, err
+	}
+	txUTXO, ok := t.TxUTXO()
+	if !ok {
+		return nil, atx.ErrNotUTXO
+	}
+	id := txUTXO.TxID()
+	if !bytes.Equal(id, hash) {
+		return nil, ErrTxMismatch
+	}
+	type m = map[string]interface{}
+	coinbaseID := make([]byte, 32)
+	vin := make([]m, 0, len(txUTXO.Inputs()))
+	for _, i := range txUTXO.Inputs() {
+		in := m{
+			"txid":      i.TxID,
+			"vout":      i.N,
+			"scriptSig": m{"hex": i.SignatureScript},
+			"sequence":  i.Sequence,
+		}
+		if i.N == math.MaxUint32 && bytes.Equal(i.TxID, coinbaseID) {
+			in = m{"coinbase": i.SignatureScript, "sequence": i.Sequence}
+		}
+		if len(i.Witness) > 0 {
+			in["txinwitness"] = i.Witness
+		}
+		vin = append(vin, in)
+	}
+	vout := make([]m, 0, len(txUTXO.Outputs()))
+	for n, i := range txUTXO.Outputs() {
+		vout = append(vout, m{
+			"value":        types.NewAmount(i.Value, uint64(c.Decimals)),
+			"n":            n,
+			"scriptPubKey": m{"hex": i.Script},
+		})
+	}
+	b, err := json.Marshal(m{
+		"txid":     id,
+		"hash":     id,
+		"locktime": txUTXO.LockTimeUInt32(),
+		"vin":      vin,
+		"vout":     vout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := newTx()
+	if err = json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,82 @@
+package chainutil
+
+import (
+	"errors"
+	"time"
+
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/block"
+	"github.com/transmutate-io/cryptocore/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// BlockWatchData holds the range of inspected blocks
+type BlockWatchData struct {
+	Bottom uint64 `yaml:"bottom"`
+	Top    uint64 `yaml:"top"`
+}
+
+// Update updates the inspected range with a new height
+func (bwd *BlockWatchData) Update(height uint64) {
+	if height > bwd.Top {
+		bwd.Top = height
+	}
+	if bwd.Bottom == 0 || height < bwd.Bottom {
+		bwd.Bottom = height
+	}
+}
+
+// WatchData holds the inspected blocks for both chains of a trade
+type WatchData struct {
+	Own    *BlockWatchData `yaml:"own"`
+	Trader *BlockWatchData `yaml:"trader"`
+}
+
+// NewWatchData returns a new empty *WatchData
+func NewWatchData() *WatchData {
+	return &WatchData{
+		Own:    &BlockWatchData{Top: 0, Bottom: 0},
+		Trader: &BlockWatchData{Top: 0, Bottom: 0},
+	}
+}
+
+func getBlockAtHeight(cl cryptocore.Client, height uint64) (block.Block, error) {
+	bh, err := cl.BlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+	return cl.Block(bh)
+}
+
+var errClosed = errors.New("closed")
+
+func blockTransactions(cl cryptocore.Client, txs []types.Bytes, closec <-chan struct{}) ([]tx.Tx, error) {
+	r := make([]tx.Tx, 0, len(txs))
+	for _, i := range txs {
+		select {
+		case <-closec:
+			return nil, errClosed
+		default:
+		}
+		tx, err := cl.Transaction(i)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, tx)
+	}
+	return r, nil
+}
+
+// BlockData represents an inspected block
+type BlockData struct {
+	Height uint64
+	// TxCount is the number of transactions in the block
+	TxCount int
+	// Txs are the transactions selected by the chain query
+	Txs []tx.Tx
+}
+
+const (
+	initTimeout = time.Second
+	maxTimeout  = time.Minute
+)
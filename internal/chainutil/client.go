@@ -0,0 +1,116 @@
+package chainutil
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/cryptocore"
+)
+
+type newClientFunc func(addr, user, pass string, tlsConf *cryptocore.TLSConfig) (cryptocore.Client, error)
+
+var newClientFuncs = map[string]newClientFunc{
+	cryptos.Bitcoin.Name:     cryptocore.NewClientBTC,
+	cryptos.Litecoin.Name:    cryptocore.NewClientLTC,
+	cryptos.Dogecoin.Name:    cryptocore.NewClientDOGE,
+	cryptos.Decred.Name:      cryptocore.NewClientDCR,
+	cryptos.BitcoinCash.Name: cryptocore.NewClientBCH,
+}
+
+// ErrClientUnavailable is returned when there is no client for a crypto
+var ErrClientUnavailable = errors.New("client unavailable")
+
+// NewClient returns a new node client for the given crypto
+func NewClient(
+	c *cryptos.Crypto,
+	address string,
+	username string,
+	password string,
+	tlsConf *cryptocore.TLSConfig,
+) (cryptocore.Client, error) {
+	nc, ok := newClientFuncs[c.Name]
+	if !ok {
+		return nil, ErrClientUnavailable
+	}
+	return nc(address, username, password, tlsConf)
+}
+
+// ClientConfig represents the configuration of a node client
+type ClientConfig struct {
+	Address  string
+	Username string
+	Password string
+	TLS      *cryptocore.TLSConfig
+	// Backend is the url of the chain backend, the node rpc if empty
+	// (electrum://host:port, electrums://host:port, esplora://host/path,
+	// esploras://host/path)
+	Backend string
+	// ChainQuery is the chain query strategy of the scanners (auto if empty)
+	ChainQuery string
+	// crypto labels the calls metrics
+	crypto string
+}
+
+// forCrypto returns a copy of the configuration labeling the calls with the
+// crypto name
+func (cfg *ClientConfig) forCrypto(c *cryptos.Crypto) *ClientConfig {
+	r := *cfg
+	r.crypto = c.Name
+	return &r
+}
+
+// NewClient returns a new node client for the given crypto using the
+// configuration, or a backend client if the backend is set
+func (cfg *ClientConfig) NewClient(c *cryptos.Crypto) (cryptocore.Client, error) {
+	if cfg.Backend == "" {
+		cl, err := NewClient(c, cfg.Address, cfg.Username, cfg.Password, cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		return &metricsClient{Client: cl, crypto: c.Name}, nil
+	}
+	u, err := url.Parse(cfg.Backend)
+	if err != nil {
+		return nil, InvalidBackendError(cfg.Backend)
+	}
+	nb, ok := newBackendFuncs[u.Scheme]
+	if !ok || u.Host == "" {
+		return nil, InvalidBackendError(cfg.Backend)
+	}
+	return nb(c, cfg, u)
+}
+
+// NewScanner returns a new chain scanner for the crypto using the chain query
+// strategy of the configuration. The spenders of the outputs are looked up
+// with the node or backend of the configuration
+func (cfg *ClientConfig) NewScanner(c *cryptos.Crypto, cl cryptocore.Client) (*Scanner, error) {
+	q, err := NewChainQuery(cfg.ChainQuery, cl, cfg.forCrypto(c))
+	if err != nil {
+		return nil, err
+	}
+	return newScanner(c, cl, q, NewSpendFinder(c, cl, cfg)), nil
+}
+
+// RegisterLike registers the node client and fee estimation of base for the
+// crypto c
+func RegisterLike(c *cryptos.Crypto, base *cryptos.Crypto) error {
+	nc, ok := newClientFuncs[base.Name]
+	if !ok {
+		return ErrClientUnavailable
+	}
+	newClientFuncs[c.Name] = nc
+	if ef, ok := estimateFeeFuncs[base.Name]; ok {
+		estimateFeeFuncs[c.Name] = ef
+	}
+	if fl, ok := feeLimits[base.Name]; ok {
+		feeLimits[c.Name] = fl
+	}
+	if ntx, ok := newBackendTxFuncs[base.Name]; ok {
+		newBackendTxFuncs[c.Name] = ntx
+	}
+	if p, ok := getTxOutParams[base.Name]; ok {
+		getTxOutParams[c.Name] = p
+	}
+	return nil
+}
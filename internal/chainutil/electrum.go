@@ -0,0 +1,406 @@
+package chainutil
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/metricsutil"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/block"
+	"github.com/transmutate-io/cryptocore/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+const (
+	// electrum protocol version requested to the servers
+	electrumProtocolVersion = "1.4"
+	// maximum number of block headers kept for Block
+	electrumMaxBlocks = 1024
+)
+
+var electrumTimeout = 30 * time.Second
+
+type (
+	// ElectrumClient is a client of an Electrum server. The wallet and
+	// block generation methods are not available and the blocks don't list
+	// their transactions, the scanners find them in the scripts history
+	ElectrumClient struct {
+		noWallet
+		crypto   *cryptos.Crypto
+		newTx    func() tx.Tx
+		conn     net.Conn
+		writeMtx sync.Mutex
+		mtx      sync.Mutex
+		nextID   uint64
+		pending  map[uint64]chan *electrumMessage
+		subs     map[string][]chan struct{}
+		blocks   map[string]*electrumBlock
+		// hashes of the blocks in the order they were added
+		order []string
+		tip   uint64
+		err   error
+		donec chan struct{}
+	}
+
+	electrumMessage struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      *uint64         `json:"id,omitempty"`
+		Method  string          `json:"method,omitempty"`
+		Params  json.RawMessage `json:"params,omitempty"`
+		Result  json.RawMessage `json:"result,omitempty"`
+		Error   *RPCError       `json:"error,omitempty"`
+	}
+
+	electrumBlock struct {
+		hash   types.Bytes
+		height uint64
+		header []byte
+		tip    uint64
+	}
+)
+
+// DialElectrum connects to an Electrum server. The connection uses TLS if
+// tlsConf is not nil
+func DialElectrum(c *cryptos.Crypto, addr string, tlsConf *tls.Config) (*ElectrumClient, error) {
+	newTx, ok := newBackendTxFuncs[c.Name]
+	if !ok {
+		return nil, ErrClientUnavailable
+	}
+	d := &net.Dialer{Timeout: electrumTimeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if tlsConf != nil {
+		conn, err = tls.DialWithDialer(d, "tcp", addr, tlsConf)
+	} else {
+		conn, err = d.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &ElectrumClient{
+		crypto:  c,
+		newTx:   newTx,
+		conn:    conn,
+		pending: make(map[uint64]chan *electrumMessage, 4),
+		subs:    make(map[string][]chan struct{}, 4),
+		blocks:  make(map[string]*electrumBlock, 16),
+		donec:   make(chan struct{}),
+	}
+	go r.read()
+	if err = r.call("server.version", []interface{}{"atomicswap", electrumProtocolVersion}, nil); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Close closes the connection to the server
+func (ec *ElectrumClient) Close() error {
+	err := ec.conn.Close()
+	<-ec.donec
+	return err
+}
+
+func (ec *ElectrumClient) read() {
+	defer close(ec.donec)
+	s := bufio.NewScanner(ec.conn)
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		m := &electrumMessage{}
+		if err := json.Unmarshal(s.Bytes(), m); err != nil {
+			continue
+		}
+		if m.ID == nil {
+			ec.notify(m)
+			continue
+		}
+		ec.mtx.Lock()
+		ch, ok := ec.pending[*m.ID]
+		delete(ec.pending, *m.ID)
+		ec.mtx.Unlock()
+		if ok {
+			ch <- m
+		}
+	}
+	err := s.Err()
+	if err == nil {
+		err = errors.New("connection closed")
+	}
+	ec.mtx.Lock()
+	ec.err = err
+	ec.pending = nil
+	for _, i := range ec.subs {
+		for _, j := range i {
+			close(j)
+		}
+	}
+	ec.subs = nil
+	ec.mtx.Unlock()
+}
+
+// notify signals the subscribers of a script hash
+func (ec *ElectrumClient) notify(m *electrumMessage) {
+	if m.Method != "blockchain.scripthash.subscribe" {
+		return
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(m.Params, &params); err != nil || len(params) == 0 {
+		return
+	}
+	var sh string
+	if err := json.Unmarshal(params[0], &sh); err != nil {
+		return
+	}
+	ec.mtx.Lock()
+	defer ec.mtx.Unlock()
+	for _, i := range ec.subs[sh] {
+		select {
+		case i <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// call calls a method of the server and decodes the result into r
+func (ec *ElectrumClient) call(method string, params []interface{}, r interface{}) (err error) {
+	defer func(start time.Time) { metricsutil.ObserveRPC(ec.crypto.Name, method, start, err) }(time.Now())
+	if params == nil {
+		params = []interface{}{}
+	}
+	ch := make(chan *electrumMessage, 1)
+	ec.mtx.Lock()
+	if ec.err != nil {
+		ec.mtx.Unlock()
+		return ec.err
+	}
+	id := ec.nextID
+	ec.nextID++
+	ec.pending[id] = ch
+	ec.mtx.Unlock()
+	b, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	ec.writeMtx.Lock()
+	_, err = ec.conn.Write(append(b, '\n'))
+	ec.writeMtx.Unlock()
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(electrumTimeout)
+	defer timer.Stop()
+	var m *electrumMessage
+	select {
+	case m = <-ch:
+	case <-ec.donec:
+		ec.mtx.Lock()
+		defer ec.mtx.Unlock()
+		return ec.err
+	case <-timer.C:
+		ec.mtx.Lock()
+		delete(ec.pending, id)
+		ec.mtx.Unlock()
+		return ErrBackendTimeout
+	}
+	if m.Error != nil {
+		return m.Error
+	}
+	if r == nil {
+		return nil
+	}
+	return json.Unmarshal(m.Result, r)
+}
+
+// electrumScriptHash returns the electrum script hash of a script
+func electrumScriptHash(script []byte) string {
+	h := sha256.Sum256(script)
+	return types.Bytes(reverseBytes(h[:])).Hex()
+}
+
+func reverseBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, j := range b {
+		r[len(b)-1-i] = j
+	}
+	return r
+}
+
+// BlockCount implement cryptocore.Client
+func (ec *ElectrumClient) BlockCount() (uint64, error) {
+	var r struct {
+		Height uint64 `json:"height"`
+	}
+	if err := ec.call("blockchain.headers.subscribe", nil, &r); err != nil {
+		return 0, err
+	}
+	ec.mtx.Lock()
+	ec.tip = r.Height
+	ec.mtx.Unlock()
+	return r.Height, nil
+}
+
+// BlockHash implement cryptocore.Client
+func (ec *ElectrumClient) BlockHash(height uint64) (types.Bytes, error) {
+	var r string
+	if err := ec.call("blockchain.block.header", []interface{}{height}, &r); err != nil {
+		if _, ok := err.(*RPCError); ok {
+			return nil, cryptocore.ErrNoBlock
+		}
+		return nil, err
+	}
+	header, err := hex.DecodeString(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 80 {
+		return nil, fmt.Errorf("short block header: %d bytes", len(header))
+	}
+	h := sha256.Sum256(header[:80])
+	h = sha256.Sum256(h[:])
+	b := &electrumBlock{hash: reverseBytes(h[:]), height: height, header: header[:80]}
+	ec.addBlock(b)
+	return b.hash, nil
+}
+
+// addBlock keeps a block header, dropping the oldest ones past the limit
+func (ec *ElectrumClient) addBlock(b *electrumBlock) {
+	ec.mtx.Lock()
+	defer ec.mtx.Unlock()
+	k := b.hash.Hex()
+	if _, ok := ec.blocks[k]; !ok {
+		ec.order = append(ec.order, k)
+	}
+	ec.blocks[k] = b
+	for len(ec.order) > electrumMaxBlocks {
+		delete(ec.blocks, ec.order[0])
+		ec.order = ec.order[1:]
+	}
+}
+
+// Block implement cryptocore.Client. The block must be requested by height
+// first
+func (ec *ElectrumClient) Block(hash types.Bytes) (block.Block, error) {
+	ec.mtx.Lock()
+	defer ec.mtx.Unlock()
+	b, ok := ec.blocks[hash.Hex()]
+	if !ok {
+		return nil, ErrUnknownBlock
+	}
+	r := *b
+	r.tip = ec.tip
+	return &r, nil
+}
+
+// Transaction implement cryptocore.Client. Not every server returns the
+// verbose transactions, the raw transaction is decoded locally
+func (ec *ElectrumClient) Transaction(hash types.Bytes) (tx.Tx, error) {
+	raw, err := ec.RawTransaction(hash)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRawTx(ec.crypto, ec.newTx, raw, hash)
+}
+
+// RawTransaction implement cryptocore.Client
+func (ec *ElectrumClient) RawTransaction(hash types.Bytes) (types.Bytes, error) {
+	var r types.Bytes
+	if err := ec.call("blockchain.transaction.get", []interface{}{hash.Hex(), false}, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SendRawTransaction implement cryptocore.Client
+func (ec *ElectrumClient) SendRawTransaction(b types.Bytes) (types.Bytes, error) {
+	var r types.Bytes
+	if err := ec.call("blockchain.transaction.broadcast", []interface{}{b.Hex()}, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// EstimateFee implement FeeEstimator
+func (ec *ElectrumClient) EstimateFee(target uint64) (float64, error) {
+	var r json.RawMessage
+	if err := ec.call("blockchain.estimatefee", []interface{}{target}, &r); err != nil {
+		return 0, err
+	}
+	return parseFeeRate(r)
+}
+
+// ScriptHistory implement HistoryClient
+func (ec *ElectrumClient) ScriptHistory(script []byte) ([]*HistoryItem, error) {
+	var r []struct {
+		TxHash types.Bytes `json:"tx_hash"`
+		Height int64       `json:"height"`
+	}
+	if err := ec.call("blockchain.scripthash.get_history", []interface{}{electrumScriptHash(script)}, &r); err != nil {
+		return nil, err
+	}
+	items := make([]*HistoryItem, 0, len(r))
+	for _, i := range r {
+		item := &HistoryItem{TxID: i.TxHash}
+		// unconfirmed transactions have a zero or negative height
+		if i.Height > 0 {
+			item.Height = uint64(i.Height)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// SubscribeScript implement ScriptSubscriber. The channel is closed with
+// the connection
+func (ec *ElectrumClient) SubscribeScript(script []byte) (<-chan struct{}, error) {
+	sh := electrumScriptHash(script)
+	if err := ec.call("blockchain.scripthash.subscribe", []interface{}{sh}, nil); err != nil {
+		return nil, err
+	}
+	ec.mtx.Lock()
+	defer ec.mtx.Unlock()
+	if ec.err != nil {
+		return nil, ec.err
+	}
+	r := make(chan struct{}, 1)
+	ec.subs[sh] = append(ec.subs[sh], r)
+	return r, nil
+}
+
+func (b *electrumBlock) Hash() types.Bytes { return b.hash }
+
+func (b *electrumBlock) Confirmations() int {
+	if b.tip < b.height {
+		return 0
+	}
+	return int(b.tip-b.height) + 1
+}
+
+func (b *electrumBlock) Height() int { return int(b.height) }
+
+// Transactions returns nil, the headers don't list the transactions
+func (b *electrumBlock) Transactions() []types.Bytes { return nil }
+
+func (b *electrumBlock) Time() types.UnixTime {
+	return types.UnixTime(binary.LittleEndian.Uint32(b.header[68:72]))
+}
+
+func (b *electrumBlock) PreviousBlockHash() types.Bytes { return reverseBytes(b.header[4:36]) }
+
+func (b *electrumBlock) NextBlockHash() types.Bytes { return nil }
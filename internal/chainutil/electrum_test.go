@@ -0,0 +1,293 @@
+package chainutil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	atx "github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// electrumStub is an electrum server answering from memory
+type electrumStub struct {
+	t       *testing.T
+	ln      net.Listener
+	mtx     sync.Mutex
+	headers [][]byte
+	txs     map[string]string
+	history map[string]string
+	calls   map[string]int
+	conns   []net.Conn
+}
+
+func newElectrumStub(t *testing.T, nBlocks int) *electrumStub {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen")
+	r := &electrumStub{
+		t:       t,
+		ln:      ln,
+		txs:     map[string]string{},
+		history: map[string]string{},
+		calls:   map[string]int{},
+	}
+	prev := make([]byte, 32)
+	for i := 0; i < nBlocks; i++ {
+		h := make([]byte, 80)
+		copy(h[4:36], prev)
+		binary.LittleEndian.PutUint32(h[68:72], uint32(1600000000+i))
+		r.headers = append(r.headers, h)
+		hash := sha256.Sum256(h)
+		hash = sha256.Sum256(hash[:])
+		prev = hash[:]
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r.mtx.Lock()
+			r.conns = append(r.conns, conn)
+			r.mtx.Unlock()
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (s *electrumStub) close() {
+	s.ln.Close()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, i := range s.conns {
+		i.Close()
+	}
+}
+
+func (s *electrumStub) addr() string { return s.ln.Addr().String() }
+
+func (s *electrumStub) count(method string) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.calls[method]
+}
+
+// addTx adds a transaction with an output paying to the script and returns
+// its id. The server only returns raw transactions
+func (s *electrumStub) addTx(height int, script []byte) types.Bytes {
+	t, err := atx.New(cryptos.Bitcoin)
+	require.NoError(s.t, err, "can't create tx")
+	txUTXO, _ := t.TxUTXO()
+	require.NoError(s.t, txUTXO.AddInput(bytes.Repeat([]byte{0x01}, 32), 0, []byte{0x51}, 0), "can't add input")
+	txUTXO.AddOutput(150000000, script)
+	raw, err := t.Serialize()
+	require.NoError(s.t, err, "can't serialize tx")
+	id := txUTXO.TxID()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.txs[id.Hex()] = `"` + hex.EncodeToString(raw) + `"`
+	sh := electrumScriptHash(script)
+	items := []string{}
+	if h, ok := s.history[sh]; ok {
+		items = append(items, h[1:len(h)-1])
+	}
+	items = append(items, fmt.Sprintf(`{"tx_hash":"%s","height":%d}`, id.Hex(), height))
+	s.history[sh] = "[" + strings.Join(items, ",") + "]"
+	return id
+}
+
+// notify sends a script hash notification to the clients
+func (s *electrumStub) notify(script []byte) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	msg := `{"jsonrpc":"2.0","method":"blockchain.scripthash.subscribe","params":["` +
+		electrumScriptHash(script) + `","status"]}` + "\n"
+	for _, i := range s.conns {
+		i.Write([]byte(msg))
+	}
+}
+
+func (s *electrumStub) handle(method string, params []json.RawMessage) (string, string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.calls[method]++
+	switch method {
+	case "server.version":
+		return `["stub","1.4"]`, ""
+	case "blockchain.headers.subscribe":
+		return fmt.Sprintf(`{"height":%d,"hex":"%x"}`, len(s.headers)-1, s.headers[len(s.headers)-1]), ""
+	case "blockchain.block.header":
+		var h int
+		json.Unmarshal(params[0], &h)
+		if h >= len(s.headers) {
+			return "", "height out of range"
+		}
+		return `"` + hex.EncodeToString(s.headers[h]) + `"`, ""
+	case "blockchain.transaction.get":
+		var (
+			id      string
+			verbose bool
+		)
+		json.Unmarshal(params[0], &id)
+		if len(params) > 1 {
+			json.Unmarshal(params[1], &verbose)
+		}
+		if verbose {
+			return "", "verbose transactions not supported"
+		}
+		t, ok := s.txs[id]
+		if !ok {
+			return "", "no such transaction"
+		}
+		return t, ""
+	case "blockchain.transaction.broadcast":
+		var raw string
+		json.Unmarshal(params[0], &raw)
+		h := sha256.Sum256([]byte(raw))
+		return `"` + hex.EncodeToString(h[:]) + `"`, ""
+	case "blockchain.estimatefee":
+		return "0.0002", ""
+	case "blockchain.scripthash.get_history":
+		var sh string
+		json.Unmarshal(params[0], &sh)
+		if h, ok := s.history[sh]; ok {
+			return h, ""
+		}
+		return "[]", ""
+	case "blockchain.scripthash.subscribe":
+		return "null", ""
+	default:
+		return "", "unknown method"
+	}
+}
+
+func (s *electrumStub) serve(conn net.Conn) {
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		var req struct {
+			ID     uint64            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &req); err != nil {
+			return
+		}
+		result, errMsg := s.handle(req.Method, req.Params)
+		var resp string
+		if errMsg != "" {
+			resp = fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"error":{"code":1,"message":"%s"}}`, req.ID, errMsg)
+		} else {
+			resp = fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":%s}`, req.ID, result)
+		}
+		s.mtx.Lock()
+		conn.Write([]byte(resp + "\n"))
+		s.mtx.Unlock()
+	}
+}
+
+func TestElectrumClient(t *testing.T) {
+	stub := newElectrumStub(t, 4)
+	defer stub.close()
+	script := append([]byte{0xa9, 0x14}, bytes.Repeat([]byte{0xaa}, 21)...)
+	id := stub.addTx(2, script)
+	cl, err := DialElectrum(cryptos.Bitcoin, stub.addr(), nil)
+	require.NoError(t, err, "can't dial")
+	defer cl.Close()
+	n, err := cl.BlockCount()
+	require.NoError(t, err, "can't get block count")
+	require.Equal(t, uint64(3), n, "block count mismatch")
+	h, err := cl.BlockHash(2)
+	require.NoError(t, err, "can't get block hash")
+	prev, err := cl.BlockHash(1)
+	require.NoError(t, err, "can't get block hash")
+	b, err := cl.Block(h)
+	require.NoError(t, err, "can't get block")
+	require.Equal(t, 2, b.Height(), "height mismatch")
+	require.Equal(t, 2, b.Confirmations(), "confirmations mismatch")
+	require.Equal(t, types.UnixTime(1600000002), b.Time(), "time mismatch")
+	require.Equal(t, prev, b.PreviousBlockHash(), "previous block mismatch")
+	_, err = cl.BlockHash(4)
+	require.Equal(t, cryptocore.ErrNoBlock, err, "expecting no block")
+	_, err = cl.Block([]byte{0x01})
+	require.Equal(t, ErrUnknownBlock, err, "expecting unknown block")
+	// the oldest blocks are dropped
+	for i := 0; i < electrumMaxBlocks; i++ {
+		cl.addBlock(&electrumBlock{hash: []byte(fmt.Sprintf("%08d", i)), header: make([]byte, 80)})
+	}
+	_, err = cl.Block(h)
+	require.Equal(t, ErrUnknownBlock, err, "expecting a dropped block")
+	require.Len(t, cl.blocks, electrumMaxBlocks, "blocks mismatch")
+	tx, err := cl.Transaction(id)
+	require.NoError(t, err, "can't get transaction")
+	require.Equal(t, id, tx.ID(), "id mismatch")
+	txUTXO, _ := tx.UTXO()
+	require.Len(t, txUTXO.Outputs(), 1, "outputs mismatch")
+	require.Equal(t, types.Bytes(script), txUTXO.Outputs()[0].LockScript().Bytes(), "script mismatch")
+	history, err := cl.ScriptHistory(script)
+	require.NoError(t, err, "can't get history")
+	require.Equal(t, []*HistoryItem{{TxID: id, Height: 2}}, history, "history mismatch")
+	sent, err := cl.SendRawTransaction([]byte{0x01, 0x02})
+	require.NoError(t, err, "can't broadcast")
+	exp := sha256.Sum256([]byte("0102"))
+	require.Equal(t, types.Bytes(exp[:]), sent, "transaction id mismatch")
+	_, err = cl.NewAddress()
+	require.Equal(t, ErrUnsupported, err, "expecting unsupported")
+	// fee estimation through the configured backend
+	rate, err := EstimateFeeRate(cryptos.Bitcoin, &ClientConfig{Backend: "electrum://" + stub.addr()}, 6)
+	require.NoError(t, err, "can't estimate fee")
+	require.Equal(t, uint64(20), rate, "fee rate mismatch")
+	_, err = (&ClientConfig{Backend: "bogus://" + stub.addr()}).NewClient(cryptos.Bitcoin)
+	require.Equal(t, InvalidBackendError("bogus://"+stub.addr()), err, "expecting invalid backend")
+	_, err = DialElectrum(cryptos.Decred, stub.addr(), nil)
+	require.Equal(t, ErrClientUnavailable, err, "expecting unavailable client")
+}
+
+func TestElectrumScanner(t *testing.T) {
+	stub := newElectrumStub(t, 4)
+	defer stub.close()
+	script := append([]byte{0xa9, 0x14}, bytes.Repeat([]byte{0xaa}, 21)...)
+	id := stub.addTx(2, script)
+	cfg := &ClientConfig{Backend: "electrum://" + stub.addr()}
+	cl, err := cfg.NewClient(cryptos.Bitcoin)
+	require.NoError(t, err, "can't create client")
+	defer CloseClient(cl)
+	sc, err := cfg.NewScanner(cryptos.Bitcoin, cl)
+	require.NoError(t, err, "can't create scanner")
+	defer sc.Close()
+	require.Equal(t, QueryHistory, sc.QueryName(), "expecting history lookups")
+	res, errc := testWatch(sc, &ScanFilter{OutputScripts: [][]byte{script}}, 1, 3)
+	require.NoError(t, <-errc, "watch failed")
+	require.Equal(t, []string{OutputID(id, 0)}, res.outputs, "outputs mismatch")
+	require.Equal(t, 1, stub.count("blockchain.scripthash.subscribe"), "expecting one subscription")
+	require.Equal(t, 1, stub.count("blockchain.scripthash.get_history"), "history fetched more than once")
+	// a notification invalidates the history
+	q, err := NewChainQuery(QueryAuto, cl, cfg)
+	require.NoError(t, err, "can't create chain query")
+	b, err := getBlockAtHeight(cl, 2)
+	require.NoError(t, err, "can't get block")
+	for i := 0; i < 2; i++ {
+		txs, err := q.Transactions(b, &Query{OutputScripts: [][]byte{script}}, nil)
+		require.NoError(t, err, "can't query block")
+		require.Len(t, txs, 1, "transactions mismatch")
+	}
+	require.Equal(t, 2, stub.count("blockchain.scripthash.get_history"), "history fetched more than once")
+	stub.notify(script)
+	for stub.count("blockchain.scripthash.get_history") == 2 {
+		_, err = q.Transactions(b, &Query{OutputScripts: [][]byte{script}}, nil)
+		require.NoError(t, err, "can't query block")
+	}
+	_, err = NewChainQuery(QueryFilters, cl, cfg)
+	require.Equal(t, InvalidQueryError(QueryFilters), err, "expecting invalid chain query")
+}
@@ -0,0 +1,431 @@
+package chainutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/metricsutil"
+	"github.com/transmutate-io/atomicswap/networks"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/block"
+	"github.com/transmutate-io/cryptocore/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// esplora page size of the confirmed transactions of an address
+const esploraChainPageSize = 25
+
+var esploraTimeout = 30 * time.Second
+
+// EsploraError is returned for a failed request to an Esplora api
+type EsploraError struct {
+	Status  int
+	Message string
+}
+
+func (e *EsploraError) Error() string {
+	return fmt.Sprintf("esplora error %d: %s", e.Status, e.Message)
+}
+
+type (
+	// EsploraClient is a client of an Esplora REST api. The wallet and block
+	// generation methods are not available and the blocks don't list their
+	// transactions, the scanners find them in the addresses history
+	EsploraClient struct {
+		noWallet
+		c     *cryptos.Crypto
+		newTx func() tx.Tx
+		base  string
+		hc    *http.Client
+		mtx   sync.Mutex
+		tip   uint64
+		// params of the chain, found with the genesis block
+		params      params.Params
+		paramsFound bool
+	}
+
+	esploraStatus struct {
+		Confirmed   bool        `json:"confirmed"`
+		BlockHeight uint64      `json:"block_height"`
+		BlockHash   types.Bytes `json:"block_hash"`
+		BlockTime   int64       `json:"block_time"`
+	}
+
+	esploraTx struct {
+		TxID     types.Bytes `json:"txid"`
+		LockTime int64       `json:"locktime"`
+		Vin      []struct {
+			TxID         types.Bytes `json:"txid"`
+			Vout         int         `json:"vout"`
+			ScriptSig    types.Bytes `json:"scriptsig"`
+			ScriptSigAsm string      `json:"scriptsig_asm"`
+			Sequence     int64       `json:"sequence"`
+			IsCoinbase   bool        `json:"is_coinbase"`
+		} `json:"vin"`
+		Vout []struct {
+			ScriptPubKey        types.Bytes `json:"scriptpubkey"`
+			ScriptPubKeyAsm     string      `json:"scriptpubkey_asm"`
+			ScriptPubKeyType    string      `json:"scriptpubkey_type"`
+			ScriptPubKeyAddress string      `json:"scriptpubkey_address"`
+			Value               uint64      `json:"value"`
+		} `json:"vout"`
+		Status esploraStatus `json:"status"`
+	}
+
+	esploraBlock struct {
+		ID            types.Bytes `json:"id"`
+		BlockHeight   int         `json:"height"`
+		Timestamp     int64       `json:"timestamp"`
+		PreviousBlock types.Bytes `json:"previousblockhash"`
+		tip           uint64
+	}
+)
+
+// NewEsploraClient returns a new client of the Esplora api at the base url
+// (https://host/api). The connections use tlsConf if it's not nil
+func NewEsploraClient(c *cryptos.Crypto, baseURL string, tlsConf *tls.Config) (*EsploraClient, error) {
+	newTx, ok := newBackendTxFuncs[c.Name]
+	if !ok {
+		return nil, ErrClientUnavailable
+	}
+	tr := &http.Transport{TLSClientConfig: tlsConf}
+	return &EsploraClient{
+		c:     c,
+		newTx: newTx,
+		base:  strings.TrimSuffix(baseURL, "/"),
+		hc:    &http.Client{Transport: tr, Timeout: esploraTimeout},
+	}, nil
+}
+
+// esploraEndpoint returns the endpoint of a path for the calls metrics
+func esploraEndpoint(method string, path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	return method + " /" + parts[0]
+}
+
+func (ec *EsploraClient) do(method string, path string, body []byte) (_ []byte, err error) {
+	defer func(start time.Time) {
+		metricsutil.ObserveRPC(ec.c.Name, esploraEndpoint(method, path), start, err)
+	}(time.Now())
+	req, err := http.NewRequest(method, ec.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	resp, err := ec.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &EsploraError{Status: resp.StatusCode, Message: strings.TrimSpace(string(b))}
+	}
+	return b, nil
+}
+
+func (ec *EsploraClient) get(path string) ([]byte, error) { return ec.do(http.MethodGet, path, nil) }
+
+func (ec *EsploraClient) getJSON(path string, r interface{}) error {
+	b, err := ec.get(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, r)
+}
+
+func (ec *EsploraClient) getBytes(path string) (types.Bytes, error) {
+	b, err := ec.get(path)
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseBytesHex(strings.TrimSpace(string(b)))
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*EsploraError)
+	return ok && e.Status == http.StatusNotFound
+}
+
+// BlockCount implement cryptocore.Client
+func (ec *EsploraClient) BlockCount() (uint64, error) {
+	b, err := ec.get("/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+	r, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	ec.mtx.Lock()
+	ec.tip = r
+	ec.mtx.Unlock()
+	return r, nil
+}
+
+// BlockHash implement cryptocore.Client
+func (ec *EsploraClient) BlockHash(height uint64) (types.Bytes, error) {
+	r, err := ec.getBytes(fmt.Sprintf("/block-height/%d", height))
+	if isNotFound(err) {
+		return nil, cryptocore.ErrNoBlock
+	}
+	return r, err
+}
+
+// Block implement cryptocore.Client
+func (ec *EsploraClient) Block(hash types.Bytes) (block.Block, error) {
+	r := &esploraBlock{}
+	if err := ec.getJSON("/block/"+hash.Hex(), r); err != nil {
+		return nil, err
+	}
+	ec.mtx.Lock()
+	r.tip = ec.tip
+	ec.mtx.Unlock()
+	return r, nil
+}
+
+// Transaction implement cryptocore.Client
+func (ec *EsploraClient) Transaction(hash types.Bytes) (tx.Tx, error) {
+	et := &esploraTx{}
+	if err := ec.getJSON("/tx/"+hash.Hex(), et); err != nil {
+		return nil, err
+	}
+	return ec.nodeTx(et)
+}
+
+// nodeTx converts a transaction to the node format
+func (ec *EsploraClient) nodeTx(et *esploraTx) (tx.Tx, error) {
+	type m = map[string]interface{}
+	vin := make([]m, 0, len(et.Vin))
+	for _, i := range et.Vin {
+		in := m{
+			"txid":      i.TxID,
+			"vout":      i.Vout,
+			"scriptSig": m{"hex": i.ScriptSig, "asm": i.ScriptSigAsm},
+			"sequence":  i.Sequence,
+		}
+		if i.IsCoinbase {
+			in = m{"coinbase": i.ScriptSig, "sequence": i.Sequence}
+		}
+		vin = append(vin, in)
+	}
+	vout := make([]m, 0, len(et.Vout))
+	for n, i := range et.Vout {
+		spk := m{"hex": i.ScriptPubKey, "asm": i.ScriptPubKeyAsm, "type": i.ScriptPubKeyType}
+		if i.ScriptPubKeyAddress != "" {
+			spk["addresses"] = []string{i.ScriptPubKeyAddress}
+		}
+		vout = append(vout, m{
+			"value":        types.NewAmount(i.Value, uint64(ec.c.Decimals)),
+			"n":            n,
+			"scriptPubKey": spk,
+		})
+	}
+	nt := m{"txid": et.TxID, "hash": et.TxID, "locktime": et.LockTime, "vin": vin, "vout": vout}
+	if et.Status.Confirmed {
+		ec.mtx.Lock()
+		tip := ec.tip
+		ec.mtx.Unlock()
+		nt["blockhash"] = et.Status.BlockHash
+		nt["blocktime"] = et.Status.BlockTime
+		if tip >= et.Status.BlockHeight {
+			nt["confirmations"] = tip - et.Status.BlockHeight + 1
+		}
+	}
+	b, err := json.Marshal(nt)
+	if err != nil {
+		return nil, err
+	}
+	r := ec.newTx()
+	if err = json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RawTransaction implement cryptocore.Client
+func (ec *EsploraClient) RawTransaction(hash types.Bytes) (types.Bytes, error) {
+	return ec.getBytes("/tx/" + hash.Hex() + "/hex")
+}
+
+// SendRawTransaction implement cryptocore.Client
+func (ec *EsploraClient) SendRawTransaction(b types.Bytes) (types.Bytes, error) {
+	r, err := ec.do(http.MethodPost, "/tx", []byte(b.Hex()))
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseBytesHex(strings.TrimSpace(string(r)))
+}
+
+// EstimateFee implement FeeEstimator. The estimate of the highest target up
+// to the requested one is used
+func (ec *EsploraClient) EstimateFee(target uint64) (float64, error) {
+	var r map[string]float64
+	if err := ec.getJSON("/fee-estimates", &r); err != nil {
+		return 0, err
+	}
+	targets := make([]uint64, 0, len(r))
+	rates := make(map[uint64]float64, len(r))
+	for k, v := range r {
+		t, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			continue
+		}
+		targets = append(targets, t)
+		rates[t] = v
+	}
+	if len(targets) == 0 {
+		return 0, ErrNoFeeEstimate
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+	t := targets[0]
+	for _, i := range targets {
+		if i > target {
+			break
+		}
+		t = i
+	}
+	if rates[t] <= 0 {
+		return 0, ErrNoFeeEstimate
+	}
+	// units per byte to coins per kilobyte
+	return rates[t] * 1000 / math.Pow10(ec.c.Decimals), nil
+}
+
+// chainParams returns the parameters of the chain of the api, nil if the
+// genesis block is unknown
+func (ec *EsploraClient) chainParams() (params.Params, error) {
+	ec.mtx.Lock()
+	p, found := ec.params, ec.paramsFound
+	ec.mtx.Unlock()
+	if found {
+		return p, nil
+	}
+	genesis, err := ec.BlockHash(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range networks.AllByName[ec.c.Name] {
+		if i.Network().GenesisHash == genesis.Hex() {
+			p = i
+			break
+		}
+	}
+	ec.mtx.Lock()
+	ec.params, ec.paramsFound = p, true
+	ec.mtx.Unlock()
+	return p, nil
+}
+
+// historyPath returns the path of the history of a script. The p2sh scripts
+// are looked up by address
+func (ec *EsploraClient) historyPath(script []byte) (string, error) {
+	if len(script) == 23 && script[0] == 0xa9 && script[1] == 0x14 && script[22] == 0x87 {
+		p, err := ec.chainParams()
+		if err != nil {
+			return "", err
+		}
+		if p != nil {
+			addr, err := p.P2SH(script[2:22])
+			if err != nil {
+				return "", err
+			}
+			return "/address/" + addr, nil
+		}
+	}
+	return "/scripthash/" + electrumScriptHash(script), nil
+}
+
+// ScriptHistory implement HistoryClient
+func (ec *EsploraClient) ScriptHistory(script []byte) ([]*HistoryItem, error) {
+	path, err := ec.historyPath(script)
+	if err != nil {
+		return nil, err
+	}
+	// the first page has the mempool and the first confirmed transactions
+	var page []*esploraTx
+	if err = ec.getJSON(path+"/txs", &page); err != nil {
+		return nil, err
+	}
+	r := make([]*HistoryItem, 0, len(page))
+	for {
+		var confirmed, last *esploraTx
+		nConfirmed := 0
+		for _, i := range page {
+			item := &HistoryItem{TxID: i.TxID}
+			if i.Status.Confirmed {
+				item.Height = i.Status.BlockHeight
+				confirmed, nConfirmed = i, nConfirmed+1
+			}
+			r = append(r, item)
+			last = i
+		}
+		if nConfirmed < esploraChainPageSize || confirmed != last {
+			return r, nil
+		}
+		page = page[:0]
+		if err = ec.getJSON(path+"/txs/chain/"+last.TxID.Hex(), &page); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Outspends implement OutspendClient
+func (ec *EsploraClient) Outspends(txID types.Bytes) ([]*Outspend, error) {
+	var outs []struct {
+		Spent  bool          `json:"spent"`
+		TxID   types.Bytes   `json:"txid"`
+		Vin    int           `json:"vin"`
+		Status esploraStatus `json:"status"`
+	}
+	if err := ec.getJSON("/tx/"+txID.Hex()+"/outspends", &outs); err != nil {
+		return nil, err
+	}
+	r := make([]*Outspend, 0, len(outs))
+	for _, i := range outs {
+		o := &Outspend{Spent: i.Spent, TxID: i.TxID, Input: i.Vin}
+		if i.Status.Confirmed {
+			o.Height = i.Status.BlockHeight
+		}
+		r = append(r, o)
+	}
+	return r, nil
+}
+
+func (b *esploraBlock) Hash() types.Bytes { return b.ID }
+
+func (b *esploraBlock) Confirmations() int {
+	if b.tip < uint64(b.BlockHeight) {
+		return 0
+	}
+	return int(b.tip-uint64(b.BlockHeight)) + 1
+}
+
+func (b *esploraBlock) Height() int { return b.BlockHeight }
+
+// Transactions returns nil, the transactions are found in the addresses
+// history
+func (b *esploraBlock) Transactions() []types.Bytes { return nil }
+
+func (b *esploraBlock) Time() types.UnixTime { return types.UnixTime(b.Timestamp) }
+
+func (b *esploraBlock) PreviousBlockHash() types.Bytes { return b.PreviousBlock }
+
+func (b *esploraBlock) NextBlockHash() types.Bytes { return nil }
@@ -0,0 +1,191 @@
+package chainutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/networks"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+const esploraGenesis = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+
+// esploraStub serves recorded responses by method and path
+type esploraStub struct {
+	mtx       sync.Mutex
+	responses map[string]string
+	posted    []string
+}
+
+func (s *esploraStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if r.Method == http.MethodPost {
+		b, _ := ioutil.ReadAll(r.Body)
+		s.posted = append(s.posted, string(b))
+	}
+	resp, ok := s.responses[r.Method+" "+r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+		return
+	}
+	w.Write([]byte(resp))
+}
+
+func esploraHash(b byte) string { return hex.EncodeToString(bytes.Repeat([]byte{b}, 32)) }
+
+func esploraTxJSON(id string, height int, ins string, outScript []byte, value uint64) string {
+	status := `{"confirmed":false}`
+	if height > 0 {
+		status = fmt.Sprintf(
+			`{"confirmed":true,"block_height":%d,"block_hash":"%s","block_time":%d}`,
+			height, esploraHash(byte(height)), 1600000000+height,
+		)
+	}
+	return fmt.Sprintf(
+		`{"txid":"%s","version":1,"locktime":0,"vin":[%s],"vout":[{"scriptpubkey":"%x","scriptpubkey_asm":"","scriptpubkey_type":"p2sh","value":%d}],"size":100,"weight":400,"fee":1000,"status":%s}`,
+		id, ins, outScript, value, status,
+	)
+}
+
+// newEsploraStub returns the recorded responses of a chain with a deposit to
+// the lock at height 2 redeemed at height 3
+func newEsploraStub(t *testing.T, lock []byte) (*esploraStub, []byte, string, string) {
+	gen, err := script.NewGenerator(cryptos.Bitcoin)
+	require.NoError(t, err, "can't create generator")
+	lockScript := gen.P2SHScript(lock)
+	addr, err := networks.All[cryptos.Bitcoin][params.MainNet].P2SHFromScript(lock)
+	require.NoError(t, err, "can't get address")
+	fundID, redeemID := esploraHash(0xf1), esploraHash(0xf2)
+	coinbase := `{"txid":"` + esploraHash(0) + `","vout":4294967295,"scriptsig":"0102","scriptsig_asm":"","is_coinbase":true,"sequence":4294967295}`
+	redeemIn := fmt.Sprintf(
+		`{"txid":"%s","vout":0,"scriptsig":"%x","scriptsig_asm":"","is_coinbase":false,"sequence":4294967295}`,
+		fundID, pushData(make([]byte, 71), lock),
+	)
+	fundTx := esploraTxJSON(fundID, 2, coinbase, lockScript, 50000)
+	redeemTx := esploraTxJSON(redeemID, 3, redeemIn, []byte{0x51}, 49000)
+	r := &esploraStub{responses: map[string]string{
+		"GET /blocks/tip/height":     "3",
+		"GET /block-height/0":        esploraGenesis,
+		"GET /tx/" + fundID:          fundTx,
+		"GET /tx/" + redeemID:        redeemTx,
+		"GET /tx/" + fundID + "/hex": "0100",
+		"GET /tx/" + fundID + "/outspends": fmt.Sprintf(
+			`[{"spent":true,"txid":"%s","vin":0,"status":{"confirmed":true,"block_height":3,"block_hash":"%s","block_time":1600000003}}]`,
+			redeemID, esploraHash(3),
+		),
+		"GET /address/" + addr + "/txs": "[" + redeemTx + "," + fundTx + "]",
+		"GET /fee-estimates":            `{"1":87.882,"3":70.1,"6":20.5,"144":1.027}`,
+		"POST /tx":                      esploraHash(0xf3),
+	}}
+	for i := 1; i <= 3; i++ {
+		r.responses[fmt.Sprintf("GET /block-height/%d", i)] = esploraHash(byte(i))
+		r.responses["GET /block/"+esploraHash(byte(i))] = fmt.Sprintf(
+			`{"id":"%s","height":%d,"version":1,"timestamp":%d,"tx_count":2,"size":300,"weight":1200,"merkle_root":"%s","previousblockhash":"%s","nonce":0,"bits":0}`,
+			esploraHash(byte(i)), i, 1600000000+i, esploraHash(0xee), esploraHash(byte(i-1)),
+		)
+	}
+	return r, lockScript, fundID, redeemID
+}
+
+func TestEsploraClient(t *testing.T) {
+	lock := bytes.Repeat([]byte{0xcc}, 40)
+	stub, lockScript, fundID, redeemID := newEsploraStub(t, lock)
+	// a script without address with more than a page of transactions
+	other := []byte{0x51, 0x52}
+	page := make([]string, 0, esploraChainPageSize)
+	for i := 0; i < esploraChainPageSize; i++ {
+		page = append(page, esploraTxJSON(esploraHash(byte(i+1)), 1, "", other, 1))
+	}
+	stub.responses["GET /scripthash/"+electrumScriptHash(other)+"/txs"] = "[" + strings.Join(page, ",") + "]"
+	stub.responses["GET /scripthash/"+electrumScriptHash(other)+"/txs/chain/"+esploraHash(esploraChainPageSize)] =
+		"[" + esploraTxJSON(esploraHash(0xa0), 1, "", other, 1) + "]"
+	s := httptest.NewServer(stub)
+	defer s.Close()
+	cl, err := NewEsploraClient(cryptos.Bitcoin, s.URL+"/", nil)
+	require.NoError(t, err, "can't create client")
+	n, err := cl.BlockCount()
+	require.NoError(t, err, "can't get block count")
+	require.Equal(t, uint64(3), n, "block count mismatch")
+	_, err = cl.BlockHash(4)
+	require.Equal(t, cryptocore.ErrNoBlock, err, "expecting no block")
+	h, err := cl.BlockHash(2)
+	require.NoError(t, err, "can't get block hash")
+	b, err := cl.Block(h)
+	require.NoError(t, err, "can't get block")
+	require.Equal(t, 2, b.Height(), "height mismatch")
+	require.Equal(t, 2, b.Confirmations(), "confirmations mismatch")
+	require.Equal(t, types.UnixTime(1600000002), b.Time(), "time mismatch")
+	require.Equal(t, esploraHash(1), b.PreviousBlockHash().Hex(), "previous block mismatch")
+	id, _ := types.ParseBytesHex(fundID)
+	tx, err := cl.Transaction(id)
+	require.NoError(t, err, "can't get transaction")
+	require.Equal(t, 2, tx.Confirmations(), "confirmations mismatch")
+	txUTXO, _ := tx.UTXO()
+	require.NotNil(t, txUTXO.Inputs()[0].Coinbase(), "expecting a coinbase input")
+	require.Len(t, txUTXO.Outputs(), 1, "outputs mismatch")
+	require.Equal(t, types.Amount("0.0005"), txUTXO.Outputs()[0].Value(), "value mismatch")
+	require.Equal(t, types.Bytes(lockScript), txUTXO.Outputs()[0].LockScript().Bytes(), "script mismatch")
+	raw, err := cl.RawTransaction(id)
+	require.NoError(t, err, "can't get raw transaction")
+	require.Equal(t, types.Bytes{0x01, 0x00}, raw, "raw transaction mismatch")
+	history, err := cl.ScriptHistory(lockScript)
+	require.NoError(t, err, "can't get history")
+	require.Len(t, history, 2, "history mismatch")
+	require.Equal(t, redeemID, history[0].TxID.Hex(), "history mismatch")
+	require.Equal(t, uint64(3), history[0].Height, "history mismatch")
+	history, err = cl.ScriptHistory(other)
+	require.NoError(t, err, "can't get history")
+	require.Len(t, history, esploraChainPageSize+1, "expecting the next page")
+	outs, err := cl.Outspends(id)
+	require.NoError(t, err, "can't get outspends")
+	require.Len(t, outs, 1, "outspends mismatch")
+	require.True(t, outs[0].Spent, "expecting a spent output")
+	require.Equal(t, redeemID, outs[0].TxID.Hex(), "spender mismatch")
+	require.Equal(t, uint64(3), outs[0].Height, "height mismatch")
+	sent, err := cl.SendRawTransaction([]byte{0x01, 0x02})
+	require.NoError(t, err, "can't broadcast")
+	require.Equal(t, esploraHash(0xf3), sent.Hex(), "transaction id mismatch")
+	require.Equal(t, []string{"0102"}, stub.posted, "posted transaction mismatch")
+	kb, err := cl.EstimateFee(10)
+	require.NoError(t, err, "can't estimate fee")
+	require.InDelta(t, 0.000205, kb, 1e-12, "fee mismatch")
+	rate, err := EstimateFeeRate(cryptos.Bitcoin, &ClientConfig{Backend: "esplora://" + strings.TrimPrefix(s.URL, "http://")}, 1)
+	require.NoError(t, err, "can't estimate fee")
+	require.Equal(t, uint64(88), rate, "fee rate mismatch")
+}
+
+func TestEsploraScanner(t *testing.T) {
+	lock := bytes.Repeat([]byte{0xcc}, 40)
+	stub, lockScript, fundID, redeemID := newEsploraStub(t, lock)
+	s := httptest.NewServer(stub)
+	defer s.Close()
+	cfg := &ClientConfig{Backend: "esplora://" + strings.TrimPrefix(s.URL, "http://")}
+	cl, err := cfg.NewClient(cryptos.Bitcoin)
+	require.NoError(t, err, "can't create client")
+	require.NoError(t, CheckNetwork(cl, cryptos.Bitcoin, params.MainNet), "network mismatch")
+	sc, err := cfg.NewScanner(cryptos.Bitcoin, cl)
+	require.NoError(t, err, "can't create scanner")
+	defer sc.Close()
+	require.Equal(t, QueryHistory, sc.QueryName(), "expecting history lookups")
+	res, errc := testWatch(sc, &ScanFilter{
+		OutputScripts: [][]byte{lockScript},
+		RedeemedLocks: [][]byte{lock},
+	}, 1, 3)
+	require.NoError(t, <-errc, "watch failed")
+	require.Equal(t, []string{fundID + ":0"}, res.outputs, "outputs mismatch")
+	require.Equal(t, []string{redeemID}, res.redeems, "redeems mismatch")
+}
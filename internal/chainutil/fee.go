@@ -0,0 +1,154 @@
+package chainutil
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+)
+
+// FeeLimits are the bounds of an estimated fee rate, in units per byte
+type FeeLimits struct {
+	Min uint64
+	Max uint64
+}
+
+// estimateFeeFunc returns the fee rate in coins per kilobyte
+type estimateFeeFunc func(cfg *ClientConfig, target uint64) (float64, error)
+
+var (
+	estimateFeeFuncs = map[string]estimateFeeFunc{
+		cryptos.Bitcoin.Name:     estimateSmartFee,
+		cryptos.Litecoin.Name:    estimateSmartFee,
+		cryptos.Dogecoin.Name:    estimateSmartFee,
+		cryptos.Decred.Name:      estimateSmartFee,
+		cryptos.BitcoinCash.Name: estimateFee,
+	}
+
+	feeLimits = map[string]*FeeLimits{
+		cryptos.Bitcoin.Name:     {Min: 1, Max: 1000},
+		cryptos.Litecoin.Name:    {Min: 1, Max: 1000},
+		cryptos.Dogecoin.Name:    {Min: 1000, Max: 100000},
+		cryptos.Decred.Name:      {Min: 10, Max: 1000},
+		cryptos.BitcoinCash.Name: {Min: 1, Max: 100},
+	}
+)
+
+// ErrNoFeeEstimate is returned when the node can't estimate the fee
+var ErrNoFeeEstimate = errors.New("no fee estimate available")
+
+// parseFeeRate parses a fee rate returned as a number or as an object with a
+// feerate field
+func parseFeeRate(b json.RawMessage) (float64, error) {
+	var r struct {
+		FeeRate *float64 `json:"feerate"`
+	}
+	if err := json.Unmarshal(b, &r.FeeRate); err != nil {
+		if err = json.Unmarshal(b, &r); err != nil {
+			return 0, err
+		}
+	}
+	if r.FeeRate == nil || *r.FeeRate <= 0 {
+		return 0, ErrNoFeeEstimate
+	}
+	return *r.FeeRate, nil
+}
+
+func estimateSmartFee(cfg *ClientConfig, target uint64) (float64, error) {
+	var r json.RawMessage
+	if err := cfg.Call("estimatesmartfee", []interface{}{target}, &r); err != nil {
+		return 0, err
+	}
+	return parseFeeRate(r)
+}
+
+// estimateFee is used by the nodes without smart fee estimation, the target
+// is ignored
+func estimateFee(cfg *ClientConfig, _ uint64) (float64, error) {
+	var r json.RawMessage
+	if err := cfg.Call("estimatefee", nil, &r); err != nil {
+		return 0, err
+	}
+	return parseFeeRate(r)
+}
+
+// estimateBackendFee returns the fee rate estimated by the backend, in coins
+// per kilobyte
+func estimateBackendFee(c *cryptos.Crypto, cfg *ClientConfig, target uint64) (float64, error) {
+	cl, err := cfg.NewClient(c)
+	if err != nil {
+		return 0, err
+	}
+	defer CloseClient(cl)
+	fe, ok := cl.(FeeEstimator)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	return fe.EstimateFee(target)
+}
+
+// CryptoFeeLimits returns the bounds of the estimated fee rate for a crypto
+func CryptoFeeLimits(c *cryptos.Crypto) (*FeeLimits, error) {
+	r, ok := feeLimits[c.Name]
+	if !ok {
+		return nil, ErrClientUnavailable
+	}
+	return r, nil
+}
+
+// EstimateFeeRate returns the fee rate, in units per byte, for the
+// confirmation target. The estimate is bounded by the crypto fee limits
+func EstimateFeeRate(c *cryptos.Crypto, cfg *ClientConfig, target uint64) (uint64, error) {
+	ef, ok := estimateFeeFuncs[c.Name]
+	if !ok {
+		return 0, ErrClientUnavailable
+	}
+	limits, err := CryptoFeeLimits(c)
+	if err != nil {
+		return 0, err
+	}
+	var kb float64
+	if cfg.Backend == "" {
+		kb, err = ef(cfg.forCrypto(c), target)
+	} else {
+		kb, err = estimateBackendFee(c, cfg, target)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// round to units per kilobyte first to avoid float errors
+	r := (uint64(math.Round(kb*math.Pow10(c.Decimals))) + 999) / 1000
+	if r < limits.Min {
+		return limits.Min, nil
+	}
+	if r > limits.Max {
+		return limits.Max, nil
+	}
+	return r, nil
+}
+
+// Fee represents the fee of a spend. It's a fixed fee, a fee per byte or,
+// if the value is zero and a target is set, a fee per byte estimated by the
+// node for the confirmation target
+type Fee struct {
+	Value  uint64
+	Fixed  bool
+	Target uint64
+}
+
+// Estimated returns true if the fee is estimated by the node
+func (f *Fee) Estimated() bool { return f.Value == 0 && f.Target > 0 }
+
+// Resolve returns the fee value and if it's a fixed fee, estimating the fee
+// per byte if needed
+func (f *Fee) Resolve(c *cryptos.Crypto, cfg *ClientConfig) (uint64, bool, error) {
+	if !f.Estimated() {
+		return f.Value, f.Fixed, nil
+	}
+	r, err := EstimateFeeRate(c, cfg, f.Target)
+	if err != nil {
+		return 0, false, err
+	}
+	return r, false, nil
+}
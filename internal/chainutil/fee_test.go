@@ -0,0 +1,93 @@
+package chainutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+)
+
+// newTestNode returns a node answering the methods handled by f
+func newTestNode(t *testing.T, f func(method string, params []interface{}) (string, bool)) (*ClientConfig, func()) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		require.True(t, ok, "missing auth")
+		require.Equal(t, "user", user, "username mismatch")
+		require.Equal(t, "pass", pass, "password mismatch")
+		req := &rpcRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(req), "can't decode request")
+		result, ok := f(req.Method, req.Params)
+		if !ok {
+			w.Write([]byte(`{"result":null,"error":{"code":-32601,"message":"Method not found"}}`))
+			return
+		}
+		w.Write([]byte(`{"result":` + result + `,"error":null}`))
+	}))
+	cfg := &ClientConfig{
+		Address:  strings.TrimPrefix(s.URL, "http://"),
+		Username: "user",
+		Password: "pass",
+	}
+	return cfg, s.Close
+}
+
+// nodeMethod returns a node handler answering a single method with result
+func nodeMethod(method string, result string) func(string, []interface{}) (string, bool) {
+	return func(m string, _ []interface{}) (string, bool) { return result, m == method }
+}
+
+func TestEstimateFeeRate(t *testing.T) {
+	for _, i := range []struct {
+		crypto *cryptos.Crypto
+		method string
+		result string
+		exp    uint64
+		err    error
+	}{
+		{cryptos.Bitcoin, "estimatesmartfee", `{"feerate":0.00012345,"blocks":6}`, 13, nil},
+		{cryptos.Bitcoin, "estimatesmartfee", `{"feerate":0.000001,"blocks":6}`, 1, nil},
+		{cryptos.Bitcoin, "estimatesmartfee", `{"feerate":1,"blocks":6}`, 1000, nil},
+		{cryptos.Bitcoin, "estimatesmartfee", `{"errors":["Insufficient data or no feerate found"],"blocks":0}`, 0, ErrNoFeeEstimate},
+		{cryptos.Litecoin, "estimatesmartfee", `{"feerate":0.0002,"blocks":2}`, 20, nil},
+		{cryptos.Dogecoin, "estimatesmartfee", `{"feerate":0.001,"blocks":2}`, 1000, nil},
+		{cryptos.Decred, "estimatesmartfee", `0.0002`, 20, nil},
+		{cryptos.BitcoinCash, "estimatefee", `0.00001`, 1, nil},
+		{cryptos.BitcoinCash, "estimatefee", `-1`, 0, ErrNoFeeEstimate},
+	} {
+		cfg, closeNode := newTestNode(t, nodeMethod(i.method, i.result))
+		r, err := EstimateFeeRate(i.crypto, cfg, 6)
+		closeNode()
+		if i.err != nil {
+			require.Equal(t, i.err, err, "expecting an error")
+			continue
+		}
+		require.NoError(t, err, "can't estimate fee")
+		require.Equal(t, i.exp, r, "fee rate mismatch")
+	}
+}
+
+func TestFeeResolve(t *testing.T) {
+	cfg, closeNode := newTestNode(t, nodeMethod("estimatesmartfee", `{"feerate":0.0001,"blocks":3}`))
+	defer closeNode()
+	for _, i := range []struct {
+		fee   *Fee
+		exp   uint64
+		fixed bool
+	}{
+		{&Fee{Value: 1000, Fixed: true, Target: 3}, 1000, true},
+		{&Fee{Value: 5, Target: 3}, 5, false},
+		{&Fee{Target: 3}, 10, false},
+		{&Fee{}, 0, false},
+	} {
+		r, fixed, err := i.fee.Resolve(cryptos.Bitcoin, cfg)
+		require.NoError(t, err, "can't resolve fee")
+		require.Equal(t, i.exp, r, "fee mismatch")
+		require.Equal(t, i.fixed, fixed, "fixed fee mismatch")
+	}
+	_, err := EstimateFeeRate(cryptos.BitcoinCash, cfg, 3)
+	require.IsType(t, &RPCError{}, err, "expecting an rpc error")
+}
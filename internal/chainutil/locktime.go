@@ -0,0 +1,88 @@
+package chainutil
+
+import (
+	"sort"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore"
+)
+
+// medianTimeBlocks is the number of blocks of the median time past
+const medianTimeBlocks = 11
+
+// MedianTimePast returns the median time of the last blocks of the chain. The
+// lock times are checked against it (BIP113)
+func MedianTimePast(cl cryptocore.Client) (time.Time, error) {
+	top, err := cl.BlockCount()
+	if err != nil {
+		return time.Time{}, err
+	}
+	times := make([]int64, 0, medianTimeBlocks)
+	for h := top; len(times) < medianTimeBlocks; h-- {
+		b, err := getBlockAtHeight(cl, h)
+		if err != nil {
+			return time.Time{}, err
+		}
+		times = append(times, int64(b.Time()))
+		if h == 0 {
+			break
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return time.Unix(times[len(times)/2], 0).UTC(), nil
+}
+
+// LockTime returns the lock time of the funds, or the zero time if the funds
+// aren't locked
+func LockTime(fd trade.FundsData) (time.Time, error) {
+	if fd == nil || fd.Lock() == nil || len(fd.Lock().Bytes()) == 0 {
+		return time.Time{}, nil
+	}
+	ld, err := fd.Lock().LockData()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ld.LockTime.UTC(), nil
+}
+
+// Deadlines holds the critical times of a trade. The times are zero when the
+// lock is missing
+type Deadlines struct {
+	// Recovery is the lock time of the own funds. The own funds can be
+	// recovered after it
+	Recovery time.Time
+	// Redeem is the lock time of the trader funds. The trader funds must be
+	// redeemed before it
+	Redeem time.Time
+}
+
+// TradeDeadlines returns the critical times of a trade from both locks
+func TradeDeadlines(tr trade.Trade) (*Deadlines, error) {
+	recovery, err := LockTime(tr.RecoverableFunds())
+	if err != nil {
+		return nil, err
+	}
+	redeem, err := LockTime(tr.RedeemableFunds())
+	if err != nil {
+		return nil, err
+	}
+	return &Deadlines{Recovery: recovery, Redeem: redeem}, nil
+}
+
+// FundsSpent returns true if any output of the funds is spent
+func FundsSpent(sf SpendFinder, fd trade.FundsData) (bool, error) {
+	outputs, _ := fd.Funds().([]*trade.Output)
+	for _, i := range outputs {
+		spender, err := sf.FindSpender(i.TxID, i.N)
+		if err == ErrSpenderUnknown {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+		if spender != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,70 @@
+package chainutil
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+func TestMedianTimePast(t *testing.T) {
+	c := &testChain{gate: make(chan struct{}), txs: map[string]*testTx{}, calls: map[string]int{}}
+	close(c.gate)
+	// out of order times, like the miners set them
+	for _, i := range []types.UnixTime{100, 300, 200, 500, 400} {
+		c.addBlock()
+		c.blocks[len(c.blocks)-1].time = i
+	}
+	r, err := MedianTimePast(c)
+	require.NoError(t, err, "can't get median time past")
+	require.Equal(t, time.Unix(300, 0).UTC(), r, "median time mismatch")
+	for i := 0; i < 20; i++ {
+		c.addBlock()
+		c.blocks[len(c.blocks)-1].time = types.UnixTime(1000 + i*10)
+	}
+	r, err = MedianTimePast(c)
+	require.NoError(t, err, "can't get median time past")
+	require.Equal(t, time.Unix(1140, 0).UTC(), r, "median time mismatch")
+}
+
+func TestLockTime(t *testing.T) {
+	lockTime := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+	fd := &testFunds{}
+	r, err := LockTime(fd)
+	require.NoError(t, err, "can't get lock time")
+	require.True(t, r.IsZero(), "expecting no lock time")
+	fd.lock = &testLock{b: []byte{0x01}, ld: &trade.LockData{LockTime: lockTime.Local()}}
+	r, err = LockTime(fd)
+	require.NoError(t, err, "can't get lock time")
+	require.Equal(t, lockTime, r, "lock time mismatch")
+}
+
+type spendStub map[string]error
+
+func (ss spendStub) FindSpender(txID types.Bytes, n uint32) (types.Bytes, error) {
+	err, ok := ss[txID.Hex()]
+	if !ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte{0x01}, nil
+}
+
+func TestFundsSpent(t *testing.T) {
+	a, b := bytes.Repeat([]byte{0x0a}, 32), bytes.Repeat([]byte{0x0b}, 32)
+	fd := &testFunds{outputs: []*trade.Output{{TxID: a}, {TxID: b}}}
+	spent, err := FundsSpent(spendStub{}, fd)
+	require.NoError(t, err, "can't check funds")
+	require.False(t, spent, "expecting unspent funds")
+	spent, err = FundsSpent(spendStub{types.Bytes(b).Hex(): nil}, fd)
+	require.NoError(t, err, "can't check funds")
+	require.True(t, spent, "expecting spent funds")
+	spent, err = FundsSpent(spendStub{types.Bytes(a).Hex(): ErrSpenderUnknown}, fd)
+	require.NoError(t, err, "can't check funds")
+	require.True(t, spent, "expecting spent funds")
+}
@@ -0,0 +1,70 @@
+package chainutil
+
+import (
+	"time"
+
+	"github.com/transmutate-io/atomicswap/internal/metricsutil"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/block"
+	"github.com/transmutate-io/cryptocore/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// metricsClient records the latency and the failures of the node calls used
+// by the scanners and the spends
+type metricsClient struct {
+	cryptocore.Client
+	crypto string
+}
+
+func (mc *metricsClient) observe(method string, start time.Time, err error) {
+	metricsutil.ObserveRPC(mc.crypto, method, start, err)
+}
+
+// BlockCount implement cryptocore.Client
+func (mc *metricsClient) BlockCount() (uint64, error) {
+	start := time.Now()
+	r, err := mc.Client.BlockCount()
+	mc.observe("getblockcount", start, err)
+	return r, err
+}
+
+// BlockHash implement cryptocore.Client
+func (mc *metricsClient) BlockHash(height uint64) (types.Bytes, error) {
+	start := time.Now()
+	r, err := mc.Client.BlockHash(height)
+	mc.observe("getblockhash", start, err)
+	return r, err
+}
+
+// Block implement cryptocore.Client
+func (mc *metricsClient) Block(hash types.Bytes) (block.Block, error) {
+	start := time.Now()
+	r, err := mc.Client.Block(hash)
+	mc.observe("getblock", start, err)
+	return r, err
+}
+
+// Transaction implement cryptocore.Client
+func (mc *metricsClient) Transaction(hash types.Bytes) (tx.Tx, error) {
+	start := time.Now()
+	r, err := mc.Client.Transaction(hash)
+	mc.observe("getrawtransaction", start, err)
+	return r, err
+}
+
+// RawTransaction implement cryptocore.Client
+func (mc *metricsClient) RawTransaction(hash types.Bytes) (types.Bytes, error) {
+	start := time.Now()
+	r, err := mc.Client.RawTransaction(hash)
+	mc.observe("getrawtransaction", start, err)
+	return r, err
+}
+
+// SendRawTransaction implement cryptocore.Client
+func (mc *metricsClient) SendRawTransaction(b types.Bytes) (types.Bytes, error) {
+	start := time.Now()
+	r, err := mc.Client.SendRawTransaction(b)
+	mc.observe("sendrawtransaction", start, err)
+	return r, err
+}
@@ -0,0 +1,55 @@
+package chainutil
+
+import (
+	"fmt"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/networks"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/cryptocore"
+)
+
+// GenesisMismatchError is returned when the node genesis block doesn't match
+// the selected network
+type GenesisMismatchError struct {
+	Crypto   string
+	Chain    params.Chain
+	Expected string
+	Got      string
+}
+
+func (e *GenesisMismatchError) Error() string {
+	return fmt.Sprintf(
+		"%s node is not on %s: genesis block is %s, expected %s",
+		e.Crypto, e.Chain, e.Got, e.Expected,
+	)
+}
+
+// CheckNetwork checks the node genesis block against the selected network
+func CheckNetwork(cl cryptocore.Client, c *cryptos.Crypto, chain params.Chain) error {
+	cp, ok := networks.AllByName[c.Name]
+	if !ok {
+		return ErrClientUnavailable
+	}
+	p, ok := cp[chain]
+	if !ok {
+		return params.InvalidChainError(chain.String())
+	}
+	expected := p.Network().GenesisHash
+	if expected == "" {
+		return nil
+	}
+	h, err := cl.BlockHash(0)
+	if err != nil {
+		return err
+	}
+	if got := h.Hex(); got != expected {
+		return &GenesisMismatchError{
+			Crypto:   c.Name,
+			Chain:    chain,
+			Expected: expected,
+			Got:      got,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package chainutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// ErrSpenderUnknown is returned when an output is spent by a transaction
+// that can't be found without scanning the chain
+var ErrSpenderUnknown = errors.New("spender unknown")
+
+// SpendFinder finds the transactions spending the outputs
+type SpendFinder interface {
+	// FindSpender returns the id of the transaction spending the output, or
+	// nil if it's unspent. It returns ErrSpenderUnknown if the output is spent
+	// by an unknown transaction
+	FindSpender(txID types.Bytes, n uint32) (types.Bytes, error)
+}
+
+type getTxOutParamsFunc func(txID types.Bytes, n uint32) []interface{}
+
+func getTxOutParamsBTC(txID types.Bytes, n uint32) []interface{} {
+	return []interface{}{txID.Hex(), n, true}
+}
+
+// the decred outputs are in the regular transactions tree
+func getTxOutParamsDCR(txID types.Bytes, n uint32) []interface{} {
+	return []interface{}{txID.Hex(), n, 0, true}
+}
+
+var getTxOutParams = map[string]getTxOutParamsFunc{
+	cryptos.Bitcoin.Name:     getTxOutParamsBTC,
+	cryptos.Litecoin.Name:    getTxOutParamsBTC,
+	cryptos.Dogecoin.Name:    getTxOutParamsBTC,
+	cryptos.Decred.Name:      getTxOutParamsDCR,
+	cryptos.BitcoinCash.Name: getTxOutParamsBTC,
+}
+
+// NewSpendFinder returns the spend finder of a client. The backends use the
+// outspends or the scripts index and the nodes use the utxo set and the
+// mempool. It returns nil if the spenders can't be found
+func NewSpendFinder(c *cryptos.Crypto, cl cryptocore.Client, cfg *ClientConfig) SpendFinder {
+	if oc, ok := cl.(OutspendClient); ok {
+		return &outspendFinder{oc: oc}
+	}
+	if hc, ok := cl.(HistoryClient); ok {
+		return &historyFinder{cl: cl, hc: hc}
+	}
+	if cfg == nil || cfg.Backend != "" {
+		return nil
+	}
+	p, ok := getTxOutParams[c.Name]
+	if !ok {
+		return nil
+	}
+	return &nodeFinder{cfg: cfg.forCrypto(c), params: p}
+}
+
+// outspendFinder finds the spenders with the outspends index of a backend
+type outspendFinder struct{ oc OutspendClient }
+
+// FindSpender implement SpendFinder
+func (of *outspendFinder) FindSpender(txID types.Bytes, n uint32) (types.Bytes, error) {
+	outs, err := of.oc.Outspends(txID)
+	if err != nil {
+		return nil, err
+	}
+	if int(n) >= len(outs) {
+		return nil, fmt.Errorf("output not found: %s", OutputID(txID, uint64(n)))
+	}
+	if !outs[n].Spent {
+		return nil, nil
+	}
+	return outs[n].TxID, nil
+}
+
+// historyFinder finds the spenders in the history of the output script
+type historyFinder struct {
+	cl cryptocore.Client
+	hc HistoryClient
+}
+
+// FindSpender implement SpendFinder
+func (hf *historyFinder) FindSpender(txID types.Bytes, n uint32) (types.Bytes, error) {
+	t, err := hf.cl.Transaction(txID)
+	if err != nil {
+		return nil, err
+	}
+	txUTXO, ok := t.UTXO()
+	if !ok {
+		return nil, ErrNotUTXO
+	}
+	var outScript []byte
+	for _, i := range txUTXO.Outputs() {
+		if i.N() == int(n) {
+			outScript = i.LockScript().Bytes()
+			break
+		}
+	}
+	if outScript == nil {
+		return nil, fmt.Errorf("output not found: %s", OutputID(txID, uint64(n)))
+	}
+	items, err := hf.hc.ScriptHistory(outScript)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range items {
+		if bytes.Equal(i.TxID, txID) {
+			continue
+		}
+		st, err := hf.cl.Transaction(i.TxID)
+		if err != nil {
+			return nil, err
+		}
+		stUTXO, ok := st.UTXO()
+		if !ok {
+			return nil, ErrNotUTXO
+		}
+		for _, j := range stUTXO.Inputs() {
+			if j.N() == int(n) && bytes.Equal(j.TransactionID(), txID) {
+				return i.TxID, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// nodeFinder checks the node utxo set and finds the spenders in the mempool
+type nodeFinder struct {
+	cfg    *ClientConfig
+	params getTxOutParamsFunc
+}
+
+// FindSpender implement SpendFinder
+func (nf *nodeFinder) FindSpender(txID types.Bytes, n uint32) (types.Bytes, error) {
+	var out json.RawMessage
+	if err := nf.cfg.Call("gettxout", nf.params(txID, n), &out); err != nil {
+		return nil, err
+	}
+	if string(out) != "null" {
+		return nil, nil
+	}
+	// spent, look in the mempool. Older nodes don't have the method
+	var r []struct {
+		SpendingTxID types.Bytes `json:"spendingtxid"`
+	}
+	prevout := map[string]interface{}{"txid": txID.Hex(), "vout": n}
+	err := nf.cfg.Call("gettxspendingprevout", []interface{}{[]interface{}{prevout}}, &r)
+	if err != nil || len(r) == 0 || len(r[0].SpendingTxID) == 0 {
+		return nil, ErrSpenderUnknown
+	}
+	return r[0].SpendingTxID, nil
+}
+
+// FindSecretToken looks up the redeem of the own funds with the spend finder
+// and sets the secret token on the trade when found. It returns true if the
+// lookup is conclusive, either the token was found or the funds are unspent,
+// and false if the chain must be scanned
+func FindSecretToken(sf SpendFinder, cl cryptocore.Client, tr trade.Trade) (types.Bytes, bool, error) {
+	fd := tr.RecoverableFunds()
+	outputs, ok := fd.Funds().([]*trade.Output)
+	if !ok || len(outputs) == 0 {
+		return nil, false, nil
+	}
+	for _, i := range outputs {
+		spender, err := sf.FindSpender(i.TxID, i.N)
+		if err == ErrSpenderUnknown {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		if spender == nil {
+			continue
+		}
+		t, err := cl.Transaction(spender)
+		if err != nil {
+			return nil, false, err
+		}
+		token, err := ExtractToken(tr.OwnInfo().Crypto, t, fd.Lock())
+		if err != nil {
+			return nil, false, err
+		}
+		// spent by the recovery
+		if token == nil {
+			continue
+		}
+		tr.SetToken(token)
+		return token, true, nil
+	}
+	return nil, true, nil
+}
+
+// RedeemBroadcast returns true if the trader funds are spent by a redeem
+// revealing the secret token. It returns ErrSpenderUnknown if the spender
+// can't be found without scanning the chain
+func RedeemBroadcast(sf SpendFinder, cl cryptocore.Client, tr trade.Trade) (bool, error) {
+	fd := tr.RedeemableFunds()
+	outputs, ok := fd.Funds().([]*trade.Output)
+	if !ok || len(outputs) == 0 {
+		return false, nil
+	}
+	for _, i := range outputs {
+		spender, err := sf.FindSpender(i.TxID, i.N)
+		if err != nil {
+			return false, err
+		}
+		if spender == nil {
+			continue
+		}
+		t, err := cl.Transaction(spender)
+		if err != nil {
+			return false, err
+		}
+		token, err := ExtractToken(tr.TraderInfo().Crypto, t, fd.Lock())
+		if err != nil {
+			return false, err
+		}
+		if token != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
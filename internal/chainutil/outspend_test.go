@@ -0,0 +1,200 @@
+package chainutil
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/networks"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+type (
+	testLock struct {
+		b  types.Bytes
+		ld *trade.LockData
+	}
+
+	testFunds struct {
+		outputs []*trade.Output
+		lock    trade.Lock
+	}
+
+	// testTrade is a trade with own funds waiting for the redeem
+	testTrade struct {
+		trade.Trade
+		funds *testFunds
+		token types.Bytes
+	}
+
+	// historyOnly hides the outspends of a backend
+	historyOnly struct {
+		cryptocore.Client
+		HistoryClient
+	}
+)
+
+func (l *testLock) Bytes() types.Bytes                         { return l.b }
+func (l *testLock) LockData() (*trade.LockData, error)         { return l.ld, nil }
+func (l *testLock) Address(chain params.Chain) (string, error) { return "", nil }
+
+func (f *testFunds) AddFunds(funds interface{}) {}
+func (f *testFunds) Funds() interface{}         { return f.outputs }
+func (f *testFunds) SetLock(lock trade.Lock)    { f.lock = lock }
+func (f *testFunds) Lock() trade.Lock           { return f.lock }
+
+func (t *testTrade) OwnInfo() *trade.TraderInfo        { return &trade.TraderInfo{Crypto: cryptos.Bitcoin} }
+func (t *testTrade) RecoverableFunds() trade.FundsData { return t.funds }
+func (t *testTrade) SetToken(token types.Bytes)        { t.token = token }
+
+// newTokenStub returns an esplora stub with a redeem revealing the token
+func newTokenStub(t *testing.T, token []byte) (*esploraStub, *testTrade) {
+	lock := bytes.Repeat([]byte{0xcc}, 40)
+	stub, _, fundID, redeemID := newEsploraStub(t, lock)
+	pubKey := bytes.Repeat([]byte{0x02}, 33)
+	h, err := hash.New(cryptos.Bitcoin)
+	require.NoError(t, err, "can't create hash")
+	redeemIn := fmt.Sprintf(
+		`{"txid":"%s","vout":0,"scriptsig":"%x","scriptsig_asm":"","is_coinbase":false,"sequence":4294967295}`,
+		fundID, pushData(make([]byte, 71), pubKey, token, nil, lock),
+	)
+	redeemTx := esploraTxJSON(redeemID, 3, redeemIn, []byte{0x51}, 49000)
+	fundTx := stub.responses["GET /tx/"+fundID]
+	addr, err := networks.All[cryptos.Bitcoin][params.MainNet].P2SHFromScript(lock)
+	require.NoError(t, err, "can't get address")
+	stub.responses["GET /tx/"+redeemID] = redeemTx
+	stub.responses["GET /address/"+addr+"/txs"] = "[" + redeemTx + "," + fundTx + "]"
+	id, err := types.ParseBytesHex(fundID)
+	require.NoError(t, err, "can't parse id")
+	tr := &testTrade{funds: &testFunds{
+		outputs: []*trade.Output{{TxID: id, N: 0, Amount: 50000}},
+		lock:    &testLock{b: lock, ld: &trade.LockData{RedeemKeyData: h.Hash160(pubKey)}},
+	}}
+	return stub, tr
+}
+
+func TestFindSecretToken(t *testing.T) {
+	token := bytes.Repeat([]byte{0x5e}, 32)
+	stub, tr := newTokenStub(t, token)
+	s := httptest.NewServer(stub)
+	defer s.Close()
+	cfg := &ClientConfig{Backend: "esplora://" + strings.TrimPrefix(s.URL, "http://")}
+	cl, err := cfg.NewClient(cryptos.Bitcoin)
+	require.NoError(t, err, "can't create client")
+	sc, err := cfg.NewScanner(cryptos.Bitcoin, cl)
+	require.NoError(t, err, "can't create scanner")
+	defer sc.Close()
+	require.IsType(t, &outspendFinder{}, sc.finder, "expecting outspends lookups")
+	// found without scanning the blocks
+	closec := make(chan struct{})
+	close(closec)
+	r, err := WatchSecretToken(sc, tr, &BlockWatchData{}, 0, closec, nil)
+	require.NoError(t, err, "can't watch secret token")
+	require.Equal(t, types.Bytes(token), r, "token mismatch")
+	require.Equal(t, types.Bytes(token), tr.token, "token not set")
+	// the scripts history
+	hf := NewSpendFinder(cryptos.Bitcoin, &historyOnly{Client: cl, HistoryClient: cl.(HistoryClient)}, cfg)
+	require.IsType(t, &historyFinder{}, hf, "expecting history lookups")
+	tr.token = nil
+	r, ok, err := FindSecretToken(hf, cl, tr)
+	require.NoError(t, err, "can't find secret token")
+	require.True(t, ok, "expecting a conclusive lookup")
+	require.Equal(t, types.Bytes(token), r, "token mismatch")
+	require.Equal(t, types.Bytes(token), tr.token, "token not set")
+	// unspent funds
+	stub.mtx.Lock()
+	for k := range stub.responses {
+		if strings.HasSuffix(k, "/outspends") {
+			stub.responses[k] = `[{"spent":false}]`
+		}
+	}
+	stub.mtx.Unlock()
+	tr.token = nil
+	r, ok, err = FindSecretToken(sc.finder, cl, tr)
+	require.NoError(t, err, "can't find secret token")
+	require.True(t, ok, "expecting a conclusive lookup")
+	require.Nil(t, r, "expecting no token")
+	require.Nil(t, tr.token, "expecting no token")
+}
+
+// redeemTrade is a trade with trader funds to redeem
+type redeemTrade struct{ *testTrade }
+
+func (t *redeemTrade) TraderInfo() *trade.TraderInfo     { return t.OwnInfo() }
+func (t *redeemTrade) RedeemableFunds() trade.FundsData  { return t.funds }
+func (t *redeemTrade) RecoverableFunds() trade.FundsData { return &testFunds{} }
+
+func TestRedeemBroadcast(t *testing.T) {
+	stub, tr := newTokenStub(t, bytes.Repeat([]byte{0x5e}, 32))
+	s := httptest.NewServer(stub)
+	defer s.Close()
+	cfg := &ClientConfig{Backend: "esplora://" + strings.TrimPrefix(s.URL, "http://")}
+	cl, err := cfg.NewClient(cryptos.Bitcoin)
+	require.NoError(t, err, "can't create client")
+	sf := NewSpendFinder(cryptos.Bitcoin, cl, cfg)
+	rtr := &redeemTrade{testTrade: tr}
+	ok, err := RedeemBroadcast(sf, cl, rtr)
+	require.NoError(t, err, "can't check redeem")
+	require.True(t, ok, "expecting a redeem")
+	// unspent funds
+	stub.mtx.Lock()
+	for k := range stub.responses {
+		if strings.HasSuffix(k, "/outspends") {
+			stub.responses[k] = `[{"spent":false}]`
+		}
+	}
+	stub.mtx.Unlock()
+	ok, err = RedeemBroadcast(sf, cl, rtr)
+	require.NoError(t, err, "can't check redeem")
+	require.False(t, ok, "expecting no redeem")
+}
+
+func TestNodeSpendFinder(t *testing.T) {
+	var spending string
+	spent := map[uint32]bool{1: true, 2: true}
+	cfg, closeNode := newTestNode(t, func(method string, params []interface{}) (string, bool) {
+		switch method {
+		case "gettxout":
+			require.Len(t, params, 3, "params mismatch")
+			if spent[uint32(params[1].(float64))] {
+				return "null", true
+			}
+			return `{"value":1.5,"confirmations":2}`, true
+		case "gettxspendingprevout":
+			if spending == "" {
+				return "", false
+			}
+			prevout := params[0].([]interface{})[0].(map[string]interface{})
+			if prevout["vout"].(float64) != 1 {
+				return `[{}]`, true
+			}
+			return `[{"txid":"` + prevout["txid"].(string) + `","vout":1,"spendingtxid":"` + spending + `"}]`, true
+		default:
+			return "", false
+		}
+	})
+	defer closeNode()
+	sf := NewSpendFinder(cryptos.Bitcoin, &testChain{}, cfg)
+	require.IsType(t, &nodeFinder{}, sf, "expecting node lookups")
+	txID := bytes.Repeat([]byte{0x01}, 32)
+	r, err := sf.FindSpender(txID, 0)
+	require.NoError(t, err, "can't find spender")
+	require.Nil(t, r, "expecting an unspent output")
+	_, err = sf.FindSpender(txID, 1)
+	require.Equal(t, ErrSpenderUnknown, err, "expecting an unknown spender")
+	spending = esploraHash(0xf2)
+	r, err = sf.FindSpender(txID, 1)
+	require.NoError(t, err, "can't find spender")
+	require.Equal(t, spending, r.Hex(), "spender mismatch")
+	_, err = sf.FindSpender(txID, 2)
+	require.Equal(t, ErrSpenderUnknown, err, "expecting an unknown spender")
+	require.Nil(t, NewSpendFinder(cryptos.Bitcoin, &testChain{}, nil), "expecting no finder")
+}
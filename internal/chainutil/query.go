@@ -0,0 +1,373 @@
+package chainutil
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil/gcs"
+	"github.com/btcsuite/btcutil/gcs/builder"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/block"
+	"github.com/transmutate-io/cryptocore/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// chain query strategies
+const (
+	// QueryAuto picks the fastest strategy supported by the node that finds
+	// every matching transaction
+	QueryAuto = "auto"
+	// QueryScan fetches every transaction of every block
+	QueryScan = "scan"
+	// QueryUTXOSet looks up the outputs in the node utxo set (scantxoutset).
+	// It's never picked automatically: the deposits already spent aren't in
+	// the utxo set and each lookup is a slow scan holding the node utxo set
+	// exclusively
+	QueryUTXOSet = "utxoset"
+	// QueryFilters fetches only the blocks matching the BIP158 compact block
+	// filters (getblockfilter)
+	QueryFilters = "filters"
+	// QueryHistory looks up the scripts history in a backend indexing them
+	QueryHistory = "history"
+)
+
+// QueryStrategies are the available chain query strategies
+var QueryStrategies = []string{QueryAuto, QueryScan, QueryUTXOSet, QueryFilters, QueryHistory}
+
+// InvalidQueryError is returned for an unknown chain query strategy
+type InvalidQueryError string
+
+func (e InvalidQueryError) Error() string { return "invalid chain query: " + string(e) }
+
+type (
+	// Query holds the scripts looked up in a block
+	Query struct {
+		// OutputScripts are the scripts of the outputs
+		OutputScripts [][]byte
+		// SpentScripts are the scripts of the outputs spent by the inputs
+		SpentScripts [][]byte
+	}
+
+	// ChainQuery selects the transactions of a block inspected by a scanner
+	ChainQuery interface {
+		// Name returns the name of the strategy
+		Name() string
+		// Transactions returns the transactions of the block that can match
+		// the query. The transactions left out are known not to match
+		Transactions(b block.Block, q *Query, closec <-chan struct{}) ([]tx.Tx, error)
+	}
+)
+
+func (q *Query) empty() bool { return len(q.OutputScripts) == 0 && len(q.SpentScripts) == 0 }
+
+// NewChainQuery returns the chain query strategy with the given name. The
+// configuration is used for the methods not available in the node client
+func NewChainQuery(name string, cl cryptocore.Client, cfg *ClientConfig) (ChainQuery, error) {
+	// the blocks of the backends don't list the transactions
+	if hc, ok := cl.(HistoryClient); ok {
+		switch name {
+		case QueryAuto, QueryHistory, "":
+			return newHistoryQuery(cl, hc), nil
+		default:
+			return nil, InvalidQueryError(name)
+		}
+	}
+	switch name {
+	case QueryAuto, "":
+		return autoQuery(cl, cfg)
+	case QueryScan:
+		return &scanQuery{cl: cl}, nil
+	case QueryUTXOSet:
+		return newUTXOSetQuery(cl, cfg, &scanQuery{cl: cl}), nil
+	case QueryFilters:
+		return &filtersQuery{cl: cl, cfg: cfg}, nil
+	default:
+		return nil, InvalidQueryError(name)
+	}
+}
+
+// autoQuery probes the node for the compact block filters, falling back to
+// fetching every transaction. The utxo set lookups must be picked explicitly
+func autoQuery(cl cryptocore.Client, cfg *ClientConfig) (ChainQuery, error) {
+	if cfg == nil {
+		return &scanQuery{cl: cl}, nil
+	}
+	if fq := (&filtersQuery{cl: cl, cfg: cfg}); fq.supported() {
+		return fq, nil
+	}
+	return &scanQuery{cl: cl}, nil
+}
+
+// scanQuery fetches every transaction of the block
+type scanQuery struct{ cl cryptocore.Client }
+
+// Name implement ChainQuery
+func (sq *scanQuery) Name() string { return QueryScan }
+
+// Transactions implement ChainQuery
+func (sq *scanQuery) Transactions(b block.Block, q *Query, closec <-chan struct{}) ([]tx.Tx, error) {
+	if q.empty() {
+		return nil, nil
+	}
+	return blockTransactions(sq.cl, b.Transactions(), closec)
+}
+
+// filtersQuery fetches the transactions of the blocks matching the basic
+// compact block filter
+type filtersQuery struct {
+	cl  cryptocore.Client
+	cfg *ClientConfig
+}
+
+// Name implement ChainQuery
+func (fq *filtersQuery) Name() string { return QueryFilters }
+
+func (fq *filtersQuery) blockFilter(blockHash types.Bytes) (*gcs.Filter, error) {
+	var r struct {
+		Filter string `json:"filter"`
+	}
+	if err := fq.cfg.Call("getblockfilter", []interface{}{blockHash.Hex(), "basic"}, &r); err != nil {
+		return nil, err
+	}
+	b, err := hex.DecodeString(r.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return gcs.FromNBytes(builder.DefaultP, builder.DefaultM, b)
+}
+
+// supported returns true if the node has the block filters index
+func (fq *filtersQuery) supported() bool {
+	n, err := fq.cl.BlockCount()
+	if err != nil {
+		return false
+	}
+	bh, err := fq.cl.BlockHash(n)
+	if err != nil {
+		return false
+	}
+	_, err = fq.blockFilter(bh)
+	return err == nil
+}
+
+// Transactions implement ChainQuery
+func (fq *filtersQuery) Transactions(b block.Block, q *Query, closec <-chan struct{}) ([]tx.Tx, error) {
+	if q.empty() {
+		return nil, nil
+	}
+	f, err := fq.blockFilter(b.Hash())
+	if err != nil {
+		return nil, err
+	}
+	h, err := chainhash.NewHashFromStr(b.Hash().Hex())
+	if err != nil {
+		return nil, err
+	}
+	scripts := make([][]byte, 0, len(q.OutputScripts)+len(q.SpentScripts))
+	scripts = append(append(scripts, q.OutputScripts...), q.SpentScripts...)
+	ok, err := f.MatchAny(builder.DeriveKey(h), scripts)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return blockTransactions(fq.cl, b.Transactions(), closec)
+}
+
+// utxoSetScan holds the unspent outputs of a script found in the utxo set
+type utxoSetScan struct {
+	// height of the utxo set
+	height uint64
+	// transactions by height
+	txs map[uint64][]types.Bytes
+}
+
+// utxoSetQuery finds the unspent outputs in the node utxo set. The outputs
+// spent before the scan of the utxo set are not found. The blocks after the
+// scan and the queries for inputs are passed to the fallback strategy
+type utxoSetQuery struct {
+	cl       cryptocore.Client
+	cfg      *ClientConfig
+	fallback ChainQuery
+	scans    map[string]*utxoSetScan
+}
+
+func newUTXOSetQuery(cl cryptocore.Client, cfg *ClientConfig, fallback ChainQuery) *utxoSetQuery {
+	return &utxoSetQuery{
+		cl:       cl,
+		cfg:      cfg,
+		fallback: fallback,
+		scans:    make(map[string]*utxoSetScan, 16),
+	}
+}
+
+// Name implement ChainQuery
+func (uq *utxoSetQuery) Name() string { return QueryUTXOSet }
+
+type scanTxOutSetResult struct {
+	Height   uint64 `json:"height"`
+	Unspents []struct {
+		TxID         types.Bytes `json:"txid"`
+		ScriptPubKey types.Bytes `json:"scriptPubKey"`
+		Height       uint64      `json:"height"`
+	} `json:"unspents"`
+}
+
+// scan looks up the scripts in the utxo set
+func (uq *utxoSetQuery) scan(scripts [][]byte) error {
+	descs := make([]interface{}, 0, len(scripts))
+	for _, i := range scripts {
+		descs = append(descs, map[string]string{"desc": "raw(" + hex.EncodeToString(i) + ")"})
+	}
+	var r scanTxOutSetResult
+	if err := uq.cfg.Call("scantxoutset", []interface{}{"start", descs}, &r); err != nil {
+		return err
+	}
+	// older nodes don't report the heights and every block is passed to
+	// the fallback strategy
+	for _, i := range scripts {
+		uq.scans[string(i)] = &utxoSetScan{height: r.Height, txs: map[uint64][]types.Bytes{}}
+	}
+	for _, i := range r.Unspents {
+		s, ok := uq.scans[string(i.ScriptPubKey)]
+		if !ok {
+			continue
+		}
+		s.txs[i.Height] = append(s.txs[i.Height], i.TxID)
+	}
+	return nil
+}
+
+// Transactions implement ChainQuery
+func (uq *utxoSetQuery) Transactions(b block.Block, q *Query, closec <-chan struct{}) ([]tx.Tx, error) {
+	if q.empty() {
+		return nil, nil
+	}
+	if len(q.SpentScripts) > 0 {
+		return uq.fallback.Transactions(b, q, closec)
+	}
+	pending := make([][]byte, 0, len(q.OutputScripts))
+	for _, i := range q.OutputScripts {
+		if _, ok := uq.scans[string(i)]; !ok {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) > 0 {
+		if err := uq.scan(pending); err != nil {
+			return nil, err
+		}
+	}
+	height := uint64(b.Height())
+	txIDs := make([]types.Bytes, 0, 4)
+	seen := make(map[string]struct{}, 4)
+	for _, i := range q.OutputScripts {
+		s := uq.scans[string(i)]
+		if height > s.height {
+			return uq.fallback.Transactions(b, q, closec)
+		}
+		for _, j := range s.txs[height] {
+			if _, ok := seen[string(j)]; ok {
+				continue
+			}
+			seen[string(j)] = struct{}{}
+			txIDs = append(txIDs, j)
+		}
+	}
+	return blockTransactions(uq.cl, txIDs, closec)
+}
+
+// historyQuery fetches the transactions in the history of the scripts at the
+// height of the block
+type historyQuery struct {
+	cl      cryptocore.Client
+	hc      HistoryClient
+	mtx     sync.Mutex
+	history map[string]*scriptHistory
+}
+
+// scriptHistory is the history of a script up to a height
+type scriptHistory struct {
+	height  uint64
+	items   []*HistoryItem
+	changed <-chan struct{}
+}
+
+func newHistoryQuery(cl cryptocore.Client, hc HistoryClient) *historyQuery {
+	return &historyQuery{cl: cl, hc: hc, history: make(map[string]*scriptHistory, 16)}
+}
+
+// Name implement ChainQuery
+func (hq *historyQuery) Name() string { return QueryHistory }
+
+// stale returns true if the history must be fetched again for the height
+func (sh *scriptHistory) stale(height uint64) bool {
+	if height > sh.height {
+		return true
+	}
+	select {
+	case _, ok := <-sh.changed:
+		if !ok {
+			sh.changed = nil
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// scriptHistory returns the history of a script including the height
+func (hq *historyQuery) scriptHistory(script []byte, height uint64) ([]*HistoryItem, error) {
+	hq.mtx.Lock()
+	defer hq.mtx.Unlock()
+	sh, ok := hq.history[string(script)]
+	if ok && !sh.stale(height) {
+		return sh.items, nil
+	}
+	if !ok {
+		sh = &scriptHistory{}
+		// subscribe before fetching to catch the changes in between
+		if ss, ok := hq.hc.(ScriptSubscriber); ok {
+			changed, err := ss.SubscribeScript(script)
+			if err != nil {
+				return nil, err
+			}
+			sh.changed = changed
+		}
+		hq.history[string(script)] = sh
+	}
+	tip, err := hq.cl.BlockCount()
+	if err != nil {
+		return nil, err
+	}
+	if sh.items, err = hq.hc.ScriptHistory(script); err != nil {
+		return nil, err
+	}
+	sh.height = tip
+	return sh.items, nil
+}
+
+// Transactions implement ChainQuery
+func (hq *historyQuery) Transactions(b block.Block, q *Query, closec <-chan struct{}) ([]tx.Tx, error) {
+	height := uint64(b.Height())
+	txIDs := make([]types.Bytes, 0, 4)
+	seen := make(map[string]struct{}, 4)
+	for _, scripts := range [][][]byte{q.OutputScripts, q.SpentScripts} {
+		for _, i := range scripts {
+			items, err := hq.scriptHistory(i, height)
+			if err != nil {
+				return nil, err
+			}
+			for _, j := range items {
+				// unconfirmed transactions have no height
+				if j.Height == 0 || j.Height != height {
+					continue
+				}
+				if _, ok := seen[string(j.TxID)]; ok {
+					continue
+				}
+				seen[string(j.TxID)] = struct{}{}
+				txIDs = append(txIDs, j.TxID)
+			}
+		}
+	}
+	return blockTransactions(hq.cl, txIDs, closec)
+}
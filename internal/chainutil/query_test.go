@@ -0,0 +1,130 @@
+package chainutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil/gcs"
+	"github.com/btcsuite/btcutil/gcs/builder"
+	"github.com/stretchr/testify/require"
+)
+
+// testBlockFilter returns the basic filter of a block with the scripts
+func testBlockFilter(t *testing.T, blockHash string, scripts ...[]byte) string {
+	h, err := chainhash.NewHashFromStr(blockHash)
+	require.NoError(t, err, "can't parse block hash")
+	f, err := gcs.BuildGCSFilter(builder.DefaultP, builder.DefaultM, builder.DeriveKey(h), scripts)
+	require.NoError(t, err, "can't build filter")
+	b, err := f.NBytes()
+	require.NoError(t, err, "can't serialize filter")
+	return `{"filter":"` + hex.EncodeToString(b) + `"}`
+}
+
+func TestFiltersQuery(t *testing.T) {
+	scriptA := append([]byte{0xa9, 0x14}, bytes.Repeat([]byte{0xaa}, 21)...)
+	scriptB := append([]byte{0xa9, 0x14}, bytes.Repeat([]byte{0xbb}, 21)...)
+	chain := newTestChain(scriptA, scriptB, nil)
+	close(chain.gate)
+	filters := map[string][][]byte{
+		"01": {scriptA, bytes.Repeat([]byte{0x01}, 23)},
+		"02": {scriptB},
+		"04": {scriptA, scriptB},
+	}
+	cfg, closeNode := newTestNode(t, func(method string, params []interface{}) (string, bool) {
+		if method != "getblockfilter" {
+			return "", false
+		}
+		blockHash := params[0].(string)
+		return testBlockFilter(t, blockHash, filters[blockHash]...), true
+	})
+	defer closeNode()
+	q, err := NewChainQuery(QueryAuto, chain, cfg)
+	require.NoError(t, err, "can't create chain query")
+	require.Equal(t, QueryFilters, q.Name(), "expecting compact block filters")
+	for _, i := range []struct {
+		height  int
+		scripts [][]byte
+		txs     int
+	}{
+		{1, [][]byte{scriptA}, 1},
+		{2, [][]byte{scriptA}, 0},
+		{2, [][]byte{scriptA, scriptB}, 2},
+		{4, [][]byte{scriptB}, 1},
+		{4, nil, 0},
+	} {
+		chain.calls = map[string]int{}
+		txs, err := q.Transactions(chain.blocks[i.height], &Query{OutputScripts: i.scripts}, nil)
+		require.NoError(t, err, "can't query block")
+		require.Len(t, txs, i.txs, "transactions mismatch")
+		require.Equal(t, i.txs, chain.calls["transaction"], "unexpected transactions fetched")
+	}
+}
+
+func TestUTXOSetQuery(t *testing.T) {
+	scriptA := append([]byte{0xa9, 0x14}, bytes.Repeat([]byte{0xaa}, 21)...)
+	chain := newTestChain(scriptA, nil, nil)
+	close(chain.gate)
+	scans := 0
+	cfg, closeNode := newTestNode(t, func(method string, params []interface{}) (string, bool) {
+		if method != "scantxoutset" {
+			return "", false
+		}
+		scans++
+		return `{"height":3,"unspents":[{"txid":"30313030","scriptPubKey":"` +
+			hex.EncodeToString(scriptA) + `","height":1}]}`, true
+	})
+	defer closeNode()
+	q, err := NewChainQuery(QueryUTXOSet, chain, cfg)
+	require.NoError(t, err, "can't create chain query")
+	for _, i := range []struct {
+		height int
+		query  *Query
+		txs    []string
+	}{
+		{1, &Query{OutputScripts: [][]byte{scriptA}}, []string{"30313030"}},
+		{2, &Query{OutputScripts: [][]byte{scriptA}}, []string{}},
+		// past the scanned utxo set
+		{4, &Query{OutputScripts: [][]byte{scriptA}}, []string{"30343030"}},
+		// inputs are not in the utxo set
+		{3, &Query{SpentScripts: [][]byte{scriptA}}, []string{"30333030"}},
+	} {
+		txs, err := q.Transactions(chain.blocks[i.height], i.query, nil)
+		require.NoError(t, err, "can't query block")
+		ids := make([]string, 0, len(txs))
+		for _, j := range txs {
+			ids = append(ids, j.ID().Hex())
+		}
+		require.Equal(t, i.txs, ids, "transactions mismatch")
+	}
+	require.Equal(t, 1, scans, "utxo set scanned more than once")
+}
+
+func TestNewChainQuery(t *testing.T) {
+	chain := newTestChain(nil, nil, nil)
+	close(chain.gate)
+	cfg, closeNode := newTestNode(t, func(string, []interface{}) (string, bool) { return "", false })
+	defer closeNode()
+	// the utxo set lookups are never picked automatically
+	utxoCfg, closeUTXONode := newTestNode(t, nodeMethod("scantxoutset", "null"))
+	defer closeUTXONode()
+	for _, i := range []struct {
+		name string
+		cfg  *ClientConfig
+		exp  string
+	}{
+		{QueryAuto, cfg, QueryScan},
+		{QueryAuto, utxoCfg, QueryScan},
+		{"", nil, QueryScan},
+		{QueryScan, cfg, QueryScan},
+		{QueryUTXOSet, cfg, QueryUTXOSet},
+		{QueryFilters, cfg, QueryFilters},
+	} {
+		q, err := NewChainQuery(i.name, chain, i.cfg)
+		require.NoError(t, err, "can't create chain query")
+		require.Equal(t, i.exp, q.Name(), "strategy mismatch")
+	}
+	_, err := NewChainQuery("bad", chain, cfg)
+	require.Equal(t, InvalidQueryError("bad"), err, "expecting invalid chain query")
+}
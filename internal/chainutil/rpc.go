@@ -0,0 +1,105 @@
+package chainutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/internal/metricsutil"
+)
+
+// RPCError is an error returned by the node
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string { return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message) }
+
+type (
+	rpcRequest struct {
+		JSONRPC string        `json:"jsonrpc"`
+		ID      string        `json:"id"`
+		Method  string        `json:"method"`
+		Params  []interface{} `json:"params"`
+	}
+	rpcResponse struct {
+		Result json.RawMessage `json:"result"`
+		Error  *RPCError       `json:"error"`
+	}
+)
+
+func (cfg *ClientConfig) tlsConfig() (*tls.Config, error) {
+	r := &tls.Config{InsecureSkipVerify: cfg.TLS.SkipVerify}
+	if cfg.TLS.CA != "" {
+		b, err := ioutil.ReadFile(cfg.TLS.CA)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(b)
+		if err != nil {
+			return nil, err
+		}
+		r.RootCAs = x509.NewCertPool()
+		r.RootCAs.AddCert(cert)
+	}
+	if cfg.TLS.ClientCertificate != "" && cfg.TLS.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.ClientCertificate, cfg.TLS.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		r.Certificates = append(r.Certificates, cert)
+	}
+	return r, nil
+}
+
+// Call calls a method of the node rpc interface and decodes the result into
+// r. It's used for the methods not available in the node client
+func (cfg *ClientConfig) Call(method string, params []interface{}, r interface{}) (err error) {
+	defer func(start time.Time) { metricsutil.ObserveRPC(cfg.crypto, method, start, err) }(time.Now())
+	if params == nil {
+		params = []interface{}{}
+	}
+	b, err := json.Marshal(&rpcRequest{
+		JSONRPC: "1.0",
+		ID:      "atomicswap",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+	u := &url.URL{Scheme: "http", Host: cfg.Address, Path: "/"}
+	tr := &http.Transport{}
+	if cfg.TLS != nil {
+		u.Scheme = "https"
+		if tr.TLSClientConfig, err = cfg.tlsConfig(); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		u.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+	resp, err := (&http.Client{Transport: tr}).Post(u.String(), "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	rr := &rpcResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(rr); err != nil {
+		return err
+	}
+	if rr.Error != nil {
+		return rr.Error
+	}
+	if r == nil {
+		return nil
+	}
+	return json.Unmarshal(rr.Result, r)
+}
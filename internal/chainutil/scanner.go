@@ -0,0 +1,470 @@
+package chainutil
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/metricsutil"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/tx"
+)
+
+// ErrScannerClosed is returned to the watches of a closed scanner
+var ErrScannerClosed = errors.New("scanner closed")
+
+type (
+	// ScanFilter selects the transactions dispatched to a watch
+	ScanFilter struct {
+		// OutputScripts are the scripts of the outputs to match
+		OutputScripts [][]byte
+		// RedeemedLocks are the lock scripts to match in the inputs
+		RedeemedLocks [][]byte
+	}
+
+	// ScanMatch is a transaction matching a filter
+	ScanMatch struct {
+		Tx tx.Tx
+		// Outputs are the matched outputs, empty if only inputs matched
+		Outputs []tx.Output
+	}
+
+	// ScanFunc handles an inspected block and the matching transactions. It
+	// returns true to stop the watch
+	ScanFunc func(bd *BlockData, matches []*ScanMatch) (bool, error)
+
+	// Scanner inspects the blocks of a chain once for every watch
+	Scanner struct {
+		c       *cryptos.Crypto
+		cl      cryptocore.Client
+		query   ChainQuery
+		finder  SpendFinder
+		mtx     sync.Mutex
+		watches map[*scanWatch]struct{}
+		// held while a watch handles a block
+		dispatchMtx sync.Mutex
+		wakec       chan struct{}
+		closec      chan struct{}
+		donec       chan struct{}
+		once        sync.Once
+	}
+
+	scanWatch struct {
+		bwd        *BlockWatchData
+		stopBottom uint64
+		filter     *ScanFilter
+		spent      [][]byte
+		f          ScanFunc
+		errc       chan error
+		// next height up and previous height down
+		ready    bool
+		next     uint64
+		prev     uint64
+		backward bool
+	}
+)
+
+// NewScanner returns a new scanner for the chain of the client. The blocks
+// transactions are selected with the query, or fetched if it's nil
+func NewScanner(c *cryptos.Crypto, cl cryptocore.Client, q ChainQuery) *Scanner {
+	return newScanner(c, cl, q, NewSpendFinder(c, cl, nil))
+}
+
+func newScanner(c *cryptos.Crypto, cl cryptocore.Client, q ChainQuery, sf SpendFinder) *Scanner {
+	if q == nil {
+		q = &scanQuery{cl: cl}
+	}
+	r := &Scanner{
+		c:       c,
+		cl:      cl,
+		query:   q,
+		finder:  sf,
+		watches: make(map[*scanWatch]struct{}, 16),
+		wakec:   make(chan struct{}, 1),
+		closec:  make(chan struct{}),
+		donec:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// QueryName returns the name of the chain query strategy
+func (s *Scanner) QueryName() string { return s.query.Name() }
+
+// Close stops the scanner
+func (s *Scanner) Close() {
+	s.once.Do(func() { close(s.closec) })
+	<-s.donec
+}
+
+// Watch dispatches the blocks up from the top of the watch data and down from
+// the bottom until stopBottom. The returned channel receives the result
+// of the watch. The returned function removes the watch
+func (s *Scanner) Watch(bwd *BlockWatchData, stopBottom uint64, filter *ScanFilter, f ScanFunc) (<-chan error, func()) {
+	w := &scanWatch{
+		bwd:        bwd,
+		stopBottom: stopBottom,
+		filter:     filter,
+		f:          f,
+		errc:       make(chan error, 1),
+	}
+	if filter != nil && len(filter.RedeemedLocks) > 0 {
+		gen, err := script.NewGenerator(s.c)
+		if err != nil {
+			w.errc <- err
+			return w.errc, func() {}
+		}
+		// the inputs redeeming a lock spend outputs paying to it
+		for _, i := range filter.RedeemedLocks {
+			w.spent = append(w.spent, gen.P2SHScript(i))
+		}
+	}
+	s.mtx.Lock()
+	s.watches[w] = struct{}{}
+	s.mtx.Unlock()
+	select {
+	case s.wakec <- struct{}{}:
+	default:
+	}
+	return w.errc, func() {
+		s.dispatchMtx.Lock()
+		defer s.dispatchMtx.Unlock()
+		s.remove(w, nil)
+	}
+}
+
+// remove removes a watch and sends the result. It's a no-op if the watch
+// was already removed
+func (s *Scanner) remove(w *scanWatch, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.watches[w]; !ok {
+		return
+	}
+	delete(s.watches, w)
+	w.errc <- err
+}
+
+func (s *Scanner) active(w *scanWatch) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	_, ok := s.watches[w]
+	return ok
+}
+
+func (s *Scanner) snapshot() []*scanWatch {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r := make([]*scanWatch, 0, len(s.watches))
+	for w := range s.watches {
+		r = append(r, w)
+	}
+	return r
+}
+
+func (s *Scanner) fail(ws []*scanWatch, err error) {
+	for _, w := range ws {
+		s.remove(w, err)
+	}
+}
+
+// nextHeight returns the next block to inspect and the watches waiting for it.
+// Blocks up are inspected before blocks down
+func nextHeight(ws []*scanWatch, blockCount uint64) (uint64, []*scanWatch, bool) {
+	var (
+		r     []*scanWatch
+		found bool
+		h     uint64
+	)
+	for _, w := range ws {
+		if w.next > blockCount || (found && w.next > h) {
+			continue
+		}
+		if !found || w.next < h {
+			r, h, found = r[:0], w.next, true
+		}
+		r = append(r, w)
+	}
+	if found {
+		// watches down waiting for the same block
+		for _, w := range ws {
+			if w.backward && w.prev == h {
+				r = append(r, w)
+			}
+		}
+		return h, r, true
+	}
+	for _, w := range ws {
+		if !w.backward || (found && w.prev < h) {
+			continue
+		}
+		if !found || w.prev > h {
+			r, h, found = r[:0], w.prev, true
+		}
+		r = append(r, w)
+	}
+	return h, r, found
+}
+
+// retryable returns true for the errors retried by the scanner, a missing
+// block or a failed connection to the node
+func retryable(err error) bool {
+	if err == cryptocore.ErrNoBlock || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// scan inspects the next block of the watches. It returns true if the
+// watches progressed. The watches failing with errors that can't be retried
+// are removed
+func (s *Scanner) scan(ws []*scanWatch) (bool, error) {
+	blockCount, err := s.cl.BlockCount()
+	if err != nil {
+		if retryable(err) {
+			return false, err
+		}
+		s.fail(ws, err)
+		return true, nil
+	}
+	for _, w := range ws {
+		w.init(blockCount)
+	}
+	h, hws, ok := nextHeight(ws, blockCount)
+	if !ok {
+		return false, nil
+	}
+	if err = s.inspect(h, hws); err != nil {
+		if err == errClosed || retryable(err) {
+			return false, err
+		}
+		s.fail(hws, err)
+	}
+	return true, nil
+}
+
+func (s *Scanner) run() {
+	defer close(s.donec)
+	defer func() { s.fail(s.snapshot(), ErrScannerClosed) }()
+	timeout := initTimeout
+	for {
+		if ws := s.snapshot(); len(ws) > 0 {
+			// the retryable errors wait like the missing blocks
+			ok, err := s.scan(ws)
+			if err == errClosed {
+				return
+			}
+			if ok {
+				timeout = initTimeout
+				continue
+			}
+		}
+		timer := time.NewTimer(timeout)
+		select {
+		case <-s.closec:
+			timer.Stop()
+			return
+		case <-s.wakec:
+			timer.Stop()
+		case <-timer.C:
+			if timeout *= 2; timeout > maxTimeout {
+				timeout = maxTimeout
+			}
+		}
+	}
+}
+
+// init sets the heights of a new watch
+func (w *scanWatch) init(blockCount uint64) {
+	if w.ready {
+		return
+	}
+	w.ready = true
+	if w.bwd.Top == 0 {
+		w.next = blockCount
+	} else {
+		w.next = w.bwd.Top + 1
+	}
+	if w.bwd.Bottom == 0 {
+		w.prev, w.backward = blockCount-1, blockCount > 0
+	} else {
+		w.prev, w.backward = w.bwd.Bottom-1, w.bwd.Bottom > 0
+	}
+	if w.prev < w.stopBottom {
+		w.backward = false
+	}
+}
+
+// advance moves the watch past an inspected height
+func (w *scanWatch) advance(h uint64) {
+	if h == w.next {
+		w.next++
+		return
+	}
+	if h == 0 || h == w.stopBottom {
+		w.backward = false
+		return
+	}
+	w.prev = h - 1
+}
+
+// inspect fetches the block at the height and dispatches it to the watches
+func (s *Scanner) inspect(h uint64, ws []*scanWatch) error {
+	b, err := getBlockAtHeight(s.cl, h)
+	if err != nil {
+		return err
+	}
+	q := &Query{}
+	for _, w := range ws {
+		if w.filter != nil {
+			q.OutputScripts = append(q.OutputScripts, w.filter.OutputScripts...)
+		}
+		q.SpentScripts = append(q.SpentScripts, w.spent...)
+	}
+	txs, err := s.query.Transactions(b, q, s.closec)
+	if err != nil {
+		return err
+	}
+	bd := &BlockData{Height: uint64(b.Height()), TxCount: len(b.Transactions()), Txs: txs}
+	metricsutil.BlocksScanned.Inc(s.c.Name)
+	matches, err := s.match(txs, ws)
+	if err != nil {
+		return err
+	}
+	for _, w := range ws {
+		s.dispatch(w, h, bd, matches[w])
+	}
+	return nil
+}
+
+// dispatch calls the handler of an active watch
+func (s *Scanner) dispatch(w *scanWatch, h uint64, bd *BlockData, matches []*ScanMatch) {
+	s.dispatchMtx.Lock()
+	defer s.dispatchMtx.Unlock()
+	if !s.active(w) {
+		return
+	}
+	w.advance(h)
+	done, err := w.f(bd, matches)
+	if err != nil {
+		s.remove(w, err)
+	} else if done {
+		s.remove(w, nil)
+	}
+}
+
+// match returns the transactions matching the filters of the watches
+func (s *Scanner) match(txs []tx.Tx, ws []*scanWatch) (map[*scanWatch][]*ScanMatch, error) {
+	outIdx := make(map[string][]*scanWatch, len(ws))
+	lockIdx := make(map[string][]*scanWatch, len(ws))
+	for _, w := range ws {
+		if w.filter == nil {
+			continue
+		}
+		for _, i := range w.filter.OutputScripts {
+			outIdx[string(i)] = append(outIdx[string(i)], w)
+		}
+		for _, i := range w.filter.RedeemedLocks {
+			lockIdx[string(i)] = append(lockIdx[string(i)], w)
+		}
+	}
+	r := make(map[*scanWatch][]*ScanMatch, len(ws))
+	if len(outIdx) == 0 && len(lockIdx) == 0 {
+		return r, nil
+	}
+	for _, t := range txs {
+		txUTXO, ok := t.UTXO()
+		if !ok {
+			return nil, ErrNotUTXO
+		}
+		txMatches := make(map[*scanWatch]*ScanMatch, 1)
+		txMatch := func(w *scanWatch) *ScanMatch {
+			m, ok := txMatches[w]
+			if !ok {
+				m = &ScanMatch{Tx: t}
+				txMatches[w] = m
+				r[w] = append(r[w], m)
+			}
+			return m
+		}
+		for _, out := range txUTXO.Outputs() {
+			for _, w := range outIdx[string(out.LockScript().Bytes())] {
+				m := txMatch(w)
+				m.Outputs = append(m.Outputs, out)
+			}
+		}
+		if len(lockIdx) == 0 {
+			continue
+		}
+		for _, in := range txUTXO.Inputs() {
+			if in.Coinbase() != nil {
+				continue
+			}
+			lock, ok := s.redeemedLock(in.UnlockScript().Bytes())
+			if !ok {
+				continue
+			}
+			for _, w := range lockIdx[string(lock)] {
+				txMatch(w)
+			}
+		}
+	}
+	return r, nil
+}
+
+// redeemedLock returns the lock script pushed last by a p2sh input
+func (s *Scanner) redeemedLock(sigScript []byte) ([]byte, bool) {
+	dis, err := script.DisassembleStrings(s.c, sigScript)
+	if err != nil || len(dis) == 0 {
+		return nil, false
+	}
+	r, err := hex.DecodeString(dis[len(dis)-1])
+	if err != nil {
+		return nil, false
+	}
+	return r, true
+}
+
+// Scanners holds a scanner for each crypto, sharing the inspected blocks
+// between the watches of all the trades
+type Scanners struct {
+	newScanner func(c *cryptos.Crypto) (*Scanner, error)
+	mtx        sync.Mutex
+	scanners   map[string]*Scanner
+}
+
+// NewScanners returns a new *Scanners creating the scanners with newScanner
+func NewScanners(newScanner func(c *cryptos.Crypto) (*Scanner, error)) *Scanners {
+	return &Scanners{newScanner: newScanner, scanners: make(map[string]*Scanner, 8)}
+}
+
+// Scanner returns the scanner for a crypto, creating it if needed
+func (s *Scanners) Scanner(c *cryptos.Crypto) (*Scanner, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if r, ok := s.scanners[c.Name]; ok {
+		return r, nil
+	}
+	r, err := s.newScanner(c)
+	if err != nil {
+		return nil, err
+	}
+	s.scanners[c.Name] = r
+	return r, nil
+}
+
+// Close stops all the scanners
+func (s *Scanners) Close() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, i := range s.scanners {
+		i.Close()
+	}
+	s.scanners = make(map[string]*Scanner, 8)
+}
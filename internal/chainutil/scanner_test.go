@@ -0,0 +1,239 @@
+package chainutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/block"
+	"github.com/transmutate-io/cryptocore/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+type (
+	testScript []byte
+
+	testOutput struct {
+		n      int
+		script testScript
+	}
+
+	testInput struct{ script testScript }
+
+	testTx struct {
+		id   types.Bytes
+		ins  []tx.Input
+		outs []tx.Output
+	}
+
+	testBlock struct {
+		height int
+		txs    []types.Bytes
+		time   types.UnixTime
+	}
+
+	// testChain serves blocks and transactions counting the requests
+	testChain struct {
+		cryptocore.Client
+		gate   chan struct{}
+		mtx    sync.Mutex
+		blocks []*testBlock
+		txs    map[string]*testTx
+		calls  map[string]int
+		// errors returned by the next block counts
+		errs []error
+	}
+)
+
+func (s testScript) Bytes() types.Bytes      { return types.Bytes(s) }
+func (s testScript) Asm() string             { return "" }
+func (s testScript) RequiredSignatures() int { return 1 }
+func (s testScript) Type() string            { return "scripthash" }
+func (s testScript) Addresses() []string     { return nil }
+
+func (o *testOutput) Value() types.Amount         { return types.Amount("1") }
+func (o *testOutput) N() int                      { return o.n }
+func (o *testOutput) LockScript() tx.ScriptPubKey { return o.script }
+
+func (i *testInput) TransactionID() types.Bytes { return make([]byte, 32) }
+func (i *testInput) N() int                     { return 0 }
+func (i *testInput) UnlockScript() tx.ScriptSig { return i.script }
+func (i *testInput) Sequence() int              { return -1 }
+func (i *testInput) Coinbase() types.Bytes      { return nil }
+
+func (t *testTx) ID() types.Bytes           { return t.id }
+func (t *testTx) Hash() types.Bytes         { return t.id }
+func (t *testTx) BlockHash() types.Bytes    { return nil }
+func (t *testTx) Confirmations() int        { return 1 }
+func (t *testTx) BlockTime() types.UnixTime { return 0 }
+func (t *testTx) UTXO() (tx.TxUTXO, bool)   { return t, true }
+func (t *testTx) LockTime() types.UnixTime  { return 0 }
+func (t *testTx) Inputs() []tx.Input        { return t.ins }
+func (t *testTx) Outputs() []tx.Output      { return t.outs }
+
+func (b *testBlock) Hash() types.Bytes              { return []byte{byte(b.height)} }
+func (b *testBlock) Confirmations() int             { return 1 }
+func (b *testBlock) Height() int                    { return b.height }
+func (b *testBlock) Transactions() []types.Bytes    { return b.txs }
+func (b *testBlock) Time() types.UnixTime           { return b.time }
+func (b *testBlock) PreviousBlockHash() types.Bytes { return nil }
+func (b *testBlock) NextBlockHash() types.Bytes     { return nil }
+
+func (c *testChain) count(method string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.calls[method]++
+}
+
+func (c *testChain) BlockCount() (uint64, error) {
+	<-c.gate
+	c.count("blockcount")
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return 0, err
+	}
+	return uint64(len(c.blocks) - 1), nil
+}
+
+func (c *testChain) BlockHash(height uint64) (types.Bytes, error) {
+	if height >= uint64(len(c.blocks)) {
+		return nil, cryptocore.ErrNoBlock
+	}
+	return c.blocks[height].Hash(), nil
+}
+
+func (c *testChain) Block(hash types.Bytes) (block.Block, error) {
+	c.count("block")
+	return c.blocks[hash[0]], nil
+}
+
+func (c *testChain) Transaction(hash types.Bytes) (tx.Tx, error) {
+	c.count("transaction")
+	return c.txs[hash.Hex()], nil
+}
+
+func (c *testChain) addBlock(txs ...*testTx) {
+	b := &testBlock{height: len(c.blocks)}
+	for i, t := range txs {
+		t.id = []byte(fmt.Sprintf("%02d%02d", b.height, i))
+		c.txs[t.id.Hex()] = t
+		b.txs = append(b.txs, t.id)
+	}
+	c.blocks = append(c.blocks, b)
+}
+
+// pushData returns a script pushing the data
+func pushData(data ...[]byte) testScript {
+	r := make([]byte, 0, 64)
+	for _, i := range data {
+		r = append(append(r, byte(len(i))), i...)
+	}
+	return r
+}
+
+func newTestChain(scriptA, scriptB, lockB []byte) *testChain {
+	r := &testChain{gate: make(chan struct{}), txs: map[string]*testTx{}, calls: map[string]int{}}
+	other := bytes.Repeat([]byte{0x01}, 23)
+	r.addBlock(&testTx{outs: []tx.Output{&testOutput{n: 0, script: scriptA}}})
+	r.addBlock(&testTx{outs: []tx.Output{&testOutput{n: 0, script: other}, &testOutput{n: 1, script: scriptA}}})
+	r.addBlock(&testTx{outs: []tx.Output{&testOutput{n: 0, script: scriptB}}}, &testTx{})
+	r.addBlock(&testTx{ins: []tx.Input{&testInput{script: pushData(make([]byte, 71), lockB)}}})
+	r.addBlock(&testTx{outs: []tx.Output{&testOutput{n: 0, script: scriptA}, &testOutput{n: 1, script: scriptB}}})
+	return r
+}
+
+type testWatchResult struct {
+	heights map[uint64]int
+	outputs []string
+	redeems []string
+}
+
+func testWatch(sc *Scanner, filter *ScanFilter, stopBottom uint64, nBlocks int) (*testWatchResult, <-chan error) {
+	r := &testWatchResult{heights: map[uint64]int{}}
+	errc, _ := sc.Watch(&BlockWatchData{}, stopBottom, filter, func(bd *BlockData, matches []*ScanMatch) (bool, error) {
+		r.heights[bd.Height]++
+		for _, i := range matches {
+			if len(i.Outputs) == 0 {
+				r.redeems = append(r.redeems, i.Tx.ID().Hex())
+			}
+			for _, j := range i.Outputs {
+				r.outputs = append(r.outputs, OutputID(i.Tx.ID(), uint64(j.N())))
+			}
+		}
+		return len(r.heights) == nBlocks, nil
+	})
+	return r, errc
+}
+
+func TestScanner(t *testing.T) {
+	scriptA := append([]byte{0xa9, 0x14}, bytes.Repeat([]byte{0xaa}, 21)...)
+	scriptB := append([]byte{0xa9, 0x14}, bytes.Repeat([]byte{0xbb}, 21)...)
+	lockB := bytes.Repeat([]byte{0xcc}, 40)
+	chain := newTestChain(scriptA, scriptB, lockB)
+	sc := NewScanner(cryptos.Bitcoin, chain, nil)
+	defer sc.Close()
+	resA, errA := testWatch(sc, &ScanFilter{OutputScripts: [][]byte{scriptA}}, 1, 4)
+	resB, errB := testWatch(sc, &ScanFilter{
+		OutputScripts: [][]byte{scriptB},
+		RedeemedLocks: [][]byte{lockB},
+	}, 1, 4)
+	close(chain.gate)
+	require.NoError(t, <-errA, "watch failed")
+	require.NoError(t, <-errB, "watch failed")
+	// every block inspected once for both watches
+	require.Equal(t, 4, chain.calls["block"], "blocks fetched more than once")
+	require.Equal(t, 5, chain.calls["transaction"], "transactions fetched more than once")
+	for _, i := range []*testWatchResult{resA, resB} {
+		require.Equal(t, map[uint64]int{1: 1, 2: 1, 3: 1, 4: 1}, i.heights, "heights mismatch")
+	}
+	require.ElementsMatch(t, []string{"30313030:1", "30343030:0"}, resA.outputs, "outputs mismatch")
+	require.Empty(t, resA.redeems, "unexpected redeem")
+	require.ElementsMatch(t, []string{"30323030:0", "30343030:1"}, resB.outputs, "outputs mismatch")
+	require.Equal(t, []string{"30333030"}, resB.redeems, "redeems mismatch")
+}
+
+func TestScannerClose(t *testing.T) {
+	chain := newTestChain(nil, nil, nil)
+	close(chain.gate)
+	sc := NewScanner(cryptos.Bitcoin, chain, nil)
+	// a watch waiting for new blocks
+	errc, _ := sc.Watch(&BlockWatchData{Top: 4, Bottom: 1}, 1, nil, func(*BlockData, []*ScanMatch) (bool, error) {
+		return false, nil
+	})
+	// a removed watch
+	errc2, cancel := sc.Watch(&BlockWatchData{Top: 4, Bottom: 1}, 1, nil, func(*BlockData, []*ScanMatch) (bool, error) {
+		return false, nil
+	})
+	cancel()
+	require.NoError(t, <-errc2, "expecting no error")
+	sc.Close()
+	require.Equal(t, ErrScannerClosed, <-errc, "expecting scanner closed")
+}
+
+func TestScannerRetry(t *testing.T) {
+	chain := newTestChain(nil, nil, nil)
+	close(chain.gate)
+	// a failed connection is retried
+	chain.errs = []error{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	sc := NewScanner(cryptos.Bitcoin, chain, nil)
+	defer sc.Close()
+	_, errc := testWatch(sc, nil, 1, 4)
+	require.NoError(t, <-errc, "watch failed")
+	// other errors fail the watches
+	errOther := errors.New("other")
+	chain.mtx.Lock()
+	require.Empty(t, chain.errs, "expecting a retry")
+	chain.errs = []error{errOther}
+	chain.mtx.Unlock()
+	_, errc = testWatch(sc, nil, 1, 4)
+	require.Equal(t, errOther, <-errc, "expecting an error")
+}
@@ -0,0 +1,128 @@
+package chainutil
+
+import (
+	"github.com/transmutate-io/atomicswap/networks"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/atomicswap/psbt"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// RedeemTx returns the transaction to redeem the trader funds to an address
+func RedeemTx(tr trade.Trade, chain params.Chain, destAddr string, fee uint64, fixedFee bool) (tx.Tx, error) {
+	addrScript, err := networks.
+		AllByName[tr.TraderInfo().Crypto.Name][chain].
+		AddressToScript(destAddr)
+	if err != nil {
+		return nil, err
+	}
+	if fixedFee {
+		return tr.RedeemTxFixedFee(addrScript, fee)
+	}
+	return tr.RedeemTx(addrScript, fee)
+}
+
+// RecoveryTx returns the transaction to recover the own funds to an address
+func RecoveryTx(tr trade.Trade, chain params.Chain, destAddr string, fee uint64, fixedFee bool) (tx.Tx, error) {
+	addrScript, err := networks.
+		AllByName[tr.OwnInfo().Crypto.Name][chain].
+		AddressToScript(destAddr)
+	if err != nil {
+		return nil, err
+	}
+	if fixedFee {
+		return tr.RecoveryTxFixedFee(addrScript, fee)
+	}
+	return tr.RecoveryTx(addrScript, fee)
+}
+
+// setPreviousTxs fetches the funding transactions of the inputs of the packet
+// and sets them as the non-witness utxos
+func setPreviousTxs(cl cryptocore.Client, p *psbt.Packet) error {
+	txUTXO, ok := p.Tx.TxUTXO()
+	if !ok {
+		return tx.ErrNotUTXO
+	}
+	prevs := make(map[string]tx.Tx, 1)
+	for i, in := range txUTXO.Inputs() {
+		id := types.Bytes(in.TxID).Hex()
+		prev, ok := prevs[id]
+		if !ok {
+			b, err := cl.RawTransaction(in.TxID)
+			if err != nil {
+				return err
+			}
+			if prev, err = tx.Parse(p.Tx.Crypto(), b); err != nil {
+				return err
+			}
+			prevs[id] = prev
+		}
+		if err := p.SetNonWitnessUTXO(i, prev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedeemPSBT returns the unsigned transaction to redeem the trader funds to an
+// address. The funding transactions are fetched with cl
+func RedeemPSBT(cl cryptocore.Client, tr trade.Trade, chain params.Chain, destAddr string, fee uint64, fixedFee bool) (*psbt.Packet, error) {
+	addrScript, err := networks.
+		AllByName[tr.TraderInfo().Crypto.Name][chain].
+		AddressToScript(destAddr)
+	if err != nil {
+		return nil, err
+	}
+	var r *psbt.Packet
+	if fixedFee {
+		r, err = tr.RedeemPSBTFixedFee(addrScript, fee)
+	} else {
+		r, err = tr.RedeemPSBT(addrScript, fee)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = setPreviousTxs(cl, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RecoveryPSBT returns the unsigned transaction to recover the own funds to an
+// address. The funding transactions are fetched with cl
+func RecoveryPSBT(cl cryptocore.Client, tr trade.Trade, chain params.Chain, destAddr string, fee uint64, fixedFee bool) (*psbt.Packet, error) {
+	addrScript, err := networks.
+		AllByName[tr.OwnInfo().Crypto.Name][chain].
+		AddressToScript(destAddr)
+	if err != nil {
+		return nil, err
+	}
+	var r *psbt.Packet
+	if fixedFee {
+		r, err = tr.RecoveryPSBTFixedFee(addrScript, fee)
+	} else {
+		r, err = tr.RecoveryPSBT(addrScript, fee)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = setPreviousTxs(cl, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FundsAmounts returns the amounts of the outputs of the funds
+func FundsAmounts(fd trade.FundsData) []uint64 {
+	outputs, ok := fd.Funds().([]*trade.Output)
+	if !ok {
+		return nil
+	}
+	r := make([]uint64, 0, len(outputs))
+	for _, i := range outputs {
+		r = append(r, i.Amount)
+	}
+	return r
+}
@@ -0,0 +1,67 @@
+package chainutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// ErrNotUTXO is returned when a transaction isn't utxo based
+var ErrNotUTXO = errors.New("not implemented")
+
+// ExtractToken extracts the secret token from a transaction redeeming the lock
+func ExtractToken(c *cryptos.Crypto, t tx.Tx, lock trade.Lock) (types.Bytes, error) {
+	txUtxo, ok := t.UTXO()
+	if !ok {
+		return nil, ErrNotUTXO
+	}
+	ld, err := lock.LockData()
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range txUtxo.Inputs() {
+		if j.Coinbase() != nil {
+			continue
+		}
+		dis, err := script.DisassembleStrings(c, j.UnlockScript().Bytes())
+		if err != nil {
+			continue
+		}
+		if len(dis) != 5 {
+			continue
+		}
+		if dis[3] != "0" {
+			continue
+		}
+		h, err := hash.New(c)
+		if err != nil {
+			return nil, err
+		}
+		b, err := hex.DecodeString(dis[1])
+		if err != nil {
+			continue
+		}
+		hb := h.Hash160(b)
+		if !bytes.Equal(ld.RedeemKeyData, hb) {
+			continue
+		}
+		if b, err = hex.DecodeString(dis[4]); err != nil {
+			continue
+		}
+		if !bytes.Equal(lock.Bytes(), b) {
+			continue
+		}
+		if b, err = hex.DecodeString(dis[2]); err != nil {
+			continue
+		}
+		return b, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,308 @@
+package chainutil
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// OutputID returns the output id (txid:n)
+func OutputID(tx []byte, n uint64) string { return fmt.Sprintf("%s:%d", hex.EncodeToString(tx), n) }
+
+// ErrTraderRecovered is returned when the trader funds are recovered by the
+// trader instead of redeemed
+var ErrTraderRecovered = errors.New("funds recovered by the trader")
+
+// DepositEvent represents a deposit output
+type DepositEvent struct {
+	New bool
+	// Height is the height of the block of a new output
+	Height uint64
+	ID     string
+	Amount types.Amount
+	Total  types.Amount
+	Target types.Amount
+}
+
+// DepositHandlers are the callbacks used while watching a deposit
+type DepositHandlers struct {
+	Address       func(addr string) error
+	Block         func(bd *BlockData) error
+	Output        func(ev *DepositEvent) error
+	SaveTrade     func() error
+	SaveWatchData func() error
+}
+
+func callString(f func(string) error, s string) error {
+	if f == nil {
+		return nil
+	}
+	return f(s)
+}
+
+func callDeposit(f func(*DepositEvent) error, ev *DepositEvent) error {
+	if f == nil {
+		return nil
+	}
+	return f(ev)
+}
+
+func callBlock(f func(*BlockData) error, bd *BlockData) error {
+	if f == nil {
+		return nil
+	}
+	return f(bd)
+}
+
+func call(f func() error) error {
+	if f == nil {
+		return nil
+	}
+	return f()
+}
+
+// lockOutputScript returns the script of the outputs paying to the lock
+func lockOutputScript(c *cryptos.Crypto, lock trade.Lock) ([]byte, error) {
+	gen, err := script.NewGenerator(c)
+	if err != nil {
+		return nil, err
+	}
+	return gen.P2SHScript(lock.Bytes()), nil
+}
+
+// waitWatch waits for the result of a scanner watch or until closec is closed
+func waitWatch(errc <-chan error, closec <-chan struct{}) error {
+	select {
+	case err := <-errc:
+		return err
+	case <-closec:
+		return nil
+	}
+}
+
+// WatchDeposit watches the chain for deposits into the funds lock until the
+// target amount is reached or closec is closed
+func WatchDeposit(
+	sc *Scanner,
+	chain params.Chain,
+	cryptoInfo *trade.TraderInfo,
+	funds trade.FundsData,
+	bwd *BlockWatchData,
+	firstBlock uint64,
+	ignoreTarget bool,
+	closec <-chan struct{},
+	h *DepositHandlers,
+) error {
+	if h == nil {
+		h = &DepositHandlers{}
+	}
+	dec := uint64(cryptoInfo.Crypto.Decimals)
+	targetAmount := cryptoInfo.Amount.UInt64(cryptoInfo.Crypto.Decimals)
+	depositAddr, err := funds.Lock().Address(chain)
+	if err != nil {
+		return err
+	}
+	if err = callString(h.Address, depositAddr); err != nil {
+		return err
+	}
+	depositScript, err := lockOutputScript(cryptoInfo.Crypto, funds.Lock())
+	if err != nil {
+		return err
+	}
+	outputs, ok := funds.Funds().([]*trade.Output)
+	if !ok {
+		return ErrNotUTXO
+	}
+	outMap := make(map[string]uint64, len(outputs))
+	totalAmount := uint64(0)
+	for _, i := range outputs {
+		txID := OutputID(i.TxID, uint64(i.N))
+		outMap[txID] = i.Amount
+		totalAmount += i.Amount
+		err := callDeposit(h.Output, &DepositEvent{
+			ID:     txID,
+			Amount: types.NewAmount(i.Amount, dec),
+			Total:  types.NewAmount(totalAmount, dec),
+			Target: cryptoInfo.Amount,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !ignoreTarget && totalAmount >= targetAmount {
+		return nil
+	}
+	filter := &ScanFilter{OutputScripts: [][]byte{depositScript}}
+	errc, cancel := sc.Watch(bwd, firstBlock, filter, func(bd *BlockData, matches []*ScanMatch) (bool, error) {
+		if err := callBlock(h.Block, bd); err != nil {
+			return false, err
+		}
+		var tradeChanged bool
+		for _, i := range matches {
+			for _, j := range i.Outputs {
+				outID := OutputID(i.Tx.ID(), uint64(j.N()))
+				if _, ok := outMap[outID]; ok {
+					continue
+				}
+				amount := j.Value().UInt64(cryptoInfo.Crypto.Decimals)
+				funds.AddFunds(&trade.Output{
+					TxID:   i.Tx.ID(),
+					N:      uint32(j.N()),
+					Amount: amount,
+				})
+				outMap[outID] = amount
+				totalAmount += amount
+				err := callDeposit(h.Output, &DepositEvent{
+					New:    true,
+					Height: bd.Height,
+					ID:     outID,
+					Amount: j.Value(),
+					Total:  types.NewAmount(totalAmount, dec),
+					Target: cryptoInfo.Amount,
+				})
+				if err != nil {
+					return false, err
+				}
+				tradeChanged = true
+			}
+		}
+		bwd.Update(bd.Height)
+		if err := call(h.SaveWatchData); err != nil {
+			return false, err
+		}
+		if tradeChanged {
+			if err := call(h.SaveTrade); err != nil {
+				return false, err
+			}
+		}
+		return !ignoreTarget && totalAmount >= targetAmount, nil
+	})
+	defer cancel()
+	return waitWatch(errc, closec)
+}
+
+// WatchSecretToken watches the chain for the redeem of the own funds and sets
+// the secret token on the trade when found. The spenders of the funds are
+// looked up first and the blocks are scanned only if the lookup can't find
+// them. It returns the token or nil if closec was closed before finding it
+func WatchSecretToken(
+	sc *Scanner,
+	tr trade.Trade,
+	bwd *BlockWatchData,
+	firstBlock uint64,
+	closec <-chan struct{},
+	blockFunc func(*BlockData) error,
+) (types.Bytes, error) {
+	if sc.finder != nil {
+		// the blocks up to the tip don't have to be scanned if the funds are unspent
+		tip, err := sc.cl.BlockCount()
+		if err != nil {
+			return nil, err
+		}
+		token, ok, err := FindSecretToken(sc.finder, sc.cl, tr)
+		if err != nil {
+			return nil, err
+		}
+		if token != nil {
+			return token, nil
+		}
+		if ok && tip > firstBlock {
+			firstBlock = tip
+		}
+	}
+	lock := tr.RecoverableFunds().Lock()
+	var token types.Bytes
+	filter := &ScanFilter{RedeemedLocks: [][]byte{lock.Bytes()}}
+	errc, cancel := sc.Watch(bwd, firstBlock, filter, func(bd *BlockData, matches []*ScanMatch) (bool, error) {
+		if err := callBlock(blockFunc, bd); err != nil {
+			return false, err
+		}
+		for _, i := range matches {
+			t, err := ExtractToken(tr.OwnInfo().Crypto, i.Tx, lock)
+			if err != nil {
+				return false, err
+			}
+			if t == nil {
+				continue
+			}
+			tr.SetToken(t)
+			token = t
+			return true, nil
+		}
+		return false, nil
+	})
+	defer cancel()
+	if err := waitWatch(errc, closec); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// RedeemConfirmation represents a confirmed redeem of the trader funds
+type RedeemConfirmation struct {
+	TxID types.Bytes
+	// Height is the height of the block of the redeem
+	Height uint64
+}
+
+// WatchRedeem watches the chain from firstBlock for the redeem of the trader
+// funds and waits for the confirmations. It returns nil if closec was closed
+// before the redeem was confirmed and ErrTraderRecovered if the trader
+// recovered the funds
+func WatchRedeem(
+	sc *Scanner,
+	tr trade.Trade,
+	firstBlock uint64,
+	confirmations uint64,
+	closec <-chan struct{},
+	blockFunc func(*BlockData) error,
+) (*RedeemConfirmation, error) {
+	fd := tr.RedeemableFunds()
+	if fd == nil || fd.Lock() == nil || len(fd.Lock().Bytes()) == 0 {
+		return nil, errors.New("missing lock")
+	}
+	lock := fd.Lock()
+	var (
+		r   *RedeemConfirmation
+		top uint64
+	)
+	confirmed := func() bool {
+		return r != nil && (confirmations <= 1 || (top >= r.Height && top-r.Height+1 >= confirmations))
+	}
+	filter := &ScanFilter{RedeemedLocks: [][]byte{lock.Bytes()}}
+	errc, cancel := sc.Watch(&BlockWatchData{}, firstBlock, filter, func(bd *BlockData, matches []*ScanMatch) (bool, error) {
+		if err := callBlock(blockFunc, bd); err != nil {
+			return false, err
+		}
+		if bd.Height > top {
+			top = bd.Height
+		}
+		for _, i := range matches {
+			if r != nil {
+				break
+			}
+			token, err := ExtractToken(tr.TraderInfo().Crypto, i.Tx, lock)
+			if err != nil {
+				return false, err
+			}
+			if token == nil {
+				return false, ErrTraderRecovered
+			}
+			r = &RedeemConfirmation{TxID: i.Tx.ID(), Height: bd.Height}
+		}
+		return confirmed(), nil
+	})
+	err := waitWatch(errc, closec)
+	// no block is dispatched after removing the watch
+	cancel()
+	if err != nil || !confirmed() {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,73 @@
+package chainutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// newRedeemChain returns a chain with the spend of the trader funds at the
+// height and the trade
+func newRedeemChain(t *testing.T, height int, blocks int, token []byte) (*testChain, *redeemTrade) {
+	lock := bytes.Repeat([]byte{0xcc}, 40)
+	pubKey := bytes.Repeat([]byte{0x02}, 33)
+	h, err := hash.New(cryptos.Bitcoin)
+	require.NoError(t, err, "can't create hash")
+	chain := &testChain{gate: make(chan struct{}), txs: map[string]*testTx{}, calls: map[string]int{}}
+	for i := 0; i < blocks; i++ {
+		if i != height {
+			chain.addBlock(&testTx{})
+			continue
+		}
+		// the redeem reveals the token, the recovery pushes true instead
+		sigScript := pushData(make([]byte, 71), pubKey, token, nil, lock)
+		if token == nil {
+			sigScript = pushData(make([]byte, 71), pubKey, []byte{0x01}, lock)
+		}
+		chain.addBlock(&testTx{ins: []tx.Input{&testInput{script: sigScript}}})
+	}
+	close(chain.gate)
+	return chain, &redeemTrade{testTrade: &testTrade{funds: &testFunds{
+		lock: &testLock{b: lock, ld: &trade.LockData{RedeemKeyData: h.Hash160(pubKey)}},
+	}}}
+}
+
+func TestWatchRedeem(t *testing.T) {
+	token := bytes.Repeat([]byte{0x5e}, 32)
+	chain, tr := newRedeemChain(t, 2, 5, token)
+	sc := NewScanner(cryptos.Bitcoin, chain, nil)
+	defer sc.Close()
+	var heights []uint64
+	r, err := WatchRedeem(sc, tr, 1, 3, make(chan struct{}), func(bd *BlockData) error {
+		heights = append(heights, bd.Height)
+		return nil
+	})
+	require.NoError(t, err, "can't watch redeem")
+	require.NotNil(t, r, "expecting a confirmed redeem")
+	require.Equal(t, uint64(2), r.Height, "height mismatch")
+	require.Equal(t, types.Bytes("0200"), r.TxID, "tx id mismatch")
+	require.Equal(t, []uint64{4, 3, 2}, heights, "heights mismatch")
+	// not enough confirmations
+	closec := make(chan struct{})
+	donec := make(chan struct{})
+	go func() {
+		defer close(donec)
+		r, err = WatchRedeem(sc, tr, 1, 4, closec, nil)
+	}()
+	close(closec)
+	<-donec
+	require.NoError(t, err, "can't watch redeem")
+	require.Nil(t, r, "expecting no confirmed redeem")
+	// recovered by the trader
+	chain, tr = newRedeemChain(t, 3, 5, nil)
+	sc2 := NewScanner(cryptos.Bitcoin, chain, nil)
+	defer sc2.Close()
+	_, err = WatchRedeem(sc2, tr, 1, 1, make(chan struct{}), nil)
+	require.Equal(t, ErrTraderRecovered, err, "expecting a recovery")
+}
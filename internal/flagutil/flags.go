@@ -1,9 +1,11 @@
 package flagutil
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
@@ -11,9 +13,12 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"github.com/transmutate-io/atomicswap/cryptos"
 	"github.com/transmutate-io/atomicswap/internal/cmdutil"
 	"github.com/transmutate-io/atomicswap/internal/flagutil/exitcodes"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
 	"github.com/transmutate-io/atomicswap/internal/testutil"
+	"github.com/transmutate-io/atomicswap/negotiation"
 	"github.com/transmutate-io/atomicswap/params"
 	"github.com/transmutate-io/cryptocore"
 )
@@ -82,6 +87,16 @@ func MustUInt64(fs *pflag.FlagSet, name string) uint64 {
 	return r
 }
 
+func Float64(fs *pflag.FlagSet, name string) (float64, error) { return fs.GetFloat64(name) }
+
+func MustFloat64(fs *pflag.FlagSet, name string) float64 {
+	r, err := Float64(fs, name)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantGetFlag, err)
+	}
+	return r
+}
+
 func AddVerbose(fs *pflag.FlagSet)           { fs.CountP("verbose", "v", "increse verbose level") }
 func Verbose(fs *pflag.FlagSet) (int, error) { return Count(fs, "verbose") }
 func MustVerbose(fs *pflag.FlagSet) int      { return MustCount(fs, "verbose") }
@@ -112,6 +127,12 @@ func AddFormat(fs *pflag.FlagSet) {
 func Format(fs *pflag.FlagSet) (string, error) { return String(fs, "format") }
 func MustFormat(fs *pflag.FlagSet) string      { return MustString(fs, "format") }
 
+func AddKeyEncoding(fs *pflag.FlagSet) {
+	fs.String("keyencoding", "wif", "set the key encoding (wif, xprv)")
+}
+func KeyEncoding(fs *pflag.FlagSet) (string, error) { return String(fs, "keyencoding") }
+func MustKeyEncoding(fs *pflag.FlagSet) string      { return MustString(fs, "keyencoding") }
+
 func AddForce(fs *pflag.FlagSet)            { fs.BoolP("force", "f", false, "force") }
 func Force(fs *pflag.FlagSet) (bool, error) { return Bool(fs, "force") }
 func MustForce(fs *pflag.FlagSet) bool      { return MustBool(fs, "force") }
@@ -120,6 +141,48 @@ func AddAll(fs *pflag.FlagSet)            { fs.BoolP("all", "a", false, "all") }
 func All(fs *pflag.FlagSet) (bool, error) { return Bool(fs, "all") }
 func MustAll(fs *pflag.FlagSet) bool      { return MustBool(fs, "all") }
 
+func AddDryRun(fs *pflag.FlagSet) {
+	fs.Bool("dryrun", false, "decode the transaction instead of sending it")
+}
+
+func DryRun(fs *pflag.FlagSet) (bool, error) { return Bool(fs, "dryrun") }
+func MustDryRun(fs *pflag.FlagSet) bool      { return MustBool(fs, "dryrun") }
+
+func AddKeySigner(fs *pflag.FlagSet) {
+	fs.String("keysigner", "", "signer holding the trade keys (file:<keystore> or unix:<socket>)")
+}
+
+func KeySigner(fs *pflag.FlagSet) (string, error) { return String(fs, "keysigner") }
+func MustKeySigner(fs *pflag.FlagSet) string      { return MustString(fs, "keysigner") }
+
+func AddKeyFile(fs *pflag.FlagSet) {
+	fs.String("keyfile", "", "signer keystore file")
+}
+
+func KeyFile(fs *pflag.FlagSet) (string, error) { return String(fs, "keyfile") }
+func MustKeyFile(fs *pflag.FlagSet) string      { return MustString(fs, "keyfile") }
+
+func AddConfTarget(fs *pflag.FlagSet) {
+	fs.Uint64("conftarget", 6, "confirmation target (blocks) used to estimate the fee when it's not set")
+}
+
+func ConfTarget(fs *pflag.FlagSet) (uint64, error) { return UInt64(fs, "conftarget") }
+func MustConfTarget(fs *pflag.FlagSet) uint64      { return MustUInt64(fs, "conftarget") }
+
+func AddMaxFeeShare(fs *pflag.FlagSet) {
+	fs.Float64("maxfeeshare", 0.05, "ask before sending if the fee exceeds this share of the amount")
+}
+
+func MaxFeeShare(fs *pflag.FlagSet) (float64, error) { return Float64(fs, "maxfeeshare") }
+func MustMaxFeeShare(fs *pflag.FlagSet) float64      { return MustFloat64(fs, "maxfeeshare") }
+
+func AddAcceptFee(fs *pflag.FlagSet) {
+	fs.Bool("acceptfee", false, "send without asking when the fee exceeds the maximum share")
+}
+
+func AcceptFee(fs *pflag.FlagSet) (bool, error) { return Bool(fs, "acceptfee") }
+func MustAcceptFee(fs *pflag.FlagSet) bool      { return MustBool(fs, "acceptfee") }
+
 func AddConfirmations(fs *pflag.FlagSet) {
 	fs.Uint64P("confirmations", "c", 0, "number of confirmations")
 }
@@ -134,6 +197,20 @@ func AddIgnoreTarget(fs *pflag.FlagSet) {
 func IgnoreTarget(fs *pflag.FlagSet) (bool, error) { return Bool(fs, "ignoretarget") }
 func MustIgnoreTarget(fs *pflag.FlagSet) bool      { return MustBool(fs, "ignoretarget") }
 
+func AddClientsConfig(fs *pflag.FlagSet) {
+	fs.String("config", "", "clients configuration file (default console defaults)")
+}
+
+func ClientsConfig(fs *pflag.FlagSet) (string, error) { return String(fs, "config") }
+func MustClientsConfig(fs *pflag.FlagSet) string      { return MustString(fs, "config") }
+
+func AddChainQuery(fs *pflag.FlagSet) {
+	fs.String("chainquery", "auto", "set the chain query strategy (auto, scan, utxoset, filters, history), utxoset misses the spent deposits")
+}
+
+func ChainQuery(fs *pflag.FlagSet) (string, error) { return String(fs, "chainquery") }
+func MustChainQuery(fs *pflag.FlagSet) string      { return MustString(fs, "chainquery") }
+
 func AddFirstBlock(fs *pflag.FlagSet) {
 	fs.Uint64P("firstblock", "b", 1, "set the first block where is possible to find an input")
 }
@@ -141,6 +218,114 @@ func AddFirstBlock(fs *pflag.FlagSet) {
 func FirstBlock(fs *pflag.FlagSet) (uint64, error) { return UInt64(fs, "firstblock") }
 func MustFirstBlock(fs *pflag.FlagSet) uint64      { return MustUInt64(fs, "firstblock") }
 
+func AddPeerKey(fs *pflag.FlagSet) {
+	fs.StringP("peerkey", "k", "", "expected remote peer identity public key (hex)")
+}
+
+func PeerKey(fs *pflag.FlagSet) ([]byte, error) {
+	v, err := String(fs, "peerkey")
+	if err != nil || v == "" {
+		return nil, err
+	}
+	return hex.DecodeString(v)
+}
+
+func MustPeerKey(fs *pflag.FlagSet) []byte {
+	r, err := PeerKey(fs)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantGetFlag, err)
+	}
+	return r
+}
+
+func AddPeerTimeout(fs *pflag.FlagSet) {
+	fs.Duration("peertimeout", negotiation.DefaultTimeout, "set the peer handshake and message timeout")
+}
+
+func PeerTimeout(fs *pflag.FlagSet) (time.Duration, error) { return Duration(fs, "peertimeout") }
+func MustPeerTimeout(fs *pflag.FlagSet) time.Duration      { return MustDuration(fs, "peertimeout") }
+
+func AddAutoAccept(fs *pflag.FlagSet) {
+	fs.BoolP("accept", "y", false, "accept proposals without asking")
+}
+
+func AutoAccept(fs *pflag.FlagSet) (bool, error) { return Bool(fs, "accept") }
+func MustAutoAccept(fs *pflag.FlagSet) bool      { return MustBool(fs, "accept") }
+
+func AddExpiry(fs *pflag.FlagSet) {
+	fs.DurationP("expiry", "e", time.Hour, "set the message expiry")
+}
+
+func Expiry(fs *pflag.FlagSet) (time.Duration, error) { return Duration(fs, "expiry") }
+func MustExpiry(fs *pflag.FlagSet) time.Duration      { return MustDuration(fs, "expiry") }
+
+func AddAllowUnsigned(fs *pflag.FlagSet) {
+	fs.Bool("unsigned", false, "accept unsigned messages")
+}
+
+func AllowUnsigned(fs *pflag.FlagSet) (bool, error) { return Bool(fs, "unsigned") }
+func MustAllowUnsigned(fs *pflag.FlagSet) bool      { return MustBool(fs, "unsigned") }
+
+func AddAllowExpired(fs *pflag.FlagSet) {
+	fs.Bool("expired", false, "accept expired messages")
+}
+
+func AllowExpired(fs *pflag.FlagSet) (bool, error) { return Bool(fs, "expired") }
+func MustAllowExpired(fs *pflag.FlagSet) bool      { return MustBool(fs, "expired") }
+
+func AddSigner(fs *pflag.FlagSet) {
+	fs.StringP("signer", "s", "", "expected signer public key (hex)")
+}
+
+func Signer(fs *pflag.FlagSet) ([]byte, error) {
+	v, err := String(fs, "signer")
+	if err != nil || v == "" {
+		return nil, err
+	}
+	return hex.DecodeString(v)
+}
+
+func MustSigner(fs *pflag.FlagSet) []byte {
+	r, err := Signer(fs)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantGetFlag, err)
+	}
+	return r
+}
+
+func AddReason(fs *pflag.FlagSet) {
+	fs.StringP("reason", "r", "", "set the rejection reason")
+}
+
+func Reason(fs *pflag.FlagSet) (string, error) { return String(fs, "reason") }
+func MustReason(fs *pflag.FlagSet) string      { return MustString(fs, "reason") }
+
+func AddCounterTerms(fs *pflag.FlagSet) {
+	fs.String("buyeramount", "", "set the buyer amount")
+	fs.String("selleramount", "", "set the seller amount")
+	fs.Duration("buyerduration", 0, "set the buyer lock duration")
+	fs.Duration("sellerduration", 0, "set the seller lock duration")
+}
+
+func BuyerAmount(fs *pflag.FlagSet) (string, error) { return String(fs, "buyeramount") }
+func MustBuyerAmount(fs *pflag.FlagSet) string      { return MustString(fs, "buyeramount") }
+
+func SellerAmount(fs *pflag.FlagSet) (string, error) { return String(fs, "selleramount") }
+func MustSellerAmount(fs *pflag.FlagSet) string      { return MustString(fs, "selleramount") }
+
+func BuyerDuration(fs *pflag.FlagSet) (time.Duration, error) { return Duration(fs, "buyerduration") }
+func MustBuyerDuration(fs *pflag.FlagSet) time.Duration      { return MustDuration(fs, "buyerduration") }
+
+func SellerDuration(fs *pflag.FlagSet) (time.Duration, error) { return Duration(fs, "sellerduration") }
+func MustSellerDuration(fs *pflag.FlagSet) time.Duration      { return MustDuration(fs, "sellerduration") }
+
+func AddTraderDuration(fs *pflag.FlagSet) {
+	fs.Duration("traderduration", 0, "set the trader lock duration (defaults to half the duration)")
+}
+
+func TraderDuration(fs *pflag.FlagSet) (time.Duration, error) { return Duration(fs, "traderduration") }
+func MustTraderDuration(fs *pflag.FlagSet) time.Duration      { return MustDuration(fs, "traderduration") }
+
 func AddRPC(fs *pflag.FlagSet) {
 	fs.StringP("rpcaddr", "a", "127.0.0.1:3333", "set RPC host:port")
 	fs.StringP("rpcusername", "u", "admin", "set RPC username")
@@ -149,8 +334,12 @@ func AddRPC(fs *pflag.FlagSet) {
 	fs.Bool("rpctlsskipverify", false, "skip TLS verification")
 	fs.String("rpctlsclientcert", "", "RPC client certificate")
 	fs.String("rpctlsclientkey", "", "RPC client key")
+	fs.String("backend", "", "set the chain backend url instead of the node RPC (electrum://host:port, electrums://host:port, esplora://host/path, esploras://host/path)")
 }
 
+func Backend(fs *pflag.FlagSet) (string, error) { return String(fs, "backend") }
+func MustBackend(fs *pflag.FlagSet) string      { return MustString(fs, "backend") }
+
 func RPCUsername(fs *pflag.FlagSet) (string, error) { return String(fs, "rpcusername") }
 func MustRPCUsername(fs *pflag.FlagSet) string      { return MustString(fs, "rpcusername") }
 func RPCPassword(fs *pflag.FlagSet) (string, error) { return String(fs, "rpcpassword") }
@@ -209,7 +398,7 @@ func (f *FeeFlag) Set(v string) error {
 		f.Fixed = false
 		v = strings.TrimSuffix(v, "b")
 	} else {
-		f.Fixed = false
+		f.Fixed = true
 	}
 	i, err := strconv.Atoi(v)
 	if err != nil {
@@ -228,7 +417,7 @@ func (f *FeeFlag) String() string {
 }
 
 func (f *FeeFlag) Type() string              { return "string" }
-func (f *FeeFlag) AddFlag(fs *pflag.FlagSet) { fs.VarP(f, "fee", "f", "set fee per byte") }
+func (f *FeeFlag) AddFlag(fs *pflag.FlagSet) { fs.VarP(f, "fee", "f", "set fee, Nb per byte") }
 
 type NetworkFlag string
 
@@ -252,6 +441,16 @@ func (c *NetworkFlag) AddFlag(fs *pflag.FlagSet) {
 	fs.VarP(c, "network", "n", "set the network to use ("+strings.Join(availableNetworks, ", ")+")")
 }
 
+var customNetworks = make(map[string]map[string]params.Chain, 4)
+
+// RegisterNetwork adds a network with the chains of each crypto
+func RegisterNetwork(name string, chains map[string]params.Chain) {
+	if _, ok := customNetworks[name]; !ok {
+		availableNetworks = append(availableNetworks, name)
+	}
+	customNetworks[name] = chains
+}
+
 func (c NetworkFlag) Network(cn string) (params.Chain, error) {
 	switch c {
 	case "mainnet":
@@ -265,6 +464,9 @@ func (c NetworkFlag) Network(cn string) (params.Chain, error) {
 			}
 		}
 	default:
+		if r, ok := customNetworks[string(c)][cn]; ok {
+			return r, nil
+		}
 	}
 	return 0, params.InvalidChainError(c)
 }
@@ -277,6 +479,17 @@ func (c NetworkFlag) MustNetwork(cn string) params.Chain {
 	return r
 }
 
+// Lookup returns the value of a single flag in args, ignoring any other flags
+func Lookup(args []string, name string, shorthand string, value string) string {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.ParseErrorsWhitelist.UnknownFlags = true
+	fs.Usage = func() {}
+	fs.SetOutput(ioutil.Discard)
+	r := fs.StringP(name, shorthand, value, "")
+	fs.Parse(args)
+	return *r
+}
+
 func AddInput(fs *pflag.FlagSet) { fs.StringP("input", "i", "-", "set input") }
 
 func OpenInput(fs *pflag.FlagSet) (io.Reader, func() error, error) {
@@ -326,3 +539,99 @@ func MustOpenOutput(fs *pflag.FlagSet) (io.Writer, func() error) {
 	}
 	return w, c
 }
+
+func AddNotify(fs *pflag.FlagSet) {
+	fs.String("notify-webhook", "", "post the trade events to the url")
+	fs.String("notify-secret", "", "sign the webhook events with HMAC-SHA256 using the secret")
+	fs.String("notify-command", "", "execute the shell command for each trade event")
+	fs.String("notify-log", "", "append the trade events to the file (jsonl)")
+}
+
+func Notifier(fs *pflag.FlagSet) (*notifyutil.Notifier, error) {
+	cfg := &notifyutil.Config{}
+	for _, i := range []struct {
+		name string
+		v    *string
+	}{
+		{"notify-webhook", &cfg.Webhook},
+		{"notify-secret", &cfg.Secret},
+		{"notify-command", &cfg.Command},
+		{"notify-log", &cfg.Log},
+	} {
+		v, err := String(fs, i.name)
+		if err != nil {
+			return nil, err
+		}
+		*i.v = v
+	}
+	r := cfg.NewNotifier()
+	r.OnError(func(ev *notifyutil.Event, err error) {
+		fmt.Fprintf(os.Stderr, "can't notify %s: %s\n", ev.Type, err)
+	})
+	return r, nil
+}
+
+func MustNotifier(fs *pflag.FlagSet) *notifyutil.Notifier {
+	r, err := Notifier(fs)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantGetFlag, err)
+	}
+	return r
+}
+
+func AddMetricsAddr(fs *pflag.FlagSet) {
+	fs.String("metrics-addr", "", "expose prometheus metrics at http://<host:port>/metrics")
+}
+
+func MetricsAddr(fs *pflag.FlagSet) (string, error) { return String(fs, "metrics-addr") }
+func MustMetricsAddr(fs *pflag.FlagSet) string      { return MustString(fs, "metrics-addr") }
+
+func AddLockSchedule(fs *pflag.FlagSet) {
+	fs.DurationSlice("lock-margins", notifyutil.DefaultMargins, "warn when a lock time is within the margins")
+	fs.StringSlice("recover-to", nil, "recover the own funds to <crypto>=<address> once the lock time is reached")
+	fs.Duration("check-interval", time.Minute, "set the interval between the lock checks")
+}
+
+func LockMargins(fs *pflag.FlagSet) ([]time.Duration, error) {
+	return fs.GetDurationSlice("lock-margins")
+}
+
+func MustLockMargins(fs *pflag.FlagSet) []time.Duration {
+	r, err := LockMargins(fs)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantGetFlag, err)
+	}
+	return r
+}
+
+// RecoverTo returns the recovery addresses by crypto name
+func RecoverTo(fs *pflag.FlagSet) (map[string]string, error) {
+	v, err := fs.GetStringSlice("recover-to")
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[string]string, len(v))
+	for _, i := range v {
+		parts := strings.SplitN(i, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid recovery address: %s", i)
+		}
+		c, err := cryptos.Parse(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		r[c.Name] = parts[1]
+	}
+	return r, nil
+}
+
+func MustRecoverTo(fs *pflag.FlagSet) map[string]string {
+	r, err := RecoverTo(fs)
+	if err != nil {
+		cmdutil.ErrorExit(exitcodes.CantGetFlag, err)
+	}
+	return r
+}
+
+func CheckInterval(fs *pflag.FlagSet) (time.Duration, error) { return Duration(fs, "check-interval") }
+func MustCheckInterval(fs *pflag.FlagSet) time.Duration      { return MustDuration(fs, "check-interval") }
@@ -0,0 +1,90 @@
+package flagutil
+
+import (
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/networks"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/atomicswap/tx"
+)
+
+// RegisterNetworks registers the custom cryptos and networks. The custom
+// networks are added to the network flag
+func RegisterNetworks(cfg *storeutil.NetworksConfig) error {
+	for _, i := range cfg.Cryptos {
+		if err := registerCrypto(i); err != nil {
+			return err
+		}
+	}
+	families := cfg.Families()
+	for _, i := range cfg.Networks {
+		if err := registerNetwork(i, families); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func registerCrypto(cc *storeutil.CryptoConfig) error {
+	base, err := cc.Base()
+	if err != nil {
+		return err
+	}
+	c := cc.Crypto()
+	if err = cryptos.Register(c); err != nil {
+		return err
+	}
+	cc.Name = c.Name
+	for _, rf := range []func(*cryptos.Crypto, *cryptos.Crypto) error{
+		hash.RegisterLike,
+		key.RegisterLike,
+		script.RegisterLike,
+		tx.RegisterLike,
+		trade.RegisterLike,
+		chainutil.RegisterLike,
+	} {
+		if err := rf(c, base); err != nil {
+			return err
+		}
+	}
+	if cc.MessagePrefix != "" {
+		return key.SetMessagePrefix(c, cc.MessagePrefix)
+	}
+	return nil
+}
+
+func registerNetwork(nc *storeutil.NetworkConfig, families map[string]string) error {
+	chain, err := params.ParseChain(nc.Name)
+	custom := err != nil
+	if custom {
+		if chain, err = params.RegisterChain(nc.Name); err != nil {
+			return err
+		}
+	}
+	chains := make(map[string]params.Chain, len(nc.Cryptos))
+	for cn, cc := range nc.Cryptos {
+		c, err := cryptos.Parse(cn)
+		if err != nil {
+			return err
+		}
+		family, ok := families[c.Name]
+		if !ok {
+			return storeutil.InvalidHashFamilyError(c.Name)
+		}
+		p, err := cc.Params(nc.Name, family)
+		if err != nil {
+			return err
+		}
+		networks.Register(c, chain, p)
+		chains[c.Name] = chain
+	}
+	if custom {
+		RegisterNetwork(nc.Name, chains)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package flagutil
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/storeutil"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+const testNetworksConfig = `cryptos:
+- name: labcoin
+  short: LAB
+  decimals: 8
+  hash_family: btc
+networks:
+- name: labnet
+  cryptos:
+    bitcoin:
+      pubkey_hash_addr_id: 6f
+      script_hash_addr_id: c4
+      private_key_id: ef
+      bech32_hrp: tb
+    labcoin:
+      pubkey_hash_addr_id: "30"
+      script_hash_addr_id: "32"
+      private_key_id: b0
+- name: mainnet
+  cryptos:
+    labcoin:
+      pubkey_hash_addr_id: "30"
+      script_hash_addr_id: "32"
+      private_key_id: b0
+`
+
+func TestRegisterNetworks(t *testing.T) {
+	dd, err := ioutil.TempDir("", "swapcli")
+	require.NoError(t, err, "can't create data dir")
+	defer os.RemoveAll(dd)
+	st := storeutil.New(dd)
+	require.NoError(t, os.MkdirAll(st.ConfigDir(), 0755), "can't create config dir")
+	err = ioutil.WriteFile(st.NetworksConfigPath(), []byte(testNetworksConfig), 0644)
+	require.NoError(t, err, "can't write config")
+	cfg, err := st.OpenNetworksConfig()
+	require.NoError(t, err, "can't open networks config")
+	require.NoError(t, RegisterNetworks(cfg), "can't register networks")
+	// crypto
+	c, err := cryptos.Parse("labcoin")
+	require.NoError(t, err, "can't parse crypto")
+	_, err = cryptos.ParseShort("LAB")
+	require.NoError(t, err, "can't parse ticker")
+	ctx, err := tx.New(c)
+	require.NoError(t, err, "can't create tx")
+	require.Equal(t, c.Name, ctx.Crypto().Name, "tx crypto mismatch")
+	// network
+	nf := NetworkFlag("")
+	require.NoError(t, nf.Set("labnet"), "can't set network")
+	chain, err := nf.Network(c.Name)
+	require.NoError(t, err, "can't get chain")
+	require.Equal(t, "labnet", chain.String(), "chain mismatch")
+	btcChain, err := nf.Network(cryptos.Bitcoin.Name)
+	require.NoError(t, err, "can't get chain")
+	// trade
+	bt, err := trade.NewOnChainTrade(types.Amount("1"), c, types.Amount("1"), cryptos.Bitcoin, 48*time.Hour)
+	require.NoError(t, err, "can't create trade")
+	buyer, _ := bt.Buyer()
+	prop, err := buyer.GenerateBuyProposal()
+	require.NoError(t, err, "can't generate proposal")
+	st1, err := trade.AcceptProposal(prop)
+	require.NoError(t, err, "can't accept proposal")
+	seller, _ := st1.Seller()
+	b, err := yaml.Marshal(seller.Locks())
+	require.NoError(t, err, "can't marshal locks")
+	locks, err := trade.UnamrshalLocks(c, cryptos.Bitcoin, b)
+	require.NoError(t, err, "can't unmarshal locks")
+	require.NoError(t, buyer.SetLocks(locks), "can't set locks")
+	addr, err := locks.Buyer.Address(chain)
+	require.NoError(t, err, "can't get address")
+	require.Equal(t, byte('M'), addr[0], "address prefix mismatch")
+	addr, err = locks.Seller.Address(btcChain)
+	require.NoError(t, err, "can't get address")
+	require.Equal(t, byte('2'), addr[0], "address prefix mismatch")
+	// trade round trip
+	b, err = yaml.Marshal(st1)
+	require.NoError(t, err, "can't marshal trade")
+	st2 := &trade.OnChainTrade{}
+	require.NoError(t, yaml.Unmarshal(b, st2), "can't unmarshal trade")
+	addr2, err := st2.RedeemableFunds().Lock().Address(chain)
+	require.NoError(t, err, "can't get address")
+	addr, _ = locks.Buyer.Address(chain)
+	require.Equal(t, addr, addr2, "address mismatch")
+	// duplicated crypto
+	cfg, err = st.OpenNetworksConfig()
+	require.NoError(t, err, "can't open networks config")
+	require.Error(t, RegisterNetworks(cfg), "expecting duplicated crypto")
+}
@@ -0,0 +1,103 @@
+package metricsutil
+
+import (
+	"math"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/trade"
+)
+
+// Default is the registry of the atomicswap metrics
+var Default = NewRegistry()
+
+// atomicswap metrics
+var (
+	BlocksScanned = Default.NewCounterVec(
+		"atomicswap_blocks_scanned_total",
+		"Blocks inspected by the chain scanners.",
+		"crypto",
+	)
+	WatchTop = Default.NewGaugeVec(
+		"atomicswap_watch_top_height",
+		"Top height of the inspected blocks of a deposit watch.",
+		"trade", "target",
+	)
+	WatchBottom = Default.NewGaugeVec(
+		"atomicswap_watch_bottom_height",
+		"Bottom height of the inspected blocks of a deposit watch.",
+		"trade", "target",
+	)
+	RPCDuration = Default.NewHistogramVec(
+		"atomicswap_rpc_duration_seconds",
+		"Latency of the node and backend calls.",
+		DefaultBuckets,
+		"crypto", "method",
+	)
+	RPCErrors = Default.NewCounterVec(
+		"atomicswap_rpc_errors_total",
+		"Failed node and backend calls.",
+		"crypto", "method",
+	)
+	Trades = Default.NewGaugeVec(
+		"atomicswap_trades",
+		"Open trades by stage.",
+		"stage",
+	)
+	ValueLocked = Default.NewGaugeVec(
+		"atomicswap_value_locked",
+		"Own funds locked in the trades.",
+		"crypto",
+	)
+	ValueRedeemable = Default.NewGaugeVec(
+		"atomicswap_value_redeemable",
+		"Trader funds locked in the trades.",
+		"crypto",
+	)
+)
+
+// ObserveRPC records the latency of a call and counts the failures
+func ObserveRPC(crypto string, method string, start time.Time, err error) {
+	RPCDuration.Observe(time.Since(start).Seconds(), crypto, method)
+	if err != nil {
+		RPCErrors.Inc(crypto, method)
+	}
+}
+
+// ObserveWatchData records the inspected heights of a deposit watch
+func ObserveWatchData(tradeName string, target string, top uint64, bottom uint64) {
+	WatchTop.Set(float64(top), tradeName, target)
+	WatchBottom.Set(float64(bottom), tradeName, target)
+}
+
+// ForgetWatchData removes the inspected heights of an ended deposit watch
+func ForgetWatchData(tradeName string, target string) {
+	WatchTop.Delete(tradeName, target)
+	WatchBottom.Delete(tradeName, target)
+}
+
+// CollectTrades sets the metrics of the open trades before the metrics are
+// written
+func CollectTrades(eachTrade func(func(string, trade.Trade) error) error) {
+	Default.OnCollect(func() {
+		Trades.Reset()
+		ValueLocked.Reset()
+		ValueRedeemable.Reset()
+		eachTrade(func(name string, tr trade.Trade) error {
+			if tr.Finished() {
+				return nil
+			}
+			Trades.Add(1, trade.CurrentStage(tr).String())
+			if info := tr.OwnInfo(); info != nil && info.Crypto != nil {
+				ValueLocked.Add(coins(trade.FundsAmount(tr.RecoverableFunds()), info.Crypto.Decimals), info.Crypto.Name)
+			}
+			if info := tr.TraderInfo(); info != nil && info.Crypto != nil {
+				ValueRedeemable.Add(coins(trade.FundsAmount(tr.RedeemableFunds()), info.Crypto.Decimals), info.Crypto.Name)
+			}
+			return nil
+		})
+	})
+}
+
+func coins(amount uint64, decimals int) float64 {
+	return float64(amount) / math.Pow10(decimals)
+}
@@ -0,0 +1,275 @@
+package metricsutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the default histogram buckets, in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type (
+	// Registry holds the metrics exposed in the prometheus text format
+	Registry struct {
+		mtx      sync.Mutex
+		metrics  []*metric
+		collects []func()
+	}
+
+	metric struct {
+		name    string
+		help    string
+		typ     string
+		labels  []string
+		buckets []float64
+		mtx     sync.Mutex
+		samples map[string]*sample
+	}
+
+	sample struct {
+		labels []string
+		value  float64
+		counts []uint64
+		count  uint64
+	}
+
+	// CounterVec is a counter partitioned by labels
+	CounterVec struct{ m *metric }
+
+	// GaugeVec is a gauge partitioned by labels
+	GaugeVec struct{ m *metric }
+
+	// HistogramVec is a histogram partitioned by labels
+	HistogramVec struct{ m *metric }
+)
+
+// NewRegistry returns a new empty registry
+func NewRegistry() *Registry { return &Registry{} }
+
+func (r *Registry) add(name, help, typ string, buckets []float64, labels []string) *metric {
+	m := &metric{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		samples: make(map[string]*sample, 4),
+	}
+	r.mtx.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mtx.Unlock()
+	return m
+}
+
+// NewCounterVec adds a new counter to the registry
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{m: r.add(name, help, "counter", nil, labels)}
+}
+
+// NewGaugeVec adds a new gauge to the registry
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{m: r.add(name, help, "gauge", nil, labels)}
+}
+
+// NewHistogramVec adds a new histogram to the registry
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{m: r.add(name, help, "histogram", buckets, labels)}
+}
+
+// OnCollect adds a function called before the metrics are written
+func (r *Registry) OnCollect(f func()) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.collects = append(r.collects, f)
+}
+
+// WriteTo writes the metrics in the prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mtx.Lock()
+	collects := append([]func(){}, r.collects...)
+	metrics := append([]*metric{}, r.metrics...)
+	r.mtx.Unlock()
+	for _, i := range collects {
+		i()
+	}
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	for _, i := range metrics {
+		i.write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP implement http.Handler
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// Serve serves the registry metrics at /metrics on the address. It returns
+// a function to stop the server
+func (r *Registry) Serve(addr string) (func() error, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	s := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go s.Serve(ln)
+	return s.Close, nil
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (m *metric) key(values []string) string {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("%s: expecting %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (m *metric) get(values []string) *sample {
+	key := m.key(values)
+	s, ok := m.samples[key]
+	if !ok {
+		s = &sample{labels: append([]string{}, values...)}
+		if m.buckets != nil {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.samples[key] = s
+	}
+	return s
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func formatLabels(names []string, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	r := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		r = append(r, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		r = append(r, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(r, ",") + "}"
+}
+
+func (m *metric) write(cw *countWriter) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	cw.printf("# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+	keys := make([]string, 0, len(m.samples))
+	for k := range m.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := m.samples[k]
+		if m.buckets == nil {
+			cw.printf("%s%s %s\n", m.name, formatLabels(m.labels, s.labels), formatFloat(s.value))
+			continue
+		}
+		var cum uint64
+		for i, b := range m.buckets {
+			cum += s.counts[i]
+			cw.printf("%s_bucket%s %d\n", m.name, formatLabels(m.labels, s.labels, "le", formatFloat(b)), cum)
+		}
+		cw.printf("%s_bucket%s %d\n", m.name, formatLabels(m.labels, s.labels, "le", "+Inf"), s.count)
+		cw.printf("%s_sum%s %s\n", m.name, formatLabels(m.labels, s.labels), formatFloat(s.value))
+		cw.printf("%s_count%s %d\n", m.name, formatLabels(m.labels, s.labels), s.count)
+	}
+}
+
+// Add adds v to the counter
+func (cv *CounterVec) Add(v float64, labels ...string) {
+	cv.m.mtx.Lock()
+	defer cv.m.mtx.Unlock()
+	cv.m.get(labels).value += v
+}
+
+// Inc increments the counter
+func (cv *CounterVec) Inc(labels ...string) { cv.Add(1, labels...) }
+
+// Set sets the gauge
+func (gv *GaugeVec) Set(v float64, labels ...string) {
+	gv.m.mtx.Lock()
+	defer gv.m.mtx.Unlock()
+	gv.m.get(labels).value = v
+}
+
+// Add adds v to the gauge
+func (gv *GaugeVec) Add(v float64, labels ...string) {
+	gv.m.mtx.Lock()
+	defer gv.m.mtx.Unlock()
+	gv.m.get(labels).value += v
+}
+
+// Delete removes the gauge value of the labels
+func (gv *GaugeVec) Delete(labels ...string) {
+	gv.m.mtx.Lock()
+	defer gv.m.mtx.Unlock()
+	delete(gv.m.samples, gv.m.key(labels))
+}
+
+// Reset removes all the gauge values
+func (gv *GaugeVec) Reset() {
+	gv.m.mtx.Lock()
+	defer gv.m.mtx.Unlock()
+	gv.m.samples = make(map[string]*sample, 4)
+}
+
+// Observe adds an observation to the histogram
+func (hv *HistogramVec) Observe(v float64, labels ...string) {
+	hv.m.mtx.Lock()
+	defer hv.m.mtx.Unlock()
+	s := hv.m.get(labels)
+	for i, b := range hv.m.buckets {
+		if v <= b {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.value += v
+}
@@ -0,0 +1,136 @@
+package metricsutil
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/roles"
+	"github.com/transmutate-io/atomicswap/trade"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_calls_total", "Calls.", "crypto")
+	g := r.NewGaugeVec("test_height", "Height.", "trade", "target")
+	h := r.NewHistogramVec("test_seconds", "Latency.", []float64{0.1, 1}, "method")
+	c.Inc("bitcoin")
+	c.Add(2, "bitcoin")
+	c.Inc("litecoin")
+	g.Set(10, `a"b`, "own")
+	h.Observe(0.05, "get")
+	h.Observe(0.5, "get")
+	h.Observe(5, "get")
+	var collected int
+	r.OnCollect(func() { collected++ })
+	s := httptest.NewServer(r)
+	defer s.Close()
+	resp, err := s.Client().Get(s.URL)
+	require.NoError(t, err, "can't get metrics")
+	defer resp.Body.Close()
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header.Get("Content-Type"), "content type mismatch")
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err, "can't read metrics")
+	require.Equal(t, 1, collected, "collect functions not called")
+	require.Equal(t, `# HELP test_calls_total Calls.
+# TYPE test_calls_total counter
+test_calls_total{crypto="bitcoin"} 3
+test_calls_total{crypto="litecoin"} 1
+# HELP test_height Height.
+# TYPE test_height gauge
+test_height{trade="a\"b",target="own"} 10
+# HELP test_seconds Latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="get",le="0.1"} 1
+test_seconds_bucket{method="get",le="1"} 2
+test_seconds_bucket{method="get",le="+Inf"} 3
+test_seconds_sum{method="get"} 5.55
+test_seconds_count{method="get"} 3
+`, string(b), "metrics mismatch")
+	g.Set(20, "trade2", "own")
+	g.Delete("trade2", "own")
+	buf := &bytes.Buffer{}
+	_, err = r.WriteTo(buf)
+	require.NoError(t, err, "can't write metrics")
+	require.NotContains(t, buf.String(), `test_height{trade="trade2"`, "expecting a deleted gauge value")
+	require.Contains(t, buf.String(), `test_height{trade="a\"b",target="own"} 10`, "expecting a gauge value")
+	g.Reset()
+	buf = &bytes.Buffer{}
+	_, err = r.WriteTo(buf)
+	require.NoError(t, err, "can't write metrics")
+	require.NotContains(t, buf.String(), "test_height{", "expecting no gauge values")
+	require.Panics(t, func() { c.Inc() }, "expecting a panic")
+}
+
+type testTrade struct {
+	trade.Trade
+	own      *trade.TraderInfo
+	trader   *trade.TraderInfo
+	finished bool
+}
+
+func (tt *testTrade) Role() roles.Role                  { return roles.Buyer }
+func (tt *testTrade) OwnInfo() *trade.TraderInfo        { return tt.own }
+func (tt *testTrade) TraderInfo() *trade.TraderInfo     { return tt.trader }
+func (tt *testTrade) RecoverableFunds() trade.FundsData { return nil }
+func (tt *testTrade) RedeemableFunds() trade.FundsData  { return nil }
+func (tt *testTrade) Finished() bool                    { return tt.finished }
+
+func TestDefaultMetrics(t *testing.T) {
+	ObserveRPC("bitcoin", "getblockcount", time.Now(), nil)
+	ObserveRPC("bitcoin", "getblockcount", time.Now(), errors.New("failed"))
+	ObserveWatchData("trade1", "own", 20, 10)
+	buf := &bytes.Buffer{}
+	_, err := Default.WriteTo(buf)
+	require.NoError(t, err, "can't write metrics")
+	for _, i := range []string{
+		`atomicswap_rpc_duration_seconds_count{crypto="bitcoin",method="getblockcount"} 2`,
+		`atomicswap_rpc_errors_total{crypto="bitcoin",method="getblockcount"} 1`,
+		`atomicswap_watch_top_height{trade="trade1",target="own"} 20`,
+		`atomicswap_watch_bottom_height{trade="trade1",target="own"} 10`,
+	} {
+		require.Contains(t, buf.String(), i+"\n", "metric mismatch")
+	}
+	// the series of the ended watches are removed
+	ForgetWatchData("trade1", "own")
+	buf.Reset()
+	_, err = Default.WriteTo(buf)
+	require.NoError(t, err, "can't write metrics")
+	require.NotContains(t, buf.String(), `trade="trade1"`, "expecting no watch series")
+}
+
+func TestCollectTrades(t *testing.T) {
+	trades := []*testTrade{
+		{own: &trade.TraderInfo{Crypto: cryptos.Bitcoin}, trader: &trade.TraderInfo{Crypto: cryptos.Litecoin}},
+		{own: &trade.TraderInfo{Crypto: cryptos.Bitcoin}, trader: &trade.TraderInfo{Crypto: cryptos.Dogecoin}},
+		// finished trades aren't collected
+		{own: &trade.TraderInfo{Crypto: cryptos.Decred}, trader: &trade.TraderInfo{Crypto: cryptos.BitcoinCash}, finished: true},
+	}
+	CollectTrades(func(f func(string, trade.Trade) error) error {
+		for _, i := range trades {
+			if err := f("trade", i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	buf := &bytes.Buffer{}
+	_, err := Default.WriteTo(buf)
+	require.NoError(t, err, "can't write metrics")
+	for _, i := range []string{
+		`atomicswap_trades{stage="share-proposal"} 2`,
+		`atomicswap_value_locked{crypto="bitcoin"} 0`,
+		`atomicswap_value_redeemable{crypto="dogecoin"} 0`,
+		`atomicswap_value_redeemable{crypto="litecoin"} 0`,
+	} {
+		require.Contains(t, buf.String(), i+"\n", "metric mismatch")
+	}
+	require.NotContains(t, buf.String(), `crypto="decred"`, "expecting no finished trades")
+	require.NotContains(t, buf.String(), `crypto="bitcoin-cash"`, "expecting no finished trades")
+	require.Equal(t, 1.5, coins(150000000, 8), "coins mismatch")
+}
@@ -0,0 +1,85 @@
+package notifyutil
+
+import (
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/trade"
+)
+
+// DepositTracker turns the deposit watch callbacks into events. The new
+// outputs are confirmed when the watch inspects the block at the required
+// number of confirmations
+type DepositTracker struct {
+	trade         string
+	info          *trade.TraderInfo
+	confirmations uint64
+	top           uint64
+	pending       map[string]*chainutil.DepositEvent
+	reached       bool
+}
+
+// NewDepositTracker returns a new tracker for a trade deposit
+func NewDepositTracker(tradeName string, info *trade.TraderInfo, confirmations uint64) *DepositTracker {
+	return &DepositTracker{
+		trade:         tradeName,
+		info:          info,
+		confirmations: confirmations,
+		pending:       make(map[string]*chainutil.DepositEvent, 4),
+	}
+}
+
+func (dt *DepositTracker) newEvent(t EventType, ev *chainutil.DepositEvent) *Event {
+	return &Event{
+		Type:   t,
+		Trade:  dt.trade,
+		Crypto: dt.info.Crypto.Name,
+		Data: map[string]interface{}{
+			"output": ev.ID,
+			"height": ev.Height,
+			"amount": ev.Amount,
+			"total":  ev.Total,
+			"target": ev.Target,
+		},
+	}
+}
+
+func (dt *DepositTracker) confirmed(height uint64) bool {
+	return dt.confirmations <= 1 || (dt.top >= height && dt.top-height+1 >= dt.confirmations)
+}
+
+// Output returns the events of a deposit output
+func (dt *DepositTracker) Output(ev *chainutil.DepositEvent) []*Event {
+	if !ev.New {
+		return nil
+	}
+	if ev.Height > dt.top {
+		dt.top = ev.Height
+	}
+	r := []*Event{dt.newEvent(DepositSeen, ev)}
+	if dt.confirmed(ev.Height) {
+		r = append(r, dt.newEvent(DepositConfirmed, ev))
+	} else {
+		dt.pending[ev.ID] = ev
+	}
+	dec := dt.info.Crypto.Decimals
+	if !dt.reached && ev.Total.UInt64(dec) >= ev.Target.UInt64(dec) {
+		dt.reached = true
+		r = append(r, dt.newEvent(TargetReached, ev))
+	}
+	return r
+}
+
+// Block returns the events of an inspected block
+func (dt *DepositTracker) Block(height uint64) []*Event {
+	if height <= dt.top {
+		return nil
+	}
+	dt.top = height
+	var r []*Event
+	for id, ev := range dt.pending {
+		if dt.confirmed(ev.Height) {
+			r = append(r, dt.newEvent(DepositConfirmed, ev))
+			delete(dt.pending, id)
+		}
+	}
+	return r
+}
@@ -0,0 +1,93 @@
+package notifyutil
+
+import (
+	"sort"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+)
+
+// lock names
+const (
+	OwnLock    = "own"
+	TraderLock = "trader"
+)
+
+// DefaultMargins are the default warning margins before the lock times
+var DefaultMargins = []time.Duration{24 * time.Hour, 6 * time.Hour, time.Hour}
+
+// LockTracker turns the median time past of a chain into the events of a
+// lock. It warns once per margin before the lock time and once when the lock
+// time is reached
+type LockTracker struct {
+	trade    string
+	lock     string
+	crypto   *cryptos.Crypto
+	lockTime time.Time
+	margins  []time.Duration
+	warned   int
+	expired  bool
+}
+
+// NewLockTracker returns a new tracker for the own or the trader lock of a
+// trade
+func NewLockTracker(tradeName string, lock string, c *cryptos.Crypto, lockTime time.Time, margins []time.Duration) *LockTracker {
+	ms := append([]time.Duration{}, margins...)
+	sort.Slice(ms, func(i, j int) bool { return ms[i] > ms[j] })
+	return &LockTracker{
+		trade:    tradeName,
+		lock:     lock,
+		crypto:   c,
+		lockTime: lockTime,
+		margins:  ms,
+	}
+}
+
+// Expired returns true if the lock time was reached
+func (lt *LockTracker) Expired() bool { return lt.expired }
+
+func (lt *LockTracker) newEvent(t EventType, mtp time.Time) *Event {
+	return &Event{
+		Type:   t,
+		Trade:  lt.trade,
+		Crypto: lt.crypto.Name,
+		Data: map[string]interface{}{
+			"lock":        lt.lock,
+			"lock_time":   lt.lockTime.UTC().Format(time.RFC3339),
+			"median_time": mtp.UTC().Format(time.RFC3339),
+		},
+	}
+}
+
+// Time returns the events for the median time past of the chain. Reaching the
+// own lock time makes the recovery available
+func (lt *LockTracker) Time(mtp time.Time) []*Event {
+	if lt.expired || lt.lockTime.IsZero() {
+		return nil
+	}
+	if mtp.After(lt.lockTime) {
+		lt.expired = true
+		if lt.lock == OwnLock {
+			return []*Event{lt.newEvent(RecoveryAvailable, mtp)}
+		}
+		r := lt.newEvent(LockExpiring, mtp)
+		r.Data["expired"] = true
+		return []*Event{r}
+	}
+	remaining := lt.lockTime.Sub(mtp)
+	crossed := 0
+	for _, i := range lt.margins {
+		if remaining <= i {
+			crossed++
+		}
+	}
+	if crossed <= lt.warned {
+		return nil
+	}
+	// only the smallest margin crossed is warned
+	lt.warned = crossed
+	r := lt.newEvent(LockExpiring, mtp)
+	r.Data["margin"] = lt.margins[crossed-1].String()
+	r.Data["remaining"] = remaining.String()
+	return []*Event{r}
+}
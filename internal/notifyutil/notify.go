@@ -0,0 +1,338 @@
+package notifyutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType represents the type of a trade event
+type EventType string
+
+// event types
+const (
+	DepositSeen       EventType = "deposit-seen"
+	DepositConfirmed  EventType = "deposit-confirmed"
+	TargetReached     EventType = "target-reached"
+	TokenRevealed     EventType = "token-revealed"
+	RedeemBroadcast   EventType = "redeem-broadcast"
+	RedeemConfirmed   EventType = "redeem-confirmed"
+	RecoveryBroadcast EventType = "recovery-broadcast"
+	LockExpiring      EventType = "lock-expiring"
+	RecoveryAvailable EventType = "recovery-available"
+)
+
+// Event represents a trade event
+type Event struct {
+	Time   time.Time              `json:"time"`
+	Type   EventType              `json:"type"`
+	Trade  string                 `json:"trade"`
+	Crypto string                 `json:"crypto,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+// Sink receives the events
+type Sink interface {
+	// Notify sends an event
+	Notify(ev *Event) error
+}
+
+// Config holds the sinks configuration
+type Config struct {
+	// Webhook is the url the events are posted to
+	Webhook string
+	// Secret is the key of the webhook signature
+	Secret string
+	// Command is the shell command executed for each event
+	Command string
+	// Log is the file the events are appended to
+	Log string
+}
+
+// NewNotifier returns a notifier for the configured sinks or nil if none
+// is configured
+func (cfg *Config) NewNotifier() *Notifier {
+	sinks := make([]Sink, 0, 3)
+	if cfg.Webhook != "" {
+		sinks = append(sinks, NewWebhook(cfg.Webhook, cfg.Secret))
+	}
+	if cfg.Command != "" {
+		sinks = append(sinks, &CommandSink{Command: cfg.Command})
+	}
+	if cfg.Log != "" {
+		sinks = append(sinks, &LogSink{Path: cfg.Log})
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+	return NewNotifier(sinks...)
+}
+
+// queueSize is the number of events queued for delivery
+const queueSize = 256
+
+// errors
+var (
+	ErrQueueFull      = errors.New("notification queue full")
+	ErrNotifierClosed = errors.New("notifier closed")
+)
+
+// Notifier sends the events to every sink in the background, in order. A nil
+// notifier discards the events
+type Notifier struct {
+	sinks   []Sink
+	queue   chan *Event
+	mtx     sync.Mutex
+	onError func(ev *Event, err error)
+	closed  bool
+	donec   chan struct{}
+}
+
+// NewNotifier returns a notifier for the sinks
+func NewNotifier(sinks ...Sink) *Notifier {
+	r := &Notifier{
+		sinks: sinks,
+		queue: make(chan *Event, queueSize),
+		donec: make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (n *Notifier) run() {
+	defer close(n.donec)
+	for ev := range n.queue {
+		if err := n.Send(ev); err != nil {
+			n.mtx.Lock()
+			f := n.onError
+			n.mtx.Unlock()
+			if f != nil {
+				f(ev, err)
+			}
+		}
+	}
+}
+
+// OnError sets the function called with the events that failed to be
+// delivered
+func (n *Notifier) OnError(f func(ev *Event, err error)) {
+	if n == nil {
+		return
+	}
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.onError = f
+}
+
+// Notify queues the event for delivery without waiting for the sinks. The
+// time is set if it's zero. It returns ErrQueueFull if the queue is full
+func (n *Notifier) Notify(ev *Event) error {
+	if n == nil {
+		return nil
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if n.closed {
+		return ErrNotifierClosed
+	}
+	select {
+	case n.queue <- ev:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Send sends the event to all the sinks and waits for them. The time is set if
+// it's zero. It returns the first error
+func (n *Notifier) Send(ev *Event) error {
+	if n == nil {
+		return nil
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	var r error
+	for _, i := range n.sinks {
+		if err := i.Notify(ev); err != nil && r == nil {
+			r = err
+		}
+	}
+	return r
+}
+
+// Close delivers the queued events and stops the notifier. The events can't
+// be notified after closing
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	n.mtx.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mtx.Unlock()
+	<-n.donec
+}
+
+// signature headers
+const (
+	EventHeader     = "X-Atomicswap-Event"
+	SignatureHeader = "X-Atomicswap-Signature"
+)
+
+// Signature returns the signature of a webhook body
+func Signature(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// WebhookSink posts the events as json. The body is signed with HMAC-SHA256
+// if the secret isn't empty
+type WebhookSink struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// NewWebhook returns a new webhook sink
+func NewWebhook(url string, secret string) *WebhookSink {
+	return &WebhookSink{URL: url, Secret: secret, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Notify implement Sink
+func (ws *WebhookSink) Notify(ev *Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, ws.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(ev.Type))
+	if ws.Secret != "" {
+		req.Header.Set(SignatureHeader, Signature(ws.Secret, b))
+	}
+	resp, err := ws.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// CommandSink executes a shell command for each event. The event is written
+// to the standard input as json and the fields are set in the environment:
+// ATOMICSWAP_EVENT, ATOMICSWAP_TRADE, ATOMICSWAP_CRYPTO, ATOMICSWAP_TIME and
+// ATOMICSWAP_DATA_<KEY> for each data key. The command is killed after the
+// timeout, DefaultCommandTimeout if it's zero
+type CommandSink struct {
+	Command string
+	Timeout time.Duration
+}
+
+// DefaultCommandTimeout is the default time limit of a command
+const DefaultCommandTimeout = 30 * time.Second
+
+// Env returns the environment variables of an event
+func Env(ev *Event) []string {
+	r := []string{
+		"ATOMICSWAP_EVENT=" + string(ev.Type),
+		"ATOMICSWAP_TRADE=" + ev.Trade,
+		"ATOMICSWAP_CRYPTO=" + ev.Crypto,
+		"ATOMICSWAP_TIME=" + ev.Time.Format(time.RFC3339),
+	}
+	keys := make([]string, 0, len(ev.Data))
+	for k := range ev.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(k))
+		r = append(r, fmt.Sprintf("ATOMICSWAP_DATA_%s=%v", name, ev.Data[k]))
+	}
+	return r
+}
+
+// Notify implement Sink
+func (cs *CommandSink) Notify(ev *Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	timeout := cs.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", cs.Command)
+	cmd.Env = append(os.Environ(), Env(ev)...)
+	cmd.Stdin = bytes.NewReader(b)
+	out := &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = out, out
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("command: %s", err)
+	}
+	// the children of the shell can keep the output open after it's killed
+	errc := make(chan error, 1)
+	go func() { errc <- cmd.Wait() }()
+	select {
+	case err = <-errc:
+		if err != nil {
+			return fmt.Errorf("command: %s: %s", err, bytes.TrimSpace(out.Bytes()))
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("command: timed out after %s", timeout)
+	}
+}
+
+// LogSink appends the events to a file, one json object per line
+type LogSink struct {
+	Path string
+	mtx  sync.Mutex
+}
+
+// Notify implement Sink
+func (ls *LogSink) Notify(ev *Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	ls.mtx.Lock()
+	defer ls.mtx.Unlock()
+	f, err := os.OpenFile(ls.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
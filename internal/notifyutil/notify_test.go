@@ -0,0 +1,230 @@
+package notifyutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+func newTestEvent() *Event {
+	return &Event{
+		Time:   time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC),
+		Type:   RedeemBroadcast,
+		Trade:  "trade1",
+		Crypto: cryptos.Bitcoin.Name,
+		Data:   map[string]interface{}{"txid": "0102"},
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	reqs := make(chan *request, 1)
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err, "can't read body")
+		reqs <- &request{header: r.Header, body: b}
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+	n := (&Config{Webhook: s.URL, Secret: "secret"}).NewNotifier()
+	require.NoError(t, n.Send(newTestEvent()), "can't notify")
+	req := <-reqs
+	require.Equal(t, "application/json", req.header.Get("Content-Type"), "content type mismatch")
+	require.Equal(t, string(RedeemBroadcast), req.header.Get(EventHeader), "event mismatch")
+	require.Equal(t, Signature("secret", req.body), req.header.Get(SignatureHeader), "signature mismatch")
+	require.NotEqual(t, Signature("other", req.body), req.header.Get(SignatureHeader), "signature mismatch")
+	ev := &Event{}
+	require.NoError(t, json.Unmarshal(req.body, ev), "can't unmarshal event")
+	require.Equal(t, newTestEvent(), ev, "event mismatch")
+	// without a secret the events aren't signed
+	require.NoError(t, NewWebhook(s.URL, "").Notify(newTestEvent()), "can't notify")
+	req = <-reqs
+	require.Empty(t, req.header.Get(SignatureHeader), "expecting no signature")
+	status = http.StatusInternalServerError
+	require.Error(t, n.Send(newTestEvent()), "expecting an error")
+	<-reqs
+}
+
+func TestCommandAndLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notifyutil")
+	require.NoError(t, err, "can't create temp dir")
+	defer os.RemoveAll(dir)
+	envFile := filepath.Join(dir, "env")
+	stdinFile := filepath.Join(dir, "stdin")
+	logFile := filepath.Join(dir, "events.jsonl")
+	n := (&Config{
+		Command: "env | grep ^ATOMICSWAP_ | sort > " + envFile + " && cat > " + stdinFile,
+		Log:     logFile,
+	}).NewNotifier()
+	require.NoError(t, n.Send(newTestEvent()), "can't notify")
+	b, err := ioutil.ReadFile(envFile)
+	require.NoError(t, err, "can't read environment")
+	require.Equal(t, []string{
+		"ATOMICSWAP_CRYPTO=bitcoin",
+		"ATOMICSWAP_DATA_TXID=0102",
+		"ATOMICSWAP_EVENT=redeem-broadcast",
+		"ATOMICSWAP_TIME=2020-09-01T12:00:00Z",
+		"ATOMICSWAP_TRADE=trade1",
+	}, strings.Split(strings.TrimSpace(string(b)), "\n"), "environment mismatch")
+	b, err = ioutil.ReadFile(stdinFile)
+	require.NoError(t, err, "can't read input")
+	ev := &Event{}
+	require.NoError(t, json.Unmarshal(b, ev), "can't unmarshal event")
+	require.Equal(t, newTestEvent(), ev, "event mismatch")
+	ev2 := newTestEvent()
+	ev2.Type = TokenRevealed
+	require.NoError(t, n.Send(ev2), "can't notify")
+	f, err := os.Open(logFile)
+	require.NoError(t, err, "can't open log")
+	defer f.Close()
+	var evTypes []EventType
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		ev := &Event{}
+		require.NoError(t, json.Unmarshal(s.Bytes(), ev), "can't unmarshal event")
+		evTypes = append(evTypes, ev.Type)
+	}
+	require.Equal(t, []EventType{RedeemBroadcast, TokenRevealed}, evTypes, "events mismatch")
+	require.Error(t, (&CommandSink{Command: "exit 1"}).Notify(newTestEvent()), "expecting an error")
+	start := time.Now()
+	err = (&CommandSink{Command: "sleep 10", Timeout: 100 * time.Millisecond}).Notify(newTestEvent())
+	require.Error(t, err, "expecting a timeout")
+	require.True(t, time.Since(start) < 5*time.Second, "expecting the command to be killed")
+	var nilNotifier *Notifier
+	require.NoError(t, nilNotifier.Notify(newTestEvent()), "expecting no error")
+	nilNotifier.Close()
+	require.Nil(t, (&Config{}).NewNotifier(), "expecting no notifier")
+}
+
+type errorSink struct{ err error }
+
+func (es *errorSink) Notify(ev *Event) error { return es.err }
+
+func TestNotifierQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notifyutil")
+	require.NoError(t, err, "can't create temp dir")
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "events.jsonl")
+	sinkErr := errors.New("sink error")
+	n := NewNotifier(&LogSink{Path: logFile}, &errorSink{err: sinkErr})
+	var failed []EventType
+	n.OnError(func(ev *Event, err error) {
+		require.Equal(t, sinkErr, err, "error mismatch")
+		failed = append(failed, ev.Type)
+	})
+	evTypes := []EventType{DepositSeen, DepositConfirmed, RedeemBroadcast, RedeemConfirmed}
+	for _, i := range evTypes {
+		ev := newTestEvent()
+		ev.Type = i
+		require.NoError(t, n.Notify(ev), "can't notify")
+	}
+	// the queued events are delivered in order when closing
+	n.Close()
+	require.Equal(t, evTypes, failed, "failed events mismatch")
+	b, err := ioutil.ReadFile(logFile)
+	require.NoError(t, err, "can't read log")
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, len(evTypes), "events mismatch")
+	for i, l := range lines {
+		ev := &Event{}
+		require.NoError(t, json.Unmarshal([]byte(l), ev), "can't unmarshal event")
+		require.Equal(t, evTypes[i], ev.Type, "event mismatch")
+	}
+	require.Equal(t, ErrNotifierClosed, n.Notify(newTestEvent()), "expecting a closed notifier")
+	n.Close()
+}
+
+func eventTypes(evs []*Event) []EventType {
+	r := make([]EventType, 0, len(evs))
+	for _, i := range evs {
+		r = append(r, i.Type)
+	}
+	return r
+}
+
+func TestDepositTracker(t *testing.T) {
+	info := &trade.TraderInfo{Crypto: cryptos.Bitcoin, Amount: types.Amount("1")}
+	dt := NewDepositTracker("trade1", info, 3)
+	require.Empty(t, dt.Output(&chainutil.DepositEvent{ID: "known"}), "expecting no events")
+	evs := dt.Output(&chainutil.DepositEvent{
+		New:    true,
+		Height: 10,
+		ID:     "a:0",
+		Amount: types.Amount("0.5"),
+		Total:  types.Amount("0.5"),
+		Target: info.Amount,
+	})
+	require.Equal(t, []EventType{DepositSeen}, eventTypes(evs), "events mismatch")
+	require.Equal(t, "trade1", evs[0].Trade, "trade mismatch")
+	require.Equal(t, "a:0", evs[0].Data["output"], "output mismatch")
+	require.Empty(t, dt.Block(11), "expecting no events")
+	evs = dt.Output(&chainutil.DepositEvent{
+		New:    true,
+		Height: 11,
+		ID:     "b:1",
+		Amount: types.Amount("0.5"),
+		Total:  types.Amount("1"),
+		Target: info.Amount,
+	})
+	require.Equal(t, []EventType{DepositSeen, TargetReached}, eventTypes(evs), "events mismatch")
+	evs = dt.Block(12)
+	require.Equal(t, []EventType{DepositConfirmed}, eventTypes(evs), "events mismatch")
+	require.Equal(t, "a:0", evs[0].Data["output"], "output mismatch")
+	require.Empty(t, dt.Block(9), "expecting no events")
+	evs = dt.Block(13)
+	require.Equal(t, []EventType{DepositConfirmed}, eventTypes(evs), "events mismatch")
+	require.Equal(t, "b:1", evs[0].Data["output"], "output mismatch")
+	// confirmed when found
+	dt = NewDepositTracker("trade1", info, 0)
+	evs = dt.Output(&chainutil.DepositEvent{New: true, Height: 10, ID: "a:0", Total: types.Amount("0.1"), Target: info.Amount})
+	require.Equal(t, []EventType{DepositSeen, DepositConfirmed}, eventTypes(evs), "events mismatch")
+}
+
+func TestLockTracker(t *testing.T) {
+	lockTime := time.Date(2020, 9, 2, 12, 0, 0, 0, time.UTC)
+	lt := NewLockTracker("trade1", OwnLock, cryptos.Bitcoin, lockTime, []time.Duration{time.Hour, 24 * time.Hour})
+	require.Empty(t, lt.Time(lockTime.Add(-48*time.Hour)), "expecting no events")
+	evs := lt.Time(lockTime.Add(-12 * time.Hour))
+	require.Equal(t, []EventType{LockExpiring}, eventTypes(evs), "events mismatch")
+	require.Equal(t, OwnLock, evs[0].Data["lock"], "lock mismatch")
+	require.Equal(t, "24h0m0s", evs[0].Data["margin"], "margin mismatch")
+	require.Equal(t, "12h0m0s", evs[0].Data["remaining"], "remaining mismatch")
+	require.Empty(t, lt.Time(lockTime.Add(-6*time.Hour)), "expecting no events")
+	evs = lt.Time(lockTime.Add(-time.Minute))
+	require.Equal(t, []EventType{LockExpiring}, eventTypes(evs), "events mismatch")
+	require.Equal(t, "1h0m0s", evs[0].Data["margin"], "margin mismatch")
+	// the lock time must be passed
+	require.Empty(t, lt.Time(lockTime), "expecting no events")
+	require.False(t, lt.Expired(), "expecting an active lock")
+	evs = lt.Time(lockTime.Add(time.Second))
+	require.Equal(t, []EventType{RecoveryAvailable}, eventTypes(evs), "events mismatch")
+	require.True(t, lt.Expired(), "expecting an expired lock")
+	require.Empty(t, lt.Time(lockTime.Add(time.Hour)), "expecting no events")
+	// only the smallest margin is warned and the trader lock expires
+	lt = NewLockTracker("trade1", TraderLock, cryptos.Litecoin, lockTime, DefaultMargins)
+	evs = lt.Time(lockTime.Add(-30 * time.Minute))
+	require.Equal(t, []EventType{LockExpiring}, eventTypes(evs), "events mismatch")
+	require.Equal(t, "1h0m0s", evs[0].Data["margin"], "margin mismatch")
+	evs = lt.Time(lockTime.Add(time.Minute))
+	require.Equal(t, []EventType{LockExpiring}, eventTypes(evs), "events mismatch")
+	require.Equal(t, true, evs[0].Data["expired"], "expecting an expired lock")
+	require.Equal(t, cryptos.Litecoin.Name, evs[0].Crypto, "crypto mismatch")
+}
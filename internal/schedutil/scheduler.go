@@ -0,0 +1,274 @@
+package schedutil
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/internal/flagutil"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore"
+)
+
+// Config holds the scheduler configuration
+type Config struct {
+	// Margins are the durations before the lock times to warn at
+	Margins []time.Duration
+	// RecoveryAddresses are the addresses, by crypto name, the own funds are
+	// recovered to once the lock time is reached. The funds of the cryptos
+	// without an address aren't recovered
+	RecoveryAddresses map[string]string
+	// Fee is the fee of the recoveries
+	Fee chainutil.Fee
+	// MaxFeeShare is the maximum share of the amount paid in fees by the
+	// recoveries, zero for no limit
+	MaxFeeShare float64
+}
+
+// Scheduler checks the lock times of the trades against the median time past
+// of the chains. It warns at the margins and recovers the own funds once the
+// lock time is reached
+type Scheduler struct {
+	cfg       *Config
+	clients   map[string]*chainutil.ClientConfig
+	network   flagutil.NetworkFlag
+	newClient func(c *cryptos.Crypto, cfg *chainutil.ClientConfig, chain params.Chain) (cryptocore.Client, error)
+	trackers  map[string]*notifyutil.LockTracker
+	recovered map[string]bool
+}
+
+// New returns a new scheduler using the clients, by crypto name
+func New(cfg *Config, clients map[string]*chainutil.ClientConfig, network flagutil.NetworkFlag) *Scheduler {
+	if cfg.Margins == nil {
+		cfg.Margins = notifyutil.DefaultMargins
+	}
+	return &Scheduler{
+		cfg:       cfg,
+		clients:   clients,
+		network:   network,
+		newClient: newClient,
+		trackers:  make(map[string]*notifyutil.LockTracker, 16),
+		recovered: make(map[string]bool, 16),
+	}
+}
+
+func newClient(c *cryptos.Crypto, cfg *chainutil.ClientConfig, chain params.Chain) (cryptocore.Client, error) {
+	cl, err := cfg.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+	if err = chainutil.CheckNetwork(cl, c, chain); err != nil {
+		chainutil.CloseClient(cl)
+		return nil, err
+	}
+	return cl, nil
+}
+
+// chainState holds the state of a chain during a check
+type chainState struct {
+	cl  cryptocore.Client
+	cfg *chainutil.ClientConfig
+	sf  chainutil.SpendFinder
+	mtp time.Time
+	err error
+}
+
+func (s *Scheduler) chainState(states map[string]*chainState, c *cryptos.Crypto) *chainState {
+	if r, ok := states[c.Name]; ok {
+		return r
+	}
+	r := &chainState{}
+	states[c.Name] = r
+	cfg, ok := s.clients[c.Name]
+	if !ok {
+		r.err = fmt.Errorf("no client configured for %s", c.Name)
+		return r
+	}
+	chain, err := s.network.Network(c.Name)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	cl, err := s.newClient(c, cfg, chain)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.cl, r.cfg = cl, cfg
+	r.sf = chainutil.NewSpendFinder(c, cl, cfg)
+	r.mtp, r.err = chainutil.MedianTimePast(cl)
+	return r
+}
+
+// Check checks the locks of the trades once. It returns the events and the
+// first error, the failed trades don't stop the check. The recovered trades
+// are marked as finished, the caller must save them
+func (s *Scheduler) Check(eachTrade func(func(string, trade.Trade) error) error) ([]*notifyutil.Event, error) {
+	states := make(map[string]*chainState, 4)
+	defer func() {
+		for _, i := range states {
+			if i.cl != nil {
+				chainutil.CloseClient(i.cl)
+			}
+		}
+	}()
+	var (
+		r        []*notifyutil.Event
+		firstErr error
+	)
+	err := eachTrade(func(name string, tr trade.Trade) error {
+		evs, err := s.checkTrade(states, name, tr)
+		r = append(r, evs...)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+	return r, firstErr
+}
+
+func (s *Scheduler) checkTrade(states map[string]*chainState, name string, tr trade.Trade) ([]*notifyutil.Event, error) {
+	if tr.Finished() {
+		return nil, nil
+	}
+	dl, err := chainutil.TradeDeadlines(tr)
+	if err != nil {
+		return nil, err
+	}
+	var r []*notifyutil.Event
+	for _, i := range []struct {
+		lock     string
+		info     *trade.TraderInfo
+		fd       trade.FundsData
+		lockTime time.Time
+	}{
+		{notifyutil.OwnLock, tr.OwnInfo(), tr.RecoverableFunds(), dl.Recovery},
+		{notifyutil.TraderLock, tr.TraderInfo(), tr.RedeemableFunds(), dl.Redeem},
+	} {
+		key := name + "/" + i.lock
+		outputs, _ := i.fd.Funds().([]*trade.Output)
+		if i.lockTime.IsZero() || len(outputs) == 0 || s.recovered[key] {
+			continue
+		}
+		cs := s.chainState(states, i.info.Crypto)
+		if cs.err != nil {
+			return r, cs.err
+		}
+		// spent funds are redeemed or recovered
+		if cs.sf != nil {
+			spent, err := chainutil.FundsSpent(cs.sf, i.fd)
+			if err != nil {
+				return r, err
+			}
+			if spent {
+				delete(s.trackers, key)
+				continue
+			}
+		}
+		lt, ok := s.trackers[key]
+		if !ok {
+			lt = notifyutil.NewLockTracker(name, i.lock, i.info.Crypto, i.lockTime, s.cfg.Margins)
+			s.trackers[key] = lt
+		}
+		r = append(r, lt.Time(cs.mtp)...)
+		if i.lock != notifyutil.OwnLock || !lt.Expired() {
+			continue
+		}
+		addr, ok := s.cfg.RecoveryAddresses[i.info.Crypto.Name]
+		if !ok {
+			continue
+		}
+		ev, err := s.recover(cs, name, tr, addr)
+		if err != nil {
+			return r, err
+		}
+		tr.Finish()
+		s.recovered[key] = true
+		delete(s.trackers, key)
+		r = append(r, ev)
+	}
+	return r, nil
+}
+
+// recover broadcasts the recovery of the own funds
+func (s *Scheduler) recover(cs *chainState, name string, tr trade.Trade, addr string) (*notifyutil.Event, error) {
+	c := tr.OwnInfo().Crypto
+	chain, err := s.network.Network(c.Name)
+	if err != nil {
+		return nil, err
+	}
+	fee, fixedFee, err := s.cfg.Fee.Resolve(c, cs.cfg)
+	if err != nil {
+		return nil, err
+	}
+	t, err := chainutil.RecoveryTx(tr, chain, addr, fee, fixedFee)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.MaxFeeShare > 0 {
+		if err = checkFeeShare(t, chainutil.FundsAmounts(tr.RecoverableFunds()), s.cfg.MaxFeeShare); err != nil {
+			return nil, err
+		}
+	}
+	b, err := t.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	txID, err := cs.cl.SendRawTransaction(b)
+	if err != nil {
+		return nil, err
+	}
+	return &notifyutil.Event{
+		Type:   notifyutil.RecoveryBroadcast,
+		Trade:  name,
+		Crypto: c.Name,
+		Data:   map[string]interface{}{"txid": txID.Hex(), "address": addr},
+	}, nil
+}
+
+// checkFeeShare fails if the fee exceeds the share of the amount
+func checkFeeShare(t tx.Tx, amounts []uint64, maxShare float64) error {
+	txUTXO, ok := t.TxUTXO()
+	if !ok {
+		return tx.ErrNotUTXO
+	}
+	fee, err := tx.Fee(txUTXO, amounts)
+	if err != nil {
+		return err
+	}
+	var total uint64
+	for _, i := range amounts {
+		total += i
+	}
+	if float64(fee) > maxShare*float64(total) {
+		return fmt.Errorf("the fee is %.2f%% of the amount", float64(fee)*100/float64(total))
+	}
+	return nil
+}
+
+// Run checks the trades at every interval until closec is closed. The events
+// and the errors of each check are passed to f
+func (s *Scheduler) Run(
+	eachTrade func(func(string, trade.Trade) error) error,
+	interval time.Duration,
+	closec <-chan struct{},
+	f func([]*notifyutil.Event, error),
+) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		f(s.Check(eachTrade))
+		select {
+		case <-closec:
+			return
+		case <-t.C:
+		}
+	}
+}
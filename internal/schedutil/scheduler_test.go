@@ -0,0 +1,157 @@
+package schedutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/internal/notifyutil"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore"
+	"github.com/transmutate-io/cryptocore/block"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+type (
+	testLock struct{ lockTime time.Time }
+
+	testFunds struct {
+		outputs []*trade.Output
+		lock    trade.Lock
+	}
+
+	testTrade struct {
+		trade.Trade
+		own, trader *testFunds
+		finished    bool
+	}
+
+	// testChain is a chain where every block has the same time
+	testChain struct {
+		cryptocore.Client
+		time time.Time
+	}
+
+	testBlock struct{ time time.Time }
+)
+
+var errNoKeys = errors.New("no keys")
+
+func (l *testLock) Bytes() types.Bytes { return []byte{0x01} }
+func (l *testLock) LockData() (*trade.LockData, error) {
+	return &trade.LockData{LockTime: l.lockTime}, nil
+}
+func (l *testLock) Address(chain params.Chain) (string, error) { return "", nil }
+
+func (f *testFunds) AddFunds(funds interface{}) {}
+func (f *testFunds) Funds() interface{}         { return f.outputs }
+func (f *testFunds) SetLock(lock trade.Lock)    { f.lock = lock }
+func (f *testFunds) Lock() trade.Lock           { return f.lock }
+
+func (t *testTrade) OwnInfo() *trade.TraderInfo { return &trade.TraderInfo{Crypto: cryptos.Bitcoin} }
+func (t *testTrade) TraderInfo() *trade.TraderInfo {
+	return &trade.TraderInfo{Crypto: cryptos.Litecoin}
+}
+func (t *testTrade) RecoverableFunds() trade.FundsData { return t.own }
+func (t *testTrade) RedeemableFunds() trade.FundsData  { return t.trader }
+func (t *testTrade) Finished() bool                    { return t.finished }
+func (t *testTrade) Finish()                           { t.finished = true }
+func (t *testTrade) RecoveryTx(destScript []byte, fee uint64) (tx.Tx, error) {
+	return nil, errNoKeys
+}
+
+func (c *testChain) BlockCount() (uint64, error)                  { return 20, nil }
+func (c *testChain) BlockHash(height uint64) (types.Bytes, error) { return []byte{byte(height)}, nil }
+func (c *testChain) Block(hash types.Bytes) (block.Block, error) {
+	return &testBlock{time: c.time}, nil
+}
+
+func (b *testBlock) Hash() types.Bytes              { return nil }
+func (b *testBlock) Confirmations() int             { return 1 }
+func (b *testBlock) Height() int                    { return 0 }
+func (b *testBlock) Transactions() []types.Bytes    { return nil }
+func (b *testBlock) Time() types.UnixTime           { return types.NewUnixTime(b.time) }
+func (b *testBlock) PreviousBlockHash() types.Bytes { return nil }
+func (b *testBlock) NextBlockHash() types.Bytes     { return nil }
+
+func newTestFunds(lockTime time.Time) *testFunds {
+	return &testFunds{
+		outputs: []*trade.Output{{TxID: make([]byte, 32), Amount: 100000}},
+		lock:    &testLock{lockTime: lockTime},
+	}
+}
+
+func eventTypes(evs []*notifyutil.Event) []notifyutil.EventType {
+	r := make([]notifyutil.EventType, 0, len(evs))
+	for _, i := range evs {
+		r = append(r, i.Type)
+	}
+	return r
+}
+
+func TestScheduler(t *testing.T) {
+	lockTime := time.Date(2020, 9, 2, 12, 0, 0, 0, time.UTC)
+	trades := map[string]*testTrade{
+		"trade1": {own: newTestFunds(lockTime), trader: newTestFunds(lockTime.Add(24 * time.Hour))},
+		// nothing locked yet
+		"trade2": {own: &testFunds{}, trader: &testFunds{}},
+	}
+	eachTrade := func(f func(string, trade.Trade) error) error {
+		for _, name := range []string{"trade1", "trade2"} {
+			if err := f(name, trades[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	chains := map[string]*testChain{
+		cryptos.Bitcoin.Name:  {time: lockTime.Add(-2 * time.Hour)},
+		cryptos.Litecoin.Name: {time: lockTime.Add(-2 * time.Hour)},
+	}
+	cfg := &chainutil.ClientConfig{Backend: "esplora://localhost"}
+	s := New(&Config{}, map[string]*chainutil.ClientConfig{
+		cryptos.Bitcoin.Name:  cfg,
+		cryptos.Litecoin.Name: cfg,
+	}, "mainnet")
+	s.newClient = func(c *cryptos.Crypto, _ *chainutil.ClientConfig, _ params.Chain) (cryptocore.Client, error) {
+		return chains[c.Name], nil
+	}
+	evs, err := s.Check(eachTrade)
+	require.NoError(t, err, "can't check locks")
+	require.Equal(t, []notifyutil.EventType{notifyutil.LockExpiring}, eventTypes(evs), "events mismatch")
+	require.Equal(t, notifyutil.OwnLock, evs[0].Data["lock"], "lock mismatch")
+	require.Equal(t, "6h0m0s", evs[0].Data["margin"], "margin mismatch")
+	// the own lock time is passed and the trader lock is within the margin
+	for _, i := range chains {
+		i.time = lockTime.Add(time.Minute)
+	}
+	evs, err = s.Check(eachTrade)
+	require.NoError(t, err, "can't check locks")
+	require.Equal(t, []notifyutil.EventType{notifyutil.RecoveryAvailable, notifyutil.LockExpiring}, eventTypes(evs), "events mismatch")
+	require.Equal(t, notifyutil.TraderLock, evs[1].Data["lock"], "lock mismatch")
+	require.Equal(t, cryptos.Litecoin.Name, evs[1].Crypto, "crypto mismatch")
+	evs, err = s.Check(eachTrade)
+	require.NoError(t, err, "can't check locks")
+	require.Empty(t, evs, "expecting no events")
+	// the recovery is retried until it's broadcast
+	s.cfg.RecoveryAddresses = map[string]string{cryptos.Bitcoin.Name: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"}
+	for i := 0; i < 2; i++ {
+		_, err = s.Check(eachTrade)
+		require.True(t, errors.Is(err, errNoKeys), "expecting a recovery error")
+		require.Contains(t, err.Error(), "trade1", "expecting the trade name")
+	}
+	require.False(t, trades["trade1"].finished, "expecting an unfinished trade")
+	// missing clients fail the trades
+	s.clients = map[string]*chainutil.ClientConfig{}
+	_, err = s.Check(eachTrade)
+	require.Error(t, err, "expecting an error")
+	// the finished trades aren't checked
+	trades["trade1"].Finish()
+	_, err = s.Check(eachTrade)
+	require.NoError(t, err, "expecting no checks")
+}
@@ -0,0 +1,52 @@
+package storeutil
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/transmutate-io/atomicswap/envelope"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+type identityFile struct {
+	Private types.Bytes `yaml:"private"`
+}
+
+// IdentityPath returns the path of the identity key
+func (s *Store) IdentityPath() string { return filepath.Join(s.Root, "identity") }
+
+// OpenIdentityKey opens the identity key, generating a new one if it doesn't
+// exist
+func (s *Store) OpenIdentityKey() (key.Private, error) {
+	return OpenIdentityKeyFile(s.IdentityPath())
+}
+
+// OpenIdentityKeyFile opens an identity key file, generating a new key if it
+// doesn't exist
+func OpenIdentityKeyFile(p string) (key.Private, error) {
+	idf := &identityFile{}
+	if err := openYAML(p, idf); err != nil {
+		if !isNotExist(err) {
+			return nil, err
+		}
+		k, err := envelope.NewIdentityKey()
+		if err != nil {
+			return nil, err
+		}
+		if err = os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err = yaml.NewEncoder(f).Encode(&identityFile{Private: k.Serialize()}); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+	return envelope.ParseIdentityKey(idf.Private)
+}
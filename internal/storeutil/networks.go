@@ -0,0 +1,202 @@
+package storeutil
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// NETWORKS_CONFIG_NAME is the name of the custom networks configuration file
+const NETWORKS_CONFIG_NAME = "networks.yaml"
+
+type (
+	// NetworksConfig represents custom cryptos and networks
+	NetworksConfig struct {
+		Cryptos  []*CryptoConfig  `yaml:"cryptos,omitempty"`
+		Networks []*NetworkConfig `yaml:"networks,omitempty"`
+	}
+
+	// CryptoConfig represents a bitcoin-like crypto
+	CryptoConfig struct {
+		Name     string `yaml:"name"`
+		Short    string `yaml:"short"`
+		Decimals int    `yaml:"decimals"`
+		// HashFamily is the implementation used ("btc" or "bch")
+		HashFamily string `yaml:"hash_family"`
+		// MessagePrefix is the magic prefix of signed messages
+		MessagePrefix string `yaml:"message_prefix,omitempty"`
+	}
+
+	// NetworkConfig represents a network. Using the name of an existing chain
+	// (mainnet, testnet, simnet or regnet) adds cryptos to that chain
+	NetworkConfig struct {
+		Name    string                  `yaml:"name"`
+		Cryptos map[string]*ChainConfig `yaml:"cryptos"`
+	}
+
+	// ChainConfig represents the parameters of a crypto in a network
+	ChainConfig struct {
+		GenesisHash      string      `yaml:"genesis_hash,omitempty"`
+		Magic            uint32      `yaml:"magic,omitempty"`
+		DefaultPort      uint16      `yaml:"default_port,omitempty"`
+		RPCPort          uint16      `yaml:"rpc_port,omitempty"`
+		PubKeyHashAddrID types.Bytes `yaml:"pubkey_hash_addr_id"`
+		ScriptHashAddrID types.Bytes `yaml:"script_hash_addr_id"`
+		PrivateKeyID     types.Bytes `yaml:"private_key_id"`
+		HDPrivateKeyID   types.Bytes `yaml:"hd_private_key_id,omitempty"`
+		HDPublicKeyID    types.Bytes `yaml:"hd_public_key_id,omitempty"`
+		Bech32HRP        string      `yaml:"bech32_hrp,omitempty"`
+		CashAddrPrefix   string      `yaml:"cashaddr_prefix,omitempty"`
+	}
+)
+
+// InvalidHashFamilyError is returned for an unknown hash family
+type InvalidHashFamilyError string
+
+func (e InvalidHashFamilyError) Error() string {
+	return fmt.Sprintf("invalid hash family: \"%s\"", string(e))
+}
+
+var (
+	hashFamilies = map[string]*cryptos.Crypto{
+		"btc": cryptos.Bitcoin,
+		"bch": cryptos.BitcoinCash,
+	}
+	newParamsFuncs = map[string]func(*params.Network) params.Params{
+		"btc": params.NewBTCLike,
+		"bch": params.NewBCHLike,
+	}
+	builtinFamilies = map[string]string{
+		cryptos.Bitcoin.Name:     "btc",
+		cryptos.Litecoin.Name:    "btc",
+		cryptos.Dogecoin.Name:    "btc",
+		cryptos.BitcoinCash.Name: "bch",
+	}
+)
+
+var (
+	errInvalidAddrID = errors.New("address and key ids must be a single byte")
+	errInvalidHDID   = errors.New("extended key ids must have 4 bytes")
+)
+
+// NetworksConfigPath returns the path of the custom networks configuration file
+func (s *Store) NetworksConfigPath() string {
+	return filepath.Join(s.ConfigDir(), NETWORKS_CONFIG_NAME)
+}
+
+// OpenNetworksConfig opens and validates the custom networks configuration. A
+// missing file is returned empty
+func (s *Store) OpenNetworksConfig() (*NetworksConfig, error) {
+	r := &NetworksConfig{}
+	if err := openYAML(s.NetworksConfigPath(), r); err != nil {
+		if isNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Validate checks the hash families and the network ids
+func (cfg *NetworksConfig) Validate() error {
+	for _, i := range cfg.Cryptos {
+		if _, err := i.Base(); err != nil {
+			return err
+		}
+	}
+	for _, i := range cfg.Networks {
+		for _, j := range i.Cryptos {
+			if _, err := j.network(i.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Families returns the hash family of the builtin and the custom cryptos
+func (cfg *NetworksConfig) Families() map[string]string {
+	r := make(map[string]string, len(builtinFamilies)+len(cfg.Cryptos))
+	for k, v := range builtinFamilies {
+		r[k] = v
+	}
+	for _, i := range cfg.Cryptos {
+		r[i.Name] = i.HashFamily
+	}
+	return r
+}
+
+// Crypto returns the crypto
+func (cc *CryptoConfig) Crypto() *cryptos.Crypto {
+	return &cryptos.Crypto{
+		Name:     cc.Name,
+		Short:    cc.Short,
+		Decimals: cc.Decimals,
+		Type:     cryptos.UTXO,
+	}
+}
+
+// Base returns the crypto implementing the hash family
+func (cc *CryptoConfig) Base() (*cryptos.Crypto, error) {
+	r, ok := hashFamilies[cc.HashFamily]
+	if !ok {
+		return nil, InvalidHashFamilyError(cc.HashFamily)
+	}
+	return r, nil
+}
+
+// Params returns the parameters of the network for a crypto of the hash family
+func (cc *ChainConfig) Params(name string, family string) (params.Params, error) {
+	newParams, ok := newParamsFuncs[family]
+	if !ok {
+		return nil, InvalidHashFamilyError(family)
+	}
+	n, err := cc.network(name)
+	if err != nil {
+		return nil, err
+	}
+	return newParams(n), nil
+}
+
+func (cc *ChainConfig) network(name string) (*params.Network, error) {
+	for _, i := range [][]byte{cc.PubKeyHashAddrID, cc.ScriptHashAddrID, cc.PrivateKeyID} {
+		if len(i) != 1 {
+			return nil, errInvalidAddrID
+		}
+	}
+	r := &params.Network{
+		Name:             name,
+		GenesisHash:      cc.GenesisHash,
+		Magic:            cc.Magic,
+		DefaultPort:      cc.DefaultPort,
+		RPCPort:          cc.RPCPort,
+		PubKeyHashAddrID: cc.PubKeyHashAddrID,
+		ScriptHashAddrID: cc.ScriptHashAddrID,
+		PrivateKeyID:     cc.PrivateKeyID,
+		Bech32HRP:        cc.Bech32HRP,
+		CashAddrPrefix:   cc.CashAddrPrefix,
+	}
+	for _, i := range []struct {
+		src types.Bytes
+		dst *[4]byte
+	}{
+		{cc.HDPrivateKeyID, &r.HDPrivateKeyID},
+		{cc.HDPublicKeyID, &r.HDPublicKeyID},
+	} {
+		if len(i.src) == 0 {
+			continue
+		}
+		if len(i.src) != 4 {
+			return nil, errInvalidHDID
+		}
+		copy(i.dst[:], i.src)
+	}
+	return r, nil
+}
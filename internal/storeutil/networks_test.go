@@ -0,0 +1,74 @@
+package storeutil
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/params"
+)
+
+const testNetworksConfig = `cryptos:
+- name: labcoin
+  short: LAB
+  decimals: 8
+  hash_family: btc
+networks:
+- name: labnet
+  cryptos:
+    labcoin:
+      pubkey_hash_addr_id: "30"
+      script_hash_addr_id: "32"
+      private_key_id: b0
+      hd_private_key_id: 04358394
+`
+
+func testOpenNetworksConfig(t *testing.T, data string) (*NetworksConfig, error) {
+	dd, err := ioutil.TempDir("", "storeutil")
+	require.NoError(t, err, "can't create data dir")
+	defer os.RemoveAll(dd)
+	st := New(dd)
+	require.NoError(t, os.MkdirAll(st.ConfigDir(), 0755), "can't create config dir")
+	require.NoError(t, ioutil.WriteFile(st.NetworksConfigPath(), []byte(data), 0644), "can't write config")
+	return st.OpenNetworksConfig()
+}
+
+func TestOpenNetworksConfig(t *testing.T) {
+	cfg, err := testOpenNetworksConfig(t, testNetworksConfig)
+	require.NoError(t, err, "can't open networks config")
+	require.Len(t, cfg.Cryptos, 1, "cryptos mismatch")
+	c := cfg.Cryptos[0].Crypto()
+	require.Equal(t, "labcoin", c.Name, "name mismatch")
+	require.Equal(t, "LAB", c.Short, "short name mismatch")
+	require.Equal(t, "btc", cfg.Families()["labcoin"], "family mismatch")
+	require.Equal(t, "bch", cfg.Families()["bitcoin-cash"], "family mismatch")
+	p, err := cfg.Networks[0].Cryptos["labcoin"].Params("labnet", "btc")
+	require.NoError(t, err, "can't get params")
+	require.IsType(t, params.NewBTCLike(&params.Network{}), p, "params type mismatch")
+	_, err = cfg.Networks[0].Cryptos["labcoin"].Params("labnet", "other")
+	require.Equal(t, InvalidHashFamilyError("other"), err, "expecting an invalid family")
+	// missing file
+	dd, err := ioutil.TempDir("", "storeutil")
+	require.NoError(t, err, "can't create data dir")
+	defer os.RemoveAll(dd)
+	cfg, err = New(dd).OpenNetworksConfig()
+	require.NoError(t, err, "can't open missing networks config")
+	require.Empty(t, cfg.Cryptos, "expecting no cryptos")
+	// invalid configurations
+	for _, i := range []struct {
+		data string
+		err  error
+	}{
+		{"cryptos:\n- name: labcoin\n  hash_family: other\n", InvalidHashFamilyError("other")},
+		{"networks:\n- name: labnet\n  cryptos:\n    labcoin:\n      pubkey_hash_addr_id: 3031\n", errInvalidAddrID},
+		{
+			"networks:\n- name: labnet\n  cryptos:\n    labcoin:\n      pubkey_hash_addr_id: \"30\"\n" +
+				"      script_hash_addr_id: \"32\"\n      private_key_id: b0\n      hd_public_key_id: \"01\"\n",
+			errInvalidHDID,
+		},
+	} {
+		_, err := testOpenNetworksConfig(t, i.data)
+		require.Equal(t, i.err, err, "error mismatch")
+	}
+}
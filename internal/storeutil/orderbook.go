@@ -0,0 +1,39 @@
+package storeutil
+
+import (
+	"path/filepath"
+
+	"github.com/transmutate-io/atomicswap/orderbook"
+	"github.com/transmutate-io/atomicswap/trade"
+)
+
+// OrderBookPath returns the path of the order book
+func (s *Store) OrderBookPath() string { return filepath.Join(s.Root, "orderbook") }
+
+// OpenOrderBook opens the order book. A missing order book is returned empty
+func (s *Store) OpenOrderBook() (*orderbook.Book, error) {
+	r := orderbook.New()
+	if err := openYAML(s.OrderBookPath(), r); err != nil {
+		if isNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// SaveOrderBook saves the order book
+func (s *Store) SaveOrderBook(b *orderbook.Book) error { return saveYAML(s.OrderBookPath(), b) }
+
+// Trades returns all the trades
+func (s *Store) Trades() ([]trade.Trade, error) {
+	r := make([]trade.Trade, 0, 16)
+	err := s.EachTrade(func(_ string, tr trade.Trade) error {
+		r = append(r, tr)
+		return nil
+	})
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,240 @@
+package storeutil
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/transmutate-io/atomicswap/internal/chainutil"
+	"github.com/transmutate-io/atomicswap/internal/cmdutil"
+	"github.com/transmutate-io/atomicswap/trade"
+	"gopkg.in/yaml.v2"
+)
+
+// DEFAULT_CONFIG_NAME is the name of the default clients configuration file
+const DEFAULT_CONFIG_NAME = "console_defaults.yaml"
+
+// Store represents a data directory
+type Store struct{ Root string }
+
+// New returns a new store for the data directory
+func New(root string) *Store { return &Store{Root: filepath.Clean(root)} }
+
+// TradesDir returns the trades directory
+func (s *Store) TradesDir() string { return filepath.Join(s.Root, "trades") }
+
+// ErrInvalidTradeName is returned for the names that aren't clean relative
+// paths inside the trades directory
+var ErrInvalidTradeName = errors.New("invalid trade name")
+
+// CheckTradeName fails if the name isn't a clean relative slash separated
+// path. The elements can't start with a dot
+func CheckTradeName(name string) error {
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(filepath.FromSlash(name)) || path.Clean(name) != name {
+		return ErrInvalidTradeName
+	}
+	for _, i := range strings.Split(name, "/") {
+		if strings.HasPrefix(i, ".") || strings.Contains(i, `\`) {
+			return ErrInvalidTradeName
+		}
+	}
+	return nil
+}
+
+// TradePath returns the path of a trade
+func (s *Store) TradePath(name string) string {
+	return filepath.Join(s.TradesDir(), filepath.FromSlash(name))
+}
+
+// WatchDataDir returns the watch data directory
+func (s *Store) WatchDataDir() string { return filepath.Join(s.Root, "watch_data") }
+
+// WatchDataPath returns the path of the watch data of a trade
+func (s *Store) WatchDataPath(name string) string {
+	return filepath.Join(s.WatchDataDir(), filepath.FromSlash(name))
+}
+
+// ConfigDir returns the configuration directory
+func (s *Store) ConfigDir() string { return filepath.Join(s.Root, "config") }
+
+// ConfigPath returns the path of a configuration file
+func (s *Store) ConfigPath(name string) string {
+	if name == "" {
+		name = DEFAULT_CONFIG_NAME
+	}
+	return filepath.Join(s.ConfigDir(), name)
+}
+
+// OpenTrade opens a trade by name
+func (s *Store) OpenTrade(name string) (trade.Trade, error) { return OpenTradeFile(s.TradePath(name)) }
+
+// SaveTrade saves a trade by name
+func (s *Store) SaveTrade(name string, tr trade.Trade) error {
+	return SaveTradeFile(s.TradePath(name), tr)
+}
+
+// CreateTrade saves a new trade by name. It fails with a TradeExistsError if
+// the trade exists
+func (s *Store) CreateTrade(name string, tr trade.Trade) error {
+	err := CreateTradeFile(s.TradePath(name), tr)
+	if _, ok := err.(TradeExistsError); ok {
+		return TradeExistsError(name)
+	}
+	return err
+}
+
+// EachTrade calls f for each trade
+func (s *Store) EachTrade(f func(string, trade.Trade) error) error {
+	return EachTrade(s.TradesDir(), f)
+}
+
+// OpenWatchData opens the watch data of a trade by name
+func (s *Store) OpenWatchData(name string) (*chainutil.WatchData, error) {
+	return OpenWatchDataFile(s.WatchDataPath(name))
+}
+
+// SaveWatchData saves the watch data of a trade by name
+func (s *Store) SaveWatchData(name string, wd *chainutil.WatchData) error {
+	return SaveWatchDataFile(s.WatchDataPath(name), wd)
+}
+
+// OpenClientsConfig opens a clients configuration file by name
+func (s *Store) OpenClientsConfig(name string) (map[string]*chainutil.ClientConfig, error) {
+	r := make(map[string]*chainutil.ClientConfig, 8)
+	if err := openYAML(s.ConfigPath(name), &r); err != nil {
+		if isNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// CreateFile creates a file and it's parent directories
+func CreateFile(p string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(p)
+}
+
+func isNotExist(err error) bool {
+	e, ok := err.(*os.PathError)
+	return ok && e.Err == syscall.ENOENT
+}
+
+func openYAML(p string, v interface{}) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return yaml.NewDecoder(f).Decode(v)
+}
+
+// writeTempYAML writes v to a hidden temporary file next to p
+func writeTempYAML(p string, v interface{}) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), "."+filepath.Base(p)+".")
+	if err != nil {
+		return "", err
+	}
+	if err = yaml.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func saveYAML(p string, v interface{}) error {
+	tmp, err := writeTempYAML(p, v)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// createYAML is like saveYAML but it fails if the file exists
+func createYAML(p string, v interface{}) error {
+	tmp, err := writeTempYAML(p, v)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Link(tmp, p)
+}
+
+// OpenTradeFile opens a trade file
+func OpenTradeFile(tp string) (trade.Trade, error) {
+	r := &trade.OnChainTrade{}
+	if err := openYAML(tp, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SaveTradeFile saves a trade file
+func SaveTradeFile(tp string, tr trade.Trade) error { return saveYAML(tp, tr) }
+
+// TradeExistsError is returned when creating a trade with a used name
+type TradeExistsError string
+
+func (e TradeExistsError) Error() string {
+	return fmt.Sprintf("trade exists: \"%s\"", string(e))
+}
+
+// CreateTradeFile saves a new trade file. It fails with a TradeExistsError
+// if the file exists
+func CreateTradeFile(tp string, tr trade.Trade) error {
+	err := createYAML(tp, tr)
+	if os.IsExist(err) {
+		return TradeExistsError(tp)
+	}
+	return err
+}
+
+// EachTrade calls f for each trade inside the trades directory
+func EachTrade(td string, f func(string, trade.Trade) error) error {
+	return filepath.Walk(td, func(path string, info os.FileInfo, err error) error {
+		if info == nil || info.IsDir() || filepath.Base(path)[0] == '.' {
+			return nil
+		}
+		tr, err := OpenTradeFile(path)
+		if err != nil {
+			return err
+		}
+		return f(filepath.ToSlash(cmdutil.TrimPath(path, td)), tr)
+	})
+}
+
+// OpenWatchDataFile opens a watch data file. If the file doesn't exist it
+// returns empty watch data
+func OpenWatchDataFile(wdPath string) (*chainutil.WatchData, error) {
+	r := chainutil.NewWatchData()
+	if err := openYAML(wdPath, r); err != nil {
+		if isNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// SaveWatchDataFile saves a watch data file
+func SaveWatchDataFile(wdPath string, wd *chainutil.WatchData) error { return saveYAML(wdPath, wd) }
@@ -0,0 +1,62 @@
+package storeutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+func TestCreateTradeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storeutil")
+	require.NoError(t, err, "can't create temp dir")
+	defer os.RemoveAll(dir)
+	tr, err := trade.NewOnChainTrade(types.Amount("1"), cryptos.Bitcoin, types.Amount("2"), cryptos.Litecoin, 48*time.Hour)
+	require.NoError(t, err, "can't create trade")
+	tp := filepath.Join(dir, "trades", "trade1")
+	require.NoError(t, CreateTradeFile(tp, tr), "can't create trade file")
+	_, err = OpenTradeFile(tp)
+	require.NoError(t, err, "can't open trade file")
+	// the existing trades aren't overwritten
+	require.Equal(t, TradeExistsError(tp), CreateTradeFile(tp, tr), "expecting an existing trade")
+	files, err := ioutil.ReadDir(filepath.Dir(tp))
+	require.NoError(t, err, "can't read dir")
+	require.Len(t, files, 1, "expecting no temporary files")
+}
+
+func TestCheckTradeName(t *testing.T) {
+	for _, i := range []string{"trade1", "offers/btc-ltc-0102", "a.b"} {
+		require.NoError(t, CheckTradeName(i), "expecting a valid name: %s", i)
+	}
+	for _, i := range []string{
+		"",
+		"/etc/passwd",
+		"../trade1",
+		"a/../../b",
+		"a/./b",
+		"a//b",
+		"a/",
+		".hidden",
+		"a/..",
+		`..\trade1`,
+	} {
+		require.Equal(t, ErrInvalidTradeName, CheckTradeName(i), "expecting an invalid name: %s", i)
+	}
+}
+
+func TestStoreCreateTrade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storeutil")
+	require.NoError(t, err, "can't create temp dir")
+	defer os.RemoveAll(dir)
+	tr, err := trade.NewOnChainTrade(types.Amount("1"), cryptos.Bitcoin, types.Amount("2"), cryptos.Litecoin, 48*time.Hour)
+	require.NoError(t, err, "can't create trade")
+	s := New(dir)
+	require.NoError(t, s.CreateTrade("trade1", tr), "can't create trade")
+	require.Equal(t, TradeExistsError("trade1"), s.CreateTrade("trade1", tr), "expecting an existing trade")
+}
@@ -187,6 +187,13 @@ func newClientConfigMenu(name string, parent *MenuCompleter) *MenuCompleter {
 				Description: "configure the password",
 			},
 		},
+		&MenuCompleter{
+			Parent: r,
+			Suggestion: &prompt.Suggest{
+				Text:        "backend",
+				Description: "configure the backend url (electrum://host:port, esploras://host/path)",
+			},
+		},
 		newClientTLSConfigMenu(r),
 		&MenuCompleter{
 			Parent: r,
@@ -0,0 +1,133 @@
+package key
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/hash"
+)
+
+// ErrInvalidSignature is returned when a message signature doesn't match the key
+var ErrInvalidSignature = errors.New("invalid message signature")
+
+// messageFormat describes how messages are signed for a crypto
+type messageFormat struct {
+	// prefix is the magic prefix of signed messages
+	prefix string
+	// hash is the message hash
+	hash func([]byte) []byte
+}
+
+var messageFormats = map[string]*messageFormat{
+	cryptos.Bitcoin.Name:     &messageFormat{"Bitcoin Signed Message:\n", hash.NewBTC().Hash256},
+	cryptos.BitcoinCash.Name: &messageFormat{"Bitcoin Signed Message:\n", hash.NewBCH().Hash256},
+	cryptos.Litecoin.Name:    &messageFormat{"Litecoin Signed Message:\n", hash.NewLTC().Hash256},
+	cryptos.Dogecoin.Name:    &messageFormat{"Dogecoin Signed Message:\n", hash.NewDOGE().Hash256},
+	cryptos.Decred.Name:      &messageFormat{"Decred Signed Message:\n", hash.Blake256Sum},
+}
+
+// SetMessagePrefix sets the magic prefix of signed messages for a crypto
+func SetMessagePrefix(c *cryptos.Crypto, prefix string) error {
+	mf, err := getMessageFormat(c)
+	if err != nil {
+		return err
+	}
+	messageFormats[c.Name] = &messageFormat{prefix: prefix, hash: mf.hash}
+	return nil
+}
+
+func getMessageFormat(c *cryptos.Crypto) (*messageFormat, error) {
+	r, ok := messageFormats[c.Name]
+	if !ok {
+		return nil, cryptos.InvalidCryptoError(c.Name)
+	}
+	return r, nil
+}
+
+func writeVarString(b *bytes.Buffer, s string) {
+	var n [binary.MaxVarintLen64]byte
+	l := uint64(len(s))
+	switch {
+	case l < 0xfd:
+		b.WriteByte(byte(l))
+	case l <= 0xffff:
+		b.WriteByte(0xfd)
+		binary.LittleEndian.PutUint16(n[:], uint16(l))
+		b.Write(n[:2])
+	case l <= 0xffffffff:
+		b.WriteByte(0xfe)
+		binary.LittleEndian.PutUint32(n[:], uint32(l))
+		b.Write(n[:4])
+	default:
+		b.WriteByte(0xff)
+		binary.LittleEndian.PutUint64(n[:], l)
+		b.Write(n[:8])
+	}
+	b.WriteString(s)
+}
+
+func (mf *messageFormat) messageHash(msg string) []byte {
+	b := &bytes.Buffer{}
+	writeVarString(b, mf.prefix)
+	writeVarString(b, msg)
+	return mf.hash(b.Bytes())
+}
+
+// MessageHash returns the hash of a message with the magic prefix of the crypto
+func MessageHash(c *cryptos.Crypto, msg string) ([]byte, error) {
+	mf, err := getMessageFormat(c)
+	if err != nil {
+		return nil, err
+	}
+	return mf.messageHash(msg), nil
+}
+
+// SignMessage signs a message returning a base64 encoded compact recoverable signature
+func SignMessage(c *cryptos.Crypto, k Private, msg string) (string, error) {
+	h, err := MessageHash(c, msg)
+	if err != nil {
+		return "", err
+	}
+	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), k.Serialize())
+	sig, err := btcec.SignCompact(btcec.S256(), priv, h, true)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// RecoverMessageKey returns the public key that signed a message
+func RecoverMessageKey(c *cryptos.Crypto, sig string, msg string) (Public, error) {
+	h, err := MessageHash(c, msg)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, err
+	}
+	pub, compressed, err := btcec.RecoverCompact(btcec.S256(), b, h)
+	if err != nil {
+		return nil, err
+	}
+	if !compressed {
+		return nil, ErrInvalidSignature
+	}
+	return ParsePublic(c, pub.SerializeCompressed())
+}
+
+// VerifyMessage verifies a signed message against the key data
+func VerifyMessage(c *cryptos.Crypto, kd KeyData, sig string, msg string) error {
+	pub, err := RecoverMessageKey(c, sig, msg)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(pub.KeyData(), kd) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
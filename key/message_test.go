@@ -0,0 +1,49 @@
+package key
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	dcrhash "github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+)
+
+func TestMessageHash(t *testing.T) {
+	msg := "hello world"
+	b := &bytes.Buffer{}
+	require.NoError(t, wire.WriteVarString(b, 0, "Bitcoin Signed Message:\n"), "can't write prefix")
+	require.NoError(t, wire.WriteVarString(b, 0, msg), "can't write message")
+	h, err := MessageHash(cryptos.Bitcoin, msg)
+	require.NoError(t, err, "can't hash message")
+	require.Equal(t, chainhash.DoubleHashB(b.Bytes()), h, "bitcoin message hash mismatch")
+	b.Reset()
+	require.NoError(t, wire.WriteVarString(b, 0, "Decred Signed Message:\n"), "can't write prefix")
+	require.NoError(t, wire.WriteVarString(b, 0, msg), "can't write message")
+	h, err = MessageHash(cryptos.Decred, msg)
+	require.NoError(t, err, "can't hash message")
+	require.Equal(t, dcrhash.HashB(b.Bytes()), h, "decred message hash mismatch")
+}
+
+func TestSignMessage(t *testing.T) {
+	for _, name := range testCryptos {
+		t.Run(name, func(t *testing.T) {
+			c, err := cryptos.Parse(name)
+			require.NoError(t, err, "can't parse crypto")
+			k, err := NewPrivate(c)
+			require.NoError(t, err, "can't create private key")
+			msg := string(bytes.Repeat([]byte("a"), 300))
+			sig, err := SignMessage(c, k, msg)
+			require.NoError(t, err, "can't sign message")
+			require.NoError(t, VerifyMessage(c, k.Public().KeyData(), sig, msg), "can't verify message")
+			err = VerifyMessage(c, k.Public().KeyData(), sig, msg+"b")
+			require.Equal(t, ErrInvalidSignature, err, "expecting invalid signature")
+			k2, err := NewPrivate(c)
+			require.NoError(t, err, "can't create private key")
+			err = VerifyMessage(c, k2.Public().KeyData(), sig, msg)
+			require.Equal(t, ErrInvalidSignature, err, "expecting invalid signature")
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package key
+
+import "github.com/transmutate-io/atomicswap/cryptos"
+
+// RegisterLike registers the keys of base for the crypto c
+func RegisterLike(c *cryptos.Crypto, base *cryptos.Crypto) error {
+	cf, err := getCryptoFuncs(base)
+	if err != nil {
+		return err
+	}
+	cryptoFuncs[c.Name] = *cf
+	if mf, ok := messageFormats[base.Name]; ok {
+		messageFormats[c.Name] = mf
+	}
+	return nil
+}
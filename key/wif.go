@@ -0,0 +1,143 @@
+package key
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/params"
+)
+
+var (
+	// ErrInvalidEncoding is returned when an encoded key is malformed
+	ErrInvalidEncoding = errors.New("invalid key encoding")
+
+	// ErrInvalidChecksum is returned when the checksum of an encoded key doesn't match
+	ErrInvalidChecksum = errors.New("invalid key checksum")
+
+	// ErrWrongNetwork is returned when an encoded key belongs to another network
+	ErrWrongNetwork = errors.New("key is for another network")
+)
+
+type checksumFunc = func([]byte) []byte
+
+// wifFormat describes the encoding of private keys of a crypto
+type wifFormat struct {
+	// wifChecksum is the checksum of a wif key
+	wifChecksum checksumFunc
+	// extendedChecksum is the checksum of an extended key
+	extendedChecksum checksumFunc
+	// prefix is added between the version and the key
+	prefix []byte
+	// suffix is added after the key
+	suffix []byte
+}
+
+var (
+	// bitcoin-like wif with the compressed public key flag
+	wifBTC = &wifFormat{
+		wifChecksum:      hash.NewBTC().Hash256,
+		extendedChecksum: hash.NewBTC().Hash256,
+		suffix:           []byte{0x01},
+	}
+
+	wifFormats = map[string]*wifFormat{
+		// decred wif with the secp256k1 signature type
+		cryptos.Decred.Name: &wifFormat{
+			wifChecksum:      hash.Blake256Sum,
+			extendedChecksum: hash.NewDCR().Hash256,
+			prefix:           []byte{0x00},
+		},
+	}
+)
+
+func getWIFFormat(c *cryptos.Crypto) *wifFormat {
+	if r, ok := wifFormats[c.Name]; ok {
+		return r
+	}
+	return wifBTC
+}
+
+func encodeBase58Check(b []byte, checksum checksumFunc) string {
+	r := make([]byte, 0, len(b)+4)
+	r = append(r, b...)
+	return base58.Encode(append(r, checksum(b)[:4]...))
+}
+
+func decodeBase58Check(s string, checksum checksumFunc) ([]byte, error) {
+	b := base58.Decode(s)
+	if len(b) < 5 {
+		return nil, ErrInvalidEncoding
+	}
+	r, sum := b[:len(b)-4], b[len(b)-4:]
+	if !bytes.Equal(checksum(r)[:4], sum) {
+		return nil, ErrInvalidChecksum
+	}
+	return r, nil
+}
+
+// EncodeWIF encodes a private key in the wallet import format of the network
+func EncodeWIF(c *cryptos.Crypto, k Private, n *params.Network) string {
+	f := getWIFFormat(c)
+	b := make([]byte, 0, len(n.PrivateKeyID)+len(f.prefix)+32+len(f.suffix))
+	b = append(b, n.PrivateKeyID...)
+	b = append(b, f.prefix...)
+	b = append(b, k.Serialize()...)
+	return encodeBase58Check(append(b, f.suffix...), f.wifChecksum)
+}
+
+// DecodeWIF decodes a private key in the wallet import format of the network
+func DecodeWIF(c *cryptos.Crypto, wif string, n *params.Network) (Private, error) {
+	f := getWIFFormat(c)
+	b, err := decodeBase58Check(wif, f.wifChecksum)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(b, n.PrivateKeyID) {
+		return nil, ErrWrongNetwork
+	}
+	b = b[len(n.PrivateKeyID):]
+	if !bytes.HasPrefix(b, f.prefix) {
+		return nil, ErrInvalidEncoding
+	}
+	b = b[len(f.prefix):]
+	// accept keys without the suffix (uncompressed bitcoin keys)
+	if len(b) == 32+len(f.suffix) && bytes.HasSuffix(b, f.suffix) {
+		b = b[:32]
+	}
+	if len(b) != 32 {
+		return nil, ErrInvalidEncoding
+	}
+	return ParsePrivate(c, b)
+}
+
+const extendedKeySize = 78
+
+// EncodeExtended encodes a private key as a BIP32 extended private key of the
+// network. The key is encoded as a master key (depth 0) with a zero chain code,
+// suitable to import the key itself and not to derive child keys
+func EncodeExtended(c *cryptos.Crypto, k Private, n *params.Network) string {
+	b := make([]byte, extendedKeySize)
+	copy(b, n.HDPrivateKeyID[:])
+	// depth, parent fingerprint, child number and chain code are left zeroed
+	copy(b[46:], k.Serialize())
+	return encodeBase58Check(b, getWIFFormat(c).extendedChecksum)
+}
+
+// DecodeExtended decodes the private key of a BIP32 extended private key of
+// the network
+func DecodeExtended(c *cryptos.Crypto, xprv string, n *params.Network) (Private, error) {
+	b, err := decodeBase58Check(xprv, getWIFFormat(c).extendedChecksum)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != extendedKeySize || b[45] != 0 {
+		return nil, ErrInvalidEncoding
+	}
+	if !bytes.Equal(b[:4], n.HDPrivateKeyID[:]) {
+		return nil, ErrWrongNetwork
+	}
+	return ParsePrivate(c, b[46:])
+}
@@ -0,0 +1,73 @@
+package key
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	btccfg "github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	dcrcfg "github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/dcrec"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/networks"
+	"github.com/transmutate-io/atomicswap/params"
+)
+
+func TestWIF(t *testing.T) {
+	for _, name := range testCryptos {
+		t.Run(name, func(t *testing.T) {
+			c, err := cryptos.Parse(name)
+			require.NoError(t, err, "can't parse crypto")
+			k, err := NewPrivate(c)
+			require.NoError(t, err, "can't create private key")
+			for chain, p := range networks.AllByName[c.Name] {
+				n := p.Network()
+				// wif
+				wif := EncodeWIF(c, k, n)
+				k2, err := DecodeWIF(c, wif, n)
+				require.NoError(t, err, "can't decode wif (%s)", chain)
+				require.Equal(t, k.Serialize(), k2.Serialize(), "key mismatch (%s)", chain)
+				// extended
+				xprv := EncodeExtended(c, k, n)
+				k2, err = DecodeExtended(c, xprv, n)
+				require.NoError(t, err, "can't decode extended key (%s)", chain)
+				require.Equal(t, k.Serialize(), k2.Serialize(), "key mismatch (%s)", chain)
+			}
+			// wrong network
+			wif := EncodeWIF(c, k, networks.AllByName[c.Name][params.MainNet].Network())
+			_, err = DecodeWIF(c, wif, networks.AllByName[c.Name][params.TestNet].Network())
+			require.Equal(t, ErrWrongNetwork, err, "expecting wrong network")
+			// bad checksum
+			_, err = DecodeWIF(c, wif[:len(wif)-1]+"1", networks.AllByName[c.Name][params.MainNet].Network())
+			require.Error(t, err, "expecting bad checksum")
+		})
+	}
+}
+
+func TestWIFCompatibility(t *testing.T) {
+	// bitcoin
+	k, err := NewPrivate(cryptos.Bitcoin)
+	require.NoError(t, err, "can't create private key")
+	n := params.BTC_MainNet.Network()
+	wif, err := btcutil.NewWIF(k.Key().(*btcec.PrivateKey), &btccfg.MainNetParams, true)
+	require.NoError(t, err, "can't create wif")
+	require.Equal(t, wif.String(), EncodeWIF(cryptos.Bitcoin, k, n), "wif mismatch")
+	xprv := hdkeychain.NewExtendedKey(
+		btccfg.MainNetParams.HDPrivateKeyID[:],
+		k.Serialize(),
+		make([]byte, 32),
+		[]byte{0, 0, 0, 0},
+		0, 0, true,
+	)
+	require.Equal(t, xprv.String(), EncodeExtended(cryptos.Bitcoin, k, n), "extended key mismatch")
+	// decred
+	k, err = NewPrivate(cryptos.Decred)
+	require.NoError(t, err, "can't create private key")
+	dwif, err := dcrutil.NewWIF(k.Key().(chainec.PrivateKey), &dcrcfg.MainNetParams, dcrec.STEcdsaSecp256k1)
+	require.NoError(t, err, "can't create wif")
+	require.Equal(t, dwif.String(), EncodeWIF(cryptos.Decred, k, params.DCR_MainNet.Network()), "wif mismatch")
+}
@@ -0,0 +1,246 @@
+package negotiation
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/flynn/noise"
+	"github.com/transmutate-io/atomicswap/envelope"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+// maxFrameSize is the maximum size of a noise message
+const maxFrameSize = 65535
+
+// DefaultTimeout is the default deadline of the handshake and of each frame
+const DefaultTimeout = 5 * time.Minute
+
+var (
+	cipherSuite = noise.NewCipherSuite(noise.DH25519, noise.CipherChaChaPoly, noise.HashBLAKE2b)
+	prologue    = []byte("atomicswap-negotiation")
+
+	// ErrFrameTooBig is returned when a message doesn't fit in a frame
+	ErrFrameTooBig = errors.New("frame too big")
+	// ErrUnexpectedPeer is returned when the remote identity key doesn't match
+	// the expected one
+	ErrUnexpectedPeer = errors.New("unexpected peer key")
+	// ErrInvalidIdentityProof is returned when the remote static key isn't
+	// signed by the remote identity key
+	ErrInvalidIdentityProof = errors.New("invalid identity proof")
+	// ErrMissingIdentity is returned when the identity key is missing
+	ErrMissingIdentity = errors.New("missing identity key")
+)
+
+// Config is the configuration of a connection
+type Config struct {
+	// Identity is the identity key signing the static key and the messages
+	Identity key.Private
+	// PeerIdentity is the expected remote identity public key (if set)
+	PeerIdentity []byte
+	// Timeout is the deadline of the handshake and of each frame (defaults to
+	// DefaultTimeout)
+	Timeout time.Duration
+}
+
+// identityProof binds a noise static key to an identity key
+type identityProof struct {
+	StaticKey types.Bytes `yaml:"static_key"`
+}
+
+// Conn is an authenticated and encrypted connection
+type Conn struct {
+	conn           net.Conn
+	identity       key.Private
+	remoteIdentity []byte
+	timeout        time.Duration
+	sendMtx        sync.Mutex
+	send           *noise.CipherState
+	recvMtx        sync.Mutex
+	recv           *noise.CipherState
+}
+
+// Client performs the handshake as the initiator. If the peer identity is set
+// the remote identity key must match it
+func Client(c net.Conn, cfg *Config) (*Conn, error) { return handshake(c, cfg, true) }
+
+// Server performs the handshake as the responder. If the peer identity is set
+// the remote identity key must match it
+func Server(c net.Conn, cfg *Config) (*Conn, error) { return handshake(c, cfg, false) }
+
+// newIdentityProof returns the static key signed by the identity key
+func newIdentityProof(id key.Private, staticKey []byte) ([]byte, error) {
+	env, err := envelope.New(envelope.TypeIdentity, nil, time.Time{}, &identityProof{StaticKey: staticKey})
+	if err != nil {
+		return nil, err
+	}
+	if err = env.Sign(id); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(env)
+}
+
+// openIdentityProof checks the remote static key is signed by the remote
+// identity and returns the identity public key
+func openIdentityProof(b []byte, staticKey []byte, peerIdentity []byte) ([]byte, error) {
+	env, err := envelope.Open(b, envelope.TypeIdentity, &envelope.Options{Signer: peerIdentity})
+	if err != nil {
+		if err == envelope.ErrSignerMismatch {
+			return nil, ErrUnexpectedPeer
+		}
+		return nil, err
+	}
+	p := &identityProof{}
+	if err = yaml.Unmarshal([]byte(env.Payload), p); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p.StaticKey, staticKey) {
+		return nil, ErrInvalidIdentityProof
+	}
+	return env.Signer, nil
+}
+
+func handshake(c net.Conn, cfg *Config, initiator bool) (*Conn, error) {
+	if cfg.Identity == nil {
+		return nil, ErrMissingIdentity
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	// a new static key for each connection, signed by the identity key
+	staticKey, err := cipherSuite.GenerateKeypair(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := newIdentityProof(cfg.Identity, staticKey.Public)
+	if err != nil {
+		return nil, err
+	}
+	hs, err := noise.NewHandshakeState(noise.Config{
+		CipherSuite:   cipherSuite,
+		Random:        rand.Reader,
+		Pattern:       noise.HandshakeXX,
+		Initiator:     initiator,
+		Prologue:      prologue,
+		StaticKeypair: staticKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		cs1, cs2    *noise.CipherState
+		remoteProof []byte
+		write       = initiator
+	)
+	// XX has three messages: -> e, <- e ee s es, -> s se. The identity proofs
+	// are sent encrypted with the static keys in the last two
+	for i := 0; i < 3; i++ {
+		if write {
+			var payload, msg []byte
+			if i > 0 {
+				payload = proof
+			}
+			if msg, cs1, cs2, err = hs.WriteMessage(nil, payload); err != nil {
+				return nil, err
+			}
+			if err = writeFrame(c, msg); err != nil {
+				return nil, err
+			}
+		} else {
+			msg, err := readFrame(c)
+			if err != nil {
+				return nil, err
+			}
+			if remoteProof, cs1, cs2, err = hs.ReadMessage(nil, msg); err != nil {
+				return nil, err
+			}
+		}
+		write = !write
+	}
+	remoteIdentity, err := openIdentityProof(remoteProof, hs.PeerStatic(), cfg.PeerIdentity)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	r := &Conn{
+		conn:           c,
+		identity:       cfg.Identity,
+		remoteIdentity: remoteIdentity,
+		timeout:        timeout,
+	}
+	if initiator {
+		r.send, r.recv = cs1, cs2
+	} else {
+		r.send, r.recv = cs2, cs1
+	}
+	return r, nil
+}
+
+func writeFrame(w io.Writer, b []byte) error {
+	if len(b) > maxFrameSize {
+		return ErrFrameTooBig
+	}
+	hdr := make([]byte, 2, 2+len(b))
+	binary.BigEndian.PutUint16(hdr, uint16(len(b)))
+	_, err := w.Write(append(hdr, b...))
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	b := make([]byte, binary.BigEndian.Uint16(hdr))
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// RemoteIdentity returns the remote identity public key
+func (c *Conn) RemoteIdentity() []byte { return c.remoteIdentity }
+
+// WriteFrame encrypts and sends a frame
+func (c *Conn) WriteFrame(b []byte) error {
+	c.sendMtx.Lock()
+	defer c.sendMtx.Unlock()
+	ct, err := c.send.Encrypt(nil, nil, b)
+	if err != nil {
+		return err
+	}
+	if err = c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	return writeFrame(c.conn, ct)
+}
+
+// ReadFrame receives and decrypts a frame
+func (c *Conn) ReadFrame() ([]byte, error) {
+	c.recvMtx.Lock()
+	defer c.recvMtx.Unlock()
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	ct, err := readFrame(c.conn)
+	if err != nil {
+		return nil, err
+	}
+	return c.recv.Decrypt(nil, nil, ct)
+}
+
+// Close closes the connection
+func (c *Conn) Close() error { return c.conn.Close() }
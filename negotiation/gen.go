@@ -0,0 +1,3 @@
+package negotiation
+
+//go:generate go run ../cmd/tpl_gen/main.go gen.yaml
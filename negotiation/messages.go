@@ -0,0 +1,106 @@
+package negotiation
+
+import (
+	"fmt"
+
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+// ProtocolVersion is the current negotiation protocol version
+const ProtocolVersion = 2
+
+// Message represents a negotiation message
+type Message struct {
+	Version uint32      `yaml:"version"`
+	Type    MessageType `yaml:"type"`
+	Payload types.Bytes `yaml:"payload,omitempty"`
+	Reason  string      `yaml:"reason,omitempty"`
+}
+
+// UnsupportedVersionError is returned when receiving a message with an unknown
+// protocol version
+type UnsupportedVersionError uint32
+
+func (e UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported protocol version: %d", uint32(e))
+}
+
+// RejectedError is returned when the remote peer rejects the negotiation
+type RejectedError string
+
+func (e RejectedError) Error() string { return fmt.Sprintf("rejected: %s", string(e)) }
+
+// CanceledError is returned when the remote peer cancels the negotiation
+type CanceledError string
+
+func (e CanceledError) Error() string { return fmt.Sprintf("canceled: %s", string(e)) }
+
+// UnexpectedMessageError is returned when receiving an unexpected message
+type UnexpectedMessageError MessageType
+
+func (e UnexpectedMessageError) Error() string {
+	return fmt.Sprintf("unexpected message: %s", MessageType(e).String())
+}
+
+func newMessage(t MessageType, payload []byte) *Message {
+	return &Message{Version: ProtocolVersion, Type: t, Payload: payload}
+}
+
+// Send sends a message
+func (c *Conn) Send(m *Message) error {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.WriteFrame(b)
+}
+
+// Receive receives a message
+func (c *Conn) Receive() (*Message, error) {
+	b, err := c.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	r := &Message{}
+	if err = yaml.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	if r.Version != ProtocolVersion {
+		return nil, UnsupportedVersionError(r.Version)
+	}
+	return r, nil
+}
+
+// Reject sends a reject message
+func (c *Conn) Reject(reason string) error {
+	m := newMessage(MsgReject, nil)
+	m.Reason = reason
+	return c.Send(m)
+}
+
+// Cancel sends a cancel message
+func (c *Conn) Cancel(reason string) error {
+	m := newMessage(MsgCancel, nil)
+	m.Reason = reason
+	return c.Send(m)
+}
+
+// receiveExpected receives a message of the expected type, converting reject
+// and cancel messages into errors
+func (c *Conn) receiveExpected(t MessageType) (*Message, error) {
+	m, err := c.Receive()
+	if err != nil {
+		return nil, err
+	}
+	switch m.Type {
+	case t:
+		return m, nil
+	case MsgReject:
+		return nil, RejectedError(m.Reason)
+	case MsgCancel:
+		return nil, CanceledError(m.Reason)
+	default:
+		return nil, UnexpectedMessageError(m.Type)
+	}
+}
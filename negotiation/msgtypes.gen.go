@@ -0,0 +1,70 @@
+package negotiation
+
+import "fmt"
+
+type InvalidMessageTypeError string
+
+func (e InvalidMessageTypeError) Error() string {
+	return fmt.Sprintf("invalid message type: \"%s\"", string(e))
+}
+
+type MessageType int
+
+func ParseMessageType(s string) (MessageType, error) {
+	var r MessageType
+	if err := (&r).Set(s); err != nil {
+		return 0, err
+	}
+	return r, nil
+}
+
+func (v MessageType) String() string { return _MessageType[v] }
+
+func (v *MessageType) Set(sv string) error {
+	nv, ok := _MessageTypeNames[sv]
+	if !ok {
+		return InvalidMessageTypeError(sv)
+	}
+	*v = nv
+	return nil
+}
+
+func (v MessageType) MarshalYAML() (interface{}, error) { return v.String(), nil }
+
+func (v *MessageType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var r string
+	if err := unmarshal(&r); err != nil {
+		return err
+	}
+	return v.Set(r)
+}
+
+const (
+	MsgProposal MessageType = iota
+	MsgAccept
+	MsgReject
+	MsgCancel
+	MsgTokenReveal
+	MsgOffers
+	MsgQuoteRequest
+)
+
+var (
+	_MessageType = map[MessageType]string{
+		MsgProposal:     "proposal",
+		MsgAccept:       "accept",
+		MsgReject:       "reject",
+		MsgCancel:       "cancel",
+		MsgTokenReveal:  "token-reveal",
+		MsgOffers:       "offers",
+		MsgQuoteRequest: "quote-request",
+	}
+	_MessageTypeNames map[string]MessageType
+)
+
+func init() {
+	_MessageTypeNames = make(map[string]MessageType, len(_MessageType))
+	for k, v := range _MessageType {
+		_MessageTypeNames[v] = k
+	}
+}
@@ -0,0 +1,176 @@
+package negotiation
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/envelope"
+	"github.com/transmutate-io/atomicswap/trade"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrTokenMismatch is returned when a revealed token doesn't match the trade
+// token hash
+var ErrTokenMismatch = errors.New("token mismatch")
+
+// ProposeTrade sends the buy proposal for a buyer trade and sets the locks
+// received from the seller
+func ProposeTrade(c *Conn, tr trade.Trade) error {
+	btr, err := tr.Buyer()
+	if err != nil {
+		return err
+	}
+	prop, err := btr.GenerateBuyProposal()
+	if err != nil {
+		return err
+	}
+	return sendProposal(c, tr, btr, prop)
+}
+
+// sendProposal sends a signed buy proposal and sets the locks received from
+// the seller. The trade is bound to the session and to the seller identity
+func sendProposal(c *Conn, tr trade.Trade, btr trade.BuyerTrade, prop *trade.BuyProposal) error {
+	if len(tr.SessionID()) == 0 {
+		sid, err := envelope.NewSessionID()
+		if err != nil {
+			return err
+		}
+		tr.SetSessionID(sid)
+	}
+	b, err := c.seal(envelope.TypeProposal, tr.SessionID(), prop)
+	if err != nil {
+		return err
+	}
+	if err = c.Send(newMessage(MsgProposal, b)); err != nil {
+		return err
+	}
+	m, err := c.receiveExpected(MsgAccept)
+	if err != nil {
+		return err
+	}
+	env, err := c.open(m.Payload, envelope.TypeLockSet, tr.SessionID(), tr.PeerSigner())
+	if err != nil {
+		c.Cancel(err.Error())
+		return err
+	}
+	locks, err := trade.UnamrshalLocks(tr.OwnInfo().Crypto, tr.TraderInfo().Crypto, []byte(env.Payload))
+	if err != nil {
+		c.Cancel(err.Error())
+		return err
+	}
+	if err = btr.SetLocks(locks); err != nil {
+		return err
+	}
+	tr.SetPeerSigner(env.Signer)
+	return nil
+}
+
+// AnswerProposal waits for a signed buy proposal and calls decide with it.
+// Expired proposals are rejected. If decide returns an error the proposal is
+// rejected, otherwise a new seller trade bound to the session and to the buyer
+// identity is created and it's locks are sent to the buyer
+func AnswerProposal(c *Conn, decide func(*trade.BuyProposal) error) (trade.Trade, error) {
+	m, err := c.receiveExpected(MsgProposal)
+	if err != nil {
+		return nil, err
+	}
+	env, err := c.open(m.Payload, envelope.TypeProposal, nil, nil)
+	if err != nil {
+		c.Reject(err.Error())
+		return nil, err
+	}
+	prop, err := trade.UnamrshalBuyProposal([]byte(env.Payload))
+	if err != nil {
+		c.Reject(err.Error())
+		return nil, err
+	}
+	if prop.Expired(time.Now()) {
+		c.Reject(trade.ErrProposalExpired.Error())
+		return nil, trade.ErrProposalExpired
+	}
+	if decide != nil {
+		if err = decide(prop); err != nil {
+			c.Reject(err.Error())
+			return nil, err
+		}
+	}
+	tr, err := trade.AcceptProposal(prop)
+	if err != nil {
+		c.Reject(err.Error())
+		return nil, err
+	}
+	tr.SetSessionID(env.SessionID)
+	tr.SetPeerSigner(env.Signer)
+	str, err := tr.Seller()
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.seal(envelope.TypeLockSet, env.SessionID, str.Locks())
+	if err != nil {
+		return nil, err
+	}
+	if err = c.Send(newMessage(MsgAccept, b)); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// RevealToken sends the secret token of the trade. The token gives the trader
+// access to the own funds, it must only be sent after the redeem of the trader
+// funds is broadcast
+func RevealToken(c *Conn, tr trade.Trade) error {
+	if err := c.checkPeer(tr); err != nil {
+		return err
+	}
+	if len(tr.Token()) == 0 {
+		return errors.New("missing token")
+	}
+	return c.Send(newMessage(MsgTokenReveal, tr.Token()))
+}
+
+// ReceiveToken waits for the secret token and sets it in the trade
+func ReceiveToken(c *Conn, tr trade.Trade) error {
+	if err := c.checkPeer(tr); err != nil {
+		return err
+	}
+	m, err := c.receiveExpected(MsgTokenReveal)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(trade.TokenHash(m.Payload), tr.TokenHash()) {
+		return ErrTokenMismatch
+	}
+	tr.SetToken(m.Payload)
+	return nil
+}
+
+// seal returns a message signed with the identity key
+func (c *Conn) seal(typ string, sessionID []byte, payload interface{}) ([]byte, error) {
+	env, err := envelope.New(typ, sessionID, time.Now().Add(trade.ProposalExpiry), payload)
+	if err != nil {
+		return nil, err
+	}
+	if err = env.Sign(c.identity); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(env)
+}
+
+// open opens a message signed by the remote identity. The signer must also
+// match the trade peer signer when it's known
+func (c *Conn) open(b []byte, typ string, sessionID []byte, peerSigner []byte) (*envelope.Envelope, error) {
+	signer, err := envelope.PeerSigner(c.remoteIdentity, peerSigner)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Open(b, typ, &envelope.Options{SessionID: sessionID, Signer: signer})
+}
+
+// checkPeer fails if the remote identity isn't the trade peer signer
+func (c *Conn) checkPeer(tr trade.Trade) error {
+	if len(tr.PeerSigner()) > 0 && !bytes.Equal(tr.PeerSigner(), c.remoteIdentity) {
+		return ErrUnexpectedPeer
+	}
+	return nil
+}
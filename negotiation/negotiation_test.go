@@ -0,0 +1,326 @@
+package negotiation
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/duration"
+	"github.com/transmutate-io/atomicswap/envelope"
+	"github.com/transmutate-io/atomicswap/orderbook"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+type peerResult struct {
+	tr  trade.Trade
+	err error
+}
+
+func newPeers(t *testing.T) (*Conn, *Conn) {
+	return newPeersTimeout(t, 0)
+}
+
+func newPeersTimeout(t *testing.T, timeout time.Duration) (*Conn, *Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen")
+	defer l.Close()
+	buyerKey, err := envelope.NewIdentityKey()
+	require.NoError(t, err, "can't generate key")
+	sellerKey, err := envelope.NewIdentityKey()
+	require.NoError(t, err, "can't generate key")
+	buyerPub := buyerKey.Public().SerializeCompressed()
+	sellerPub := sellerKey.Public().SerializeCompressed()
+	connc := make(chan *Conn, 1)
+	errc := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			errc <- err
+			return
+		}
+		sc, err := Server(c, &Config{Identity: sellerKey, PeerIdentity: buyerPub, Timeout: timeout})
+		if err != nil {
+			errc <- err
+			return
+		}
+		connc <- sc
+	}()
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err, "can't dial")
+	bc, err := Client(c, &Config{Identity: buyerKey, PeerIdentity: sellerPub, Timeout: timeout})
+	require.NoError(t, err, "can't handshake")
+	select {
+	case err := <-errc:
+		require.NoError(t, err, "can't accept")
+	case sc := <-connc:
+		require.Equal(t, buyerPub, sc.RemoteIdentity(), "remote identity mismatch")
+		require.Equal(t, sellerPub, bc.RemoteIdentity(), "remote identity mismatch")
+		return bc, sc
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout")
+	}
+	return nil, nil
+}
+
+func newBuyerTrade(t *testing.T) trade.Trade {
+	tr, err := trade.NewOnChainTrade(
+		types.Amount("1"), cryptos.Bitcoin,
+		types.Amount("2"), cryptos.Litecoin,
+		48*time.Hour,
+	)
+	require.NoError(t, err, "can't create trade")
+	return tr
+}
+
+func TestNegotiation(t *testing.T) {
+	bc, sc := newPeers(t)
+	defer bc.Close()
+	defer sc.Close()
+	buyerTrade := newBuyerTrade(t)
+	resc := make(chan *peerResult, 1)
+	go func() {
+		tr, err := AnswerProposal(sc, func(prop *trade.BuyProposal) error {
+			if prop.Seller.Crypto.Name != cryptos.Litecoin.Name {
+				return errors.New("unexpected crypto")
+			}
+			return nil
+		})
+		if err == nil {
+			err = ReceiveToken(sc, tr)
+		}
+		resc <- &peerResult{tr: tr, err: err}
+	}()
+	require.NoError(t, ProposeTrade(bc, buyerTrade), "can't negotiate")
+	require.NoError(t, RevealToken(bc, buyerTrade), "can't reveal token")
+	res := <-resc
+	require.NoError(t, res.err, "seller failed")
+	require.Equal(t, buyerTrade.TokenHash(), res.tr.TokenHash(), "token hash mismatch")
+	require.Equal(t, buyerTrade.Token(), res.tr.Token(), "token mismatch")
+	// both trades are bound to the session and the peer
+	require.NotEmpty(t, buyerTrade.SessionID(), "missing session id")
+	require.Equal(t, buyerTrade.SessionID(), res.tr.SessionID(), "session id mismatch")
+	require.Equal(t, bc.RemoteIdentity(), []byte(buyerTrade.PeerSigner()), "buyer peer signer mismatch")
+	require.Equal(t, sc.RemoteIdentity(), []byte(res.tr.PeerSigner()), "seller peer signer mismatch")
+	require.Equal(t,
+		buyerTrade.RecoverableFunds().Lock().Bytes(),
+		res.tr.RedeemableFunds().Lock().Bytes(),
+		"buyer lock mismatch",
+	)
+	require.Equal(t,
+		buyerTrade.RedeemableFunds().Lock().Bytes(),
+		res.tr.RecoverableFunds().Lock().Bytes(),
+		"seller lock mismatch",
+	)
+}
+
+func TestNegotiationReject(t *testing.T) {
+	bc, sc := newPeers(t)
+	defer bc.Close()
+	defer sc.Close()
+	resc := make(chan error, 1)
+	go func() {
+		_, err := AnswerProposal(sc, func(prop *trade.BuyProposal) error {
+			return errors.New("price too low")
+		})
+		resc <- err
+	}()
+	err := ProposeTrade(bc, newBuyerTrade(t))
+	require.Equal(t, RejectedError("price too low"), err, "expecting rejection")
+	require.Error(t, <-resc, "expecting error")
+}
+
+func TestNegotiationExpired(t *testing.T) {
+	bc, sc := newPeers(t)
+	defer bc.Close()
+	defer sc.Close()
+	resc := make(chan error, 1)
+	go func() {
+		_, err := AnswerProposal(sc, nil)
+		resc <- err
+	}()
+	tr := newBuyerTrade(t)
+	btr, err := tr.Buyer()
+	require.NoError(t, err, "can't get buyer trade")
+	prop, err := btr.GenerateBuyProposal()
+	require.NoError(t, err, "can't generate proposal")
+	prop.Expiry = time.Now().Add(-time.Minute)
+	err = sendProposal(bc, tr, btr, prop)
+	require.Equal(t, RejectedError(trade.ErrProposalExpired.Error()), err, "expecting rejection")
+	require.Equal(t, trade.ErrProposalExpired, <-resc, "expecting expired proposal")
+}
+
+func TestUnexpectedPeer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen")
+	defer l.Close()
+	k1, err := envelope.NewIdentityKey()
+	require.NoError(t, err, "can't generate key")
+	k2, err := envelope.NewIdentityKey()
+	require.NoError(t, err, "can't generate key")
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		Server(c, &Config{Identity: k2})
+	}()
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err, "can't dial")
+	defer c.Close()
+	_, err = Client(c, &Config{Identity: k1, PeerIdentity: k1.Public().SerializeCompressed()})
+	require.Equal(t, ErrUnexpectedPeer, err, "expecting unexpected peer")
+}
+
+func TestIdentityProof(t *testing.T) {
+	k, err := envelope.NewIdentityKey()
+	require.NoError(t, err, "can't generate key")
+	proof, err := newIdentityProof(k, []byte{1, 2, 3})
+	require.NoError(t, err, "can't create proof")
+	id, err := openIdentityProof(proof, []byte{1, 2, 3}, nil)
+	require.NoError(t, err, "can't open proof")
+	require.Equal(t, k.Public().SerializeCompressed(), id, "identity mismatch")
+	_, err = openIdentityProof(proof, []byte{1, 2, 4}, nil)
+	require.Equal(t, ErrInvalidIdentityProof, err, "expecting an invalid proof")
+}
+
+func TestImpostorProposal(t *testing.T) {
+	bc, sc := newPeers(t)
+	defer bc.Close()
+	defer sc.Close()
+	resc := make(chan error, 1)
+	go func() {
+		_, err := AnswerProposal(sc, nil)
+		resc <- err
+	}()
+	// the proposal is signed by another identity
+	other, err := envelope.NewIdentityKey()
+	require.NoError(t, err, "can't generate key")
+	bc.identity = other
+	err = ProposeTrade(bc, newBuyerTrade(t))
+	require.Equal(t, RejectedError(envelope.ErrSignerMismatch.Error()), err, "expecting rejection")
+	require.Equal(t, envelope.ErrSignerMismatch, <-resc, "expecting signer mismatch")
+}
+
+func TestTimeout(t *testing.T) {
+	bc, sc := newPeersTimeout(t, 100*time.Millisecond)
+	defer bc.Close()
+	defer sc.Close()
+	_, err := sc.Receive()
+	require.Error(t, err, "expecting a timeout")
+	nerr, ok := err.(net.Error)
+	require.True(t, ok && nerr.Timeout(), "expecting a timeout")
+}
+
+func TestQuote(t *testing.T) {
+	bc, sc := newPeers(t)
+	defer bc.Close()
+	defer sc.Close()
+	book := orderbook.New()
+	err := book.Add("btc-ltc", &orderbook.Offer{
+		Own:          cryptos.Bitcoin,
+		Trader:       cryptos.Litecoin,
+		Price:        "100",
+		MinAmount:    "0.1",
+		MaxAmount:    "1",
+		LockDuration: duration.Duration(48 * time.Hour),
+	})
+	require.NoError(t, err, "can't add offer")
+	require.NoError(t, book.SetInventory(cryptos.Bitcoin, "5"), "can't set inventory")
+	resc := make(chan *peerResult, 1)
+	go func() {
+		if err := PublishOffers(bc, book.Published(nil)); err != nil {
+			resc <- &peerResult{err: err}
+			return
+		}
+		tr, err := AnswerQuote(bc, func(req *orderbook.QuoteRequest) (trade.Trade, *trade.BuyProposal, error) {
+			return book.Quote(req, nil)
+		})
+		resc <- &peerResult{tr: tr, err: err}
+	}()
+	offers, err := ReceiveOffers(sc)
+	require.NoError(t, err, "can't receive offers")
+	require.Contains(t, offers, "btc-ltc", "missing offer")
+	sellerTrade, err := RequestQuote(sc, offers["btc-ltc"], &orderbook.QuoteRequest{Offer: "btc-ltc", Amount: "0.5"}, nil)
+	require.NoError(t, err, "can't request quote")
+	require.Equal(t, types.Amount("50"), sellerTrade.OwnInfo().Amount, "amount mismatch")
+	res := <-resc
+	require.NoError(t, res.err, "can't answer quote")
+	require.Equal(t, sellerTrade.TokenHash(), res.tr.TokenHash(), "token hash mismatch")
+	// out of range
+	go func() {
+		_, err := AnswerQuote(bc, func(req *orderbook.QuoteRequest) (trade.Trade, *trade.BuyProposal, error) {
+			return book.Quote(req, nil)
+		})
+		resc <- &peerResult{err: err}
+	}()
+	_, err = RequestQuote(sc, offers["btc-ltc"], &orderbook.QuoteRequest{Offer: "btc-ltc", Amount: "2"}, nil)
+	require.Equal(t, RejectedError(orderbook.ErrAmountOutOfRange.Error()), err, "expecting rejection")
+	require.Equal(t, orderbook.ErrAmountOutOfRange, (<-resc).err, "expecting out of range")
+}
+
+func TestQuoteMismatch(t *testing.T) {
+	bc, sc := newPeers(t)
+	defer bc.Close()
+	defer sc.Close()
+	offer := &orderbook.Offer{
+		Own:          cryptos.Bitcoin,
+		Trader:       cryptos.Litecoin,
+		Price:        "100",
+		MinAmount:    "0.1",
+		MaxAmount:    "1",
+		LockDuration: duration.Duration(48 * time.Hour),
+	}
+	for _, i := range []struct {
+		own      types.Amount
+		trader   types.Amount
+		duration time.Duration
+	}{
+		{"0.1", "50", 48 * time.Hour},
+		{"0.5", "60", 48 * time.Hour},
+		{"0.5", "50", 96 * time.Hour},
+	} {
+		resc := make(chan error, 1)
+		go func() {
+			// the maker answers with a proposal other than the requested
+			_, err := AnswerQuote(bc, func(req *orderbook.QuoteRequest) (trade.Trade, *trade.BuyProposal, error) {
+				tr, err := trade.NewOnChainTrade(i.own, cryptos.Bitcoin, i.trader, cryptos.Litecoin, i.duration)
+				if err != nil {
+					return nil, nil, err
+				}
+				btr, err := tr.Buyer()
+				if err != nil {
+					return nil, nil, err
+				}
+				prop, err := btr.GenerateBuyProposal()
+				return tr, prop, err
+			})
+			resc <- err
+		}()
+		_, err := RequestQuote(sc, offer, &orderbook.QuoteRequest{Offer: "btc-ltc", Amount: "0.5"}, nil)
+		require.Equal(t, ErrQuoteMismatch, err, "expecting a quote mismatch")
+		require.Equal(t, RejectedError(ErrQuoteMismatch.Error()), <-resc, "expecting rejection")
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen")
+	defer l.Close()
+	k, err := envelope.NewIdentityKey()
+	require.NoError(t, err, "can't generate key")
+	// the peer never answers
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err, "can't dial")
+	defer c.Close()
+	sc, err := l.Accept()
+	require.NoError(t, err, "can't accept")
+	defer sc.Close()
+	_, err = Server(sc, &Config{Identity: k, Timeout: 100 * time.Millisecond})
+	nerr, ok := err.(net.Error)
+	require.True(t, ok && nerr.Timeout(), "expecting a timeout")
+}
@@ -0,0 +1,119 @@
+package negotiation
+
+import (
+	"errors"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/orderbook"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrQuoteMismatch is returned when the proposal received for a quote request
+// doesn't match the request or the offer
+var ErrQuoteMismatch = errors.New("quote doesn't match the request")
+
+// QuoteFunc answers a quote request with a new buyer trade and it's proposal
+type QuoteFunc = func(req *orderbook.QuoteRequest) (trade.Trade, *trade.BuyProposal, error)
+
+// PublishOffers sends the offers to the peer
+func PublishOffers(c *Conn, offers map[string]*orderbook.Offer) error {
+	b, err := yaml.Marshal(offers)
+	if err != nil {
+		return err
+	}
+	return c.Send(newMessage(MsgOffers, b))
+}
+
+// ReceiveOffers waits for the peer offers
+func ReceiveOffers(c *Conn) (map[string]*orderbook.Offer, error) {
+	m, err := c.receiveExpected(MsgOffers)
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[string]*orderbook.Offer, 8)
+	if err = yaml.Unmarshal(m.Payload, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// AnswerQuote waits for a quote request and answers it with the proposal
+// returned by quote. If quote returns an error the request is rejected
+func AnswerQuote(c *Conn, quote QuoteFunc) (trade.Trade, error) {
+	m, err := c.receiveExpected(MsgQuoteRequest)
+	if err != nil {
+		return nil, err
+	}
+	req := &orderbook.QuoteRequest{}
+	if err = yaml.Unmarshal(m.Payload, req); err != nil {
+		c.Reject(err.Error())
+		return nil, err
+	}
+	tr, prop, err := quote(req)
+	if err != nil {
+		c.Reject(err.Error())
+		return nil, err
+	}
+	btr, err := tr.Buyer()
+	if err != nil {
+		return nil, err
+	}
+	if err = sendProposal(c, tr, btr, prop); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// RequestQuote sends a quote request for an offer and answers the resulting
+// proposal. Proposals that don't match the request and the offer are rejected
+// before decide is called
+func RequestQuote(c *Conn, offer *orderbook.Offer, req *orderbook.QuoteRequest, decide func(*trade.BuyProposal) error) (trade.Trade, error) {
+	b, err := yaml.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.Send(newMessage(MsgQuoteRequest, b)); err != nil {
+		return nil, err
+	}
+	return AnswerProposal(c, func(prop *trade.BuyProposal) error {
+		if err := checkQuote(offer, req, prop); err != nil {
+			return err
+		}
+		if decide != nil {
+			return decide(prop)
+		}
+		return nil
+	})
+}
+
+// checkQuote checks the proposal against the quote request and the offer
+func checkQuote(offer *orderbook.Offer, req *orderbook.QuoteRequest, prop *trade.BuyProposal) error {
+	if prop.Buyer == nil || prop.Seller == nil {
+		return ErrQuoteMismatch
+	}
+	traderAmount, err := offer.TraderAmount(req.Amount)
+	if err != nil {
+		return err
+	}
+	if !sameAmount(prop.Buyer, offer.Own, req.Amount) || !sameAmount(prop.Seller, offer.Trader, traderAmount) {
+		return ErrQuoteMismatch
+	}
+	sellerDuration := offer.TraderLockDuration
+	if sellerDuration == 0 {
+		sellerDuration = offer.LockDuration / 2
+	}
+	if prop.Buyer.LockDuration != offer.LockDuration || prop.Seller.LockDuration != sellerDuration {
+		return ErrQuoteMismatch
+	}
+	return nil
+}
+
+// sameAmount returns true if the proposal info has the crypto and the amount
+func sameAmount(pi *trade.BuyProposalInfo, c *cryptos.Crypto, amount types.Amount) bool {
+	if pi.Crypto == nil || pi.Crypto.Name != c.Name || !pi.Amount.Valid() {
+		return false
+	}
+	return pi.Amount.UInt64(c.Decimals) == amount.UInt64(c.Decimals)
+}
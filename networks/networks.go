@@ -71,3 +71,24 @@ func init() {
 		}
 	}
 }
+
+// Register adds the parameters of a crypto chain
+func Register(c *cryptos.Crypto, chain params.Chain, p params.Params) {
+	cc, ok := All[c]
+	if !ok {
+		cc = chains{}
+		All[c] = cc
+		AllByName[c.Name] = cc
+	}
+	cc[chain] = p
+	switch chain {
+	case params.MainNet:
+		Main[c], MainByName[c.Name] = p, p
+	case params.TestNet:
+		Test[c], TestByName[c.Name] = p, p
+	case params.SimNet:
+		Sim[c], SimByName[c.Name] = p, p
+	case params.RegressionNet:
+		Regression[c], RegressionByName[c.Name] = p, p
+	}
+}
@@ -0,0 +1,92 @@
+package orderbook
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/duration"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+var (
+	// ErrInvalidPrice is returned when the price of an offer is invalid
+	ErrInvalidPrice = errors.New("invalid price")
+
+	// ErrInvalidRange is returned when the offer size limits are invalid
+	ErrInvalidRange = errors.New("invalid size range")
+
+	// ErrAmountOutOfRange is returned when a quote amount is outside the
+	// offer size limits
+	ErrAmountOutOfRange = errors.New("amount out of range")
+)
+
+// Offer represents a standing offer to trade the own crypto for the trader
+// crypto. The maker of the offer is the buyer of the resulting trades
+type Offer struct {
+	Own                *cryptos.Crypto   `yaml:"own"`
+	Trader             *cryptos.Crypto   `yaml:"trader"`
+	Price              types.Amount      `yaml:"price"`
+	MinAmount          types.Amount      `yaml:"min_amount"`
+	MaxAmount          types.Amount      `yaml:"max_amount"`
+	LockDuration       duration.Duration `yaml:"lock_duration"`
+	TraderLockDuration duration.Duration `yaml:"trader_lock_duration,omitempty"`
+}
+
+// Validate checks the offer
+func (o *Offer) Validate() error {
+	if !o.Price.Valid() {
+		return ErrInvalidPrice
+	}
+	if pu, _ := priceUnits(o.Price); pu == 0 {
+		return ErrInvalidPrice
+	}
+	if !o.MinAmount.Valid() || !o.MaxAmount.Valid() {
+		return ErrInvalidRange
+	}
+	if o.minUnits() > o.maxUnits() || o.maxUnits() == 0 {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+func (o *Offer) minUnits() uint64 { return o.MinAmount.UInt64(o.Own.Decimals) }
+
+func (o *Offer) maxUnits() uint64 { return o.MaxAmount.UInt64(o.Own.Decimals) }
+
+// InRange returns true if the amount is within the offer size limits
+func (o *Offer) InRange(amount types.Amount) bool {
+	if !amount.Valid() {
+		return false
+	}
+	u := amount.UInt64(o.Own.Decimals)
+	return u > 0 && u >= o.minUnits() && u <= o.maxUnits()
+}
+
+// TraderAmount returns the trader crypto amount for an own crypto amount,
+// rounded down to the trader crypto decimals
+func (o *Offer) TraderAmount(amount types.Amount) (types.Amount, error) {
+	if !amount.Valid() {
+		return "", types.InvalidAmountError(amount)
+	}
+	pu, pp := priceUnits(o.Price)
+	r := new(big.Int).SetUint64(amount.UInt64(o.Own.Decimals))
+	r.Mul(r, new(big.Int).SetUint64(pu))
+	r.Mul(r, pow10(o.Trader.Decimals))
+	r.Div(r, pow10(o.Own.Decimals+pp))
+	if !r.IsUint64() {
+		return "", ErrAmountOutOfRange
+	}
+	return types.NewAmount(r.Uint64(), uint64(o.Trader.Decimals)), nil
+}
+
+// priceUnits returns the price as an integer and it's precision
+func priceUnits(p types.Amount) (uint64, int) {
+	pp := int(p.Prec())
+	if pp == 0 {
+		pp = 1
+	}
+	return p.UInt64(pp), pp
+}
+
+func pow10(n int) *big.Int { return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil) }
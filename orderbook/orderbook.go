@@ -0,0 +1,180 @@
+package orderbook
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// ErrInsufficientInventory is returned when the inventory can't cover a quote
+var ErrInsufficientInventory = errors.New("insufficient inventory")
+
+// OfferExistsError is returned when adding an offer with a used name
+type OfferExistsError string
+
+func (e OfferExistsError) Error() string {
+	return fmt.Sprintf("offer exists: \"%s\"", string(e))
+}
+
+// OfferNotFoundError is returned when an offer doesn't exist
+type OfferNotFoundError string
+
+func (e OfferNotFoundError) Error() string {
+	return fmt.Sprintf("offer not found: \"%s\"", string(e))
+}
+
+// Book represents a set of standing offers and the inventory backing them
+type Book struct {
+	Offers    map[string]*Offer       `yaml:"offers,omitempty"`
+	Inventory map[string]types.Amount `yaml:"inventory,omitempty"`
+}
+
+// QuoteRequest represents a request to trade an amount of an offer own crypto
+type QuoteRequest struct {
+	Offer  string       `yaml:"offer"`
+	Amount types.Amount `yaml:"amount"`
+}
+
+// New returns a new empty book
+func New() *Book {
+	return &Book{
+		Offers:    make(map[string]*Offer, 8),
+		Inventory: make(map[string]types.Amount, 8),
+	}
+}
+
+// Add adds a new offer
+func (b *Book) Add(name string, o *Offer) error {
+	if _, ok := b.Offers[name]; ok {
+		return OfferExistsError(name)
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if b.Offers == nil {
+		b.Offers = make(map[string]*Offer, 8)
+	}
+	b.Offers[name] = o
+	return nil
+}
+
+// Remove removes an offer
+func (b *Book) Remove(name string) error {
+	if _, ok := b.Offers[name]; !ok {
+		return OfferNotFoundError(name)
+	}
+	delete(b.Offers, name)
+	return nil
+}
+
+// Offer returns an offer by name
+func (b *Book) Offer(name string) (*Offer, error) {
+	r, ok := b.Offers[name]
+	if !ok {
+		return nil, OfferNotFoundError(name)
+	}
+	return r, nil
+}
+
+// SetInventory sets the inventory of a crypto
+func (b *Book) SetInventory(c *cryptos.Crypto, amount types.Amount) error {
+	if !amount.Valid() {
+		return types.InvalidAmountError(amount)
+	}
+	if b.Inventory == nil {
+		b.Inventory = make(map[string]types.Amount, 8)
+	}
+	b.Inventory[c.Name] = amount
+	return nil
+}
+
+// Committed returns the own funds of a crypto committed to the trades. The
+// finished trades don't commit funds
+func Committed(c *cryptos.Crypto, trades []trade.Trade) uint64 {
+	var r uint64
+	for _, tr := range trades {
+		if tr.Finished() {
+			continue
+		}
+		oi := tr.OwnInfo()
+		if oi.Crypto.Name != c.Name {
+			continue
+		}
+		r += oi.Amount.UInt64(c.Decimals)
+	}
+	return r
+}
+
+// Available returns the inventory of a crypto that isn't committed to the
+// trades. A crypto without inventory has nothing available
+func (b *Book) Available(c *cryptos.Crypto, trades []trade.Trade) uint64 {
+	inv, ok := b.Inventory[c.Name]
+	if !ok {
+		return 0
+	}
+	total, committed := inv.UInt64(c.Decimals), Committed(c, trades)
+	if committed >= total {
+		return 0
+	}
+	return total - committed
+}
+
+// Published returns the offers with the maximum amounts capped to the
+// available inventory. Offers without enough inventory are left out
+func (b *Book) Published(trades []trade.Trade) map[string]*Offer {
+	r := make(map[string]*Offer, len(b.Offers))
+	for name, o := range b.Offers {
+		avail := b.Available(o.Own, trades)
+		if avail == 0 || avail < o.minUnits() {
+			continue
+		}
+		po := *o
+		if avail < o.maxUnits() {
+			po.MaxAmount = types.NewAmount(avail, uint64(o.Own.Decimals))
+		}
+		r[name] = &po
+	}
+	return r
+}
+
+// Quote answers a quote request with a new buyer trade and it's proposal
+func (b *Book) Quote(req *QuoteRequest, trades []trade.Trade) (trade.Trade, *trade.BuyProposal, error) {
+	o, err := b.Offer(req.Offer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !o.InRange(req.Amount) {
+		return nil, nil, ErrAmountOutOfRange
+	}
+	if req.Amount.UInt64(o.Own.Decimals) > b.Available(o.Own, trades) {
+		return nil, nil, ErrInsufficientInventory
+	}
+	traderAmount, err := o.TraderAmount(req.Amount)
+	if err != nil {
+		return nil, nil, err
+	}
+	tr, err := trade.NewOnChainTrade(
+		req.Amount, o.Own,
+		traderAmount, o.Trader,
+		time.Duration(o.LockDuration),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	btr, err := tr.Buyer()
+	if err != nil {
+		return nil, nil, err
+	}
+	if o.TraderLockDuration != 0 {
+		btr.SetTraderDuration(o.TraderLockDuration)
+	}
+	prop, err := btr.GenerateBuyProposal()
+	if err != nil {
+		return nil, nil, err
+	}
+	return tr, prop, nil
+}
@@ -0,0 +1,84 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/duration"
+	"github.com/transmutate-io/atomicswap/trade"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+func newTestBook(t *testing.T) *Book {
+	b := New()
+	err := b.Add("btc-ltc", &Offer{
+		Own:                cryptos.Bitcoin,
+		Trader:             cryptos.Litecoin,
+		Price:              "150.5",
+		MinAmount:          "0.01",
+		MaxAmount:          "2",
+		LockDuration:       duration.Duration(48 * time.Hour),
+		TraderLockDuration: duration.Duration(12 * time.Hour),
+	})
+	require.NoError(t, err, "can't add offer")
+	require.NoError(t, b.SetInventory(cryptos.Bitcoin, "3"), "can't set inventory")
+	return b
+}
+
+func TestOffer(t *testing.T) {
+	b := newTestBook(t)
+	o, err := b.Offer("btc-ltc")
+	require.NoError(t, err, "can't get offer")
+	amt, err := o.TraderAmount("0.5")
+	require.NoError(t, err, "can't compute amount")
+	require.Equal(t, types.Amount("75.25"), amt, "amount mismatch")
+	require.False(t, o.InRange("0.001"), "expecting out of range")
+	require.False(t, o.InRange("2.1"), "expecting out of range")
+	require.True(t, o.InRange("2"), "expecting in range")
+	require.Equal(t, OfferExistsError("btc-ltc"), b.Add("btc-ltc", o), "expecting offer exists")
+	bad := *o
+	bad.MinAmount = "3"
+	require.Equal(t, ErrInvalidRange, b.Add("bad", &bad), "expecting invalid range")
+	bad = *o
+	bad.Price = "0"
+	require.Equal(t, ErrInvalidPrice, b.Add("bad", &bad), "expecting invalid price")
+	require.Equal(t, OfferNotFoundError("bad"), b.Remove("bad"), "expecting offer not found")
+}
+
+func TestQuote(t *testing.T) {
+	b := newTestBook(t)
+	trades := make([]trade.Trade, 0, 4)
+	tr, prop, err := b.Quote(&QuoteRequest{Offer: "btc-ltc", Amount: "2"}, trades)
+	require.NoError(t, err, "can't quote")
+	require.Equal(t, types.Amount("2"), prop.Buyer.Amount, "buyer amount mismatch")
+	require.Equal(t, types.Amount("301"), prop.Seller.Amount, "seller amount mismatch")
+	require.Equal(t, duration.Duration(12*time.Hour), prop.Seller.LockDuration, "seller duration mismatch")
+	_, err = trade.AcceptProposal(prop)
+	require.NoError(t, err, "can't accept quoted proposal")
+	trades = append(trades, tr)
+	require.Equal(t, uint64(100000000), b.Available(cryptos.Bitcoin, trades), "available mismatch")
+	pub := b.Published(trades)
+	require.Equal(t, types.Amount("1"), pub["btc-ltc"].MaxAmount, "published max mismatch")
+	_, _, err = b.Quote(&QuoteRequest{Offer: "btc-ltc", Amount: "1.5"}, trades)
+	require.Equal(t, ErrInsufficientInventory, err, "expecting insufficient inventory")
+	_, _, err = b.Quote(&QuoteRequest{Offer: "btc-ltc", Amount: "5"}, trades)
+	require.Equal(t, ErrAmountOutOfRange, err, "expecting out of range")
+	_, _, err = b.Quote(&QuoteRequest{Offer: "ltc-btc", Amount: "1"}, trades)
+	require.Equal(t, OfferNotFoundError("ltc-btc"), err, "expecting offer not found")
+	// the finished trades release the inventory
+	tr.Finish()
+	require.Equal(t, uint64(300000000), b.Available(cryptos.Bitcoin, trades), "available mismatch")
+	tr2, _, err := b.Quote(&QuoteRequest{Offer: "btc-ltc", Amount: "1.5"}, trades)
+	require.NoError(t, err, "can't quote")
+	trades = append(trades, tr2)
+	// round trip
+	bb, err := yaml.Marshal(b)
+	require.NoError(t, err, "can't marshal book")
+	b2 := &Book{}
+	require.NoError(t, yaml.Unmarshal(bb, b2), "can't unmarshal book")
+	require.Equal(t, b.Offers["btc-ltc"].Price, b2.Offers["btc-ltc"].Price, "price mismatch")
+	require.Equal(t, uint64(150000000), b2.Available(cryptos.Bitcoin, trades), "available mismatch")
+}
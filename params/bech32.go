@@ -0,0 +1,193 @@
+package params
+
+import (
+	"errors"
+	"strings"
+)
+
+// bech32 and bech32m encoding of segwit addresses (BIP173, BIP350)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+var (
+	errInvalidBech32       = errors.New("invalid bech32 string")
+	errInvalidBech32Length = errors.New("invalid bech32 length")
+	errInvalidBech32Sum    = errors.New("invalid bech32 checksum")
+	errInvalidPaddingBits  = errors.New("invalid padding bits")
+	errInvalidWitnessVer   = errors.New("invalid witness version")
+	errInvalidWitnessProg  = errors.New("invalid witness program")
+	errWitnessVerMismatch  = errors.New("witness version and checksum mismatch")
+	errSegwitNotSupported  = errors.New("segwit not supported")
+)
+
+var (
+	bech32CharsetRev = make(map[byte]byte, len(bech32Charset))
+	bech32Generator  = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+)
+
+func init() {
+	for i := 0; i < len(bech32Charset); i++ {
+		bech32CharsetRev[bech32Charset[i]] = byte(i)
+	}
+}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	r := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		r = append(r, hrp[i]>>5)
+	}
+	r = append(r, 0)
+	for i := 0; i < len(hrp); i++ {
+		r = append(r, hrp[i]&31)
+	}
+	return r
+}
+
+func bech32Checksum(hrp string, data []byte, c uint32) []byte {
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := bech32Polymod(values) ^ c
+	r := make([]byte, 6)
+	for i := range r {
+		r[i] = byte((mod >> uint(5*(5-i))) & 31)
+	}
+	return r
+}
+
+// bech32Decode decodes a bech32 or bech32m string returning the hrp, the data
+// and the checksum constant
+func bech32Decode(s string) (string, []byte, uint32, error) {
+	if len(s) > 90 {
+		return "", nil, 0, errInvalidBech32Length
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, errInvalidBech32
+	}
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, 0, errInvalidBech32
+	}
+	hrp := s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, errInvalidBech32
+		}
+	}
+	data := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		v, ok := bech32CharsetRev[s[i]]
+		if !ok {
+			return "", nil, 0, errInvalidBech32
+		}
+		data = append(data, v)
+	}
+	c := bech32Polymod(append(bech32HRPExpand(hrp), data...))
+	if c != bech32Const && c != bech32mConst {
+		return "", nil, 0, errInvalidBech32Sum
+	}
+	return hrp, data[:len(data)-6], c, nil
+}
+
+func bech32Encode(hrp string, data []byte, c uint32) string {
+	combined := append(append(make([]byte, 0, len(data)+6), data...), bech32Checksum(hrp, data, c)...)
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, i := range combined {
+		sb.WriteByte(bech32Charset[i])
+	}
+	return sb.String()
+}
+
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var acc, bits uint
+	maxv := uint(1)<<to - 1
+	r := make([]byte, 0, len(data)*int(from)/int(to)+1)
+	for _, v := range data {
+		if uint(v)>>from != 0 {
+			return nil, errInvalidBech32
+		}
+		acc = acc<<from | uint(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			r = append(r, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			r = append(r, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxv != 0 {
+		return nil, errInvalidPaddingBits
+	}
+	return r, nil
+}
+
+// decodeSegwitAddress decodes a segwit address returning the witness version
+// and program
+func decodeSegwitAddress(hrp string, addr string) (byte, []byte, error) {
+	ahrp, data, c, err := bech32Decode(addr)
+	if err != nil {
+		return 0, nil, err
+	}
+	if ahrp != hrp {
+		return 0, nil, errWrongNetwork
+	}
+	if len(data) < 1 || data[0] > 16 {
+		return 0, nil, errInvalidWitnessVer
+	}
+	prog, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(prog) < 2 || len(prog) > 40 {
+		return 0, nil, errInvalidWitnessProg
+	}
+	if data[0] == 0 && len(prog) != 20 && len(prog) != 32 {
+		return 0, nil, errInvalidWitnessProg
+	}
+	if (data[0] == 0) != (c == bech32Const) {
+		return 0, nil, errWitnessVerMismatch
+	}
+	return data[0], prog, nil
+}
+
+// encodeSegwitAddress encodes a witness program as a segwit address
+func encodeSegwitAddress(hrp string, version byte, prog []byte) (string, error) {
+	if version > 16 {
+		return "", errInvalidWitnessVer
+	}
+	if len(prog) < 2 || len(prog) > 40 || (version == 0 && len(prog) != 20 && len(prog) != 32) {
+		return "", errInvalidWitnessProg
+	}
+	data, err := convertBits(prog, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	c := uint32(bech32mConst)
+	if version == 0 {
+		c = bech32Const
+	}
+	return bech32Encode(hrp, append([]byte{version}, data...), c), nil
+}
@@ -0,0 +1,22 @@
+package params
+
+import "fmt"
+
+// ChainExistsError is returned when registering a chain that already exists
+type ChainExistsError string
+
+// Error implement error
+func (e ChainExistsError) Error() string {
+	return fmt.Sprintf("chain already exists: \"%s\"", string(e))
+}
+
+// RegisterChain adds a new chain
+func RegisterChain(name string) (Chain, error) {
+	if _, ok := _ChainNames[name]; ok {
+		return 0, ChainExistsError(name)
+	}
+	r := Chain(len(_Chain))
+	_Chain[r] = name
+	_ChainNames[name] = r
+	return r, nil
+}
@@ -0,0 +1,35 @@
+package params
+
+// Network represents the parameters of a crypto network
+type Network struct {
+	// Name is the name of the network
+	Name string
+	// GenesisHash is the hash of the genesis block, as shown by the nodes
+	GenesisHash string
+	// Magic is the network magic
+	Magic uint32
+	// DefaultPort is the default peer to peer port
+	DefaultPort uint16
+	// RPCPort is the default RPC port
+	RPCPort uint16
+	// PubKeyHashAddrID is the version of a p2pkh address
+	PubKeyHashAddrID []byte
+	// ScriptHashAddrID is the version of a p2sh address
+	ScriptHashAddrID []byte
+	// PrivateKeyID is the version of a WIF private key
+	PrivateKeyID []byte
+	// HDPrivateKeyID is the version of a BIP32 extended private key
+	HDPrivateKeyID [4]byte
+	// HDPublicKeyID is the version of a BIP32 extended public key
+	HDPublicKeyID [4]byte
+	// Bech32HRP is the human readable part of a segwit address
+	Bech32HRP string
+	// CashAddrPrefix is the prefix of a cashaddr address
+	CashAddrPrefix string
+}
+
+// NewBTCLike returns the parameters for a bitcoin-like network
+func NewBTCLike(n *Network) Params { return (*btcParams)(n) }
+
+// NewBCHLike returns the parameters for a bitcoin-cash-like network
+func NewBCHLike(n *Network) Params { return (*bchParams)(n) }
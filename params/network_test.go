@@ -0,0 +1,136 @@
+package params
+
+import (
+	"fmt"
+	"testing"
+
+	btccfg "github.com/btcsuite/btcd/chaincfg"
+	dcrcfg "github.com/decred/dcrd/chaincfg"
+	bchcfg "github.com/gcash/bchd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+type networkCase struct {
+	p           Params
+	genesis     string
+	magic       uint32
+	port        string
+	pkh, sh, pk []byte
+	hdPriv      [4]byte
+	hdPub       [4]byte
+}
+
+func TestNetworks(t *testing.T) {
+	cases := []networkCase{
+		{
+			BTC_MainNet,
+			btccfg.MainNetParams.GenesisHash.String(),
+			uint32(btccfg.MainNetParams.Net),
+			btccfg.MainNetParams.DefaultPort,
+			[]byte{btccfg.MainNetParams.PubKeyHashAddrID},
+			[]byte{btccfg.MainNetParams.ScriptHashAddrID},
+			[]byte{btccfg.MainNetParams.PrivateKeyID},
+			btccfg.MainNetParams.HDPrivateKeyID,
+			btccfg.MainNetParams.HDPublicKeyID,
+		},
+		{
+			BTC_TestNet,
+			btccfg.TestNet3Params.GenesisHash.String(),
+			uint32(btccfg.TestNet3Params.Net),
+			btccfg.TestNet3Params.DefaultPort,
+			[]byte{btccfg.TestNet3Params.PubKeyHashAddrID},
+			[]byte{btccfg.TestNet3Params.ScriptHashAddrID},
+			[]byte{btccfg.TestNet3Params.PrivateKeyID},
+			btccfg.TestNet3Params.HDPrivateKeyID,
+			btccfg.TestNet3Params.HDPublicKeyID,
+		},
+		{
+			BTC_RegressionNet,
+			btccfg.RegressionNetParams.GenesisHash.String(),
+			uint32(btccfg.RegressionNetParams.Net),
+			btccfg.RegressionNetParams.DefaultPort,
+			[]byte{btccfg.RegressionNetParams.PubKeyHashAddrID},
+			[]byte{btccfg.RegressionNetParams.ScriptHashAddrID},
+			[]byte{btccfg.RegressionNetParams.PrivateKeyID},
+			btccfg.RegressionNetParams.HDPrivateKeyID,
+			btccfg.RegressionNetParams.HDPublicKeyID,
+		},
+		{
+			BTC_SimNet,
+			btccfg.SimNetParams.GenesisHash.String(),
+			uint32(btccfg.SimNetParams.Net),
+			btccfg.SimNetParams.DefaultPort,
+			[]byte{btccfg.SimNetParams.PubKeyHashAddrID},
+			[]byte{btccfg.SimNetParams.ScriptHashAddrID},
+			[]byte{btccfg.SimNetParams.PrivateKeyID},
+			btccfg.SimNetParams.HDPrivateKeyID,
+			btccfg.SimNetParams.HDPublicKeyID,
+		},
+		{
+			BCH_MainNet,
+			bchcfg.MainNetParams.GenesisHash.String(),
+			uint32(bchcfg.MainNetParams.Net),
+			bchcfg.MainNetParams.DefaultPort,
+			[]byte{bchcfg.MainNetParams.LegacyPubKeyHashAddrID},
+			[]byte{bchcfg.MainNetParams.LegacyScriptHashAddrID},
+			[]byte{bchcfg.MainNetParams.PrivateKeyID},
+			bchcfg.MainNetParams.HDPrivateKeyID,
+			bchcfg.MainNetParams.HDPublicKeyID,
+		},
+		{
+			BCH_TestNet,
+			bchcfg.TestNet3Params.GenesisHash.String(),
+			uint32(bchcfg.TestNet3Params.Net),
+			bchcfg.TestNet3Params.DefaultPort,
+			[]byte{bchcfg.TestNet3Params.LegacyPubKeyHashAddrID},
+			[]byte{bchcfg.TestNet3Params.LegacyScriptHashAddrID},
+			[]byte{bchcfg.TestNet3Params.PrivateKeyID},
+			bchcfg.TestNet3Params.HDPrivateKeyID,
+			bchcfg.TestNet3Params.HDPublicKeyID,
+		},
+		{
+			DCR_MainNet,
+			dcrcfg.MainNetParams.GenesisHash.String(),
+			uint32(dcrcfg.MainNetParams.Net),
+			dcrcfg.MainNetParams.DefaultPort,
+			dcrcfg.MainNetParams.PubKeyHashAddrID[:],
+			dcrcfg.MainNetParams.ScriptHashAddrID[:],
+			dcrcfg.MainNetParams.PrivateKeyID[:],
+			dcrcfg.MainNetParams.HDPrivateKeyID,
+			dcrcfg.MainNetParams.HDPublicKeyID,
+		},
+		{
+			DCR_TestNet,
+			dcrcfg.TestNet3Params.GenesisHash.String(),
+			uint32(dcrcfg.TestNet3Params.Net),
+			dcrcfg.TestNet3Params.DefaultPort,
+			dcrcfg.TestNet3Params.PubKeyHashAddrID[:],
+			dcrcfg.TestNet3Params.ScriptHashAddrID[:],
+			dcrcfg.TestNet3Params.PrivateKeyID[:],
+			dcrcfg.TestNet3Params.HDPrivateKeyID,
+			dcrcfg.TestNet3Params.HDPublicKeyID,
+		},
+		{
+			DCR_SimNet,
+			dcrcfg.SimNetParams.GenesisHash.String(),
+			uint32(dcrcfg.SimNetParams.Net),
+			dcrcfg.SimNetParams.DefaultPort,
+			dcrcfg.SimNetParams.PubKeyHashAddrID[:],
+			dcrcfg.SimNetParams.ScriptHashAddrID[:],
+			dcrcfg.SimNetParams.PrivateKeyID[:],
+			dcrcfg.SimNetParams.HDPrivateKeyID,
+			dcrcfg.SimNetParams.HDPublicKeyID,
+		},
+	}
+	for _, i := range cases {
+		n := i.p.Network()
+		require.Equal(t, i.genesis, n.GenesisHash, "genesis hash mismatch (%s)", n.Name)
+		require.Equal(t, i.magic, n.Magic, "magic mismatch (%s)", n.Name)
+		require.Equal(t, i.port, fmt.Sprintf("%d", n.DefaultPort), "port mismatch (%s)", n.Name)
+		require.Equal(t, i.pkh, n.PubKeyHashAddrID, "p2pkh id mismatch (%s)", n.Name)
+		require.Equal(t, i.sh, n.ScriptHashAddrID, "p2sh id mismatch (%s)", n.Name)
+		require.Equal(t, i.pk, n.PrivateKeyID, "private key id mismatch (%s)", n.Name)
+		require.Equal(t, i.hdPriv, n.HDPrivateKeyID, "hd private key id mismatch (%s)", n.Name)
+		require.Equal(t, i.hdPub, n.HDPublicKeyID, "hd public key id mismatch (%s)", n.Name)
+	}
+}
@@ -14,4 +14,28 @@ type Params interface {
 	P2SHFromScript(script []byte) (string, error)
 	// AddressToScript converts an addres to a script
 	AddressToScript(addr string) ([]byte, error)
+	// Network returns the network parameters
+	Network() *Network
+}
+
+// LegacyParams represents the parameters of a utxo crypto with a legacy
+// address encoding besides the default one
+type LegacyParams interface {
+	Params
+	// LegacyP2PKH returns the legacy p2pkh address of the public key hash
+	LegacyP2PKH(pubHash []byte) (string, error)
+	// LegacyP2SH returns the legacy p2sh address of the script hash
+	LegacyP2SH(scriptHash []byte) (string, error)
+}
+
+// SegwitParams represents the parameters of a utxo crypto with segwit
+// addresses
+type SegwitParams interface {
+	Params
+	// P2WPKH returns the p2wpkh address of the public key hash
+	P2WPKH(pubHash []byte) (string, error)
+	// P2WSH returns the p2wsh address of the script hash
+	P2WSH(scriptHash []byte) (string, error)
+	// P2TR returns the p2tr address of the x-only output key
+	P2TR(outputKey []byte) (string, error)
 }
@@ -1,6 +1,9 @@
 package params
 
 import (
+	"errors"
+
+	"github.com/btcsuite/btcutil/base58"
 	"github.com/gcash/bchd/chaincfg"
 	"github.com/gcash/bchutil"
 	"github.com/transmutate-io/atomicswap/hash"
@@ -8,51 +11,78 @@ import (
 )
 
 var (
-	_ Params = (*bchParams)(nil)
+	_ LegacyParams = (*bchParams)(nil)
 
-	// BCH_MainNet represents the bitcoin main net
+	// BCH_MainNet represents the bitcoin cash main net
 	BCH_MainNet = &bchParams{
-		prefix:           "bitcoincash",
-		pubKeyHashAddrID: 0x00, // starts with 1
-		scriptHashAddrID: 0x05, // starts with 3
-		privateKeyID:     0x80, // starts with 5 (uncompressed) or K (compressed)
+		Name:             "mainnet",
+		GenesisHash:      "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		Magic:            0xe8f3e1e3,
+		DefaultPort:      8333,
+		RPCPort:          8332,
+		PubKeyHashAddrID: []byte{0x00}, // starts with 1
+		ScriptHashAddrID: []byte{0x05}, // starts with 3
+		PrivateKeyID:     []byte{0x80}, // starts with 5 (uncompressed) or K (compressed)
+		HDPrivateKeyID:   [4]byte{0x04, 0x88, 0xad, 0xe4},
+		HDPublicKeyID:    [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		CashAddrPrefix:   "bitcoincash",
 	}
-	// BCH_TestNet represents the bitcoin test net
+	// BCH_TestNet represents the bitcoin cash test net
 	BCH_TestNet = &bchParams{
-		prefix:           "bchtest",
-		pubKeyHashAddrID: 0x6f,
-		scriptHashAddrID: 0xc4,
-		privateKeyID:     0xef,
+		Name:             "testnet3",
+		GenesisHash:      "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+		Magic:            0xf4f3e5f4,
+		DefaultPort:      18333,
+		RPCPort:          18332,
+		PubKeyHashAddrID: []byte{0x6f},
+		ScriptHashAddrID: []byte{0xc4},
+		PrivateKeyID:     []byte{0xef},
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		CashAddrPrefix:   "bchtest",
 	}
-	// BCH_RegressionNet represents the bitcoin regression test net
+	// BCH_RegressionNet represents the bitcoin cash regression test net
 	BCH_RegressionNet = &bchParams{
-		prefix:           "bchreg",
-		pubKeyHashAddrID: 0x6f,
-		scriptHashAddrID: 0xc4,
-		privateKeyID:     0xef,
+		Name:             "regtest",
+		GenesisHash:      "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+		Magic:            0xfabfb5da,
+		DefaultPort:      18444,
+		RPCPort:          18443,
+		PubKeyHashAddrID: []byte{0x6f},
+		ScriptHashAddrID: []byte{0xc4},
+		PrivateKeyID:     []byte{0xef},
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		CashAddrPrefix:   "bchreg",
 	}
-	// BCH_SimNet represents the bitcoin simulation net
+	// BCH_SimNet represents the bitcoin cash simulation net
 	BCH_SimNet = &bchParams{
-		prefix:           "bchsim",
-		pubKeyHashAddrID: 0x3f,
-		scriptHashAddrID: 0x7b,
-		privateKeyID:     0x64,
+		Name:             "simnet",
+		GenesisHash:      "683e86bd5c6d110d91b94b97137ba6bfe02dbbdb8e3dff722a669b5d69d77af6",
+		Magic:            0x12141c16,
+		DefaultPort:      18555,
+		RPCPort:          18556,
+		PubKeyHashAddrID: []byte{0x3f},
+		ScriptHashAddrID: []byte{0x7b},
+		PrivateKeyID:     []byte{0x64},
+		HDPrivateKeyID:   [4]byte{0x04, 0x20, 0xb9, 0x00},
+		HDPublicKeyID:    [4]byte{0x04, 0x20, 0xbd, 0x3a},
+		CashAddrPrefix:   "bchsim",
 	}
 )
 
-type bchParams struct {
-	prefix           string
-	pubKeyHashAddrID byte // First byte of a P2PKH address
-	scriptHashAddrID byte // First byte of a P2SH address
-	privateKeyID     byte // First byte of a WIF private key
-}
+type bchParams Network
+
+// Network returns the network parameters
+func (p *bchParams) Network() *Network { return (*Network)(p) }
 
 func (p *bchParams) params() *chaincfg.Params {
 	return &chaincfg.Params{
-		CashAddressPrefix:      p.prefix,
-		LegacyPubKeyHashAddrID: p.pubKeyHashAddrID,
-		LegacyScriptHashAddrID: p.scriptHashAddrID,
-		PrivateKeyID:           p.privateKeyID,
+		Name:                   p.Name,
+		CashAddressPrefix:      p.CashAddrPrefix,
+		LegacyPubKeyHashAddrID: p.PubKeyHashAddrID[0],
+		LegacyScriptHashAddrID: p.ScriptHashAddrID[0],
+		PrivateKeyID:           p.PrivateKeyID[0],
 	}
 }
 
@@ -65,12 +95,26 @@ func (p *bchParams) P2PK(pub []byte) (string, error) {
 	return r.String(), nil
 }
 
-// P2PKH returns the p2pkh address for a key hash
+// cashAddr returns an address with the cashaddr prefix
+func (p *bchParams) cashAddr(a bchutil.Address) string {
+	return p.CashAddrPrefix + ":" + a.EncodeAddress()
+}
+
+// P2PKH returns the cashaddr p2pkh address for a key hash
 func (p *bchParams) P2PKH(pubHash []byte) (string, error) {
 	r, err := bchutil.NewAddressPubKeyHash(pubHash, p.params())
 	if err != nil {
 		return "", err
 	}
+	return p.cashAddr(r), nil
+}
+
+// LegacyP2PKH returns the legacy p2pkh address for a key hash
+func (p *bchParams) LegacyP2PKH(pubHash []byte) (string, error) {
+	r, err := bchutil.NewLegacyAddressPubKeyHash(pubHash, p.params())
+	if err != nil {
+		return "", err
+	}
 	return r.String(), nil
 }
 
@@ -79,12 +123,21 @@ func (p *bchParams) P2PKHFromKey(pub []byte) (string, error) {
 	return p.P2PKH(hash.NewBCH().Hash160(pub))
 }
 
-// P2SH returns the p2sh address for a script hash
+// P2SH returns the cashaddr p2sh address for a script hash
 func (p *bchParams) P2SH(scriptHash []byte) (string, error) {
 	r, err := bchutil.NewAddressScriptHashFromHash(scriptHash, p.params())
 	if err != nil {
 		return "", err
 	}
+	return p.cashAddr(r), nil
+}
+
+// LegacyP2SH returns the legacy p2sh address for a script hash
+func (p *bchParams) LegacyP2SH(scriptHash []byte) (string, error) {
+	r, err := bchutil.NewLegacyAddressScriptHashFromHash(scriptHash, p.params())
+	if err != nil {
+		return "", err
+	}
 	return r.String(), nil
 }
 
@@ -93,12 +146,38 @@ func (p *bchParams) P2SHFromScript(script []byte) (string, error) {
 	return p.P2SH(hash.NewBCH().Hash160(script))
 }
 
-// AddressToScript converts an address to a script
+var errWrongNetwork = errors.New("address for the wrong network")
+
+// legacyAddressToScript converts a legacy address to a script
+func (p *bchParams) legacyAddressToScript(addr string) ([]byte, bool, error) {
+	h, ver, err := base58.CheckDecode(addr)
+	if err != nil || len(h) != 20 {
+		return nil, false, nil
+	}
+	gen := script.NewGeneratorBTC()
+	switch ver {
+	case p.PubKeyHashAddrID[0]:
+		return gen.P2PKHHash(h), true, nil
+	case p.ScriptHashAddrID[0]:
+		return gen.P2SHHash(h), true, nil
+	default:
+		return nil, true, errWrongNetwork
+	}
+}
+
+// AddressToScript converts a cashaddr (with or without prefix) or a legacy
+// address to a script
 func (p *bchParams) AddressToScript(addr string) ([]byte, error) {
+	if r, ok, err := p.legacyAddressToScript(addr); ok {
+		return r, err
+	}
 	a, err := bchutil.DecodeAddress(addr, p.params())
 	if err != nil {
 		return nil, err
 	}
+	if !a.IsForNet(p.params()) {
+		return nil, errWrongNetwork
+	}
 	gen := script.NewGeneratorBTC()
 	switch aa := a.(type) {
 	case *bchutil.AddressPubKeyHash:
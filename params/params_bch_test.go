@@ -0,0 +1,56 @@
+package params
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/script"
+)
+
+func TestCashAddr(t *testing.T) {
+	h, err := hex.DecodeString("76a04053bda0a88bda5177b86a15c3b29f559873")
+	require.NoError(t, err, "can't decode hash")
+	gen := script.NewGeneratorBTC()
+	for _, i := range []struct {
+		addr   func([]byte) (string, error)
+		legacy func([]byte) (string, error)
+		exp    string
+		expLeg string
+		script []byte
+	}{
+		{
+			BCH_MainNet.P2PKH,
+			BCH_MainNet.LegacyP2PKH,
+			"bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a",
+			"1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu",
+			gen.P2PKHHash(h),
+		},
+		{
+			BCH_MainNet.P2SH,
+			BCH_MainNet.LegacyP2SH,
+			"bitcoincash:ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq",
+			"3CWFddi6m4ndiGyKqzYvsFYagqDLPVMTzC",
+			gen.P2SHHash(h),
+		},
+	} {
+		addr, err := i.addr(h)
+		require.NoError(t, err, "can't create address")
+		require.Equal(t, i.exp, addr, "address mismatch")
+		leg, err := i.legacy(h)
+		require.NoError(t, err, "can't create legacy address")
+		require.Equal(t, i.expLeg, leg, "legacy address mismatch")
+		for _, j := range []string{addr, addr[len("bitcoincash:"):], leg} {
+			s, err := BCH_MainNet.AddressToScript(j)
+			require.NoError(t, err, "can't convert address")
+			require.Equal(t, i.script, s, "script mismatch")
+		}
+	}
+	tn, err := BCH_TestNet.P2PKH(h)
+	require.NoError(t, err, "can't create address")
+	require.Equal(t, "bchtest:", tn[:8], "prefix mismatch")
+	_, err = BCH_MainNet.AddressToScript(tn)
+	require.Error(t, err, "expecting wrong network")
+	_, err = BCH_TestNet.AddressToScript("1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu")
+	require.Equal(t, errWrongNetwork, err, "expecting wrong network")
+}
@@ -2,6 +2,7 @@ package params
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil"
@@ -10,45 +11,78 @@ import (
 )
 
 var (
-	_ Params = (*btcParams)(nil)
+	_ SegwitParams = (*btcParams)(nil)
 
 	// BTC_MainNet represents the bitcoin main net
 	BTC_MainNet = &btcParams{
-		pubKeyHashAddrID: 0x00, // starts with 1
-		scriptHashAddrID: 0x05, // starts with 3
-		privateKeyID:     0x80, // starts with 5 (uncompressed) or K (compressed)
+		Name:             "mainnet",
+		GenesisHash:      "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		Magic:            0xd9b4bef9,
+		DefaultPort:      8333,
+		RPCPort:          8332,
+		PubKeyHashAddrID: []byte{0x00}, // starts with 1
+		ScriptHashAddrID: []byte{0x05}, // starts with 3
+		PrivateKeyID:     []byte{0x80}, // starts with 5 (uncompressed) or K (compressed)
+		HDPrivateKeyID:   [4]byte{0x04, 0x88, 0xad, 0xe4},
+		HDPublicKeyID:    [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		Bech32HRP:        "bc",
 	}
 	// BTC_TestNet represents the bitcoin test net
 	BTC_TestNet = &btcParams{
-		pubKeyHashAddrID: 0x6f, // starts with m or n
-		scriptHashAddrID: 0xc4, // starts with 2
-		privateKeyID:     0xef, // starts with 9 (uncompressed) or c (compressed)
+		Name:             "testnet3",
+		GenesisHash:      "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+		Magic:            0x0709110b,
+		DefaultPort:      18333,
+		RPCPort:          18332,
+		PubKeyHashAddrID: []byte{0x6f}, // starts with m or n
+		ScriptHashAddrID: []byte{0xc4}, // starts with 2
+		PrivateKeyID:     []byte{0xef}, // starts with 9 (uncompressed) or c (compressed)
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		Bech32HRP:        "tb",
 	}
 	// BTC_RegressionNet represents the bitcoin regression test net
 	BTC_RegressionNet = &btcParams{
-		pubKeyHashAddrID: 0x6f, // starts with m or n
-		scriptHashAddrID: 0xc4, // starts with 2
-		privateKeyID:     0xef, // starts with 9 (uncompressed) or c (compressed)
+		Name:             "regtest",
+		GenesisHash:      "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+		Magic:            0xdab5bffa,
+		DefaultPort:      18444,
+		RPCPort:          18443,
+		PubKeyHashAddrID: []byte{0x6f}, // starts with m or n
+		ScriptHashAddrID: []byte{0xc4}, // starts with 2
+		PrivateKeyID:     []byte{0xef}, // starts with 9 (uncompressed) or c (compressed)
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		Bech32HRP:        "bcrt",
 	}
 	// BTC_SimNet represents the bitcoin simulation net
 	BTC_SimNet = &btcParams{
-		pubKeyHashAddrID: 0x3f, // starts with S
-		scriptHashAddrID: 0x7b, // starts with s
-		privateKeyID:     0x64, // starts with 4 (uncompressed) or F (compressed)
+		Name:             "simnet",
+		GenesisHash:      "683e86bd5c6d110d91b94b97137ba6bfe02dbbdb8e3dff722a669b5d69d77af6",
+		Magic:            0x12141c16,
+		DefaultPort:      18555,
+		RPCPort:          18556,
+		PubKeyHashAddrID: []byte{0x3f}, // starts with S
+		ScriptHashAddrID: []byte{0x7b}, // starts with s
+		PrivateKeyID:     []byte{0x64}, // starts with 4 (uncompressed) or F (compressed)
+		HDPrivateKeyID:   [4]byte{0x04, 0x20, 0xb9, 0x00},
+		HDPublicKeyID:    [4]byte{0x04, 0x20, 0xbd, 0x3a},
+		Bech32HRP:        "sb",
 	}
 )
 
-type btcParams struct {
-	pubKeyHashAddrID byte // First byte of a P2PKH address
-	scriptHashAddrID byte // First byte of a P2SH address
-	privateKeyID     byte // First byte of a WIF private key
-}
+type btcParams Network
+
+// Network returns the network parameters
+func (p *btcParams) Network() *Network { return (*Network)(p) }
 
 func (p *btcParams) params() *chaincfg.Params {
 	return &chaincfg.Params{
-		PubKeyHashAddrID: p.pubKeyHashAddrID,
-		ScriptHashAddrID: p.scriptHashAddrID,
-		PrivateKeyID:     p.privateKeyID,
+		Name:             p.Name,
+		PubKeyHashAddrID: p.PubKeyHashAddrID[0],
+		ScriptHashAddrID: p.ScriptHashAddrID[0],
+		PrivateKeyID:     p.PrivateKeyID[0],
+		Bech32HRPSegwit:  p.Bech32HRP,
 	}
 }
 
@@ -89,10 +123,64 @@ func (p *btcParams) P2SHFromScript(script []byte) (string, error) {
 	return p.P2SH(hash.NewBTC().Hash160(script))
 }
 
+// segwitAddress returns the segwit address of a witness program
+func (p *btcParams) segwitAddress(version byte, prog []byte) (string, error) {
+	if p.Bech32HRP == "" {
+		return "", errSegwitNotSupported
+	}
+	return encodeSegwitAddress(p.Bech32HRP, version, prog)
+}
+
+// P2WPKH returns the p2wpkh address for a key hash
+func (p *btcParams) P2WPKH(pubHash []byte) (string, error) {
+	if len(pubHash) != 20 {
+		return "", errInvalidWitnessProg
+	}
+	return p.segwitAddress(0, pubHash)
+}
+
+// P2WSH returns the p2wsh address for a script hash
+func (p *btcParams) P2WSH(scriptHash []byte) (string, error) {
+	if len(scriptHash) != 32 {
+		return "", errInvalidWitnessProg
+	}
+	return p.segwitAddress(0, scriptHash)
+}
+
+// P2TR returns the p2tr address for an x-only output key
+func (p *btcParams) P2TR(outputKey []byte) (string, error) {
+	if len(outputKey) != 32 {
+		return "", errInvalidWitnessProg
+	}
+	return p.segwitAddress(1, outputKey)
+}
+
+// segwitAddressToScript converts a segwit address to a script
+func (p *btcParams) segwitAddressToScript(addr string) ([]byte, error) {
+	ver, prog, err := decodeSegwitAddress(p.Bech32HRP, addr)
+	if err != nil {
+		return nil, err
+	}
+	gen := script.NewGeneratorBTC()
+	switch {
+	case ver == 0 && len(prog) == 20:
+		return gen.P2WPKHHash(prog), nil
+	case ver == 0:
+		return gen.P2WSHHash(prog), nil
+	case ver == 1 && len(prog) == 32:
+		return gen.P2TRKey(prog), nil
+	default:
+		return gen.WitnessProgram(int64(ver), prog), nil
+	}
+}
+
 var errNotSupported = errors.New("not supported")
 
 // AddressToScript converts an address to a script
 func (p *btcParams) AddressToScript(addr string) ([]byte, error) {
+	if p.Bech32HRP != "" && strings.HasPrefix(strings.ToLower(addr), p.Bech32HRP+"1") {
+		return p.segwitAddressToScript(addr)
+	}
 	a, err := btcutil.DecodeAddress(addr, p.params())
 	if err != nil {
 		return nil, err
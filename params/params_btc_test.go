@@ -0,0 +1,68 @@
+package params
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/script"
+)
+
+func TestSegwitAddresses(t *testing.T) {
+	for _, i := range []struct {
+		p      *btcParams
+		addr   string
+		script string
+	}{
+		{BTC_MainNet, "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{BTC_TestNet, "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{BTC_MainNet, "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{BTC_MainNet, "BC1SW50QGDZ25J", "6002751e"},
+		{BTC_MainNet, "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", "5210751e76e8199196d454941c45d1b3a323"},
+		{BTC_MainNet, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	} {
+		s, err := i.p.AddressToScript(i.addr)
+		require.NoError(t, err, "can't convert address: %s", i.addr)
+		require.Equal(t, i.script, hex.EncodeToString(s), "script mismatch: %s", i.addr)
+	}
+	for _, i := range []string{
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd",
+		"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5",
+		"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7",
+	} {
+		_, err := BTC_MainNet.AddressToScript(i)
+		require.Error(t, err, "expecting invalid address: %s", i)
+	}
+	h, err := hex.DecodeString("751e76e8199196d454941c45d1b3a323f1433bd6")
+	require.NoError(t, err, "can't decode hash")
+	addr, err := BTC_MainNet.P2WPKH(h)
+	require.NoError(t, err, "can't create address")
+	require.Equal(t, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", addr, "address mismatch")
+	k, err := hex.DecodeString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	require.NoError(t, err, "can't decode key")
+	addr, err = BTC_MainNet.P2TR(k)
+	require.NoError(t, err, "can't create address")
+	require.Equal(t, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", addr, "address mismatch")
+	addr, err = LTC_MainNet.P2WPKH(h)
+	require.NoError(t, err, "can't create address")
+	require.Equal(t, "ltc1", addr[:4], "prefix mismatch")
+	_, err = DOGE_MainNet.P2WPKH(h)
+	require.Equal(t, errSegwitNotSupported, err, "expecting segwit not supported")
+}
+
+func TestSegwitGenerators(t *testing.T) {
+	for _, i := range []struct {
+		gen    script.Generator
+		segwit bool
+	}{
+		{script.NewGeneratorBTC(), true},
+		{script.NewGeneratorLTC(), true},
+		{script.NewGeneratorDOGE(), false},
+		{script.NewGeneratorBCH(), false},
+		{script.NewGeneratorDCR(), false},
+	} {
+		_, ok := i.gen.(script.SegwitGenerator)
+		require.Equal(t, i.segwit, ok, "segwit mismatch")
+	}
+}
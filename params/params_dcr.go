@@ -11,43 +11,73 @@ import (
 var (
 	_ Params = (*dcrParams)(nil)
 
-	// DCR_MainNet represents the bitcoin main net
+	// DCR_MainNet represents the decred main net
 	DCR_MainNet = &dcrParams{
-		pubKeyHashAddrID: [2]byte{0x07, 0x3f},
-		scriptHashAddrID: [2]byte{0x07, 0x1a},
-		privateKeyID:     [2]byte{0x22, 0xde},
+		Name:             "mainnet",
+		GenesisHash:      "298e5cc3d985bfe7f81dc135f360abe089edd4396b86d2de66b0cef42b21d980",
+		Magic:            0xd9b400f9,
+		DefaultPort:      9108,
+		RPCPort:          9109,
+		PubKeyHashAddrID: []byte{0x07, 0x3f},
+		ScriptHashAddrID: []byte{0x07, 0x1a},
+		PrivateKeyID:     []byte{0x22, 0xde},
+		HDPrivateKeyID:   [4]byte{0x02, 0xfd, 0xa4, 0xe8},
+		HDPublicKeyID:    [4]byte{0x02, 0xfd, 0xa9, 0x26},
 	}
-	// DCR_TestNet represents the bitcoin test net
+	// DCR_TestNet represents the decred test net
 	DCR_TestNet = &dcrParams{
-		pubKeyHashAddrID: [2]byte{0x0f, 0x21},
-		scriptHashAddrID: [2]byte{0x0e, 0xfc},
-		privateKeyID:     [2]byte{0x23, 0x0e},
+		Name:             "testnet3",
+		GenesisHash:      "a649dce53918caf422e9c711c858837e08d626ecfcd198969b24f7b634a49bac",
+		Magic:            0xb194aa75,
+		DefaultPort:      19108,
+		RPCPort:          19109,
+		PubKeyHashAddrID: []byte{0x0f, 0x21},
+		ScriptHashAddrID: []byte{0x0e, 0xfc},
+		PrivateKeyID:     []byte{0x23, 0x0e},
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x97},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xd1},
 	}
-	// DCR_RegressionNet represents the bitcoin regression test net
+	// DCR_RegressionNet represents the decred regression test net
 	DCR_RegressionNet = &dcrParams{
-		pubKeyHashAddrID: [2]byte{0x0e, 0x00},
-		scriptHashAddrID: [2]byte{0x0d, 0xdb},
-		privateKeyID:     [2]byte{0x22, 0xfe},
+		Name:             "regnet",
+		GenesisHash:      "2ced94b4ae95bba344cfa043268732d230649c640f92dce2d9518823d3057cb0",
+		Magic:            0xdab500fa,
+		DefaultPort:      18655,
+		RPCPort:          18656,
+		PubKeyHashAddrID: []byte{0x0e, 0x00},
+		ScriptHashAddrID: []byte{0x0d, 0xdb},
+		PrivateKeyID:     []byte{0x22, 0xfe},
+		HDPrivateKeyID:   [4]byte{0xea, 0xb4, 0x04, 0x48},
+		HDPublicKeyID:    [4]byte{0xea, 0xb4, 0xf9, 0x87},
 	}
-	// DCR_SimNet represents the bitcoin simulation net
+	// DCR_SimNet represents the decred simulation net
 	DCR_SimNet = &dcrParams{
-		pubKeyHashAddrID: [2]byte{0x0e, 0x91},
-		scriptHashAddrID: [2]byte{0x0e, 0x6c},
-		privateKeyID:     [2]byte{0x23, 0x07},
+		Name:             "simnet",
+		GenesisHash:      "5bec7567af40504e0994db3b573c186fffcc4edefe096ff2e58d00523bd7e8a6",
+		Magic:            0x12141c16,
+		DefaultPort:      18555,
+		RPCPort:          19556,
+		PubKeyHashAddrID: []byte{0x0e, 0x91},
+		ScriptHashAddrID: []byte{0x0e, 0x6c},
+		PrivateKeyID:     []byte{0x23, 0x07},
+		HDPrivateKeyID:   [4]byte{0x04, 0x20, 0xb9, 0x03},
+		HDPublicKeyID:    [4]byte{0x04, 0x20, 0xbd, 0x3d},
 	}
 )
 
-type dcrParams struct {
-	pubKeyHashAddrID [2]byte
-	scriptHashAddrID [2]byte
-	privateKeyID     [2]byte
-}
+type dcrParams Network
+
+// Network returns the network parameters
+func (p *dcrParams) Network() *Network { return (*Network)(p) }
+
+func addrID(b []byte) [2]byte { return [2]byte{b[0], b[1]} }
 
 func (p *dcrParams) params() *chaincfg.Params {
 	return &chaincfg.Params{
-		PubKeyHashAddrID: p.pubKeyHashAddrID,
-		ScriptHashAddrID: p.scriptHashAddrID,
-		PrivateKeyID:     p.privateKeyID,
+		Name:             p.Name,
+		PubKeyHashAddrID: addrID(p.PubKeyHashAddrID),
+		ScriptHashAddrID: addrID(p.ScriptHashAddrID),
+		PrivateKeyID:     addrID(p.PrivateKeyID),
 	}
 }
 
@@ -5,20 +5,41 @@ type dogeParams = btcParams
 var (
 	// DOGE_MainNet represents the dogecoin main net
 	DOGE_MainNet = &dogeParams{
-		pubKeyHashAddrID: 0x1E,
-		scriptHashAddrID: 0x16,
-		privateKeyID:     0x9E,
+		Name:             "mainnet",
+		GenesisHash:      "1a91e3dace36e2be3bf030a65679fe821aa1d6ef92e7c9902eb318182c355691",
+		Magic:            0xc0c0c0c0,
+		DefaultPort:      22556,
+		RPCPort:          22555,
+		PubKeyHashAddrID: []byte{0x1e},
+		ScriptHashAddrID: []byte{0x16},
+		PrivateKeyID:     []byte{0x9e},
+		HDPrivateKeyID:   [4]byte{0x02, 0xfa, 0xc3, 0x98},
+		HDPublicKeyID:    [4]byte{0x02, 0xfa, 0xca, 0xfd},
 	}
 	// DOGE_TestNet represents the dogecoin test net
 	DOGE_TestNet = &dogeParams{
-		pubKeyHashAddrID: 0x71,
-		scriptHashAddrID: 0xC4,
-		privateKeyID:     0xF1,
+		Name:             "testnet3",
+		GenesisHash:      "bb0a78264637406b6360aad926284d544d7049f45189db5664f3c4d07350559e",
+		Magic:            0xdcb7c1fc,
+		DefaultPort:      44556,
+		RPCPort:          44555,
+		PubKeyHashAddrID: []byte{0x71},
+		ScriptHashAddrID: []byte{0xc4},
+		PrivateKeyID:     []byte{0xf1},
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
 	}
 	// DOGE_RegressionNet represents the dogecoin regression test net
 	DOGE_RegressionNet = &dogeParams{
-		pubKeyHashAddrID: 0x6F,
-		scriptHashAddrID: 0xc4,
-		privateKeyID:     0xEF,
+		Name:             "regtest",
+		GenesisHash:      "3d2160a3b5dc4a9d62e7e66a295f70313ac808440ef7400d6c0772171ce973a5",
+		Magic:            0xdab5bffa,
+		DefaultPort:      18444,
+		RPCPort:          18332,
+		PubKeyHashAddrID: []byte{0x6f},
+		ScriptHashAddrID: []byte{0xc4},
+		PrivateKeyID:     []byte{0xef},
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
 	}
 )
@@ -5,26 +5,58 @@ type ltcParams = btcParams
 var (
 	// LTC_MainNet represents the litecoin main net
 	LTC_MainNet = &ltcParams{
-		pubKeyHashAddrID: 0x30, // starts with L
-		scriptHashAddrID: 0x32, // starts with M
-		privateKeyID:     0xB0, // starts with 6 (uncompressed) or T (compressed)
+		Name:             "mainnet",
+		GenesisHash:      "12a765e31ffd4059bada1e25190f6e98c99d9714d334efa41a195a7e7e04bfe2",
+		Magic:            0xdbb6c0fb,
+		DefaultPort:      9333,
+		RPCPort:          9332,
+		PubKeyHashAddrID: []byte{0x30}, // starts with L
+		ScriptHashAddrID: []byte{0x32}, // starts with M
+		PrivateKeyID:     []byte{0xb0}, // starts with 6 (uncompressed) or T (compressed)
+		HDPrivateKeyID:   [4]byte{0x04, 0x88, 0xad, 0xe4},
+		HDPublicKeyID:    [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		Bech32HRP:        "ltc",
 	}
 	// LTC_TestNet represents the litecoin test net
 	LTC_TestNet = &ltcParams{
-		pubKeyHashAddrID: 0x6f, // starts with m or n
-		scriptHashAddrID: 0x3a, // starts with Q
-		privateKeyID:     0xef, // starts with 9 (uncompressed) or c (compressed)
+		Name:             "testnet4",
+		GenesisHash:      "4966625a4b2851d9fdee139e56211a0d88575f59ed816ff5e6a63deb4e3e29a0",
+		Magic:            0xf1c8d2fd,
+		DefaultPort:      19335,
+		RPCPort:          19332,
+		PubKeyHashAddrID: []byte{0x6f}, // starts with m or n
+		ScriptHashAddrID: []byte{0x3a}, // starts with Q
+		PrivateKeyID:     []byte{0xef}, // starts with 9 (uncompressed) or c (compressed)
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		Bech32HRP:        "tltc",
 	}
 	// LTC_SimNet represents the litecoin simulation net
 	LTC_SimNet = &ltcParams{
-		pubKeyHashAddrID: 0x3f, // starts with S
-		scriptHashAddrID: 0x7b, // starts with s
-		privateKeyID:     0x64, // starts with 4 (uncompressed) or F (compressed)
+		Name:             "simnet",
+		GenesisHash:      "f67ad7695d9b662a72ff3d8edbbb2de0bfa67b13974bb9910d116d5cbd863e68",
+		Magic:            0x12141c16,
+		DefaultPort:      18555,
+		RPCPort:          18556,
+		PubKeyHashAddrID: []byte{0x3f}, // starts with S
+		ScriptHashAddrID: []byte{0x7b}, // starts with s
+		PrivateKeyID:     []byte{0x64}, // starts with 4 (uncompressed) or F (compressed)
+		HDPrivateKeyID:   [4]byte{0x04, 0x20, 0xb9, 0x00},
+		HDPublicKeyID:    [4]byte{0x04, 0x20, 0xbd, 0x3a},
+		Bech32HRP:        "sltc",
 	}
 	// LTC_RegressionNet represents the litecoin regression test net
 	LTC_RegressionNet = &ltcParams{
-		pubKeyHashAddrID: 0x6f, // starts with m or n
-		scriptHashAddrID: 0x3a, // starts with Q
-		privateKeyID:     0xef, // starts with 9 (uncompressed) or c (compressed)
+		Name:             "regtest",
+		GenesisHash:      "530827f38f93b43ed12af0b3ad25a288dc02ed74d6d7857862df51fc56c416f9",
+		Magic:            0xdab5bffa,
+		DefaultPort:      19444,
+		RPCPort:          19443,
+		PubKeyHashAddrID: []byte{0x6f}, // starts with m or n
+		ScriptHashAddrID: []byte{0x3a}, // starts with Q
+		PrivateKeyID:     []byte{0xef}, // starts with 9 (uncompressed) or c (compressed)
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		Bech32HRP:        "rltc",
 	}
 )
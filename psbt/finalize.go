@@ -0,0 +1,94 @@
+package psbt
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/tx"
+)
+
+var (
+	// ErrMissingSignature is returned when an input has no signature
+	ErrMissingSignature = errors.New("missing signature")
+
+	// ErrTooManySignatures is returned when an input has more than one signature
+	ErrTooManySignatures = errors.New("too many signatures")
+
+	// ErrMissingRedeemScript is returned when an input has no redeem script
+	ErrMissingRedeemScript = errors.New("missing redeem script")
+
+	// ErrInvalidPreimage is returned when a preimage doesn't match the hash
+	ErrInvalidPreimage = errors.New("invalid preimage")
+)
+
+// finalizeInput returns the final signature script of an htlc input. If a
+// preimage is present the input is redeemed, otherwise it's recovered
+func finalizeInput(gen script.Generator, in *Input) ([]byte, error) {
+	switch {
+	case len(in.FinalScriptSig) > 0:
+		return in.FinalScriptSig, nil
+	case len(in.PartialSigs) == 0:
+		return nil, ErrMissingSignature
+	case len(in.PartialSigs) > 1:
+		return nil, ErrTooManySignatures
+	case len(in.RedeemScript) == 0:
+		return nil, ErrMissingRedeemScript
+	}
+	sig := in.PartialSigs[0]
+	if len(in.Preimages) == 0 {
+		return gen.HTLCRecover(sig.Signature, sig.PubKey, in.RedeemScript), nil
+	}
+	pi := in.Preimages[0]
+	if !bytes.Equal(hash.Ripemd160Sum(hash.Sha256Sum(pi.Preimage)), pi.Hash) {
+		return nil, ErrInvalidPreimage
+	}
+	return gen.HTLCRedeem(sig.Signature, sig.PubKey, pi.Preimage, in.RedeemScript), nil
+}
+
+// Finalize sets the final signature scripts of the htlc inputs and returns
+// the signed transaction
+func (p *Packet) Finalize() (tx.Tx, error) {
+	gen, err := script.NewGenerator(p.Crypto)
+	if err != nil {
+		return nil, err
+	}
+	finals := make([][]byte, 0, len(p.Inputs))
+	for _, in := range p.Inputs {
+		s, err := finalizeInput(gen, in)
+		if err != nil {
+			return nil, err
+		}
+		finals = append(finals, s)
+	}
+	r := p.Tx.Copy()
+	txUTXO, _ := r.TxUTXO()
+	// verify the scripts if the spent outputs are known
+	prevOuts := make([]*tx.Output, 0, len(p.Inputs))
+	for i := range p.Inputs {
+		txUTXO.SetInputSignatureScript(i, finals[i])
+		out, err := p.spentOutput(i)
+		if err != nil {
+			return nil, err
+		}
+		if out != nil {
+			txUTXO.SetInputAmount(i, out.Value)
+			prevOuts = append(prevOuts, out)
+		}
+	}
+	if len(prevOuts) == len(p.Inputs) {
+		if err = tx.Verify(r, prevOuts); err != nil {
+			return nil, err
+		}
+	}
+	for i, in := range p.Inputs {
+		*in = Input{
+			NonWitnessUTXO: in.NonWitnessUTXO,
+			WitnessUTXO:    in.WitnessUTXO,
+			FinalScriptSig: finals[i],
+			Unknown:        in.Unknown,
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,445 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// partially signed transactions (BIP174)
+
+const (
+	globalUnsignedTx = 0x00
+
+	inputNonWitnessUTXO = 0x00
+	inputWitnessUTXO    = 0x01
+	inputPartialSig     = 0x02
+	inputSigHashType    = 0x03
+	inputRedeemScript   = 0x04
+	inputBIP32          = 0x06
+	inputFinalScriptSig = 0x07
+	inputHash160        = 0x0c
+)
+
+var magic = []byte{'p', 's', 'b', 't', 0xff}
+
+var (
+	// ErrInvalidMagic is returned when the magic bytes are missing
+	ErrInvalidMagic = errors.New("invalid psbt magic")
+
+	// ErrMissingUnsignedTx is returned when the unsigned transaction is missing
+	ErrMissingUnsignedTx = errors.New("missing unsigned transaction")
+
+	// ErrDuplicateKey is returned when a key is repeated in a map
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	// ErrInvalidValue is returned when a value can't be parsed
+	ErrInvalidValue = errors.New("invalid value")
+
+	// ErrSignedTx is returned when the unsigned transaction has signature scripts
+	ErrSignedTx = errors.New("transaction is signed")
+
+	// ErrTrailingData is returned when there's data after the last map
+	ErrTrailingData = errors.New("trailing data")
+)
+
+type (
+	// Packet represents a partially signed transaction
+	Packet struct {
+		Crypto  *cryptos.Crypto
+		Tx      tx.Tx
+		Inputs  []*Input
+		Outputs []*Output
+		Unknown []*KeyValue
+	}
+
+	// Input represents the data of an input. The legacy inputs carry the
+	// previous transaction as the non-witness utxo
+	Input struct {
+		NonWitnessUTXO tx.Tx
+		WitnessUTXO    *tx.Output
+		PartialSigs    []*PartialSig
+		SigHashType    uint32
+		RedeemScript   types.Bytes
+		Derivations    []*Derivation
+		FinalScriptSig types.Bytes
+		Preimages      []*Preimage
+		Unknown        []*KeyValue
+	}
+
+	// Output represents the data of an output
+	Output struct {
+		Unknown []*KeyValue
+	}
+
+	// PartialSig represents a signature for an input
+	PartialSig struct {
+		PubKey    types.Bytes
+		Signature types.Bytes
+	}
+
+	// Derivation represents the derivation of a public key
+	Derivation struct {
+		PubKey      types.Bytes
+		Fingerprint uint32
+		Path        []uint32
+	}
+
+	// Preimage represents a hash160 preimage
+	Preimage struct {
+		Hash     types.Bytes
+		Preimage types.Bytes
+	}
+
+	// KeyValue represents an unknown key/value pair
+	KeyValue struct {
+		Key   types.Bytes
+		Value types.Bytes
+	}
+)
+
+// New returns a new packet for the unsigned transaction
+func New(t tx.Tx) (*Packet, error) {
+	txUTXO, ok := t.TxUTXO()
+	if !ok {
+		return nil, tx.ErrNotUTXO
+	}
+	for _, i := range txUTXO.Inputs() {
+		if len(i.SignatureScript) > 0 || len(i.Witness) > 0 {
+			return nil, ErrSignedTx
+		}
+	}
+	r := &Packet{
+		Crypto:  t.Crypto(),
+		Tx:      t,
+		Inputs:  make([]*Input, 0, len(txUTXO.Inputs())),
+		Outputs: make([]*Output, 0, len(txUTXO.Outputs())),
+	}
+	for range txUTXO.Inputs() {
+		r.Inputs = append(r.Inputs, &Input{})
+	}
+	for range txUTXO.Outputs() {
+		r.Outputs = append(r.Outputs, &Output{})
+	}
+	return r, nil
+}
+
+func writeCompactSize(w *bytes.Buffer, n uint64) {
+	var b [8]byte
+	switch {
+	case n < 0xfd:
+		w.WriteByte(byte(n))
+	case n <= 0xffff:
+		w.WriteByte(0xfd)
+		binary.LittleEndian.PutUint16(b[:], uint16(n))
+		w.Write(b[:2])
+	case n <= 0xffffffff:
+		w.WriteByte(0xfe)
+		binary.LittleEndian.PutUint32(b[:], uint32(n))
+		w.Write(b[:4])
+	default:
+		w.WriteByte(0xff)
+		binary.LittleEndian.PutUint64(b[:], n)
+		w.Write(b[:8])
+	}
+}
+
+func readCompactSize(r *bytes.Reader) (uint64, error) {
+	p, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	var b [8]byte
+	switch p {
+	case 0xfd:
+		if _, err = io.ReadFull(r, b[:2]); err != nil {
+			return 0, err
+		}
+		return uint64(binary.LittleEndian.Uint16(b[:])), nil
+	case 0xfe:
+		if _, err = io.ReadFull(r, b[:4]); err != nil {
+			return 0, err
+		}
+		return uint64(binary.LittleEndian.Uint32(b[:])), nil
+	case 0xff:
+		if _, err = io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+		return binary.LittleEndian.Uint64(b[:]), nil
+	default:
+		return uint64(p), nil
+	}
+}
+
+func writeBytes(w *bytes.Buffer, b []byte) {
+	writeCompactSize(w, uint64(len(b)))
+	w.Write(b)
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := readCompactSize(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func writeKeyValue(w *bytes.Buffer, keyType byte, keyData []byte, value []byte) {
+	writeBytes(w, append([]byte{keyType}, keyData...))
+	writeBytes(w, value)
+}
+
+// readMap reads a map calling fn for each key/value pair
+func readMap(r *bytes.Reader, fn func(k, v []byte) error) error {
+	seen := make(map[string]struct{}, 8)
+	for {
+		k, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+		if len(k) == 0 {
+			return nil
+		}
+		if _, ok := seen[string(k)]; ok {
+			return ErrDuplicateKey
+		}
+		seen[string(k)] = struct{}{}
+		v, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+		if err = fn(k, v); err != nil {
+			return err
+		}
+	}
+}
+
+func writeUnknown(w *bytes.Buffer, kvs []*KeyValue) {
+	for _, i := range kvs {
+		writeBytes(w, i.Key)
+		writeBytes(w, i.Value)
+	}
+}
+
+func (in *Input) serialize(w *bytes.Buffer) error {
+	if in.NonWitnessUTXO != nil {
+		b, err := in.NonWitnessUTXO.Serialize()
+		if err != nil {
+			return err
+		}
+		writeKeyValue(w, inputNonWitnessUTXO, nil, b)
+	}
+	if in.WitnessUTXO != nil {
+		v := &bytes.Buffer{}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], in.WitnessUTXO.Value)
+		v.Write(b[:])
+		writeBytes(v, in.WitnessUTXO.Script)
+		writeKeyValue(w, inputWitnessUTXO, nil, v.Bytes())
+	}
+	for _, i := range in.PartialSigs {
+		writeKeyValue(w, inputPartialSig, i.PubKey, i.Signature)
+	}
+	if in.SigHashType != 0 {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], in.SigHashType)
+		writeKeyValue(w, inputSigHashType, nil, b[:])
+	}
+	if len(in.RedeemScript) > 0 {
+		writeKeyValue(w, inputRedeemScript, nil, in.RedeemScript)
+	}
+	for _, i := range in.Derivations {
+		v := make([]byte, 4+4*len(i.Path))
+		binary.LittleEndian.PutUint32(v, i.Fingerprint)
+		for j, p := range i.Path {
+			binary.LittleEndian.PutUint32(v[4+4*j:], p)
+		}
+		writeKeyValue(w, inputBIP32, i.PubKey, v)
+	}
+	if len(in.FinalScriptSig) > 0 {
+		writeKeyValue(w, inputFinalScriptSig, nil, in.FinalScriptSig)
+	}
+	for _, i := range in.Preimages {
+		writeKeyValue(w, inputHash160, i.Hash, i.Preimage)
+	}
+	writeUnknown(w, in.Unknown)
+	w.WriteByte(0)
+	return nil
+}
+
+func (in *Input) parseKeyValue(c *cryptos.Crypto, k, v []byte) error {
+	switch k[0] {
+	case inputNonWitnessUTXO:
+		if len(k) != 1 {
+			return ErrInvalidValue
+		}
+		t, err := tx.Parse(c, v)
+		if err != nil {
+			return ErrInvalidValue
+		}
+		in.NonWitnessUTXO = t
+	case inputWitnessUTXO:
+		if len(k) != 1 || len(v) < 9 {
+			return ErrInvalidValue
+		}
+		r := bytes.NewReader(v[8:])
+		s, err := readBytes(r)
+		if err != nil || r.Len() != 0 {
+			return ErrInvalidValue
+		}
+		in.WitnessUTXO = &tx.Output{Value: binary.LittleEndian.Uint64(v), Script: s}
+	case inputPartialSig:
+		if len(k) != 34 && len(k) != 66 {
+			return ErrInvalidValue
+		}
+		in.PartialSigs = append(in.PartialSigs, &PartialSig{PubKey: k[1:], Signature: v})
+	case inputSigHashType:
+		if len(k) != 1 || len(v) != 4 {
+			return ErrInvalidValue
+		}
+		in.SigHashType = binary.LittleEndian.Uint32(v)
+	case inputRedeemScript:
+		if len(k) != 1 {
+			return ErrInvalidValue
+		}
+		in.RedeemScript = v
+	case inputBIP32:
+		if (len(k) != 34 && len(k) != 66) || len(v) < 4 || len(v)%4 != 0 {
+			return ErrInvalidValue
+		}
+		d := &Derivation{
+			PubKey:      k[1:],
+			Fingerprint: binary.LittleEndian.Uint32(v),
+		}
+		for i := 4; i < len(v); i += 4 {
+			d.Path = append(d.Path, binary.LittleEndian.Uint32(v[i:]))
+		}
+		in.Derivations = append(in.Derivations, d)
+	case inputFinalScriptSig:
+		if len(k) != 1 {
+			return ErrInvalidValue
+		}
+		in.FinalScriptSig = v
+	case inputHash160:
+		if len(k) != 21 {
+			return ErrInvalidValue
+		}
+		in.Preimages = append(in.Preimages, &Preimage{Hash: k[1:], Preimage: v})
+	default:
+		in.Unknown = append(in.Unknown, &KeyValue{Key: k, Value: v})
+	}
+	return nil
+}
+
+// Serialize serializes the packet
+func (p *Packet) Serialize() ([]byte, error) {
+	b, err := p.Tx.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	w := bytes.NewBuffer(make([]byte, 0, 1024))
+	w.Write(magic)
+	writeKeyValue(w, globalUnsignedTx, nil, b)
+	writeUnknown(w, p.Unknown)
+	w.WriteByte(0)
+	for _, i := range p.Inputs {
+		if err = i.serialize(w); err != nil {
+			return nil, err
+		}
+	}
+	for _, i := range p.Outputs {
+		writeUnknown(w, i.Unknown)
+		w.WriteByte(0)
+	}
+	return w.Bytes(), nil
+}
+
+// Base64 returns the packet serialized as base64
+func (p *Packet) Base64() (string, error) {
+	b, err := p.Serialize()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Parse parses a serialized packet for the given crypto
+func Parse(c *cryptos.Crypto, b []byte) (*Packet, error) {
+	if !bytes.HasPrefix(b, magic) {
+		return nil, ErrInvalidMagic
+	}
+	r := bytes.NewReader(b[len(magic):])
+	var (
+		unsignedTx []byte
+		unknown    []*KeyValue
+	)
+	err := readMap(r, func(k, v []byte) error {
+		if k[0] == globalUnsignedTx {
+			if len(k) != 1 {
+				return ErrInvalidValue
+			}
+			unsignedTx = v
+			return nil
+		}
+		unknown = append(unknown, &KeyValue{Key: k, Value: v})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unsignedTx == nil {
+		return nil, ErrMissingUnsignedTx
+	}
+	t, err := tx.Parse(c, unsignedTx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := New(t)
+	if err != nil {
+		return nil, err
+	}
+	p.Unknown = unknown
+	for _, i := range p.Inputs {
+		in := i
+		err = readMap(r, func(k, v []byte) error { return in.parseKeyValue(c, k, v) })
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, i := range p.Outputs {
+		out := i
+		err = readMap(r, func(k, v []byte) error {
+			out.Unknown = append(out.Unknown, &KeyValue{Key: k, Value: v})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, ErrTrailingData
+	}
+	return p, nil
+}
+
+// ParseBase64 parses a base64 encoded packet for the given crypto
+func ParseBase64(c *cryptos.Crypto, s string) (*Packet, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(c, b)
+}
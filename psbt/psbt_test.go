@@ -0,0 +1,185 @@
+package psbt
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/tx"
+)
+
+var testCryptos = []string{"bitcoin", "litecoin", "dogecoin", "bitcoin-cash", "decred"}
+
+const testLockTime = 500000000
+
+type testHTLC struct {
+	crypto   *cryptos.Crypto
+	gen      script.Generator
+	redeem   key.Private
+	recovery key.Private
+	token    []byte
+	lock     []byte
+}
+
+func newTestHTLC(t *testing.T, c *cryptos.Crypto) *testHTLC {
+	r := &testHTLC{crypto: c, token: make([]byte, 16)}
+	_, err := rand.Read(r.token)
+	require.NoError(t, err, "can't read random")
+	r.gen, err = script.NewGenerator(c)
+	require.NoError(t, err, "can't create generator")
+	r.redeem, err = key.NewPrivate(c)
+	require.NoError(t, err, "can't create private key")
+	r.recovery, err = key.NewPrivate(c)
+	require.NoError(t, err, "can't create private key")
+	r.lock = r.gen.HTLC(
+		r.gen.LockTime(testLockTime),
+		hash.Ripemd160Sum(hash.Sha256Sum(r.token)),
+		r.gen.P2PKHHash(r.recovery.Public().KeyData()),
+		r.gen.P2PKHHash(r.redeem.Public().KeyData()),
+	)
+	return r
+}
+
+// newPacket returns a packet spending two htlc outputs
+func (h *testHTLC) newPacket(t *testing.T, redeem bool) *Packet {
+	// the funding transaction
+	prev, err := tx.New(h.crypto)
+	require.NoError(t, err, "can't create tx")
+	prevUTXO, _ := prev.TxUTXO()
+	prevID := make([]byte, 32)
+	_, err = rand.Read(prevID)
+	require.NoError(t, err, "can't read random")
+	require.NoError(t, prevUTXO.AddInput(prevID, 0, []byte{0x51}, 0), "can't add input")
+	for i := 0; i < 2; i++ {
+		prevUTXO.AddOutput(100000, h.gen.P2SHScript(h.lock))
+	}
+	ut, err := tx.New(h.crypto)
+	require.NoError(t, err, "can't create tx")
+	txUTXO, _ := ut.TxUTXO()
+	for i := 0; i < 2; i++ {
+		require.NoError(t, txUTXO.AddInput(prevUTXO.TxID(), uint32(i), nil, 100000), "can't add input")
+		if !redeem {
+			txUTXO.SetInputSequenceNumber(i, 0xfffffffe)
+		}
+	}
+	txUTXO.AddOutput(190000, h.gen.P2PKHHash(h.redeem.Public().KeyData()))
+	if !redeem {
+		txUTXO.SetLockTimeUInt32(testLockTime)
+	}
+	p, err := New(ut)
+	require.NoError(t, err, "can't create packet")
+	k := h.recovery
+	if redeem {
+		k = h.redeem
+	}
+	for i, in := range p.Inputs {
+		require.NoError(t, p.SetNonWitnessUTXO(i, prev), "can't set previous transaction")
+		in.SigHashType = 1
+		in.RedeemScript = h.lock
+		in.Derivations = []*Derivation{{
+			PubKey:      k.Public().SerializeCompressed(),
+			Fingerprint: Fingerprint(k.Public()),
+		}}
+		if redeem {
+			in.Preimages = []*Preimage{{Hash: hash.Ripemd160Sum(hash.Sha256Sum(h.token)), Preimage: h.token}}
+		}
+	}
+	return p
+}
+
+func testPacket(t *testing.T, name string, redeem bool) {
+	c, err := cryptos.Parse(name)
+	require.NoError(t, err, "can't parse crypto")
+	h := newTestHTLC(t, c)
+	p := h.newPacket(t, redeem)
+	// round trip
+	s, err := p.Base64()
+	require.NoError(t, err, "can't serialize")
+	p2, err := ParseBase64(c, s)
+	require.NoError(t, err, "can't parse")
+	require.Equal(t, p.Inputs, p2.Inputs, "inputs mismatch")
+	// sign with the wrong key
+	wrongKey := h.redeem
+	if redeem {
+		wrongKey = h.recovery
+	}
+	n, err := p2.Sign(wrongKey)
+	require.NoError(t, err, "can't sign")
+	require.Equal(t, 0, n, "expecting no signed inputs")
+	_, err = p2.Finalize()
+	require.Equal(t, ErrMissingSignature, err, "expecting missing signature")
+	// sign
+	k := h.recovery
+	if redeem {
+		k = h.redeem
+	}
+	n, err = p2.Sign(k)
+	require.NoError(t, err, "can't sign")
+	require.Equal(t, 2, n, "expecting two signed inputs")
+	b, err := p2.Serialize()
+	require.NoError(t, err, "can't serialize")
+	p3, err := Parse(c, b)
+	require.NoError(t, err, "can't parse")
+	// finalize
+	ft, err := p3.Finalize()
+	require.NoError(t, err, "can't finalize")
+	ftUTXO, _ := ft.TxUTXO()
+	for i, in := range ftUTXO.Inputs() {
+		sig := p2.Inputs[i].PartialSigs[0].Signature
+		exp := h.gen.HTLCRecover(sig, k.Public().SerializeCompressed(), h.lock)
+		if redeem {
+			exp = h.gen.HTLCRedeem(sig, k.Public().SerializeCompressed(), h.token, h.lock)
+		}
+		require.Equal(t, exp, []byte(in.SignatureScript), "signature script mismatch")
+		require.Equal(t, exp, []byte(p3.Inputs[i].FinalScriptSig), "final script mismatch")
+	}
+	// a wrong preimage passes the hash check of the packet but not the scripts
+	if !redeem {
+		return
+	}
+	wrongToken := append([]byte{}, h.token...)
+	wrongToken[0] ^= 0xff
+	p4, err := Parse(c, b)
+	require.NoError(t, err, "can't parse")
+	p4.Inputs[1].Preimages = []*Preimage{{Hash: hash.Ripemd160Sum(hash.Sha256Sum(wrongToken)), Preimage: wrongToken}}
+	_, err = p4.Finalize()
+	se, ok := err.(*tx.ScriptError)
+	require.True(t, ok, "expecting a script error")
+	require.Equal(t, 1, se.Input, "input mismatch")
+	require.Contains(t, se.Opcode, "OP_EQUALVERIFY", "opcode mismatch")
+}
+
+func TestPacket(t *testing.T) {
+	for _, name := range testCryptos {
+		t.Run(name+"/redeem", func(t *testing.T) { testPacket(t, name, true) })
+		t.Run(name+"/recover", func(t *testing.T) { testPacket(t, name, false) })
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(cryptos.Bitcoin, []byte("psbx\xff\x00"))
+	require.Equal(t, ErrInvalidMagic, err, "expecting invalid magic")
+	_, err = Parse(cryptos.Bitcoin, []byte("psbt\xff\x00"))
+	require.Equal(t, ErrMissingUnsignedTx, err, "expecting missing unsigned tx")
+	h := newTestHTLC(t, cryptos.Bitcoin)
+	b, err := h.newPacket(t, true).Serialize()
+	require.NoError(t, err, "can't serialize")
+	_, err = Parse(cryptos.Bitcoin, append(b, 0))
+	require.Equal(t, ErrTrailingData, err, "expecting trailing data")
+	_, err = Parse(cryptos.Bitcoin, b[:len(b)-1])
+	require.Error(t, err, "expecting a parse error")
+}
+
+func TestSetNonWitnessUTXO(t *testing.T) {
+	h := newTestHTLC(t, cryptos.Bitcoin)
+	p := h.newPacket(t, true)
+	require.Equal(t, ErrUTXOMismatch, p.SetNonWitnessUTXO(0, p.Tx), "expecting a mismatch")
+	// the signatures need the spent outputs
+	p.Inputs[0].NonWitnessUTXO = nil
+	_, err := p.Sign(h.redeem)
+	require.Equal(t, ErrMissingUTXO, err, "expecting a missing utxo")
+}
@@ -0,0 +1,113 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/tx"
+)
+
+var (
+	// ErrMissingUTXO is returned when the spent output of an input is missing
+	ErrMissingUTXO = errors.New("missing spent output")
+
+	// ErrUTXOMismatch is returned when the previous transaction of an input
+	// isn't the one spent
+	ErrUTXOMismatch = errors.New("previous transaction mismatch")
+)
+
+// Fingerprint returns the fingerprint of a master public key (BIP32). The
+// first 4 bytes of the key hash160 are packed in the serialization order
+func Fingerprint(pub key.Public) uint32 {
+	return binary.LittleEndian.Uint32(hash.NewBTC().Hash160(pub.SerializeCompressed()))
+}
+
+// SetNonWitnessUTXO sets the previous transaction of the input idx
+func (p *Packet) SetNonWitnessUTXO(idx int, prev tx.Tx) error {
+	txUTXO, _ := p.Tx.TxUTXO()
+	prevUTXO, ok := prev.TxUTXO()
+	if !ok {
+		return tx.ErrNotUTXO
+	}
+	in := txUTXO.Inputs()[idx]
+	if !bytes.Equal(prevUTXO.TxID(), in.TxID) || int(in.N) >= len(prevUTXO.Outputs()) {
+		return ErrUTXOMismatch
+	}
+	p.Inputs[idx].NonWitnessUTXO = prev
+	return nil
+}
+
+// spentOutput returns the output spent by the input idx, or nil if unknown
+func (p *Packet) spentOutput(idx int) (*tx.Output, error) {
+	in := p.Inputs[idx]
+	if in.NonWitnessUTXO == nil {
+		return in.WitnessUTXO, nil
+	}
+	txUTXO, _ := p.Tx.TxUTXO()
+	prevUTXO, ok := in.NonWitnessUTXO.TxUTXO()
+	if !ok {
+		return nil, tx.ErrNotUTXO
+	}
+	txIn := txUTXO.Inputs()[idx]
+	outs := prevUTXO.Outputs()
+	if !bytes.Equal(prevUTXO.TxID(), txIn.TxID) || int(txIn.N) >= len(outs) {
+		return nil, ErrUTXOMismatch
+	}
+	return outs[txIn.N], nil
+}
+
+func (in *Input) hasDerivation(pub []byte) bool {
+	for _, i := range in.Derivations {
+		if bytes.Equal(i.PubKey, pub) {
+			return true
+		}
+	}
+	return false
+}
+
+// signingTx returns a copy of the transaction ready to sign the input idx
+func (p *Packet) signingTx(idx int) (tx.TxUTXO, error) {
+	r, _ := p.Tx.Copy().TxUTXO()
+	for i := range p.Inputs {
+		out, err := p.spentOutput(i)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			return nil, ErrMissingUTXO
+		}
+		r.SetInputAmount(i, out.Value)
+	}
+	r.SetInputSignatureScript(idx, p.Inputs[idx].RedeemScript)
+	return r, nil
+}
+
+// Sign adds a partial signature to every input with a derivation for the
+// key. It returns the number of inputs signed
+func (p *Packet) Sign(k key.Private) (int, error) {
+	pub := k.Public().SerializeCompressed()
+	var n int
+	for idx, in := range p.Inputs {
+		if !in.hasDerivation(pub) {
+			continue
+		}
+		t, err := p.signingTx(idx)
+		if err != nil {
+			return 0, err
+		}
+		sigHashType := in.SigHashType
+		if sigHashType == 0 {
+			sigHashType = 1
+		}
+		sig, err := t.InputSignature(idx, sigHashType, k)
+		if err != nil {
+			return 0, err
+		}
+		in.PartialSigs = append(in.PartialSigs, &PartialSig{PubKey: pub, Signature: sig})
+		n++
+	}
+	return n, nil
+}
@@ -46,6 +46,23 @@ type (
 		// MSTLC returns a multi-sig time locked contract
 		MSTLC(lockScript, timeLockedScript []byte, nRequired int64, pubKeys ...[]byte) []byte
 	}
+
+	// SegwitGenerator represents a script generator for a crypto with segwit
+	SegwitGenerator interface {
+		Generator
+		// WitnessProgram returns a segwit output script for the witness program
+		WitnessProgram(version int64, program []byte) []byte
+		// P2WPKHHash returns a p2wpkh contract using the hash
+		P2WPKHHash(hash []byte) []byte
+		// P2WPKHPublic returns a p2wpkh contract using the public key
+		P2WPKHPublic(pub []byte) []byte
+		// P2WSHHash returns a p2wsh contract using the hash
+		P2WSHHash(h []byte) []byte
+		// P2WSHScript returns a p2wsh contract using the script
+		P2WSHScript(s []byte) []byte
+		// P2TRKey returns a p2tr contract using the x-only output key
+		P2TRKey(key []byte) []byte
+	}
 )
 
 // NewGenerator returns a generator for the given crypto
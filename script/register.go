@@ -0,0 +1,16 @@
+package script
+
+import "github.com/transmutate-io/atomicswap/cryptos"
+
+// RegisterLike registers the script generator, disassembler and int parser of
+// base for the crypto c
+func RegisterLike(c *cryptos.Crypto, base *cryptos.Crypto) error {
+	gen, ok := generators[base.Name]
+	if !ok {
+		return cryptos.InvalidCryptoError(base.Name)
+	}
+	generators[c.Name] = gen
+	disassemblers[c.Name] = disassemblers[base.Name]
+	intParsers[c.Name] = intParsers[base.Name]
+	return nil
+}
@@ -2,6 +2,7 @@ package script
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"time"
 
 	"github.com/btcsuite/btcd/txscript"
@@ -10,8 +11,11 @@ import (
 
 type generatorBTC struct{}
 
+// segwitGeneratorBTC adds the segwit contracts to generatorBTC
+type segwitGeneratorBTC struct{ generatorBTC }
+
 // NewGeneratorBTC returns a new bitcoin generator
-func NewGeneratorBTC() Generator { return generatorBTC{} }
+func NewGeneratorBTC() SegwitGenerator { return segwitGeneratorBTC{} }
 
 // If implement Generator
 func (gen generatorBTC) If(i []byte, e []byte) []byte {
@@ -74,6 +78,31 @@ func (gen generatorBTC) P2SHScript(s []byte) []byte {
 	return gen.P2SHHash(hash.NewBTC().Hash160(s))
 }
 
+// WitnessProgram implement SegwitGenerator
+func (gen segwitGeneratorBTC) WitnessProgram(version int64, program []byte) []byte {
+	return bytesJoin(gen.Int64(version), gen.Data(program))
+}
+
+// P2WPKHHash implement SegwitGenerator
+func (gen segwitGeneratorBTC) P2WPKHHash(hash []byte) []byte { return gen.WitnessProgram(0, hash) }
+
+// P2WPKHPublic implement SegwitGenerator
+func (gen segwitGeneratorBTC) P2WPKHPublic(pub []byte) []byte {
+	return gen.P2WPKHHash(hash.NewBTC().Hash160(pub))
+}
+
+// P2WSHHash implement SegwitGenerator
+func (gen segwitGeneratorBTC) P2WSHHash(h []byte) []byte { return gen.WitnessProgram(0, h) }
+
+// P2WSHScript implement SegwitGenerator
+func (gen segwitGeneratorBTC) P2WSHScript(s []byte) []byte {
+	h := sha256.Sum256(s)
+	return gen.P2WSHHash(h[:])
+}
+
+// P2TRKey implement SegwitGenerator
+func (gen segwitGeneratorBTC) P2TRKey(key []byte) []byte { return gen.WitnessProgram(1, key) }
+
 // P2SHRedeem implement Generator
 func (gen generatorBTC) P2SHRedeem(s []byte, pref ...[]byte) []byte {
 	r := make([][]byte, 0, len(pref)+1)
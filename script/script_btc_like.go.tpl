@@ -3,10 +3,19 @@ package {{ .Values.package }}
 // NewEngine{{ .Values.short }} returns a new *Engine for {{ .Values.name }}
 func NewEngine{{ .Values.short }}() *Engine { return newEngine(NewGenerator{{ .Values.short }}()) }
 
+{{ if .Values.segwit -}}
+type generator{{ .Values.short }} struct{ segwitGeneratorBTC }
+
+// NewGenerator{{ .Values.short }} returns a new {{ .Values.name }} generator
+func NewGenerator{{ .Values.short }}() SegwitGenerator {
+	return &generator{{ .Values.short }}{segwitGeneratorBTC: segwitGeneratorBTC{}}
+}
+{{- else -}}
 type generator{{ .Values.short }} struct{ generatorBTC }
 
 // NewGenerator{{ .Values.short }} returns a new {{ .Values.name }} generator
 func NewGenerator{{ .Values.short }}() Generator { return &generator{{ .Values.short }}{generatorBTC: generatorBTC{}} }
+{{- end }}
 
 type disassembler{{ .Values.short }} struct{ disassemblerBTC }
 
@@ -3,10 +3,12 @@ package script
 // NewEngineLTC returns a new *Engine for litecoin
 func NewEngineLTC() *Engine { return newEngine(NewGeneratorLTC()) }
 
-type generatorLTC struct{ generatorBTC }
+type generatorLTC struct{ segwitGeneratorBTC }
 
 // NewGeneratorLTC returns a new litecoin generator
-func NewGeneratorLTC() Generator { return &generatorLTC{generatorBTC: generatorBTC{}} }
+func NewGeneratorLTC() SegwitGenerator {
+	return &generatorLTC{segwitGeneratorBTC: segwitGeneratorBTC{}}
+}
 
 type disassemblerLTC struct{ disassemblerBTC }
 
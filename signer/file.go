@@ -0,0 +1,143 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore/types"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrMissingSeed is returned when deriving a key from a keys file without seed
+var ErrMissingSeed = errors.New("missing seed")
+
+// Keystore represents the contents of a keys file. Keys with a derivation
+// path are derived from the seed (BIP32), other keys are looked up by crypto
+type Keystore struct {
+	Seed types.Bytes              `yaml:"seed,omitempty"`
+	Keys map[string][]types.Bytes `yaml:"keys,omitempty"`
+}
+
+// NewKeystore returns a keystore with a new random seed
+func NewKeystore() (*Keystore, error) {
+	seed := make([]byte, hdkeychain.RecommendedSeedLen)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return &Keystore{Seed: seed}, nil
+}
+
+// OpenKeystore reads a keys file
+func OpenKeystore(p string) (*Keystore, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := &Keystore{}
+	if err = yaml.NewDecoder(f).Decode(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Save writes the keystore to a new keys file
+func (ks *Keystore) Save(p string) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return yaml.NewEncoder(f).Encode(ks)
+}
+
+// derive derives the private key for a path
+func (ks *Keystore) derive(c *cryptos.Crypto, p string) (key.Private, error) {
+	if len(ks.Seed) == 0 {
+		return nil, ErrMissingSeed
+	}
+	path, err := ParsePath(p)
+	if err != nil {
+		return nil, err
+	}
+	// the network is only used to encode the extended keys
+	ek, err := hdkeychain.NewMaster(ks.Seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range path {
+		if ek, err = ek.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	pk, err := ek.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	return key.ParsePrivate(c, pk.Serialize())
+}
+
+// PrivateKey returns the private key referenced by ref. If ref has a
+// derivation path and no public key the derived key is returned
+func (ks *Keystore) PrivateKey(c *cryptos.Crypto, ref *KeyRef) (key.Private, error) {
+	if ref.Path != "" {
+		r, err := ks.derive(c, ref.Path)
+		if err != nil {
+			return nil, err
+		}
+		if len(ref.Public) > 0 && !bytes.Equal(ref.Public, r.Public().SerializeCompressed()) {
+			return nil, ErrKeyMismatch
+		}
+		return r, nil
+	}
+	for _, i := range ks.Keys[c.Name] {
+		r, err := key.ParsePrivate(c, i)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(ref.Public, r.Public().SerializeCompressed()) {
+			return r, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// fileSigner is a signer reading the keys from a keys file on every use
+type fileSigner string
+
+// NewFile returns a signer using the keys file p. The file is read only
+// when a key is needed
+func NewFile(p string) Signer { return fileSigner(p) }
+
+func (s fileSigner) privateKey(c *cryptos.Crypto, ref *KeyRef) (key.Private, error) {
+	ks, err := OpenKeystore(string(s))
+	if err != nil {
+		return nil, err
+	}
+	return ks.PrivateKey(c, ref)
+}
+
+// PublicKey implement Signer
+func (s fileSigner) PublicKey(c *cryptos.Crypto, ref *KeyRef) (key.Public, error) {
+	k, err := s.privateKey(c, ref)
+	if err != nil {
+		return nil, err
+	}
+	return k.Public(), nil
+}
+
+// SignInput implement Signer
+func (s fileSigner) SignInput(t tx.Tx, idx int, hashType uint32, ref *KeyRef) ([]byte, error) {
+	k, err := s.privateKey(t.Crypto(), ref)
+	if err != nil {
+		return nil, err
+	}
+	return signInput(t, idx, hashType, k)
+}
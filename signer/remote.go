@@ -0,0 +1,188 @@
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// remote signer protocol: json requests and responses, one per line
+
+const (
+	methodPublicKey = "public_key"
+	methodSignInput = "sign_input"
+)
+
+type (
+	request struct {
+		Method   string      `json:"method"`
+		Crypto   string      `json:"crypto"`
+		Key      *KeyRef     `json:"key"`
+		Tx       types.Bytes `json:"tx,omitempty"`
+		Index    int         `json:"index,omitempty"`
+		HashType uint32      `json:"hash_type,omitempty"`
+		Amounts  []uint64    `json:"amounts,omitempty"`
+	}
+
+	response struct {
+		Result types.Bytes `json:"result,omitempty"`
+		Error  string      `json:"error,omitempty"`
+	}
+)
+
+// RemoteError is returned when the remote signer fails
+type RemoteError string
+
+func (e RemoteError) Error() string { return "remote signer: " + string(e) }
+
+// UnknownMethodError is returned for unknown remote methods
+type UnknownMethodError string
+
+func (e UnknownMethodError) Error() string { return "unknown method: " + string(e) }
+
+var errInvalidIndex = errors.New("invalid input index")
+
+type remote struct {
+	network string
+	addr    string
+}
+
+// NewRemote returns a signer connecting to a remote signer
+func NewRemote(network, addr string) Signer { return &remote{network: network, addr: addr} }
+
+func (s *remote) call(req *request) ([]byte, error) {
+	c, err := net.Dial(s.network, s.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if err = json.NewEncoder(c).Encode(req); err != nil {
+		return nil, err
+	}
+	resp := &response{}
+	if err = json.NewDecoder(c).Decode(resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, RemoteError(resp.Error)
+	}
+	return resp.Result, nil
+}
+
+// PublicKey implement Signer
+func (s *remote) PublicKey(c *cryptos.Crypto, ref *KeyRef) (key.Public, error) {
+	b, err := s.call(&request{Method: methodPublicKey, Crypto: c.Name, Key: ref})
+	if err != nil {
+		return nil, err
+	}
+	return key.ParsePublic(c, b)
+}
+
+// SignInput implement Signer
+func (s *remote) SignInput(t tx.Tx, idx int, hashType uint32, ref *KeyRef) ([]byte, error) {
+	txUTXO, ok := t.TxUTXO()
+	if !ok {
+		return nil, tx.ErrNotUTXO
+	}
+	b, err := t.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	n := len(txUTXO.Inputs())
+	amounts := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		amounts = append(amounts, txUTXO.InputAmount(i))
+	}
+	return s.call(&request{
+		Method:   methodSignInput,
+		Crypto:   t.Crypto().Name,
+		Key:      ref,
+		Tx:       b,
+		Index:    idx,
+		HashType: hashType,
+		Amounts:  amounts,
+	})
+}
+
+func handleRequest(s Signer, req *request) ([]byte, error) {
+	c, err := cryptos.Parse(req.Crypto)
+	if err != nil {
+		return nil, err
+	}
+	if req.Key == nil {
+		return nil, ErrKeyNotFound
+	}
+	switch req.Method {
+	case methodPublicKey:
+		pub, err := s.PublicKey(c, req.Key)
+		if err != nil {
+			return nil, err
+		}
+		return pub.SerializeCompressed(), nil
+	case methodSignInput:
+		t, err := tx.Parse(c, req.Tx)
+		if err != nil {
+			return nil, err
+		}
+		txUTXO, ok := t.TxUTXO()
+		if !ok {
+			return nil, tx.ErrNotUTXO
+		}
+		n := len(txUTXO.Inputs())
+		if req.Index < 0 || req.Index >= n {
+			return nil, errInvalidIndex
+		}
+		for i, amt := range req.Amounts {
+			if i < n {
+				txUTXO.SetInputAmount(i, amt)
+			}
+		}
+		return s.SignInput(t, req.Index, req.HashType, req.Key)
+	default:
+		return nil, UnknownMethodError(req.Method)
+	}
+}
+
+// ServeConn answers the requests of a connection using the signer s
+func ServeConn(c io.ReadWriter, s Signer) error {
+	dec := json.NewDecoder(c)
+	enc := json.NewEncoder(c)
+	for {
+		req := &request{}
+		if err := dec.Decode(req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		resp := &response{}
+		if r, err := handleRequest(s, req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Result = r
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// Serve accepts connections from l and answers the requests using the signer s
+func Serve(l net.Listener, s Signer) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer c.Close()
+			ServeConn(c, s)
+		}()
+	}
+}
@@ -0,0 +1,150 @@
+package signer
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/hash"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+type (
+	// Signer signs transaction inputs with keys it holds
+	Signer interface {
+		// PublicKey returns the public key referenced by ref
+		PublicKey(c *cryptos.Crypto, ref *KeyRef) (key.Public, error)
+		// SignInput returns the signature for the input idx. The signature
+		// script of the input must hold the script being signed
+		SignInput(t tx.Tx, idx int, hashType uint32, ref *KeyRef) ([]byte, error)
+	}
+
+	// KeyRef references a key held by a signer. The keys with a derivation
+	// path carry the fingerprint of the master key they derive from
+	KeyRef struct {
+		Public      types.Bytes `yaml:"public" json:"public"`
+		Path        string      `yaml:"path,omitempty" json:"path,omitempty"`
+		Fingerprint types.Bytes `yaml:"fingerprint,omitempty" json:"fingerprint,omitempty"`
+	}
+)
+
+var (
+	// ErrKeyNotFound is returned when the signer doesn't hold the key
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrKeyMismatch is returned when the derived key doesn't match the reference
+	ErrKeyMismatch = errors.New("key mismatch")
+
+	// ErrInvalidPath is returned when a derivation path can't be parsed
+	ErrInvalidPath = errors.New("invalid derivation path")
+)
+
+// InvalidSignerError is returned when a signer specification is invalid
+type InvalidSignerError string
+
+func (e InvalidSignerError) Error() string { return "invalid signer: " + string(e) }
+
+// NewKeyRef returns a reference to the public key
+func NewKeyRef(pub key.Public, path string) *KeyRef {
+	return &KeyRef{Public: pub.SerializeCompressed(), Path: path}
+}
+
+// MasterFingerprint returns the fingerprint of the master key of a signer
+// (BIP32), the first 4 bytes of the hash160 of the key
+func MasterFingerprint(s Signer, c *cryptos.Crypto) (types.Bytes, error) {
+	pub, err := s.PublicKey(c, &KeyRef{Path: "m"})
+	if err != nil {
+		return nil, err
+	}
+	return hash.NewBTC().Hash160(pub.SerializeCompressed())[:4], nil
+}
+
+// ParsePath parses a derivation path like m/0'/1
+func ParsePath(p string) ([]uint32, error) {
+	parts := strings.Split(p, "/")
+	if parts[0] != "m" {
+		return nil, ErrInvalidPath
+	}
+	r := make([]uint32, 0, len(parts)-1)
+	for _, i := range parts[1:] {
+		var h uint32
+		if strings.HasSuffix(i, "'") || strings.HasSuffix(i, "h") {
+			h = hdkeychain.HardenedKeyStart
+			i = i[:len(i)-1]
+		}
+		n, err := strconv.ParseUint(i, 10, 31)
+		if err != nil {
+			return nil, ErrInvalidPath
+		}
+		r = append(r, uint32(n)+h)
+	}
+	return r, nil
+}
+
+// Open opens a signer from a specification. The specification can be
+// file:<keys_file> for a file signer or unix:<socket> for a remote signer
+func Open(spec string) (Signer, error) {
+	p := strings.SplitN(spec, ":", 2)
+	if len(p) != 2 || p[1] == "" {
+		return nil, InvalidSignerError(spec)
+	}
+	switch p[0] {
+	case "file":
+		return NewFile(p[1]), nil
+	case "unix":
+		return NewRemote("unix", p[1]), nil
+	default:
+		return nil, InvalidSignerError(spec)
+	}
+}
+
+// signInput signs the input idx with a private key
+func signInput(t tx.Tx, idx int, hashType uint32, k key.Private) ([]byte, error) {
+	txUTXO, ok := t.TxUTXO()
+	if !ok {
+		return nil, tx.ErrNotUTXO
+	}
+	return txUTXO.InputSignature(idx, hashType, k)
+}
+
+// inProcess is a signer holding the keys in memory
+type inProcess map[string]key.Private
+
+// NewInProcess returns a signer for the given keys
+func NewInProcess(keys ...key.Private) Signer {
+	r := make(inProcess, len(keys))
+	for _, i := range keys {
+		r[types.Bytes(i.Public().SerializeCompressed()).Hex()] = i
+	}
+	return r
+}
+
+func (s inProcess) privateKey(ref *KeyRef) (key.Private, error) {
+	r, ok := s[ref.Public.Hex()]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return r, nil
+}
+
+// PublicKey implement Signer
+func (s inProcess) PublicKey(_ *cryptos.Crypto, ref *KeyRef) (key.Public, error) {
+	k, err := s.privateKey(ref)
+	if err != nil {
+		return nil, err
+	}
+	return k.Public(), nil
+}
+
+// SignInput implement Signer
+func (s inProcess) SignInput(t tx.Tx, idx int, hashType uint32, ref *KeyRef) ([]byte, error) {
+	k, err := s.privateKey(ref)
+	if err != nil {
+		return nil, err
+	}
+	return signInput(t, idx, hashType, k)
+}
@@ -0,0 +1,141 @@
+package signer
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/tx"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+const testPath = "m/44'/0'/0'/0/1"
+
+func TestParsePath(t *testing.T) {
+	for _, i := range []struct {
+		path string
+		exp  []uint32
+	}{
+		{"m", []uint32{}},
+		{"m/0", []uint32{0}},
+		{"m/0'/1h/2", []uint32{hdkeychain.HardenedKeyStart, hdkeychain.HardenedKeyStart + 1, 2}},
+	} {
+		r, err := ParsePath(i.path)
+		require.NoError(t, err, "can't parse path")
+		require.Equal(t, i.exp, r, "mismatch")
+	}
+	for _, i := range []string{"", "0/1", "m/a", "m/1''", "m/2147483648"} {
+		_, err := ParsePath(i)
+		require.Equal(t, ErrInvalidPath, err, "expecting an error")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, i := range []string{"", "file", "file:", "tcp:localhost:1"} {
+		_, err := Open(i)
+		require.Equal(t, InvalidSignerError(i), err, "expecting an error")
+	}
+}
+
+// newTestTx returns a transaction with two inputs ready to be signed by k
+func newTestTx(t *testing.T, c *cryptos.Crypto, k key.Private) tx.Tx {
+	gen, err := script.NewGenerator(c)
+	require.NoError(t, err, "can't create generator")
+	r, err := tx.New(c)
+	require.NoError(t, err, "can't create tx")
+	txUTXO, _ := r.TxUTXO()
+	subScript := gen.P2PKHHash(k.Public().KeyData())
+	for i := 0; i < 2; i++ {
+		txID := make([]byte, 32)
+		_, err = rand.Read(txID)
+		require.NoError(t, err, "can't read random")
+		require.NoError(t, txUTXO.AddInput(txID, uint32(i), subScript, 100000+uint64(i)), "can't add input")
+	}
+	txUTXO.AddOutput(190000, subScript)
+	return r
+}
+
+func testSigner(t *testing.T, c *cryptos.Crypto, s Signer, ref *KeyRef, k key.Private) {
+	pub, err := s.PublicKey(c, ref)
+	require.NoError(t, err, "can't get public key")
+	require.Equal(t, k.Public().SerializeCompressed(), pub.SerializeCompressed(), "public key mismatch")
+	ut := newTestTx(t, c, k)
+	txUTXO, _ := ut.TxUTXO()
+	for i := range txUTXO.Inputs() {
+		exp, err := txUTXO.InputSignature(i, 1, k)
+		require.NoError(t, err, "can't sign input")
+		sig, err := s.SignInput(ut, i, 1, ref)
+		require.NoError(t, err, "can't sign input")
+		require.Equal(t, exp, sig, "signature mismatch")
+	}
+}
+
+func testSigners(t *testing.T, name string) {
+	c, err := cryptos.Parse(name)
+	require.NoError(t, err, "can't parse crypto")
+	dir, err := ioutil.TempDir("", "signer")
+	require.NoError(t, err, "can't create temp dir")
+	defer os.RemoveAll(dir)
+	// keystore with a seed and a raw key
+	ks, err := NewKeystore()
+	require.NoError(t, err, "can't create keystore")
+	rawKey, err := key.NewPrivate(c)
+	require.NoError(t, err, "can't create key")
+	ks.Keys = map[string][]types.Bytes{c.Name: {rawKey.Serialize()}}
+	keysPath := filepath.Join(dir, "keys.yaml")
+	require.NoError(t, ks.Save(keysPath), "can't save keystore")
+	require.Error(t, ks.Save(keysPath), "expecting an error")
+	derived, err := ks.PrivateKey(c, &KeyRef{Path: testPath})
+	require.NoError(t, err, "can't derive key")
+	// remote signer serving the keys file
+	sockPath := filepath.Join(dir, "signer.sock")
+	l, err := net.Listen("unix", sockPath)
+	require.NoError(t, err, "can't listen")
+	defer l.Close()
+	go Serve(l, NewFile(keysPath))
+	for _, i := range []struct {
+		name string
+		s    Signer
+	}{
+		{"in_process", NewInProcess(derived, rawKey)},
+		{"file", NewFile(keysPath)},
+		{"remote", NewRemote("unix", sockPath)},
+	} {
+		t.Run(i.name, func(t *testing.T) {
+			testSigner(t, c, i.s, NewKeyRef(derived.Public(), testPath), derived)
+			testSigner(t, c, i.s, NewKeyRef(rawKey.Public(), ""), rawKey)
+			otherKey, err := key.NewPrivate(c)
+			require.NoError(t, err, "can't create key")
+			_, err = i.s.PublicKey(c, NewKeyRef(otherKey.Public(), ""))
+			require.Error(t, err, "expecting an error")
+			if i.name != "in_process" {
+				_, err = i.s.PublicKey(c, NewKeyRef(otherKey.Public(), testPath))
+				require.Error(t, err, "expecting an error")
+				// the children of the master key carry its fingerprint
+				fp, err := MasterFingerprint(i.s, c)
+				require.NoError(t, err, "can't get master fingerprint")
+				master, err := hdkeychain.NewMaster(ks.Seed, &chaincfg.MainNetParams)
+				require.NoError(t, err, "can't create master key")
+				child, err := master.Child(0)
+				require.NoError(t, err, "can't derive child key")
+				require.Equal(t, child.ParentFingerprint(), binary.BigEndian.Uint32(fp), "fingerprint mismatch")
+			}
+		})
+	}
+}
+
+func TestSigners(t *testing.T) {
+	for _, i := range []string{"bitcoin", "bitcoin-cash"} {
+		t.Run(i, func(t *testing.T) { testSigners(t, i) })
+	}
+}
@@ -0,0 +1,3 @@
+package stages
+
+//go:generate go run ../cmd/tpl_gen/main.go gen.yaml
@@ -1,6 +1,9 @@
 package trade
 
 import (
+	"errors"
+	"time"
+
 	"github.com/transmutate-io/atomicswap/cryptos"
 	"github.com/transmutate-io/atomicswap/duration"
 	"github.com/transmutate-io/atomicswap/key"
@@ -23,6 +26,23 @@ type BuyProposal struct {
 	TokenHash       types.Bytes      `yaml:"token_hash"`
 	RedeemKeyData   key.KeyData      `yaml:"redeem_key_data"`
 	RecoveryKeyData key.KeyData      `yaml:"recovery_key_data"`
+	Expiry          time.Time        `yaml:"expiry,omitempty"`
+	Nonce           types.Bytes      `yaml:"nonce,omitempty"`
+}
+
+// ProposalExpiry is the validity of a new proposal
+var ProposalExpiry = time.Hour
+
+// ErrProposalExpired is returned when a proposal is expired
+var ErrProposalExpired = errors.New("proposal expired")
+
+const nonceSize = 16
+
+func newNonce() (types.Bytes, error) { return readRandom(nonceSize) }
+
+// Expired returns true if the proposal is expired
+func (p *BuyProposal) Expired(now time.Time) bool {
+	return !p.Expiry.IsZero() && now.After(p.Expiry)
 }
 
 // UnamrshalBuyProposal unmarshals a buy proposal
@@ -0,0 +1,162 @@
+package trade
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/transmutate-io/atomicswap/duration"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// CounterProposal represents a seller counter-proposal to a buy proposal
+type CounterProposal struct {
+	InReplyTo types.Bytes      `yaml:"in_reply_to"`
+	Buyer     *BuyProposalInfo `yaml:"buyer"`
+	Seller    *BuyProposalInfo `yaml:"seller"`
+	Expiry    time.Time        `yaml:"expiry,omitempty"`
+	Nonce     types.Bytes      `yaml:"nonce"`
+}
+
+// ProposalRejection represents the rejection of a proposal
+type ProposalRejection struct {
+	InReplyTo types.Bytes `yaml:"in_reply_to"`
+	Reason    string      `yaml:"reason,omitempty"`
+}
+
+// NegotiationRecord represents an entry in the negotiation history
+type NegotiationRecord struct {
+	Time      time.Time        `yaml:"time"`
+	Event     NegotiationEvent `yaml:"event"`
+	Nonce     types.Bytes      `yaml:"nonce,omitempty"`
+	InReplyTo types.Bytes      `yaml:"in_reply_to,omitempty"`
+	Buyer     *BuyProposalInfo `yaml:"buyer,omitempty"`
+	Seller    *BuyProposalInfo `yaml:"seller,omitempty"`
+	Reason    string           `yaml:"reason,omitempty"`
+}
+
+var (
+	// ErrUnknownProposal is returned when a reply doesn't match the last proposal
+	ErrUnknownProposal = errors.New("unknown proposal")
+
+	// ErrCryptoMismatch is returned when a counter-proposal changes the cryptos
+	ErrCryptoMismatch = errors.New("crypto mismatch")
+)
+
+// NewCounterProposal returns a counter-proposal for a buy proposal. Zero
+// amounts and durations are kept from the original proposal
+func NewCounterProposal(
+	prop *BuyProposal,
+	buyerAmount types.Amount,
+	sellerAmount types.Amount,
+	buyerLockDuration duration.Duration,
+	sellerLockDuration duration.Duration,
+) (*CounterProposal, error) {
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	r := &CounterProposal{
+		InReplyTo: prop.Nonce,
+		Buyer:     &BuyProposalInfo{},
+		Seller:    &BuyProposalInfo{},
+		Expiry:    time.Now().UTC().Add(ProposalExpiry),
+		Nonce:     nonce,
+	}
+	*r.Buyer, *r.Seller = *prop.Buyer, *prop.Seller
+	if buyerAmount != "" {
+		r.Buyer.Amount = buyerAmount
+	}
+	if sellerAmount != "" {
+		r.Seller.Amount = sellerAmount
+	}
+	if buyerLockDuration != 0 {
+		r.Buyer.LockDuration = buyerLockDuration
+	}
+	if sellerLockDuration != 0 {
+		r.Seller.LockDuration = sellerLockDuration
+	}
+	if r.Seller.LockDuration >= r.Buyer.LockDuration {
+		return nil, ErrInvalidLockInterval
+	}
+	return r, nil
+}
+
+// Expired returns true if the counter-proposal is expired
+func (cp *CounterProposal) Expired(now time.Time) bool {
+	return !cp.Expiry.IsZero() && now.After(cp.Expiry)
+}
+
+// lastProposalNonce returns the nonce of the last proposal sent
+func (bt *baseTrade) lastProposalNonce() types.Bytes {
+	for i := len(bt.History) - 1; i >= 0; i-- {
+		if bt.History[i].Event == ProposalSent {
+			return bt.History[i].Nonce
+		}
+	}
+	return nil
+}
+
+func (bt *baseTrade) addHistory(r *NegotiationRecord) {
+	r.Time = time.Now().UTC()
+	bt.History = append(bt.History, r)
+}
+
+// AcceptCounterProposal implement BuyerTrade
+func (bt *baseTrade) AcceptCounterProposal(cp *CounterProposal) error {
+	if cp.Expired(time.Now()) {
+		return ErrProposalExpired
+	}
+	if last := bt.lastProposalNonce(); last == nil || !bytes.Equal(last, cp.InReplyTo) {
+		return ErrUnknownProposal
+	}
+	if cp.Buyer.Crypto.Name != bt.OwnInfo.Crypto.Name ||
+		cp.Seller.Crypto.Name != bt.TraderInfo.Crypto.Name {
+		return ErrCryptoMismatch
+	}
+	if cp.Seller.LockDuration >= cp.Buyer.LockDuration {
+		return ErrInvalidLockInterval
+	}
+	bt.addHistory(&NegotiationRecord{
+		Event:     CounterProposalAccepted,
+		Nonce:     cp.Nonce,
+		InReplyTo: cp.InReplyTo,
+		Buyer:     cp.Buyer,
+		Seller:    cp.Seller,
+	})
+	// regenerate the trade
+	ownFundsData, err := newFundsData(bt.OwnInfo.Crypto)
+	if err != nil {
+		return err
+	}
+	traderFundsData, err := newFundsData(bt.TraderInfo.Crypto)
+	if err != nil {
+		return err
+	}
+	bt.OwnInfo.Amount = cp.Buyer.Amount
+	bt.TraderInfo.Amount = cp.Seller.Amount
+	bt.Duration = cp.Buyer.LockDuration
+	bt.TraderDuration = cp.Seller.LockDuration
+	bt.RecoverableFunds = ownFundsData
+	bt.RedeemableFunds = traderFundsData
+	// the new keys replace the keys held by a signer
+	bt.RedeemKeyRef, bt.RecoveryKeyRef = nil, nil
+	if err = bt.GenerateKeys(); err != nil {
+		return err
+	}
+	_, err = bt.GenerateToken()
+	return err
+}
+
+// RejectProposal implement BuyerTrade
+func (bt *baseTrade) RejectProposal(rej *ProposalRejection) error {
+	if last := bt.lastProposalNonce(); last == nil || !bytes.Equal(last, rej.InReplyTo) {
+		return ErrUnknownProposal
+	}
+	bt.addHistory(&NegotiationRecord{
+		Event:     ProposalRejected,
+		InReplyTo: rej.InReplyTo,
+		Reason:    rej.Reason,
+	})
+	return nil
+}
@@ -0,0 +1,182 @@
+package trade
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/script"
+)
+
+type (
+	// DustFunc returns the minimum value of an output with the script
+	DustFunc func(pkScript []byte) uint64
+
+	// InsufficientFundsError is returned when the funds can't pay the fee
+	InsufficientFundsError struct {
+		Funds uint64
+		Fee   uint64
+	}
+
+	// DustOutputError is returned when the value of the output is below the
+	// dust limit of the crypto
+	DustOutputError struct {
+		Value uint64
+		Limit uint64
+	}
+)
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("insufficient funds: %d available, %d fee", e.Funds, e.Fee)
+}
+
+func (e *DustOutputError) Error() string {
+	return fmt.Sprintf("dust output: %d below the dust limit of %d", e.Value, e.Limit)
+}
+
+// dust rules of the nodes default configuration
+var dustFuncs = map[string]DustFunc{
+	cryptos.Bitcoin.Name:     relayDust(1000, 8, segwitInputSize),
+	cryptos.Litecoin.Name:    relayDust(1000, 8, segwitInputSize),
+	cryptos.BitcoinCash.Name: relayDust(1000, 8, fixedInputSize(legacyInputSize)),
+	cryptos.Decred.Name:      relayDust(10000, 10, fixedInputSize(165)),
+	cryptos.Dogecoin.Name:    fixedDust(1000000),
+}
+
+// input sizes assumed by the nodes to spend an output. The witness of an
+// input is discounted to a quarter of it's size, the nodes assume the same
+// size for every witness version
+const (
+	legacyInputSize  = 148
+	witnessInputSize = 67
+)
+
+// relayDust returns the dust rule of the nodes deriving the limit from the
+// relay fee, in units per kilobyte. An output is dust if spending it costs
+// more than a third of its value. outputSize is the size of the output
+// without the script and inputSize returns the size of the input spending it
+func relayDust(relayFee, outputSize uint64, inputSize func(pkScript []byte) uint64) DustFunc {
+	return func(pkScript []byte) uint64 {
+		n := uint64(len(pkScript))
+		size := outputSize + varIntSize(n) + n + inputSize(pkScript)
+		return (3*size*relayFee + 999) / 1000
+	}
+}
+
+// fixedInputSize returns the same input size for every script
+func fixedInputSize(size uint64) func([]byte) uint64 {
+	return func([]byte) uint64 { return size }
+}
+
+// segwitInputSize returns the input size for the script type
+func segwitInputSize(pkScript []byte) uint64 {
+	if isWitnessProgram(pkScript) {
+		return witnessInputSize
+	}
+	return legacyInputSize
+}
+
+// isWitnessProgram returns true if the script is a version byte followed by a
+// push of a 2 to 40 bytes program
+func isWitnessProgram(pkScript []byte) bool {
+	n := len(pkScript)
+	if n < 4 || n > 42 {
+		return false
+	}
+	// OP_0 or OP_1 to OP_16
+	if v := pkScript[0]; v != 0x00 && (v < 0x51 || v > 0x60) {
+		return false
+	}
+	return int(pkScript[1])+2 == n
+}
+
+// fixedDust returns the dust rule of the nodes with a fixed limit
+func fixedDust(limit uint64) DustFunc {
+	return func([]byte) uint64 { return limit }
+}
+
+func varIntSize(n uint64) uint64 {
+	switch {
+	case n < 0xfd:
+		return 1
+	case n <= math.MaxUint16:
+		return 3
+	case n <= math.MaxUint32:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// DustLimit returns the minimum value of an output with the script
+func DustLimit(c *cryptos.Crypto, pkScript []byte) (uint64, error) {
+	f, ok := dustFuncs[c.Name]
+	if !ok {
+		return 0, cryptos.InvalidCryptoError(c.Name)
+	}
+	return f(pkScript), nil
+}
+
+// outputValue returns the value of the output spending amount with fee
+func outputValue(c *cryptos.Crypto, amount, fee uint64, pkScript []byte) (uint64, error) {
+	if fee >= amount {
+		return 0, &InsufficientFundsError{Funds: amount, Fee: fee}
+	}
+	limit, err := DustLimit(c, pkScript)
+	if err != nil {
+		return 0, err
+	}
+	if r := amount - fee; r >= limit {
+		return r, nil
+	}
+	return 0, &DustOutputError{Value: amount - fee, Limit: limit}
+}
+
+// feeForSize returns the fee for a transaction size, saturating on overflow
+func feeForSize(feePerByte, size uint64) uint64 {
+	if size > 0 && feePerByte > math.MaxUint64/size {
+		return math.MaxUint64
+	}
+	return feePerByte * size
+}
+
+// maxFeeRate returns the maximum fee per byte the funds can pay leaving an
+// output above the dust limit
+func maxFeeRate(c *cryptos.Crypto, amount, size uint64, pkScript []byte) (uint64, error) {
+	limit, err := DustLimit(c, pkScript)
+	if err != nil {
+		return 0, err
+	}
+	if amount < limit {
+		return 0, &DustOutputError{Value: amount, Limit: limit}
+	}
+	return (amount - limit) / size, nil
+}
+
+// RedeemMaxFeeRate implement Trade
+func (bt *baseTrade) RedeemMaxFeeRate(lockScript []byte) (uint64, error) {
+	t, err := bt.unsignedRedeemTx(lockScript, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	gen, err := script.NewGenerator(bt.TraderInfo.Crypto)
+	if err != nil {
+		return 0, err
+	}
+	fs := gen.HTLCRedeem(dummySig, dummyKey, bt.Token, bt.RedeemableFunds.Lock().Bytes())
+	return maxFeeRate(bt.TraderInfo.Crypto, FundsAmount(bt.RedeemableFunds), signedSize(t, fs), lockScript)
+}
+
+// RecoveryMaxFeeRate implement Trade
+func (bt *baseTrade) RecoveryMaxFeeRate(lockScript []byte) (uint64, error) {
+	t, err := bt.unsignedRecoveryTx(lockScript, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	gen, err := script.NewGenerator(bt.OwnInfo.Crypto)
+	if err != nil {
+		return 0, err
+	}
+	fs := gen.HTLCRecover(dummySig, dummyKey, bt.RecoverableFunds.Lock().Bytes())
+	return maxFeeRate(bt.OwnInfo.Crypto, FundsAmount(bt.RecoverableFunds), signedSize(t, fs), lockScript)
+}
@@ -0,0 +1,103 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/internal/testutil"
+	"github.com/transmutate-io/atomicswap/script"
+)
+
+func TestDustLimit(t *testing.T) {
+	for _, i := range []struct {
+		crypto *cryptos.Crypto
+		exp    uint64
+	}{
+		{cryptos.Bitcoin, 546},
+		{cryptos.Litecoin, 546},
+		{cryptos.BitcoinCash, 546},
+		{cryptos.Decred, 6030},
+		{cryptos.Dogecoin, 1000000},
+	} {
+		gen, err := script.NewGenerator(i.crypto)
+		require.NoError(t, err, "can't create generator")
+		r, err := DustLimit(i.crypto, gen.P2PKHHash(make([]byte, 20)))
+		require.NoError(t, err, "can't get dust limit")
+		require.Equal(t, i.exp, r, "dust limit mismatch")
+	}
+	for _, i := range []struct {
+		crypto *cryptos.Crypto
+		script func(script.SegwitGenerator) []byte
+		exp    uint64
+	}{
+		{cryptos.Bitcoin, func(g script.SegwitGenerator) []byte { return g.P2WPKHHash(make([]byte, 20)) }, 294},
+		{cryptos.Bitcoin, func(g script.SegwitGenerator) []byte { return g.P2WSHHash(make([]byte, 32)) }, 330},
+		{cryptos.Bitcoin, func(g script.SegwitGenerator) []byte { return g.P2TRKey(make([]byte, 32)) }, 330},
+		{cryptos.Bitcoin, func(g script.SegwitGenerator) []byte { return g.P2SHHash(make([]byte, 20)) }, 540},
+		{cryptos.Litecoin, func(g script.SegwitGenerator) []byte { return g.P2WPKHHash(make([]byte, 20)) }, 294},
+	} {
+		gen, err := script.NewGenerator(i.crypto)
+		require.NoError(t, err, "can't create generator")
+		r, err := DustLimit(i.crypto, i.script(gen.(script.SegwitGenerator)))
+		require.NoError(t, err, "can't get dust limit")
+		require.Equal(t, i.exp, r, "dust limit mismatch")
+	}
+}
+
+func testSpendLimits(t *testing.T, tc *testutil.Crypto) {
+	// trade with the locks and funds in place
+	buyerTrade, err := newBuyerTrade(tc, tc, 48*time.Hour)
+	require.NoError(t, err, "can't create buyer trade")
+	btr, err := buyerTrade.Buyer()
+	require.NoError(t, err, "can't get buyer trade")
+	prop, err := btr.GenerateBuyProposal()
+	require.NoError(t, err, "can't generate buy proposal")
+	sellerTrade, err := AcceptProposal(prop)
+	require.NoError(t, err, "can't accept proposal")
+	str, err := sellerTrade.Seller()
+	require.NoError(t, err, "can't get seller trade")
+	require.NoError(t, btr.SetLocks(str.Locks()), "can't accept locks")
+	c := buyerTrade.OwnInfo().Crypto
+	buyerTrade.RecoverableFunds().AddFunds(&Output{TxID: testutil.MustReadRandom(t, 32), Amount: 100000000})
+	buyerTrade.RedeemableFunds().AddFunds(&Output{TxID: testutil.MustReadRandom(t, 32), Amount: 100000000})
+	gen := testutil.MustNewGenerator(t, c)
+	lockScript := gen.P2PKHHash(make([]byte, 20))
+	limit, err := DustLimit(c, lockScript)
+	require.NoError(t, err, "can't get dust limit")
+	// fixed fees
+	_, err = buyerTrade.RecoveryTxFixedFee(lockScript, 100000001)
+	require.Equal(t, &InsufficientFundsError{Funds: 100000000, Fee: 100000001}, err, "expecting insufficient funds")
+	_, err = buyerTrade.RedeemPSBTFixedFee(lockScript, 100000000)
+	require.Equal(t, &InsufficientFundsError{Funds: 100000000, Fee: 100000000}, err, "expecting insufficient funds")
+	_, err = buyerTrade.RecoveryTxFixedFee(lockScript, 100000000-limit+1)
+	require.Equal(t, &DustOutputError{Value: limit - 1, Limit: limit}, err, "expecting a dust output")
+	// fees per byte
+	for _, i := range []struct {
+		max   func([]byte) (uint64, error)
+		newTx func([]byte, uint64) (interface{}, error)
+	}{
+		{
+			buyerTrade.RedeemMaxFeeRate,
+			func(s []byte, f uint64) (interface{}, error) { return buyerTrade.RedeemPSBT(s, f) },
+		},
+		{
+			buyerTrade.RecoveryMaxFeeRate,
+			func(s []byte, f uint64) (interface{}, error) { return buyerTrade.RecoveryTx(s, f) },
+		},
+	} {
+		maxRate, err := i.max(lockScript)
+		require.NoError(t, err, "can't get max fee rate")
+		_, err = i.newTx(lockScript, maxRate)
+		require.NoError(t, err, "can't create transaction")
+		_, err = i.newTx(lockScript, 1<<62)
+		require.IsType(t, &InsufficientFundsError{}, err, "expecting insufficient funds")
+	}
+}
+
+func TestSpendLimits(t *testing.T) {
+	for _, i := range testutil.Cryptos {
+		t.Run(i.Name, func(t *testing.T) { testSpendLimits(t, i) })
+	}
+}
@@ -0,0 +1,66 @@
+package trade
+
+import "fmt"
+
+type InvalidNegotiationEventError string
+
+func (e InvalidNegotiationEventError) Error() string {
+	return fmt.Sprintf("invalid negotiation event: \"%s\"", string(e))
+}
+
+type NegotiationEvent int
+
+func ParseNegotiationEvent(s string) (NegotiationEvent, error) {
+	var r NegotiationEvent
+	if err := (&r).Set(s); err != nil {
+		return 0, err
+	}
+	return r, nil
+}
+
+func (v NegotiationEvent) String() string { return _NegotiationEvent[v] }
+
+func (v *NegotiationEvent) Set(sv string) error {
+	nv, ok := _NegotiationEventNames[sv]
+	if !ok {
+		return InvalidNegotiationEventError(sv)
+	}
+	*v = nv
+	return nil
+}
+
+func (v NegotiationEvent) MarshalYAML() (interface{}, error) { return v.String(), nil }
+
+func (v *NegotiationEvent) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var r string
+	if err := unmarshal(&r); err != nil {
+		return err
+	}
+	return v.Set(r)
+}
+
+const (
+	ProposalSent NegotiationEvent = iota
+	ProposalAccepted
+	ProposalRejected
+	CounterProposalReceived
+	CounterProposalAccepted
+)
+
+var (
+	_NegotiationEvent = map[NegotiationEvent]string{
+		ProposalSent:            "proposal-sent",
+		ProposalAccepted:        "proposal-accepted",
+		ProposalRejected:        "proposal-rejected",
+		CounterProposalReceived: "counter-proposal-received",
+		CounterProposalAccepted: "counter-proposal-accepted",
+	}
+	_NegotiationEventNames map[string]NegotiationEvent
+)
+
+func init() {
+	_NegotiationEventNames = make(map[string]NegotiationEvent, len(_NegotiationEvent))
+	for k, v := range _NegotiationEvent {
+		_NegotiationEventNames[v] = k
+	}
+}
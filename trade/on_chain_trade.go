@@ -7,6 +7,7 @@ import (
 	"github.com/transmutate-io/atomicswap/duration"
 	"github.com/transmutate-io/atomicswap/key"
 	"github.com/transmutate-io/atomicswap/roles"
+	"github.com/transmutate-io/atomicswap/signer"
 	"github.com/transmutate-io/cryptocore/types"
 )
 
@@ -50,6 +51,14 @@ func (t *OnChainTrade) Token() types.Bytes { return t.baseTrade.Token }
 
 func (t *OnChainTrade) TokenHash() types.Bytes { return t.baseTrade.TokenHash }
 
+func (t *OnChainTrade) SessionID() types.Bytes { return t.baseTrade.SessionID }
+
+func (t *OnChainTrade) PeerSigner() types.Bytes { return t.baseTrade.PeerSigner }
+
+func (t *OnChainTrade) Finished() bool { return t.baseTrade.Finished }
+
+func (t *OnChainTrade) History() []*NegotiationRecord { return t.baseTrade.History }
+
 func (t *OnChainTrade) OwnInfo() *TraderInfo { return t.baseTrade.OwnInfo }
 
 func (t *OnChainTrade) TraderInfo() *TraderInfo { return t.baseTrade.TraderInfo }
@@ -58,6 +67,10 @@ func (t *OnChainTrade) RedeemKey() key.Private { return t.baseTrade.RedeemKey }
 
 func (t *OnChainTrade) RecoveryKey() key.Private { return t.baseTrade.RecoveryKey }
 
+func (t *OnChainTrade) RedeemKeyRef() *signer.KeyRef { return t.baseTrade.RedeemKeyRef }
+
+func (t *OnChainTrade) RecoveryKeyRef() *signer.KeyRef { return t.baseTrade.RecoveryKeyRef }
+
 func (t *OnChainTrade) RedeemableFunds() FundsData { return t.baseTrade.RedeemableFunds }
 
 func (t *OnChainTrade) RecoverableFunds() FundsData { return t.baseTrade.RecoverableFunds }
@@ -0,0 +1,136 @@
+package trade
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/psbt"
+	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/signer"
+	"github.com/transmutate-io/atomicswap/tx"
+)
+
+// dummy signature and public key used to estimate the size of a signed
+// transaction
+var (
+	dummySig = make([]byte, 73)
+	dummyKey = make([]byte, 33)
+)
+
+// signedSize returns the size of t once every input has the final script
+func signedSize(t tx.Tx, finalScript []byte) uint64 {
+	r := t.Copy()
+	txUTXO, _ := r.TxUTXO()
+	for i := range txUTXO.Inputs() {
+		txUTXO.SetInputSignatureScript(i, finalScript)
+	}
+	return r.SerializedSize()
+}
+
+// ErrMissingFingerprint is returned when a derived key has no master key
+// fingerprint
+var ErrMissingFingerprint = errors.New("missing master key fingerprint")
+
+// newHTLCPacket returns a packet spending the htlc funds. The inputs are
+// legacy p2sh inputs and the previous transactions aren't stored in the
+// trade, the caller must set them as the non-witness utxos. If token is not
+// nil the inputs are redeemed, otherwise they are recovered. If the key is
+// held by a signer the derivation path is included
+func newHTLCPacket(t tx.Tx, c *cryptos.Crypto, fd FundsData, k key.Private, ref *signer.KeyRef, token []byte) (*psbt.Packet, error) {
+	pub, err := publicKey(c, k, ref)
+	if err != nil {
+		return nil, err
+	}
+	// a key without derivation path is its own master key
+	fp := psbt.Fingerprint(pub)
+	var path []uint32
+	if k == nil && ref.Path != "" {
+		if len(ref.Fingerprint) != 4 {
+			return nil, ErrMissingFingerprint
+		}
+		fp = binary.LittleEndian.Uint32(ref.Fingerprint)
+		if path, err = signer.ParsePath(ref.Path); err != nil {
+			return nil, err
+		}
+	}
+	r, err := psbt.New(t)
+	if err != nil {
+		return nil, err
+	}
+	lock := fd.Lock().Bytes()
+	for _, in := range r.Inputs {
+		in.SigHashType = 1
+		in.RedeemScript = lock
+		in.Derivations = []*psbt.Derivation{{
+			PubKey:      pub.SerializeCompressed(),
+			Fingerprint: fp,
+			Path:        path,
+		}}
+		if token != nil {
+			in.Preimages = []*psbt.Preimage{{Hash: TokenHash(token), Preimage: token}}
+		}
+	}
+	return r, nil
+}
+
+func (bt *baseTrade) newRedeemPSBT(lockScript []byte, fee uint64) (*psbt.Packet, error) {
+	if bt.TraderInfo.Crypto.Type != cryptos.UTXO {
+		return nil, ErrNotUTXO
+	}
+	t, err := bt.unsignedRedeemTx(lockScript, fee, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newHTLCPacket(t, bt.TraderInfo.Crypto, bt.RedeemableFunds, bt.RedeemKey, bt.RedeemKeyRef, bt.Token)
+}
+
+// RedeemPSBTFixedFee implement Trade
+func (bt *baseTrade) RedeemPSBTFixedFee(lockScript []byte, fee uint64) (*psbt.Packet, error) {
+	return bt.newRedeemPSBT(lockScript, fee)
+}
+
+// RedeemPSBT implement Trade
+func (bt *baseTrade) RedeemPSBT(lockScript []byte, feePerByte uint64) (*psbt.Packet, error) {
+	t, err := bt.unsignedRedeemTx(lockScript, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	gen, err := script.NewGenerator(bt.TraderInfo.Crypto)
+	if err != nil {
+		return nil, err
+	}
+	fs := gen.HTLCRedeem(dummySig, dummyKey, bt.Token, bt.RedeemableFunds.Lock().Bytes())
+	return bt.newRedeemPSBT(lockScript, feeForSize(feePerByte, signedSize(t, fs)))
+}
+
+func (bt *baseTrade) newRecoveryPSBT(lockScript []byte, fee uint64) (*psbt.Packet, error) {
+	if bt.OwnInfo.Crypto.Type != cryptos.UTXO {
+		return nil, ErrNotUTXO
+	}
+	t, err := bt.unsignedRecoveryTx(lockScript, fee, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newHTLCPacket(t, bt.OwnInfo.Crypto, bt.RecoverableFunds, bt.RecoveryKey, bt.RecoveryKeyRef, nil)
+}
+
+// RecoveryPSBTFixedFee implement Trade
+func (bt *baseTrade) RecoveryPSBTFixedFee(lockScript []byte, fee uint64) (*psbt.Packet, error) {
+	return bt.newRecoveryPSBT(lockScript, fee)
+}
+
+// RecoveryPSBT implement Trade
+func (bt *baseTrade) RecoveryPSBT(lockScript []byte, feePerByte uint64) (*psbt.Packet, error) {
+	t, err := bt.unsignedRecoveryTx(lockScript, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	gen, err := script.NewGenerator(bt.OwnInfo.Crypto)
+	if err != nil {
+		return nil, err
+	}
+	fs := gen.HTLCRecover(dummySig, dummyKey, bt.RecoverableFunds.Lock().Bytes())
+	return bt.newRecoveryPSBT(lockScript, feeForSize(feePerByte, signedSize(t, fs)))
+}
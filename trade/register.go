@@ -0,0 +1,153 @@
+package trade
+
+import (
+	"github.com/transmutate-io/atomicswap/cryptos"
+	"github.com/transmutate-io/atomicswap/networks"
+	"github.com/transmutate-io/atomicswap/params"
+	"github.com/transmutate-io/cryptocore/types"
+)
+
+// bases of the registered cryptos
+var likeBases = make(map[string]*cryptos.Crypto, 8)
+
+// RegisterLike registers the funds of base for the crypto c
+func RegisterLike(c *cryptos.Crypto, base *cryptos.Crypto) error {
+	if _, ok := newFundsDataFuncs[base.Name]; !ok {
+		return cryptos.InvalidCryptoError(base.Name)
+	}
+	likeBases[c.Name] = base
+	dustFuncs[c.Name] = dustFuncs[base.Name]
+	newFundsDataFuncs[c.Name] = func() FundsData {
+		fd, _ := newFundsLike(c)
+		return fd
+	}
+	newFundsLockFuncs[c.Name] = func(l types.Bytes) Lock {
+		fl, _ := newFundsLockLike(c, l)
+		return fl
+	}
+	return nil
+}
+
+func likeBase(name string) (*cryptos.Crypto, *cryptos.Crypto, error) {
+	c, err := cryptos.Parse(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	base, ok := likeBases[c.Name]
+	if !ok {
+		return nil, nil, cryptos.InvalidCryptoError(c.Name)
+	}
+	return c, base, nil
+}
+
+func newFundsLike(c *cryptos.Crypto) (*fundsDataLike, error) {
+	c, base, err := likeBase(c.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &fundsDataLike{FundsData: newFundsDataFuncs[base.Name](), crypto: c}, nil
+}
+
+func newFundsLockLike(c *cryptos.Crypto, l types.Bytes) (*fundsLockLike, error) {
+	c, base, err := likeBase(c.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &fundsLockLike{Lock: newFundsLockFuncs[base.Name](l), crypto: c}, nil
+}
+
+// deferredYAML delays the unmarshalling of a node
+type deferredYAML struct{ unmarshal func(interface{}) error }
+
+// UnmarshalYAML implement yaml.Unmarshaler
+func (d *deferredYAML) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	d.unmarshal = unmarshal
+	return nil
+}
+
+// fundsDataLike holds the funds of a registered crypto. Because the concrete
+// type is shared between registered cryptos, the crypto is marshalled along
+// with the funds
+type fundsDataLike struct {
+	FundsData
+	crypto *cryptos.Crypto
+}
+
+type fundsDataLikeYAML struct {
+	Crypto string      `yaml:"crypto"`
+	Funds  interface{} `yaml:"funds"`
+}
+
+// Lock implement FundsData
+func (fd *fundsDataLike) Lock() Lock {
+	return &fundsLockLike{Lock: fd.FundsData.Lock(), crypto: fd.crypto}
+}
+
+// MarshalYAML implement yaml.Marshaler
+func (fd *fundsDataLike) MarshalYAML() (interface{}, error) {
+	return &fundsDataLikeYAML{Crypto: fd.crypto.Name, Funds: fd.FundsData}, nil
+}
+
+// UnmarshalYAML implement yaml.Unmarshaler
+func (fd *fundsDataLike) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	r := &struct {
+		Crypto string       `yaml:"crypto"`
+		Funds  deferredYAML `yaml:"funds"`
+	}{}
+	if err := unmarshal(r); err != nil {
+		return err
+	}
+	nfd, err := newFundsLike(&cryptos.Crypto{Name: r.Crypto})
+	if err != nil {
+		return err
+	}
+	if err = r.Funds.unmarshal(nfd.FundsData); err != nil {
+		return err
+	}
+	*fd = *nfd
+	return nil
+}
+
+// fundsLockLike is the lock of a registered crypto
+type fundsLockLike struct {
+	Lock
+	crypto *cryptos.Crypto
+}
+
+type fundsLockLikeYAML struct {
+	Crypto string      `yaml:"crypto"`
+	Lock   types.Bytes `yaml:"lock"`
+}
+
+// LockData implement Lock
+func (fl *fundsLockLike) LockData() (*LockData, error) {
+	return parseLockScript(fl.crypto, fl.Bytes())
+}
+
+// Address implement Lock
+func (fl *fundsLockLike) Address(chain params.Chain) (string, error) {
+	p, ok := networks.AllByName[fl.crypto.Name][chain]
+	if !ok {
+		return "", params.InvalidChainError(chain.String())
+	}
+	return p.P2SHFromScript(fl.Bytes())
+}
+
+// MarshalYAML implement yaml.Marshaler
+func (fl *fundsLockLike) MarshalYAML() (interface{}, error) {
+	return &fundsLockLikeYAML{Crypto: fl.crypto.Name, Lock: fl.Bytes()}, nil
+}
+
+// UnmarshalYAML implement yaml.Unmarshaler
+func (fl *fundsLockLike) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	r := &fundsLockLikeYAML{}
+	if err := unmarshal(r); err != nil {
+		return err
+	}
+	nfl, err := newFundsLockLike(&cryptos.Crypto{Name: r.Crypto}, r.Lock)
+	if err != nil {
+		return err
+	}
+	*fl = *nfl
+	return nil
+}
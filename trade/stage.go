@@ -0,0 +1,59 @@
+package trade
+
+import (
+	"github.com/transmutate-io/atomicswap/roles"
+	"github.com/transmutate-io/atomicswap/stages"
+)
+
+// FundsAmount returns the total amount of the funds
+func FundsAmount(fd FundsData) uint64 {
+	if fd == nil {
+		return 0
+	}
+	outputs, ok := fd.Funds().([]*Output)
+	if !ok {
+		return 0
+	}
+	var r uint64
+	for _, i := range outputs {
+		r += i.Amount
+	}
+	return r
+}
+
+func fundsTargetReached(fd FundsData, ti *TraderInfo) bool {
+	return FundsAmount(fd) >= ti.Amount.UInt64(ti.Crypto.Decimals)
+}
+
+func hasLock(fd FundsData) bool { return fd != nil && len(fd.Lock().Bytes()) > 0 }
+
+// CurrentStage returns the current stage of the trade
+func CurrentStage(tr Trade) stages.Stage {
+	if tr.Finished() {
+		return stages.Done
+	}
+	if tr.Role() == roles.Buyer {
+		switch {
+		case !hasLock(tr.RecoverableFunds()):
+			return stages.ShareProposal
+		case !fundsTargetReached(tr.RecoverableFunds(), tr.OwnInfo()):
+			return stages.LockFunds
+		case !fundsTargetReached(tr.RedeemableFunds(), tr.TraderInfo()):
+			return stages.WaitLockedFunds
+		default:
+			return stages.RedeemFunds
+		}
+	}
+	switch {
+	case FundsAmount(tr.RedeemableFunds()) == 0:
+		return stages.SendProposalResponse
+	case !fundsTargetReached(tr.RedeemableFunds(), tr.TraderInfo()):
+		return stages.WaitLockedFunds
+	case !fundsTargetReached(tr.RecoverableFunds(), tr.OwnInfo()):
+		return stages.LockFunds
+	case len(tr.Token()) == 0:
+		return stages.WaitSecretToken
+	default:
+		return stages.RedeemFunds
+	}
+}
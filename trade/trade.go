@@ -10,8 +10,10 @@ import (
 	"github.com/transmutate-io/atomicswap/duration"
 	"github.com/transmutate-io/atomicswap/hash"
 	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/atomicswap/psbt"
 	"github.com/transmutate-io/atomicswap/roles"
 	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/signer"
 	"github.com/transmutate-io/atomicswap/tx"
 	"github.com/transmutate-io/cryptocore/types"
 	"github.com/transmutate-io/reflection"
@@ -32,6 +34,12 @@ type (
 		GenerateBuyProposal() (*BuyProposal, error)
 		// SetLocks sets the locks for the trade
 		SetLocks(locks *Locks) error
+		// AcceptCounterProposal regenerates the trade from a counter-proposal
+		AcceptCounterProposal(cp *CounterProposal) error
+		// RejectProposal records the rejection of the last proposal
+		RejectProposal(rej *ProposalRejection) error
+		// SetTraderDuration sets the seller lock duration
+		SetTraderDuration(d duration.Duration)
 	}
 
 	// SellerTrade represents a seller trade
@@ -52,6 +60,20 @@ type (
 		Token() types.Bytes
 		// TokenHash returns the token hash
 		TokenHash() types.Bytes
+		// SessionID returns the negotiation session id
+		SessionID() types.Bytes
+		// SetSessionID sets the negotiation session id
+		SetSessionID(id types.Bytes)
+		// PeerSigner returns the identity key of the trader
+		PeerSigner() types.Bytes
+		// SetPeerSigner sets the identity key of the trader
+		SetPeerSigner(k types.Bytes)
+		// Finished returns true if the funds were redeemed or recovered
+		Finished() bool
+		// Finish marks the trade as finished
+		Finish()
+		// History returns the negotiation history
+		History() []*NegotiationRecord
 		// OwnInfo returns the trader info for the user
 		OwnInfo() *TraderInfo
 		// TraderInfo returns the trader info for the trader
@@ -66,6 +88,24 @@ type (
 		RecoverableFunds() FundsData
 		// GenerateKeys generates both redeem and recovery keys
 		GenerateKeys() error
+		// SetRedeemKey replaces the redeem key
+		SetRedeemKey(k key.Private) error
+		// SetRecoveryKey replaces the recovery key
+		SetRecoveryKey(k key.Private) error
+		// RedeemPublicKey returns the redeem public key
+		RedeemPublicKey() (key.Public, error)
+		// RecoveryPublicKey returns the recovery public key
+		RecoveryPublicKey() (key.Public, error)
+		// RedeemKeyRef returns the reference to the redeem key held by a signer
+		RedeemKeyRef() *signer.KeyRef
+		// RecoveryKeyRef returns the reference to the recovery key held by a signer
+		RecoveryKeyRef() *signer.KeyRef
+		// SetRedeemKeyRef replaces the redeem key with a key held by a signer
+		SetRedeemKeyRef(ref *signer.KeyRef) error
+		// SetRecoveryKeyRef replaces the recovery key with a key held by a signer
+		SetRecoveryKeyRef(ref *signer.KeyRef) error
+		// SetSigner sets the signer holding the referenced keys
+		SetSigner(s signer.Signer)
 		// SetToken sets the trade token (and token hash)
 		SetToken(token types.Bytes)
 		// RedeemTxFixedFee generates a redeem transaction with fixed fee
@@ -76,6 +116,20 @@ type (
 		RecoveryTxFixedFee(lockScript []byte, fee uint64) (tx.Tx, error)
 		// RecoveryTx generates a recovery transaction with fee per byte
 		RecoveryTx(lockScript []byte, feePerByte uint64) (tx.Tx, error)
+		// RedeemPSBTFixedFee generates an unsigned redeem transaction with fixed fee
+		RedeemPSBTFixedFee(lockScript []byte, fee uint64) (*psbt.Packet, error)
+		// RedeemPSBT generates an unsigned redeem transaction with fee per byte
+		RedeemPSBT(lockScript []byte, feePerByte uint64) (*psbt.Packet, error)
+		// RecoveryPSBTFixedFee generates an unsigned recovery transaction with fixed fee
+		RecoveryPSBTFixedFee(lockScript []byte, fee uint64) (*psbt.Packet, error)
+		// RecoveryPSBT generates an unsigned recovery transaction with fee per byte
+		RecoveryPSBT(lockScript []byte, feePerByte uint64) (*psbt.Packet, error)
+		// RedeemMaxFeeRate returns the maximum fee per byte the redeem transaction can
+		// pay leaving an output above the dust limit
+		RedeemMaxFeeRate(lockScript []byte) (uint64, error)
+		// RecoveryMaxFeeRate returns the maximum fee per byte the recovery transaction
+		// can pay leaving an output above the dust limit
+		RecoveryMaxFeeRate(lockScript []byte) (uint64, error)
 		// Buyer returns a buyer trade
 		Buyer() (BuyerTrade, error)
 		// Seller returns a seller trade
@@ -84,16 +138,24 @@ type (
 )
 
 type baseTrade struct {
-	Role             roles.Role        `yaml:"role"`
-	Duration         duration.Duration `yaml:"duration,omitempty"`
-	Token            types.Bytes       `yaml:"token,omitempty"`
-	TokenHash        types.Bytes       `yaml:"token_hash,omitempty"`
-	OwnInfo          *TraderInfo       `yaml:"own,omitempty"`
-	TraderInfo       *TraderInfo       `yaml:"trader,omitempty"`
-	RedeemKey        key.Private       `yaml:"redeem_key,omitempty"`
-	RecoveryKey      key.Private       `yaml:"recover_key,omitempty"`
-	RedeemableFunds  FundsData         `yaml:"redeemable_funds,omitempty"`
-	RecoverableFunds FundsData         `yaml:"recoverable_funds,omitempty"`
+	Role             roles.Role           `yaml:"role"`
+	Duration         duration.Duration    `yaml:"duration,omitempty"`
+	TraderDuration   duration.Duration    `yaml:"trader_duration,omitempty"`
+	Token            types.Bytes          `yaml:"token,omitempty"`
+	TokenHash        types.Bytes          `yaml:"token_hash,omitempty"`
+	SessionID        types.Bytes          `yaml:"session_id,omitempty"`
+	PeerSigner       types.Bytes          `yaml:"peer_signer,omitempty"`
+	Finished         bool                 `yaml:"finished,omitempty"`
+	OwnInfo          *TraderInfo          `yaml:"own,omitempty"`
+	TraderInfo       *TraderInfo          `yaml:"trader,omitempty"`
+	RedeemKey        key.Private          `yaml:"redeem_key,omitempty"`
+	RecoveryKey      key.Private          `yaml:"recover_key,omitempty"`
+	RedeemKeyRef     *signer.KeyRef       `yaml:"redeem_key_ref,omitempty"`
+	RecoveryKeyRef   *signer.KeyRef       `yaml:"recover_key_ref,omitempty"`
+	RedeemableFunds  FundsData            `yaml:"redeemable_funds,omitempty"`
+	RecoverableFunds FundsData            `yaml:"recoverable_funds,omitempty"`
+	History          []*NegotiationRecord `yaml:"history,omitempty"`
+	KeySigner        signer.Signer        `yaml:"-"`
 }
 
 func newBuyerBaseTrade(dur time.Duration, ownAmount types.Amount, ownCrypto *cryptos.Crypto, traderAmount types.Amount, traderCrypto *cryptos.Crypto) (*baseTrade, error) {
@@ -176,6 +238,13 @@ func (bt *baseTrade) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := reflection.CopyFields(td, bt); err != nil {
 		return err
 	}
+	// keys held by a signer are not stored
+	if bt.RedeemKeyRef != nil {
+		bt.RedeemKey = nil
+	}
+	if bt.RecoveryKeyRef != nil {
+		bt.RecoveryKey = nil
+	}
 	return nil
 }
 
@@ -190,6 +259,15 @@ func (bt *baseTrade) SetToken(token types.Bytes) {
 	bt.TokenHash = TokenHash(token)
 }
 
+// SetSessionID sets the negotiation session id
+func (bt *baseTrade) SetSessionID(id types.Bytes) { bt.SessionID = id }
+
+// SetPeerSigner sets the identity key of the trader
+func (bt *baseTrade) SetPeerSigner(k types.Bytes) { bt.PeerSigner = k }
+
+// Finish marks the trade as finished
+func (bt *baseTrade) Finish() { bt.Finished = true }
+
 // ErrNotEnoughBytes is returned the is not possible to read enough random bytes
 var ErrNotEnoughBytes = errors.New("not enough bytes")
 
@@ -234,6 +312,105 @@ func (bt *baseTrade) GenerateKeys() error {
 	return err
 }
 
+// ErrKeyCommitted is returned when replacing a key already shared with the trader
+var ErrKeyCommitted = errors.New("key already committed to the trade")
+
+// keysCommitted returns true if the keys were already shared with the trader
+func (bt *baseTrade) keysCommitted() bool {
+	if len(bt.History) > 0 {
+		return true
+	}
+	for _, i := range []FundsData{bt.RedeemableFunds, bt.RecoverableFunds} {
+		if i != nil && len(i.Lock().Bytes()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// ErrMissingKey is returned when a trade key is not set
+	ErrMissingKey = errors.New("missing key")
+
+	// ErrMissingSigner is returned when a key is held by a signer which is not set
+	ErrMissingSigner = errors.New("missing signer")
+)
+
+// publicKey returns the public key of a trade key or key reference
+func publicKey(c *cryptos.Crypto, k key.Private, ref *signer.KeyRef) (key.Public, error) {
+	if k != nil {
+		return k.Public(), nil
+	}
+	if ref == nil {
+		return nil, ErrMissingKey
+	}
+	return key.ParsePublic(c, ref.Public)
+}
+
+// RedeemPublicKey implement Trade
+func (bt *baseTrade) RedeemPublicKey() (key.Public, error) {
+	return publicKey(bt.TraderInfo.Crypto, bt.RedeemKey, bt.RedeemKeyRef)
+}
+
+// RecoveryPublicKey implement Trade
+func (bt *baseTrade) RecoveryPublicKey() (key.Public, error) {
+	return publicKey(bt.OwnInfo.Crypto, bt.RecoveryKey, bt.RecoveryKeyRef)
+}
+
+// keySigner returns the signer and the reference for a trade key
+func (bt *baseTrade) keySigner(k key.Private, ref *signer.KeyRef) (signer.Signer, *signer.KeyRef, error) {
+	if k != nil {
+		return signer.NewInProcess(k), signer.NewKeyRef(k.Public(), ""), nil
+	}
+	if ref == nil {
+		return nil, nil, ErrMissingKey
+	}
+	if bt.KeySigner == nil {
+		return nil, nil, ErrMissingSigner
+	}
+	return bt.KeySigner, ref, nil
+}
+
+// setKey replaces a key or key reference. The same public key can always be
+// set, a different one only if the keys are not committed
+func (bt *baseTrade) setKey(c *cryptos.Crypto, dst *key.Private, dstRef **signer.KeyRef, k key.Private, ref *signer.KeyRef) error {
+	newPub, err := publicKey(c, k, ref)
+	if err != nil {
+		return err
+	}
+	cur, err := publicKey(c, *dst, *dstRef)
+	if err != nil || !bytes.Equal(cur.SerializeCompressed(), newPub.SerializeCompressed()) {
+		if bt.keysCommitted() {
+			return ErrKeyCommitted
+		}
+	}
+	*dst, *dstRef = k, ref
+	return nil
+}
+
+// SetRedeemKey implement Trade
+func (bt *baseTrade) SetRedeemKey(k key.Private) error {
+	return bt.setKey(bt.TraderInfo.Crypto, &bt.RedeemKey, &bt.RedeemKeyRef, k, nil)
+}
+
+// SetRecoveryKey implement Trade
+func (bt *baseTrade) SetRecoveryKey(k key.Private) error {
+	return bt.setKey(bt.OwnInfo.Crypto, &bt.RecoveryKey, &bt.RecoveryKeyRef, k, nil)
+}
+
+// SetRedeemKeyRef implement Trade
+func (bt *baseTrade) SetRedeemKeyRef(ref *signer.KeyRef) error {
+	return bt.setKey(bt.TraderInfo.Crypto, &bt.RedeemKey, &bt.RedeemKeyRef, nil, ref)
+}
+
+// SetRecoveryKeyRef implement Trade
+func (bt *baseTrade) SetRecoveryKeyRef(ref *signer.KeyRef) error {
+	return bt.setKey(bt.OwnInfo.Crypto, &bt.RecoveryKey, &bt.RecoveryKeyRef, nil, ref)
+}
+
+// SetSigner implement Trade
+func (bt *baseTrade) SetSigner(s signer.Signer) { bt.KeySigner = s }
+
 var (
 	// ErrNotABuyerTrade is returned if the trade is not a buy
 	ErrNotABuyerTrade = errors.New("not a buyer trade")
@@ -242,10 +419,33 @@ var (
 	ErrNotASellerTrade = errors.New("not a seller trade")
 )
 
+// SetTraderDuration implement BuyerTrade
+func (bt *baseTrade) SetTraderDuration(d duration.Duration) { bt.TraderDuration = d }
+
+// sellerLockDuration returns the seller lock duration
+func (bt *baseTrade) sellerLockDuration() duration.Duration {
+	if bt.TraderDuration != 0 {
+		return bt.TraderDuration
+	}
+	return bt.Duration / 2
+}
+
 // GenerateBuyProposal implement BuyerTrade
 func (bt *baseTrade) GenerateBuyProposal() (*BuyProposal, error) {
 	// only a buyer can generate a proposal
-	return &BuyProposal{
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	recoveryPub, err := bt.RecoveryPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	redeemPub, err := bt.RedeemPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	r := &BuyProposal{
 		Buyer: &BuyProposalInfo{
 			Crypto:       bt.OwnInfo.Crypto,
 			Amount:       bt.OwnInfo.Amount,
@@ -254,12 +454,21 @@ func (bt *baseTrade) GenerateBuyProposal() (*BuyProposal, error) {
 		Seller: &BuyProposalInfo{
 			Crypto:       bt.TraderInfo.Crypto,
 			Amount:       bt.TraderInfo.Amount,
-			LockDuration: bt.Duration / 2,
+			LockDuration: bt.sellerLockDuration(),
 		},
-		RecoveryKeyData: bt.RecoveryKey.Public().KeyData(),
-		RedeemKeyData:   bt.RedeemKey.Public().KeyData(),
+		RecoveryKeyData: recoveryPub.KeyData(),
+		RedeemKeyData:   redeemPub.KeyData(),
 		TokenHash:       bt.TokenHash,
-	}, nil
+		Expiry:          time.Now().UTC().Add(ProposalExpiry),
+		Nonce:           nonce,
+	}
+	bt.addHistory(&NegotiationRecord{
+		Event:  ProposalSent,
+		Nonce:  r.Nonce,
+		Buyer:  r.Buyer,
+		Seller: r.Seller,
+	})
+	return r, nil
 }
 
 // generates a lock
@@ -283,6 +492,13 @@ func generateTimeLock(c *cryptos.Crypto, lockTime time.Time, tokenHash []byte, r
 func (bt *baseTrade) AcceptBuyProposal(prop *BuyProposal) error {
 	// set duration
 	bt.Duration = prop.Seller.LockDuration
+	// record the proposal
+	bt.addHistory(&NegotiationRecord{
+		Event:  ProposalAccepted,
+		Nonce:  prop.Nonce,
+		Buyer:  prop.Buyer,
+		Seller: prop.Seller,
+	})
 	// set token hash
 	bt.TokenHash = prop.TokenHash
 	// own info
@@ -299,6 +515,14 @@ func (bt *baseTrade) AcceptBuyProposal(prop *BuyProposal) error {
 	if err := bt.GenerateKeys(); err != nil {
 		return err
 	}
+	redeemPub, err := bt.RedeemPublicKey()
+	if err != nil {
+		return err
+	}
+	recoveryPub, err := bt.RecoveryPublicKey()
+	if err != nil {
+		return err
+	}
 	// now
 	timeNow := time.Now().UTC()
 	// generate buyer lock
@@ -306,7 +530,7 @@ func (bt *baseTrade) AcceptBuyProposal(prop *BuyProposal) error {
 		prop.Buyer.Crypto,
 		timeNow.Add(time.Duration(prop.Buyer.LockDuration)),
 		prop.TokenHash,
-		bt.RedeemKey.Public().KeyData(),
+		redeemPub.KeyData(),
 		prop.RecoveryKeyData,
 	)
 	if err != nil {
@@ -322,7 +546,7 @@ func (bt *baseTrade) AcceptBuyProposal(prop *BuyProposal) error {
 		timeNow.Add(time.Duration(prop.Seller.LockDuration)),
 		prop.TokenHash,
 		prop.RedeemKeyData,
-		bt.RecoveryKey.Public().KeyData(),
+		recoveryPub.KeyData(),
 	)
 	if err != nil {
 		return err
@@ -355,16 +579,24 @@ func (bt *baseTrade) SetLocks(locks *Locks) error {
 	if err != nil {
 		return err
 	}
-	if bd.LockTime.Sub(sd.LockTime) != time.Duration(bt.Duration)/2 {
+	if bd.LockTime.Sub(sd.LockTime) != time.Duration(bt.Duration-bt.sellerLockDuration()) {
 		return ErrInvalidLockInterval
 	}
 	if !bytes.Equal(bd.TokenHash, sd.TokenHash) || !bytes.Equal(bd.TokenHash, bt.TokenHash) {
 		return ErrMismatchTokenHash
 	}
-	if !bytes.Equal(bd.RecoveryKeyData, bt.RecoveryKey.Public().KeyData()) {
+	recoveryPub, err := bt.RecoveryPublicKey()
+	if err != nil {
+		return err
+	}
+	redeemPub, err := bt.RedeemPublicKey()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(bd.RecoveryKeyData, recoveryPub.KeyData()) {
 		return ErrMismatchKeyData
 	}
-	if !bytes.Equal(sd.RedeemKeyData, bt.RedeemKey.Public().KeyData()) {
+	if !bytes.Equal(sd.RedeemKeyData, redeemPub.KeyData()) {
 		return ErrMismatchKeyData
 	}
 	bt.RecoverableFunds.SetLock(locks.Buyer)
@@ -383,7 +615,33 @@ func (bt *baseTrade) Locks() *Locks {
 // ErrNotUTXO is returned in the case the crypto is not a utxo crypto
 var ErrNotUTXO = errors.New("not a utxo crypto")
 
-func (bt *baseTrade) newRedeemTxUTXO(lockScript []byte, fee uint64) (tx.Tx, error) {
+// htlcOutputs returns the outputs holding the funds
+func htlcOutputs(c *cryptos.Crypto, fd FundsData) ([]*tx.Output, error) {
+	gen, err := script.NewGenerator(c)
+	if err != nil {
+		return nil, err
+	}
+	pkScript := gen.P2SHScript(fd.Lock().Bytes())
+	funds := fd.Funds().([]*Output)
+	r := make([]*tx.Output, 0, len(funds))
+	for _, i := range funds {
+		r = append(r, &tx.Output{Value: i.Amount, Script: pkScript})
+	}
+	return r, nil
+}
+
+// verifySpend executes the scripts of a transaction spending the funds
+func verifySpend(t tx.Tx, c *cryptos.Crypto, fd FundsData) error {
+	outs, err := htlcOutputs(c, fd)
+	if err != nil {
+		return err
+	}
+	return tx.Verify(t, outs)
+}
+
+// unsignedRedeemTx returns the redeem transaction without signature scripts.
+// inputScript is set as the script of every input
+func (bt *baseTrade) unsignedRedeemTx(lockScript []byte, fee uint64, inputScript []byte) (tx.Tx, error) {
 	r, err := tx.New(bt.TraderInfo.Crypto)
 	if err != nil {
 		return nil, err
@@ -393,32 +651,51 @@ func (bt *baseTrade) newRedeemTxUTXO(lockScript []byte, fee uint64) (tx.Tx, erro
 		return nil, ErrNotUTXO
 	}
 	amount := uint64(0)
-	redeemableOutputs := bt.RedeemableFunds.Funds().([]*Output)
-	for _, i := range redeemableOutputs {
+	for _, i := range bt.RedeemableFunds.Funds().([]*Output) {
 		amount += i.Amount
-		if err = tx.AddInput(i.TxID, i.N, bt.RedeemableFunds.Lock().Bytes(), i.Amount); err != nil {
+		if err = tx.AddInput(i.TxID, i.N, inputScript, i.Amount); err != nil {
 			return nil, err
 		}
 	}
+	value, err := outputValue(bt.TraderInfo.Crypto, amount, fee, lockScript)
+	if err != nil {
+		return nil, err
+	}
+	tx.AddOutput(value, lockScript)
+	return r, nil
+}
+
+func (bt *baseTrade) newRedeemTxUTXO(lockScript []byte, fee uint64) (tx.Tx, error) {
+	r, err := bt.unsignedRedeemTx(lockScript, fee, bt.RedeemableFunds.Lock().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	tx, _ := r.TxUTXO()
 	gen, err := script.NewGenerator(bt.TraderInfo.Crypto)
 	if err != nil {
 		return nil, err
 	}
-	tx.AddOutput(amount-fee, lockScript)
-	for i := range redeemableOutputs {
-		sig, err := tx.InputSignature(i, 1, bt.RedeemKey)
+	s, ref, err := bt.keySigner(bt.RedeemKey, bt.RedeemKeyRef)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tx.Inputs() {
+		sig, err := s.SignInput(r, i, 1, ref)
 		if err != nil {
 			return nil, err
 		}
 		tx.SetInputSignatureScript(i,
 			gen.HTLCRedeem(
 				sig,
-				bt.RedeemKey.Public().SerializeCompressed(),
+				ref.Public,
 				bt.Token,
 				bt.RedeemableFunds.Lock().Bytes(),
 			),
 		)
 	}
+	if err = verifySpend(r, bt.TraderInfo.Crypto, bt.RedeemableFunds); err != nil {
+		return nil, err
+	}
 	return r, nil
 }
 
@@ -444,10 +721,12 @@ func (bt *baseTrade) RedeemTx(lockScript []byte, feePerByte uint64) (tx.Tx, erro
 	if err != nil {
 		return nil, err
 	}
-	return bt.newRedeemTx(lockScript, feePerByte*tx.SerializedSize())
+	return bt.newRedeemTx(lockScript, feeForSize(feePerByte, tx.SerializedSize()))
 }
 
-func (bt *baseTrade) newRecoveryTxUTXO(lockScript []byte, fee uint64) (tx.Tx, error) {
+// unsignedRecoveryTx returns the recovery transaction without signature
+// scripts. inputScript is set as the script of every input
+func (bt *baseTrade) unsignedRecoveryTx(lockScript []byte, fee uint64, inputScript []byte) (tx.Tx, error) {
 	r, err := tx.New(bt.OwnInfo.Crypto)
 	if err != nil {
 		return nil, err
@@ -457,37 +736,56 @@ func (bt *baseTrade) newRecoveryTxUTXO(lockScript []byte, fee uint64) (tx.Tx, er
 		return nil, ErrNotUTXO
 	}
 	amount := uint64(0)
-	outputs := bt.RecoverableFunds.Funds().([]*Output)
-	for ni, i := range outputs {
+	for ni, i := range bt.RecoverableFunds.Funds().([]*Output) {
 		amount += i.Amount
-		if err := tx.AddInput(i.TxID, i.N, bt.RecoverableFunds.Lock().Bytes(), i.Amount); err != nil {
+		if err := tx.AddInput(i.TxID, i.N, inputScript, i.Amount); err != nil {
 			return nil, err
 		}
 		tx.SetInputSequenceNumber(ni, 0xfffffffe)
 	}
-	gen, err := script.NewGenerator(bt.OwnInfo.Crypto)
+	value, err := outputValue(bt.OwnInfo.Crypto, amount, fee, lockScript)
 	if err != nil {
 		return nil, err
 	}
-	tx.AddOutput(amount-fee, lockScript)
+	tx.AddOutput(value, lockScript)
 	lst, err := bt.RecoverableFunds.Lock().LockData()
 	if err != nil {
 		return nil, err
 	}
 	tx.SetLockTime(lst.LockTime.UTC())
-	for i := range outputs {
-		sig, err := tx.InputSignature(i, 1, bt.RecoveryKey)
+	return r, nil
+}
+
+func (bt *baseTrade) newRecoveryTxUTXO(lockScript []byte, fee uint64) (tx.Tx, error) {
+	r, err := bt.unsignedRecoveryTx(lockScript, fee, bt.RecoverableFunds.Lock().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	tx, _ := r.TxUTXO()
+	gen, err := script.NewGenerator(bt.OwnInfo.Crypto)
+	if err != nil {
+		return nil, err
+	}
+	s, ref, err := bt.keySigner(bt.RecoveryKey, bt.RecoveryKeyRef)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tx.Inputs() {
+		sig, err := s.SignInput(r, i, 1, ref)
 		if err != nil {
 			return nil, err
 		}
 		tx.SetInputSignatureScript(i,
 			gen.HTLCRecover(
 				sig,
-				bt.RecoveryKey.Public().SerializeCompressed(),
+				ref.Public,
 				bt.RecoverableFunds.Lock().Bytes(),
 			),
 		)
 	}
+	if err = verifySpend(r, bt.OwnInfo.Crypto, bt.RecoverableFunds); err != nil {
+		return nil, err
+	}
 	return r, nil
 }
 
@@ -513,5 +811,5 @@ func (bt *baseTrade) RecoveryTx(lockScript []byte, feePerByte uint64) (tx.Tx, er
 	if err != nil {
 		return nil, err
 	}
-	return bt.newRecoveryTx(lockScript, tx.SerializedSize()*feePerByte)
+	return bt.newRecoveryTx(lockScript, feeForSize(feePerByte, tx.SerializedSize()))
 }
@@ -3,6 +3,7 @@ package trade
 import (
 	"encoding/hex"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -11,16 +12,27 @@ import (
 	"github.com/transmutate-io/atomicswap/internal/testutil"
 	"github.com/transmutate-io/atomicswap/key"
 	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/atomicswap/signer"
 	"github.com/transmutate-io/cryptocore/types"
 	"gopkg.in/yaml.v2"
 )
 
-func init() {
-	for _, i := range testutil.Cryptos {
-		if err := testutil.SetupMinerAddress(i); err != nil {
-			panic(err)
+var (
+	setupMinersOnce sync.Once
+	setupMinersErr  error
+)
+
+// setupMiners sets the miner addresses of the test nodes once. Only the tests
+// using the nodes need them
+func setupMiners(t *testing.T) {
+	setupMinersOnce.Do(func() {
+		for _, i := range testutil.Cryptos {
+			if setupMinersErr = testutil.SetupMinerAddress(i); setupMinersErr != nil {
+				return
+			}
 		}
-	}
+	})
+	require.NoError(t, setupMinersErr, "can't setup miner addresses")
 }
 
 func testTradeMarshalUnamarshal(t *testing.T, tc *testutil.Crypto) {
@@ -71,6 +83,33 @@ func TestTradeMarshalUnamarshal(t *testing.T) {
 	}
 }
 
+func TestAcceptCounterProposalKeyRefs(t *testing.T) {
+	tr, err := NewOnChainTrade(types.Amount("1"), cryptos.Bitcoin, types.Amount("2"), cryptos.Litecoin, 48*time.Hour)
+	require.NoError(t, err, "can't create trade")
+	// the keys are held by a signer
+	redeemKey, err := key.NewPrivate(cryptos.Litecoin)
+	require.NoError(t, err, "can't create key")
+	recoveryKey, err := key.NewPrivate(cryptos.Bitcoin)
+	require.NoError(t, err, "can't create key")
+	require.NoError(t, tr.SetRedeemKeyRef(signer.NewKeyRef(redeemKey.Public(), "")), "can't set redeem key ref")
+	require.NoError(t, tr.SetRecoveryKeyRef(signer.NewKeyRef(recoveryKey.Public(), "")), "can't set recovery key ref")
+	btr, err := tr.Buyer()
+	require.NoError(t, err, "can't get buyer trade")
+	prop, err := btr.GenerateBuyProposal()
+	require.NoError(t, err, "can't generate proposal")
+	cp, err := NewCounterProposal(prop, "", types.Amount("3"), 0, 0)
+	require.NoError(t, err, "can't create counter-proposal")
+	require.NoError(t, btr.AcceptCounterProposal(cp), "can't accept counter-proposal")
+	require.Nil(t, tr.RedeemKeyRef(), "expecting no redeem key ref")
+	require.Nil(t, tr.RecoveryKeyRef(), "expecting no recovery key ref")
+	b, err := yaml.Marshal(tr)
+	require.NoError(t, err, "can't marshal")
+	tr2 := &OnChainTrade{baseTrade: &baseTrade{}}
+	require.NoError(t, yaml.Unmarshal(b, tr2), "can't unmarshal")
+	require.Equal(t, tr.RedeemKey(), tr2.RedeemKey(), "redeem keys mismatch")
+	require.Equal(t, tr.RecoveryKey(), tr2.RecoveryKey(), "recovery keys mismatch")
+}
+
 func newBuyerTrade(own, trader *testutil.Crypto, dur time.Duration) (Trade, error) {
 	// parse cryptos names
 	ownCrypto, err := cryptos.Parse(own.Name)
@@ -214,6 +253,7 @@ func testOnChainRedeem(t *testing.T, buyerCrypto, sellerCrypto *testutil.Crypto,
 }
 
 func TestOnChainRedeem(t *testing.T) {
+	setupMiners(t)
 	for _, i := range testutil.Cryptos[1:] {
 		t.Run("bitcoin_"+i.Name, testOnChainRedeem(
 			t,
@@ -295,6 +335,7 @@ func recoverFunds(t *testing.T, tr Trade, tc *testutil.Crypto) error {
 }
 
 func TestOnChainRecover(t *testing.T) {
+	setupMiners(t)
 	for _, i := range testutil.Cryptos[1:] {
 		t.Run("bitcoin_"+i.Name, newTestOnChainRecover(
 			testutil.Cryptos[0],
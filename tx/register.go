@@ -0,0 +1,50 @@
+package tx
+
+import "github.com/transmutate-io/atomicswap/cryptos"
+
+// RegisterLike registers the transactions of base for the crypto c
+func RegisterLike(c *cryptos.Crypto, base *cryptos.Crypto) error {
+	nf, ok := txFuncs[base.Name]
+	if !ok {
+		return cryptos.InvalidCryptoError(base.Name)
+	}
+	txFuncs[c.Name] = func() (Tx, error) {
+		tx, err := nf()
+		if err != nil {
+			return nil, err
+		}
+		return &txLike{Tx: tx, crypto: c}, nil
+	}
+	pf := parseFuncs[base.Name]
+	parseFuncs[c.Name] = func(b []byte) (Tx, error) {
+		tx, err := pf(b)
+		if err != nil {
+			return nil, err
+		}
+		return &txLike{Tx: tx, crypto: c}, nil
+	}
+	if v, ok := verifiers[base.Name]; ok {
+		verifiers[c.Name] = v
+	}
+	return nil
+}
+
+// txLike is a transaction of a registered crypto
+type txLike struct {
+	Tx
+	crypto *cryptos.Crypto
+}
+
+// Crypto implement Tx
+func (tx *txLike) Crypto() *cryptos.Crypto { return tx.crypto }
+
+// Copy implement Tx
+func (tx *txLike) Copy() Tx { return &txLike{Tx: tx.Tx.Copy(), crypto: tx.crypto} }
+
+// MarshalYAML implement yaml.Marshaler
+func (tx *txLike) MarshalYAML() (interface{}, error) { return tx.Tx, nil }
+
+// UnmarshalYAML implement yaml.Unmarshaler
+func (tx *txLike) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return unmarshal(tx.Tx)
+}
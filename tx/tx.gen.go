@@ -7,3 +7,11 @@ var txFuncs = map[string]NewTxFunc{
 	"dogecoin":     NewDOGE,
 	"litecoin":     NewLTC,
 }
+
+var parseFuncs = map[string]ParseTxFunc{
+	"bitcoin-cash": ParseBCH,
+	"bitcoin":      ParseBTC,
+	"decred":       ParseDCR,
+	"dogecoin":     ParseDOGE,
+	"litecoin":     ParseLTC,
+}
@@ -6,12 +6,31 @@ import (
 
 	"github.com/transmutate-io/atomicswap/cryptos"
 	"github.com/transmutate-io/atomicswap/key"
+	"github.com/transmutate-io/cryptocore/types"
 )
 
 type (
 	// NewTxFunc represents a new transaction function
 	NewTxFunc = func() (Tx, error)
 
+	// ParseTxFunc represents a parse transaction function
+	ParseTxFunc = func(b []byte) (Tx, error)
+
+	// Input represents a transaction input
+	Input struct {
+		TxID            types.Bytes   `yaml:"txid"`
+		N               uint32        `yaml:"n"`
+		SignatureScript types.Bytes   `yaml:"signature_script"`
+		Sequence        uint32        `yaml:"sequence"`
+		Witness         []types.Bytes `yaml:"witness,omitempty"`
+	}
+
+	// Output represents a transaction output
+	Output struct {
+		Value  uint64      `yaml:"value"`
+		Script types.Bytes `yaml:"script"`
+	}
+
 	// Serializer represents a serializable object
 	Serializer interface {
 		// Serialize serializes the transaction
@@ -30,6 +49,13 @@ type (
 		InputSignature(idx int, hashType uint32, privKey key.Private) ([]byte, error)
 		// SetInputSequenceNumber sets the sequence number for a given input
 		SetInputSequenceNumber(idx int, seq uint32)
+		// SetInputAmount sets the amount of the output spent by an input
+		SetInputAmount(idx int, amount uint64)
+		// InputAmount returns the amount of the output spent by an input, if known
+		InputAmount(idx int) uint64
+		// VerifyInput executes the signature script of an input against the
+		// script of the spent output
+		VerifyInput(idx int, pkScript []byte, amount uint64) error
 		// InputSequenceNumber returns the sequence number of a given input
 		InputSequenceNumber(idx int) uint32
 		// SetLockTimeUInt32 sets the locktime
@@ -46,6 +72,16 @@ type (
 		SignP2PKInput(idx int, hashType uint32, privKey key.Private) error
 		// SignP2PKHInput signs a p2pkh input
 		SignP2PKHInput(idx int, hashType uint32, privKey key.Private) error
+		// Inputs returns the transaction inputs
+		Inputs() []*Input
+		// Outputs returns the transaction outputs
+		Outputs() []*Output
+		// LockTimeUInt32 returns the locktime
+		LockTimeUInt32() uint32
+		// TxID returns the transaction id
+		TxID() types.Bytes
+		// WTxID returns the witness transaction id
+		WTxID() types.Bytes
 	}
 
 	// TxStateBased represents a state based transaction
@@ -74,6 +110,13 @@ var (
 
 	// ErrNotUTXO is returned when the transaction is not utxo
 	ErrNotUTXO = errors.New("not UTXO")
+
+	// ErrInputAmountsMismatch is returned when the number of previous
+	// amounts doesn't match the number of inputs
+	ErrInputAmountsMismatch = errors.New("input amounts mismatch")
+
+	// ErrNegativeFee is returned when the outputs spend more than the inputs
+	ErrNegativeFee = errors.New("negative fee")
 )
 
 // New returns a new transaction for the given crypto
@@ -84,3 +127,31 @@ func New(c *cryptos.Crypto) (Tx, error) {
 	}
 	return nf()
 }
+
+// Parse parses a serialized transaction for the given crypto
+func Parse(c *cryptos.Crypto, b []byte) (Tx, error) {
+	pf, ok := parseFuncs[c.Name]
+	if !ok {
+		return nil, cryptos.InvalidCryptoError(c.Name)
+	}
+	return pf(b)
+}
+
+// Fee returns the fee paid by tx given the amounts of the outputs spent
+// by each input
+func Fee(tx TxUTXO, prevAmounts []uint64) (uint64, error) {
+	if len(prevAmounts) != len(tx.Inputs()) {
+		return 0, ErrInputAmountsMismatch
+	}
+	var in, out uint64
+	for _, i := range prevAmounts {
+		in += i
+	}
+	for _, i := range tx.Outputs() {
+		out += i.Value
+	}
+	if out > in {
+		return 0, ErrNegativeFee
+	}
+	return in - out, nil
+}
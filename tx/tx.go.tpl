@@ -5,3 +5,9 @@ var txFuncs = map[string]NewTxFunc{
 	"{{ $d.name }}": New{{ $short }},
     {{- end }}
 }
+
+var parseFuncs = map[string]ParseTxFunc{
+    {{- range $short,$d := .Values.cryptos }}
+	"{{ $d.name }}": Parse{{ $short }},
+    {{- end }}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/transmutate-io/atomicswap/cryptos"
 	"github.com/transmutate-io/atomicswap/key"
 	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/cryptocore/types"
 )
 
 // tx represents a transaction
@@ -27,6 +28,16 @@ func NewBCH() (Tx, error) {
 	}, nil
 }
 
+// ParseBCH parses a transaction for bitcoin-cash. The amounts of the inputs
+// aren't serialized and are set to zero
+func ParseBCH(b []byte) (Tx, error) {
+	r := &wire.MsgTx{}
+	if err := r.Deserialize(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+	return &txBCH{MsgTx: r, InputsAmounts: make([]uint64, len(r.TxIn))}, nil
+}
+
 // AddOutput implement TxUTXO
 func (tx *txBCH) AddOutput(value uint64, script []byte) {
 	tx.MsgTx.AddTxOut(wire.NewTxOut(int64(value), script))
@@ -60,6 +71,29 @@ func (tx *txBCH) SetInputSequenceNumber(idx int, seq uint32) {
 	tx.TxIn[idx].Sequence = seq
 }
 
+// SetInputAmount implement TxUTXO
+func (tx *txBCH) SetInputAmount(idx int, amount uint64) { tx.InputsAmounts[idx] = amount }
+
+// InputAmount implement TxUTXO
+func (tx *txBCH) InputAmount(idx int) uint64 { return tx.InputsAmounts[idx] }
+
+// VerifyInput implement TxUTXO
+func (tx *txBCH) VerifyInput(idx int, pkScript []byte, amount uint64) error {
+	vm, err := txscript.NewEngine(
+		pkScript,
+		tx.MsgTx,
+		idx,
+		txscript.StandardVerifyFlags,
+		nil,
+		nil,
+		int64(amount),
+	)
+	if err != nil {
+		return err
+	}
+	return runEngine(vm)
+}
+
 // InputSequenceNumber implement TxUTXO
 func (tx *txBCH) InputSequenceNumber(idx int) uint32 { return tx.MsgTx.TxIn[idx].Sequence }
 
@@ -106,6 +140,41 @@ func (tx *txBCH) SignP2PKHInput(idx int, hashType uint32, privKey key.Private) e
 	return nil
 }
 
+// Inputs implement TxUTXO
+func (tx *txBCH) Inputs() []*Input {
+	r := make([]*Input, 0, len(tx.TxIn))
+	for _, i := range tx.TxIn {
+		r = append(r, &Input{
+			TxID:            bytesReverse(i.PreviousOutPoint.Hash[:]),
+			N:               i.PreviousOutPoint.Index,
+			SignatureScript: i.SignatureScript,
+			Sequence:        i.Sequence,
+		})
+	}
+	return r
+}
+
+// Outputs implement TxUTXO
+func (tx *txBCH) Outputs() []*Output {
+	r := make([]*Output, 0, len(tx.TxOut))
+	for _, i := range tx.TxOut {
+		r = append(r, &Output{Value: uint64(i.Value), Script: i.PkScript})
+	}
+	return r
+}
+
+// LockTimeUInt32 implement TxUTXO
+func (tx *txBCH) LockTimeUInt32() uint32 { return tx.LockTime }
+
+// TxID implement TxUTXO
+func (tx *txBCH) TxID() types.Bytes {
+	h := tx.MsgTx.TxHash()
+	return bytesReverse(h[:])
+}
+
+// WTxID implement TxUTXO
+func (tx *txBCH) WTxID() types.Bytes { return tx.TxID() }
+
 // Serialize implement Serializer
 func (tx *txBCH) Serialize() ([]byte, error) {
 	r := bytes.NewBuffer(make([]byte, 0, 1024))
@@ -124,7 +193,8 @@ func (tx *txBCH) TxUTXO() (TxUTXO, bool) { return tx, true }
 // TxStateBased implement Tx
 func (tx *txBCH) TxStateBased() (TxStateBased, bool) { return nil, false }
 
-func (tx *txBCH) Crypto() *cryptos.Crypto { return cryptos.Cryptos["bitcoin"] }
+// Crypto implement Tx
+func (tx *txBCH) Crypto() *cryptos.Crypto { return cryptos.Cryptos["bitcoin-cash"] }
 
 // Copy implement Tx
 func (tx *txBCH) Copy() Tx {
@@ -11,6 +11,7 @@ import (
 	"github.com/transmutate-io/atomicswap/cryptos"
 	"github.com/transmutate-io/atomicswap/key"
 	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/cryptocore/types"
 )
 
 // tx represents a transaction
@@ -19,6 +20,15 @@ type txBTC wire.MsgTx
 // NewBTC creates a new transaction for bitcoin
 func NewBTC() (Tx, error) { return (*txBTC)(wire.NewMsgTx(wire.TxVersion)), nil }
 
+// ParseBTC parses a transaction for bitcoin
+func ParseBTC(b []byte) (Tx, error) {
+	r := &wire.MsgTx{}
+	if err := r.Deserialize(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+	return (*txBTC)(r), nil
+}
+
 func (tx *txBTC) tx() *wire.MsgTx { return (*wire.MsgTx)(tx) }
 
 // AddOutput implement TxUTXO
@@ -61,6 +71,29 @@ func (tx *txBTC) SetInputSequenceNumber(idx int, seq uint32) {
 	tx.TxIn[idx].Sequence = seq
 }
 
+// SetInputAmount implement TxUTXO
+func (tx *txBTC) SetInputAmount(idx int, amount uint64) {}
+
+// InputAmount implement TxUTXO
+func (tx *txBTC) InputAmount(idx int) uint64 { return 0 }
+
+// VerifyInput implement TxUTXO
+func (tx *txBTC) VerifyInput(idx int, pkScript []byte, amount uint64) error {
+	vm, err := txscript.NewEngine(
+		pkScript,
+		tx.tx(),
+		idx,
+		txscript.StandardVerifyFlags,
+		nil,
+		nil,
+		int64(amount),
+	)
+	if err != nil {
+		return err
+	}
+	return runEngine(vm)
+}
+
 // InputSequenceNumber implement TxUTXO
 func (tx *txBTC) InputSequenceNumber(idx int) uint32 { return tx.tx().TxIn[idx].Sequence }
 
@@ -107,6 +140,48 @@ func (tx *txBTC) SignP2PKHInput(idx int, hashType uint32, privKey key.Private) e
 	return nil
 }
 
+// Inputs implement TxUTXO
+func (tx *txBTC) Inputs() []*Input {
+	r := make([]*Input, 0, len(tx.TxIn))
+	for _, i := range tx.TxIn {
+		in := &Input{
+			TxID:            bytesReverse(i.PreviousOutPoint.Hash[:]),
+			N:               i.PreviousOutPoint.Index,
+			SignatureScript: i.SignatureScript,
+			Sequence:        i.Sequence,
+		}
+		for _, j := range i.Witness {
+			in.Witness = append(in.Witness, j)
+		}
+		r = append(r, in)
+	}
+	return r
+}
+
+// Outputs implement TxUTXO
+func (tx *txBTC) Outputs() []*Output {
+	r := make([]*Output, 0, len(tx.TxOut))
+	for _, i := range tx.TxOut {
+		r = append(r, &Output{Value: uint64(i.Value), Script: i.PkScript})
+	}
+	return r
+}
+
+// LockTimeUInt32 implement TxUTXO
+func (tx *txBTC) LockTimeUInt32() uint32 { return tx.LockTime }
+
+// TxID implement TxUTXO
+func (tx *txBTC) TxID() types.Bytes {
+	h := tx.tx().TxHash()
+	return bytesReverse(h[:])
+}
+
+// WTxID implement TxUTXO
+func (tx *txBTC) WTxID() types.Bytes {
+	h := tx.tx().WitnessHash()
+	return bytesReverse(h[:])
+}
+
 // Serialize implement Serializer
 func (tx *txBTC) Serialize() ([]byte, error) {
 	r := bytes.NewBuffer(make([]byte, 0, 1024))
@@ -10,6 +10,15 @@ func New{{ .Values.short }}() (Tx, error) {
 	return &tx{{ .Values.short }}{txBTC: b.(*txBTC)}, nil
 }
 
+// Parse{{ .Values.short }} parses a transaction for {{ .Values.name }}
+func Parse{{ .Values.short }}(b []byte) (Tx, error) {
+	r, err := ParseBTC(b)
+	if err != nil {
+		return nil, err
+	}
+	return &tx{{ .Values.short }}{txBTC: r.(*txBTC)}, nil
+}
+
 // Crypto implement Tx
 func (tx *tx{{ .Values.short }}) Crypto() *cryptos.Crypto { return cryptos.Cryptos["{{ .Values.name }}"] }
 
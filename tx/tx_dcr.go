@@ -11,6 +11,7 @@ import (
 	"github.com/transmutate-io/atomicswap/cryptos"
 	"github.com/transmutate-io/atomicswap/key"
 	"github.com/transmutate-io/atomicswap/script"
+	"github.com/transmutate-io/cryptocore/types"
 )
 
 // tx represents a transaction
@@ -19,6 +20,15 @@ type txDCR wire.MsgTx
 // NewDCR creates a new transaction for decred
 func NewDCR() (Tx, error) { return (*txDCR)(wire.NewMsgTx()), nil }
 
+// ParseDCR parses a transaction for decred
+func ParseDCR(b []byte) (Tx, error) {
+	r := &wire.MsgTx{}
+	if err := r.FromBytes(b); err != nil {
+		return nil, err
+	}
+	return (*txDCR)(r), nil
+}
+
 func (tx *txDCR) tx() *wire.MsgTx { return (*wire.MsgTx)(tx) }
 
 // AddOutput implement TxUTXO
@@ -53,6 +63,28 @@ func (tx *txDCR) SetInputSequenceNumber(idx int, seq uint32) {
 	tx.TxIn[idx].Sequence = seq
 }
 
+// SetInputAmount implement TxUTXO
+func (tx *txDCR) SetInputAmount(idx int, amount uint64) { tx.TxIn[idx].ValueIn = int64(amount) }
+
+// InputAmount implement TxUTXO
+func (tx *txDCR) InputAmount(idx int) uint64 { return uint64(tx.TxIn[idx].ValueIn) }
+
+// verifyFlagsDCR are the script flags used to verify decred inputs
+const verifyFlagsDCR = txscript.ScriptVerifyCheckLockTimeVerify |
+	txscript.ScriptVerifyCheckSequenceVerify |
+	txscript.ScriptVerifyCleanStack |
+	txscript.ScriptVerifySigPushOnly |
+	txscript.ScriptVerifySHA256
+
+// VerifyInput implement TxUTXO. The amount is carried by the input
+func (tx *txDCR) VerifyInput(idx int, pkScript []byte, _ uint64) error {
+	vm, err := txscript.NewEngine(pkScript, tx.tx(), idx, verifyFlagsDCR, 0, nil)
+	if err != nil {
+		return err
+	}
+	return runEngine(vm)
+}
+
 // InputSequenceNumber implement TxUTXO
 func (tx *txDCR) InputSequenceNumber(idx int) uint32 { return tx.tx().TxIn[idx].Sequence }
 
@@ -99,6 +131,44 @@ func (tx *txDCR) SignP2PKHInput(idx int, hashType uint32, privKey key.Private) e
 	return nil
 }
 
+// Inputs implement TxUTXO
+func (tx *txDCR) Inputs() []*Input {
+	r := make([]*Input, 0, len(tx.TxIn))
+	for _, i := range tx.TxIn {
+		r = append(r, &Input{
+			TxID:            bytesReverse(i.PreviousOutPoint.Hash[:]),
+			N:               i.PreviousOutPoint.Index,
+			SignatureScript: i.SignatureScript,
+			Sequence:        i.Sequence,
+		})
+	}
+	return r
+}
+
+// Outputs implement TxUTXO
+func (tx *txDCR) Outputs() []*Output {
+	r := make([]*Output, 0, len(tx.TxOut))
+	for _, i := range tx.TxOut {
+		r = append(r, &Output{Value: uint64(i.Value), Script: i.PkScript})
+	}
+	return r
+}
+
+// LockTimeUInt32 implement TxUTXO
+func (tx *txDCR) LockTimeUInt32() uint32 { return tx.LockTime }
+
+// TxID implement TxUTXO
+func (tx *txDCR) TxID() types.Bytes {
+	h := tx.tx().TxHash()
+	return bytesReverse(h[:])
+}
+
+// WTxID implement TxUTXO
+func (tx *txDCR) WTxID() types.Bytes {
+	h := tx.tx().TxHashFull()
+	return bytesReverse(h[:])
+}
+
 // Serialize implement Serializer
 func (tx *txDCR) Serialize() ([]byte, error) {
 	r := bytes.NewBuffer(make([]byte, 0, 1024))
@@ -118,7 +188,7 @@ func (tx *txDCR) TxUTXO() (TxUTXO, bool) { return tx, true }
 func (tx *txDCR) TxStateBased() (TxStateBased, bool) { return nil, false }
 
 // Crypto implement Tx
-func (tx *txDCR) Crypto() *cryptos.Crypto { return cryptos.Cryptos["bitcoin"] }
+func (tx *txDCR) Crypto() *cryptos.Crypto { return cryptos.Cryptos["decred"] }
 
 // Copy implement Tx
 func (tx *txDCR) Copy() Tx { return (*txDCR)(tx.tx().Copy()) }
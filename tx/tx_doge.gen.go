@@ -10,6 +10,15 @@ func NewDOGE() (Tx, error) {
 	return &txDOGE{txBTC: b.(*txBTC)}, nil
 }
 
+// ParseDOGE parses a transaction for dogecoin
+func ParseDOGE(b []byte) (Tx, error) {
+	r, err := ParseBTC(b)
+	if err != nil {
+		return nil, err
+	}
+	return &txDOGE{txBTC: r.(*txBTC)}, nil
+}
+
 // Crypto implement Tx
 func (tx *txDOGE) Crypto() *cryptos.Crypto { return cryptos.Cryptos["dogecoin"] }
 
@@ -10,6 +10,15 @@ func NewLTC() (Tx, error) {
 	return &txLTC{txBTC: b.(*txBTC)}, nil
 }
 
+// ParseLTC parses a transaction for litecoin
+func ParseLTC(b []byte) (Tx, error) {
+	r, err := ParseBTC(b)
+	if err != nil {
+		return nil, err
+	}
+	return &txLTC{txBTC: r.(*txBTC)}, nil
+}
+
 // Crypto implement Tx
 func (tx *txLTC) Crypto() *cryptos.Crypto { return cryptos.Cryptos["litecoin"] }
 
@@ -81,6 +81,59 @@ func TestMarshalUnmarshal(t *testing.T) {
 	}
 }
 
+func testParse(t *testing.T, tc *testutil.Crypto) {
+	c := testutil.MustParseCrypto(t, tc.Name)
+	tx, err := New(c)
+	require.NoError(t, err, "can't create a new tx")
+	txUTXO, ok := tx.TxUTXO()
+	require.True(t, ok, "expecting an utxo tx")
+	txID := testutil.MustReadRandom(t, 32)
+	require.NoError(t, txUTXO.AddInput(txID, 1, []byte{0x52, 0x87}, 100000), "can't add input")
+	txUTXO.AddOutput(90000, []byte{0x54, 0x52, 0x93, 0x56, 0x87})
+	txUTXO.SetInputSequenceNumber(0, 0xfffffffe)
+	txUTXO.SetLockTimeUInt32(500000000)
+	b, err := tx.Serialize()
+	require.NoError(t, err, "can't serialize")
+	// parse
+	ptx, err := Parse(c, b)
+	require.NoError(t, err, "can't parse")
+	require.Equal(t, c, ptx.Crypto(), "crypto mismatch")
+	pb, err := ptx.Serialize()
+	require.NoError(t, err, "can't serialize")
+	require.Equal(t, b, pb, "transactions mismatch")
+	// accessors
+	pUTXO, ok := ptx.TxUTXO()
+	require.True(t, ok, "expecting an utxo tx")
+	require.Equal(t, txUTXO.TxID(), pUTXO.TxID(), "txid mismatch")
+	require.Equal(t, txUTXO.WTxID(), pUTXO.WTxID(), "wtxid mismatch")
+	require.Equal(t, uint32(500000000), pUTXO.LockTimeUInt32(), "locktime mismatch")
+	ins := pUTXO.Inputs()
+	require.Len(t, ins, 1, "expecting one input")
+	require.Equal(t, types.Bytes(txID), ins[0].TxID, "input txid mismatch")
+	require.Equal(t, uint32(1), ins[0].N, "input index mismatch")
+	require.Equal(t, uint32(0xfffffffe), ins[0].Sequence, "sequence mismatch")
+	outs := pUTXO.Outputs()
+	require.Len(t, outs, 1, "expecting one output")
+	require.Equal(t, uint64(90000), outs[0].Value, "output value mismatch")
+	// fee
+	fee, err := Fee(pUTXO, []uint64{100000})
+	require.NoError(t, err, "can't compute fee")
+	require.Equal(t, uint64(10000), fee, "fee mismatch")
+	_, err = Fee(pUTXO, []uint64{80000})
+	require.Equal(t, ErrNegativeFee, err, "expecting negative fee")
+	_, err = Fee(pUTXO, nil)
+	require.Equal(t, ErrInputAmountsMismatch, err, "expecting input amounts mismatch")
+	// garbage
+	_, err = Parse(c, b[:len(b)-2])
+	require.Error(t, err, "expecting a parse error")
+}
+
+func TestParse(t *testing.T) {
+	for _, i := range testutil.Cryptos {
+		t.Run(i.Name, func(t *testing.T) { testParse(t, i) })
+	}
+}
+
 func testP2PKH(t *testing.T, tc *testutil.Crypto) {
 	// parse crypto
 	c := testutil.MustParseCrypto(t, tc.Name)
@@ -142,6 +195,7 @@ func testP2PKH(t *testing.T, tc *testutil.Crypto) {
 	txid, err := testutil.SendRawTransaction(t, tc, b, 1)
 	require.NoError(t, err, "can't send raw transaction")
 	t.Logf("txid: %s\n", hex.EncodeToString(txid))
+	require.Equal(t, types.Bytes(txid), txUTXO.TxID(), "txid mismatch")
 }
 
 func TestP2PKH(t *testing.T) {
@@ -213,3 +267,39 @@ func TestP2SH(t *testing.T) {
 		t.Run(i.Name, func(t *testing.T) { testP2SH(t, i) })
 	}
 }
+
+func testVerify(t *testing.T, tc *testutil.Crypto) {
+	c := testutil.MustParseCrypto(t, tc.Name)
+	gen := testutil.MustNewGenerator(t, c)
+	k := testutil.MustNewPrivateKey(t, c)
+	pkScript := gen.P2PKHHash(k.Public().KeyData())
+	tx, err := New(c)
+	require.NoError(t, err, "can't create new transaction")
+	txUTXO, ok := tx.TxUTXO()
+	require.True(t, ok, "expecting an utxo tx")
+	prevOuts := make([]*Output, 0, 2)
+	for i := 0; i < 2; i++ {
+		amt := uint64(100000000 + i)
+		err = txUTXO.AddInput(testutil.MustReadRandom(t, 32), uint32(i), pkScript, amt)
+		require.NoError(t, err, "can't add input")
+		prevOuts = append(prevOuts, &Output{Value: amt, Script: pkScript})
+	}
+	txUTXO.AddOutput(199000000, pkScript)
+	for i := range prevOuts {
+		require.NoError(t, txUTXO.SignP2PKHInput(i, 1, k), "can't sign input")
+	}
+	require.NoError(t, Verify(tx, prevOuts), "can't verify")
+	require.Equal(t, ErrOutputsMismatch, Verify(tx, prevOuts[:1]), "expecting outputs mismatch")
+	// sign the second input with another key
+	require.NoError(t, txUTXO.SignP2PKHInput(1, 1, testutil.MustNewPrivateKey(t, c)), "can't sign input")
+	err = Verify(tx, prevOuts)
+	require.IsType(t, &ScriptError{}, err, "expecting a script error")
+	require.Equal(t, 1, err.(*ScriptError).Input, "input mismatch")
+	require.Contains(t, err.(*ScriptError).Opcode, "OP_EQUALVERIFY", "opcode mismatch")
+}
+
+func TestVerify(t *testing.T) {
+	for _, i := range testutil.Cryptos {
+		t.Run(i.Name, func(t *testing.T) { testVerify(t, i) })
+	}
+}
@@ -0,0 +1,108 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/transmutate-io/atomicswap/cryptos"
+)
+
+type (
+	// Verifier verifies the inputs of the transactions of a crypto
+	Verifier interface {
+		// VerifyInput executes the signature script of an input against the
+		// script of the spent output
+		VerifyInput(t TxUTXO, idx int, pkScript []byte, amount uint64) error
+	}
+
+	// VerifierFunc is a function implementing Verifier
+	VerifierFunc func(t TxUTXO, idx int, pkScript []byte, amount uint64) error
+
+	// ScriptError is returned when the execution of an input script fails
+	ScriptError struct {
+		// Input is the index of the failed input
+		Input int
+		// Opcode is the disassembled opcode where the execution failed, in
+		// the form script:offset: opcode. The scripts are numbered from the
+		// signature script. Empty if the scripts finished with a false result
+		Opcode string
+		// Err is the engine error
+		Err error
+	}
+
+	// engine represents a script engine executed step by step
+	engine interface {
+		DisasmPC() (string, error)
+		Step() (bool, error)
+		CheckErrorCondition(finalScript bool) error
+	}
+)
+
+// VerifyInput implement Verifier
+func (f VerifierFunc) VerifyInput(t TxUTXO, idx int, pkScript []byte, amount uint64) error {
+	return f(t, idx, pkScript, amount)
+}
+
+func (e *ScriptError) Error() string {
+	if e.Opcode == "" {
+		return fmt.Sprintf("input %d: %s", e.Input, e.Err)
+	}
+	return fmt.Sprintf("input %d: script failed at %s (%s)", e.Input, e.Opcode, e.Err)
+}
+
+// ErrOutputsMismatch is returned when the number of spent outputs doesn't
+// match the number of inputs
+var ErrOutputsMismatch = errors.New("spent outputs mismatch")
+
+// verifiers holds the verifiers replacing the transaction script engine
+var verifiers = map[string]Verifier{}
+
+// RegisterVerifier sets the verifier for the inputs of a crypto, replacing
+// the transaction script engine
+func RegisterVerifier(c *cryptos.Crypto, v Verifier) { verifiers[c.Name] = v }
+
+// Verify executes the signature script of every input against the script of
+// the spent output. The failed input is returned as a *ScriptError
+func Verify(t Tx, prevOuts []*Output) error {
+	txUTXO, ok := t.TxUTXO()
+	if !ok {
+		return ErrNotUTXO
+	}
+	if len(prevOuts) != len(txUTXO.Inputs()) {
+		return ErrOutputsMismatch
+	}
+	verify := txUTXO.VerifyInput
+	if v, ok := verifiers[t.Crypto().Name]; ok {
+		verify = func(idx int, pkScript []byte, amount uint64) error {
+			return v.VerifyInput(txUTXO, idx, pkScript, amount)
+		}
+	}
+	for i, out := range prevOuts {
+		if err := verify(i, out.Script, out.Value); err != nil {
+			if se, ok := err.(*ScriptError); ok {
+				se.Input = i
+				return se
+			}
+			return &ScriptError{Input: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// runEngine executes the scripts keeping the opcode where the execution fails
+func runEngine(vm engine) error {
+	for {
+		pc, _ := vm.DisasmPC()
+		done, err := vm.Step()
+		if err != nil {
+			return &ScriptError{Opcode: pc, Err: err}
+		}
+		if done {
+			break
+		}
+	}
+	if err := vm.CheckErrorCondition(true); err != nil {
+		return &ScriptError{Err: err}
+	}
+	return nil
+}